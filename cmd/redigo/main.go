@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/rdb"
 	"github.com/pranavbrkr/redigo/internal/server"
 	"github.com/pranavbrkr/redigo/internal/store"
 )
@@ -18,58 +19,65 @@ import (
 func main() {
 	port := flag.Int("port", 6379, "TCP port to listen on")
 	aofEnabled := flag.Bool("aof-enabled", false, "Enable append-only file persistence")
-	aofPath := flag.String("aof-path", "data/appendonly.aof", "Path to AOF file")
+	aofPath := flag.String("aof-path", "data/appendonly.aof", "Path the AOF parts are named after; they are kept in appendonlydir beside it")
+	aofPreamble := flag.Bool("aof-use-rdb-preamble", true, "Write the compacted state of AOF rewrites as a binary snapshot")
 	aofFsync := flag.String("aof-fsync", "everysec", "AOF fsync policy: always|everysec|never")
+	aofChecksum := flag.Bool("aof-checksum", false, "Frame every AOF record with a CRC32C checked on load")
+	aofLoadTruncated := flag.Bool("aof-load-truncated", true, "Cut off a torn AOF tail on load instead of refusing to start")
+	aofLoadCorrupt := flag.String("aof-load-corrupt", "stop", "What loading does about a damaged AOF record: stop|skip|truncate")
+	autoRewritePerc := flag.Int64("auto-aof-rewrite-percentage", 100, "Rewrite the AOF once it grew by this percentage since the last rewrite (0 = never)")
+	autoRewriteMinSize := flag.String("auto-aof-rewrite-min-size", "64mb", "Size below which the AOF is never rewritten automatically")
+	rdbPath := flag.String("rdb-path", "data/dump.rdb", "Path to the snapshot file written by SAVE and BGSAVE")
+	save := flag.String("save", "3600 1 300 100 60 10000", "Snapshot schedule as <seconds> <changes> pairs (\"\" = never)")
+	databases := flag.Int("databases", store.DefaultDatabases, "Number of databases (SELECT 0..n-1)")
+	maxmemory := flag.String("maxmemory", "0", "Memory limit, e.g. 100mb or 1gb (0 = no limit)")
+	maxmemoryPolicy := flag.String("maxmemory-policy", "noeviction", "Eviction policy once maxmemory is reached: "+
+		"noeviction|allkeys-lru|allkeys-lfu|allkeys-random|volatile-lru|volatile-lfu|volatile-random|volatile-ttl")
 
 	flag.Parse()
 	policy := aof.ParseFsyncPolicy(*aofFsync)
 
 	addr := ":" + strconv.Itoa(*port)
 
-	st := store.New()
+	if *databases < 1 {
+		log.Fatalf("invalid -databases %d: must be at least 1", *databases)
+	}
+	dbs := store.NewDatabases(*databases)
+
+	maxBytes, err := server.ParseMemory(*maxmemory)
+	if err != nil {
+		log.Fatalf("invalid -maxmemory %q: %v", *maxmemory, err)
+	}
+	evictionPolicy, ok := store.ParseEvictionPolicy(*maxmemoryPolicy)
+	if !ok {
+		log.Fatalf("invalid -maxmemory-policy %q", *maxmemoryPolicy)
+	}
+	rewriteMinSize, err := server.ParseMemory(*autoRewriteMinSize)
+	if err != nil {
+		log.Fatalf("invalid -auto-aof-rewrite-min-size %q: %v", *autoRewriteMinSize, err)
+	}
+	if *autoRewritePerc < 0 {
+		log.Fatalf("invalid -auto-aof-rewrite-percentage %d: must not be negative", *autoRewritePerc)
+	}
+	corruptPolicy, ok := aof.ParseCorruptPolicy(*aofLoadCorrupt)
+	if !ok {
+		log.Fatalf("invalid -aof-load-corrupt %q", *aofLoadCorrupt)
+	}
+	saveParams, err := server.ParseSaveParams(*save)
+	if err != nil {
+		log.Fatalf("invalid -save %q: %v", *save, err)
+	}
 
 	var aw aof.Writer = aof.NewNoop()
 	if *aofEnabled {
-		// Replay existing AOF into the store
-		err := aof.Replay(*aofPath, func(cmd string, args []string) error {
-			switch cmd {
-			case "SET":
-				if len(args) != 2 {
-					return nil
-				}
-				st.Set(args[0], []byte(args[1]))
-
-			case "DEL":
-				for _, k := range args {
-					st.Del(k)
-				}
-
-			case "EXPIRE":
-				// Backwards compat with older AOFs (relative expiry)
-				if len(args) != 2 {
-					return nil
-				}
-				sec, err := strconv.ParseInt(args[1], 10, 64)
-				if err != nil {
-					return nil
-				}
-				st.Expire(args[0], sec)
-
-			case "EXPIREAT":
-				if len(args) != 2 {
-					return nil
-				}
-				ts, err := strconv.ParseInt(args[1], 10, 64)
-				if err != nil {
-					return nil
-				}
-				st.ExpireAt(args[0], ts)
-
-			default:
-				// Ignore unknown entries to keep replay resilient
-			}
-			return nil
-		})
+		// Replay existing AOF into the store through the server's command
+		// table; a snapshot preamble is loaded directly
+		loader := server.NewDatabasesLoader(dbs)
+		err = aof.ReplayWithOptions(*aofPath, aof.LoadOptions{
+			Restore:         loader.Restore,
+			RefuseTruncated: !*aofLoadTruncated,
+			Corrupt:         corruptPolicy,
+		}, loader.Apply)
 		if err != nil {
 			log.Fatalf("open replay failed: %v", err)
 		}
@@ -79,14 +87,36 @@ func main() {
 		if err != nil {
 			log.Fatalf("open aof: %v", err)
 		}
+		faof.SetRDBPreamble(*aofPreamble)
+		faof.SetChecksum(*aofChecksum)
 		aw = faof
+	} else {
+		// Without an AOF the snapshot is the only copy of the data
+		loaded := 0
+		err = rdb.Load(*rdbPath, func(e store.SnapshotEntry) error {
+			ok, err := dbs.Restore(e)
+			if ok {
+				loaded++
+			}
+			return err
+		})
+		if err != nil {
+			log.Fatalf("load snapshot: %v", err)
+		}
+		if loaded > 0 {
+			log.Printf("loaded %d keys from %s", loaded, *rdbPath)
+		}
 	}
 
-	s, bound, err := server.Start(addr, st, aw, policy)
+	s, bound, err := server.StartDatabases(addr, dbs, aw, policy)
 	if err != nil {
 		log.Fatalf("failed to start server on %s: %v", addr, err)
 	}
 
+	s.SetMaxMemory(maxBytes, evictionPolicy)
+	s.SetSnapshot(*rdbPath, saveParams)
+	s.SetAutoRewrite(*autoRewritePerc, rewriteMinSize)
+
 	log.Printf("redigo listening on %s", bound)
 
 	sigCh := make(chan os.Signal, 1)
@@ -8,41 +8,196 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
-	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/rdb"
 	"github.com/pranavbrkr/redigo/internal/store"
 )
 
+// FileAOF is an append-only file kept as a directory of parts (see
+// manifest.go). Appends go to the newest incremental file.
 type FileAOF struct {
 	mu     sync.Mutex
-	f      *os.File
+	f      *os.File // newest incremental file
 	w      *bufio.Writer
-	path   string
+	dir    string // directory holding the parts
+	name   string // base name the parts are named after
+	m      manifest
+	rw     *Rewrite // rewrite in progress, if any
 	closed bool
+
+	// idleIncr is set while nothing has been appended to the incremental
+	// file the last rewrite started. If that rewrite fails, the next one
+	// starts from the same file instead of adding yet another.
+	idleIncr bool
+
+	// size is the number of bytes written to the parts so far; baseSize is
+	// what it was after the last rewrite or at Open. See Size.
+	size     int64
+	baseSize int64
+
+	// rdbPreamble makes rewrites store the snapshot in the binary rdb
+	// format instead of commands; see SetRDBPreamble.
+	rdbPreamble atomic.Bool
+
+	// checksum frames records with a CRC32C; see SetChecksum.
+	checksum atomic.Bool
+	enc      recordEncoder
 }
 
+// Open opens the AOF whose parts live in DirName next to path, creating it
+// if needed. A single-file AOF from before multi-part found at path becomes
+// the base of the new one.
 func Open(path string) (*FileAOF, error) {
-	// ensure parent directory exists
-	dir := filepath.Dir(path)
-	if dir != "." && dir != "" {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	dir, name := partsDir(path)
+	if strings.ContainsAny(name, " \t\r\n") {
+		return nil, fmt.Errorf("aof file name %q must not contain spaces", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+
+	m, ok, err := readManifest(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	legacy := false
+	if !ok {
+		if m, legacy, err = adoptLegacy(path, dir, name); err != nil {
+			return nil, err
+		}
+	}
+
+	a := &FileAOF{dir: dir, name: name, m: m}
+	if len(m.incrs) == 0 {
+		// A fresh AOF, or one just adopted: start its first incremental
+		// file, which also writes the first manifest.
+		if err := a.openNextIncrLocked(); err != nil {
+			return nil, err
 		}
+	} else {
+		last := m.incrs[len(m.incrs)-1]
+		f, err := os.OpenFile(filepath.Join(dir, last.file), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open aof %s: %w", last.file, err)
+		}
+		a.f, a.w = f, a.newWriter(f)
+	}
+	if legacy {
+		// The manifest now lists the adopted copy.
+		_ = os.Remove(path)
 	}
+	removeUnlisted(dir, name, a.m)
+	a.size = a.partsSizeLocked()
+	a.baseSize = a.size
+	return a, nil
+}
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+// adoptLegacy links a single-file AOF at path into dir as the base of a new
+// manifest, without writing the manifest yet. Until that is written the
+// file at path stays the AOF, so a crash in between changes nothing.
+func adoptLegacy(path, dir, name string) (m manifest, ok bool, err error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("open aof %s: %w", path, err)
+		if errors.Is(err, os.ErrNotExist) {
+			return manifest{}, false, nil
+		}
+		return manifest{}, false, fmt.Errorf("open aof %s: %w", path, err)
+	}
+	isRDB := rdb.Detect(bufio.NewReader(f))
+	_ = f.Close()
+
+	base := part{file: baseName(name, 1, isRDB), seq: 1, typ: partBase}
+	dst := filepath.Join(dir, base.file)
+	_ = os.Remove(dst)
+	if err := os.Link(path, dst); err != nil {
+		return manifest{}, false, fmt.Errorf("adopt aof %s: %w", path, err)
+	}
+	return manifest{base: &base}, true, nil
+}
+
+// openNextIncrLocked starts a new incremental file, records it in the
+// manifest and moves appends to it. On failure appends keep going to the
+// current file. Caller must hold a.mu.
+func (a *FileAOF) openNextIncrLocked() error {
+	seq := a.m.nextSeq()
+	p := part{file: incrName(a.name, seq), seq: seq, typ: partIncr}
+	path := filepath.Join(a.dir, p.file)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open aof %s: %w", p.file, err)
+	}
+	m := a.m
+	m.incrs = append(append([]part(nil), a.m.incrs...), p)
+	if err := writeManifest(a.dir, a.name, m); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+
+	if a.f != nil {
+		// Best effort: the file is complete as far as the manifest goes.
+		_ = a.w.Flush()
+		_ = a.f.Sync()
+		_ = a.f.Close()
+	}
+	a.m = m
+	a.f, a.w = f, a.newWriter(f)
+	return nil
+}
+
+// countingWriter adds the bytes written through it to *n.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// newWriter returns the buffered writer for appends to f, which counts what
+// reaches the file in a.size. Writes happen under a.mu.
+func (a *FileAOF) newWriter(f *os.File) *bufio.Writer {
+	return bufio.NewWriterSize(countingWriter{w: f, n: &a.size}, 64*1024)
+}
+
+// partsSizeLocked returns the total size of the files the manifest lists.
+// Caller must hold a.mu.
+func (a *FileAOF) partsSizeLocked() int64 {
+	var n int64
+	for _, p := range a.m.parts() {
+		if fi, err := os.Stat(filepath.Join(a.dir, p.file)); err == nil {
+			n += fi.Size()
+		}
 	}
+	return n
+}
 
-	return &FileAOF{
-		f:    f,
-		w:    bufio.NewWriterSize(f, 64*1024),
-		path: path,
-	}, nil
+// Size returns the bytes written to the AOF so far, and what that was
+// after the last rewrite (or when it was opened), which automatic rewrites
+// measure growth against. Appends still buffered are not counted.
+func (a *FileAOF) Size() (current, base int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size, a.baseSize
 }
 
+// SetRDBPreamble chooses how later rewrites store the compacted state: as a
+// binary snapshot followed by the usual command tail (on), which loads much
+// faster, or as commands only (off, the default). Replay reads both.
+func (a *FileAOF) SetRDBPreamble(on bool) { a.rdbPreamble.Store(on) }
+
+// SetChecksum chooses whether records written from now on, by appends and
+// by rewrites without a preamble, carry a CRC32C that replay verifies (see
+// record.go). Off by default; replay reads both kinds.
+func (a *FileAOF) SetChecksum(on bool) { a.checksum.Store(on) }
+
 func (a *FileAOF) Append(cmd string, args []string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -52,19 +207,9 @@ func (a *FileAOF) Append(cmd string, args []string) error {
 	}
 
 	// Encode as RESP Array of Bulk strings: [CMD, arg1, arg2, ...]
-	if err := resp.WriteArrayHeader(a.w, 1+len(args)); err != nil {
-		return err
-	}
-	if err := resp.WriteBulkString(a.w, []byte(cmd)); err != nil {
-		return err
-	}
-	for _, s := range args {
-		if err := resp.WriteBulkString(a.w, []byte(s)); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	a.idleIncr = false
+	a.enc.checksum = a.checksum.Load()
+	return a.enc.write(a.w, cmd, args)
 }
 
 func (a *FileAOF) Sync() error {
@@ -94,201 +239,303 @@ func (a *FileAOF) Close() error {
 	return a.f.Close()
 }
 
-// Replay reads AOF from disk and calls apply(cmd,args) for each entry.
-// Crash-safe: ignores a truncated final entry (common after crash).
-func Replay(path string, apply func(cmd string, args []string) error) error {
-	f, err := os.Open(path)
+// Rewrite compacts the AOF to a base holding snapshot, which must reflect
+// every append made so far. It is synchronous; the server uses
+// StartRewrite and FinishRewrite to write the base in the background.
+func (a *FileAOF) Rewrite(snapshot []store.SnapshotEntry) error {
+	rw, err := a.StartRewrite()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return fmt.Errorf("open aof for replay %s: %w", path, err)
+		return err
 	}
-	defer f.Close()
-
-	r := bufio.NewReaderSize(f, 64*1024)
-
-	for {
-		v, err := resp.Decode(r)
-		if err != nil {
-			// clean EOF
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-
-			// tolerate truncated tail:
-			// if decode errored but there are no more bytes available, ignore tail and succeed
-			if _, peekErr := r.Peek(1); errors.Is(peekErr, io.EOF) {
-				return nil
-			}
-
-			// otherwise: corruption in the middle or malformed entry
-			return fmt.Errorf("decode aof: %w", err)
-		}
-
-		cmd, args, ok := decodeAOFCommand(v)
-		if !ok {
-			// same truncate-tail tolerance: if this happens and we are at EOF, ignore.
-			if _, peekErr := r.Peek(1); errors.Is(peekErr, io.EOF) {
-				return nil
-			}
-			return fmt.Errorf("invalid aof entry (expected array of bulk strings)")
-		}
-
-		if err := apply(cmd, args); err != nil {
-			return fmt.Errorf("apply %s: %w", cmd, err)
-		}
+	if err := rw.WriteBase(snapshot); err != nil {
+		a.AbortRewrite(rw)
+		return err
 	}
+	return a.FinishRewrite(rw)
 }
 
-func decodeAOFCommand(v resp.Value) (string, []string, bool) {
-	if v.Type != resp.Array || len(v.Array) == 0 {
-		return "", nil, false
-	}
+// Rewrite is a rewrite in progress: the new base is being written while
+// appends go to the incremental file StartRewrite opened.
+type Rewrite struct {
+	incrSeq  int64  // first incremental file that follows the new base
+	tmpPath  string // new base, until FinishRewrite installs it
+	rdb      bool   // base is a binary snapshot rather than commands
+	checksum bool   // frame the records of a command base
+}
 
-	first := v.Array[0]
-	if first.Type != resp.BulkString || first.Bulk == nil {
-		return "", nil, false
-	}
-	cmd := string(first.Bulk)
+// StartRewrite moves appends to a new incremental file, recorded in the
+// manifest right away so it is replayed whatever becomes of the rewrite.
+// After a failed rewrite whose file is still empty it reuses that one, so
+// repeated failures do not keep adding files.
+// The caller snapshots the data at this exact point, with no write in
+// flight, passes it to WriteBase, then ends with FinishRewrite or
+// AbortRewrite.
+func (a *FileAOF) StartRewrite() (*Rewrite, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	args := make([]string, 0, len(v.Array)-1)
-	for i := 1; i < len(v.Array); i++ {
-		el := v.Array[i]
-		if el.Type != resp.BulkString || el.Bulk == nil {
-			return "", nil, false
+	if a.closed {
+		return nil, fmt.Errorf("aof closed")
+	}
+	if a.rw != nil {
+		return nil, fmt.Errorf("aof rewrite already in progress")
+	}
+	if !a.idleIncr {
+		if err := a.openNextIncrLocked(); err != nil {
+			return nil, fmt.Errorf("rewrite: %w", err)
 		}
-		args = append(args, string(el.Bulk))
 	}
-
-	return cmd, args, true
-}
-
-// Rewrite compacts the AOF to a minimal set of commands representing current state.
-// It is synchronous and blocks concurrent appends.
-func (a *FileAOF) Rewrite(snapshot []store.SnapshotEntry) error {
-	tmpPath, err := a.WriteRewriteTemp(snapshot)
-	if err != nil {
-		return err
+	a.idleIncr = true
+	a.rw = &Rewrite{
+		incrSeq:  a.m.incrs[len(a.m.incrs)-1].seq,
+		tmpPath:  filepath.Join(a.dir, rewriteTempName(a.name)),
+		rdb:      a.rdbPreamble.Load(),
+		checksum: a.checksum.Load(),
 	}
-	return a.InstallRewrite(tmpPath, nil)
+	return a.rw, nil
 }
 
-func (a *FileAOF) WriteRewriteTemp(snapshot []store.SnapshotEntry) (string, error) {
-	dir := filepath.Dir(a.path)
-	tmpPath := filepath.Join(dir, filepath.Base(a.path)+".rewrite.tmp")
-
-	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+// WriteBase writes snapshot to the temp file that becomes the new base. It
+// does not hold the AOF's lock, so appends go on meanwhile.
+func (rw *Rewrite) WriteBase(snapshot []store.SnapshotEntry) error {
+	tmp, err := os.OpenFile(rw.tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
-		return "", fmt.Errorf("open rewrite temp %s: %w", tmpPath, err)
+		return fmt.Errorf("open rewrite temp %s: %w", rw.tmpPath, err)
 	}
 	defer func() { _ = tmp.Close() }()
 
 	w := bufio.NewWriterSize(tmp, 64*1024)
 
-	writeCmd := func(cmd string, args ...string) error {
-		if err := resp.WriteArrayHeader(w, 1+len(args)); err != nil {
-			return err
+	if rw.rdb {
+		if err := rdb.Encode(w, snapshot); err != nil {
+			return fmt.Errorf("rewrite write preamble: %w", err)
 		}
-		if err := resp.WriteBulkString(w, []byte(cmd)); err != nil {
-			return err
-		}
-		for _, s := range args {
-			if err := resp.WriteBulkString(w, []byte(s)); err != nil {
-				return err
-			}
-		}
-		return nil
+		return syncRewriteTemp(tmp, w)
 	}
 
+	enc := recordEncoder{checksum: rw.checksum}
+	writeCmd := func(cmd string, args ...string) error {
+		return enc.write(w, cmd, args)
+	}
+
+	// Replay starts in database 0; entries come grouped by database.
+	db := 0
 	for _, e := range snapshot {
-		// SET key value
-		if err := writeCmd("SET", e.Key, string(e.Value)); err != nil {
-			_ = os.Remove(tmpPath)
-			return "", fmt.Errorf("rewrite write SET: %w", err)
+		if e.DB != db {
+			if err := writeCmd("SELECT", strconv.Itoa(e.DB)); err != nil {
+				return fmt.Errorf("rewrite write SELECT: %w", err)
+			}
+			db = e.DB
+		}
+		if err := writeEntry(writeCmd, e); err != nil {
+			return err
 		}
-		// EXPIREAT key unixSeconds
+		// PEXPIREAT key unixMilliseconds
 		if e.ExpiresAt != nil {
-			if err := writeCmd("EXPIREAT", e.Key, strconv.FormatInt(*e.ExpiresAt, 10)); err != nil {
-				_ = os.Remove(tmpPath)
-				return "", fmt.Errorf("rewrite write EXPIREAT: %w", err)
+			if err := writeCmd("PEXPIREAT", e.Key, strconv.FormatInt(*e.ExpiresAt, 10)); err != nil {
+				return fmt.Errorf("rewrite write PEXPIREAT: %w", err)
 			}
 		}
 	}
 
+	return syncRewriteTemp(tmp, w)
+}
+
+// syncRewriteTemp makes the rewrite temp file durable.
+func syncRewriteTemp(tmp *os.File, w *bufio.Writer) error {
 	if err := w.Flush(); err != nil {
-		_ = os.Remove(tmpPath)
-		return "", fmt.Errorf("rewrite flush: %w", err)
+		return fmt.Errorf("rewrite flush: %w", err)
 	}
 	if err := tmp.Sync(); err != nil {
-		_ = os.Remove(tmpPath)
-		return "", fmt.Errorf("rewrite fsync: %w", err)
+		return fmt.Errorf("rewrite fsync: %w", err)
 	}
-
-	return tmpPath, nil
+	return nil
 }
 
-func (a *FileAOF) InstallRewrite(tmpPath string, tail []Entry) error {
+// FinishRewrite installs the base written by WriteBase: the manifest is
+// replaced by one listing it and the incremental files from the rewrite's
+// on, and only once that is durable are the parts it replaces deleted. If
+// it fails the old manifest stays in place and nothing is lost.
+func (a *FileAOF) FinishRewrite(rw *Rewrite) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.rw != rw {
+		return fmt.Errorf("aof rewrite not in progress")
+	}
+	a.rw = nil
 	if a.closed {
-		_ = os.Remove(tmpPath)
+		_ = os.Remove(rw.tmpPath)
 		return fmt.Errorf("aof closed")
 	}
 
-	// Flush current buffered writes and fsync old AOF best-effort
-	_ = a.w.Flush()
-	_ = a.f.Sync()
+	seq := a.m.nextSeq()
+	base := part{file: baseName(a.name, seq, rw.rdb), seq: seq, typ: partBase}
+	basePath := filepath.Join(a.dir, base.file)
+	if err := os.Rename(rw.tmpPath, basePath); err != nil {
+		_ = os.Remove(rw.tmpPath)
+		return fmt.Errorf("install rewrite: %w", err)
+	}
 
-	// Close old fd (Windows-friendly rename behavior)
-	_ = a.f.Close()
+	next := manifest{base: &base}
+	var old []part
+	if a.m.base != nil {
+		old = append(old, *a.m.base)
+	}
+	for _, p := range a.m.incrs {
+		if p.seq < rw.incrSeq {
+			old = append(old, p)
+		} else {
+			next.incrs = append(next.incrs, p)
+		}
+	}
+	if err := writeManifest(a.dir, a.name, next); err != nil {
+		_ = os.Remove(basePath)
+		return fmt.Errorf("install rewrite: %w", err)
+	}
+	a.m = next
+	a.idleIncr = false
 
-	// Replace old AOF with temp
-	_ = os.Remove(a.path) // ignore error if doesn't exist
-	if err := os.Rename(tmpPath, a.path); err != nil {
-		_ = os.Remove(tmpPath)
-		return fmt.Errorf("install rewrite replace: %w", err)
+	for _, p := range old {
+		_ = os.Remove(filepath.Join(a.dir, p.file))
 	}
+	_ = a.w.Flush()
+	a.size = a.partsSizeLocked()
+	a.baseSize = a.size
+	return syncDir(a.dir)
+}
 
-	// Reopen live AOF for appends
-	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
-	if err != nil {
-		return fmt.Errorf("install rewrite reopen: %w", err)
+// AbortRewrite gives up a rewrite, removing its temp file. The incremental
+// file it started stays part of the AOF.
+func (a *FileAOF) AbortRewrite(rw *Rewrite) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.rw == rw {
+		a.rw = nil
 	}
-	a.f = f
-	a.w = bufio.NewWriterSize(f, 64*1024)
+	_ = os.Remove(rw.tmpPath)
+}
 
-	// Append tail ops (what happened after snapshot)
-	for _, op := range tail {
-		if err := a.appendLocked(op.Cmd, op.Args); err != nil {
-			return fmt.Errorf("install rewrite tail append: %w", err)
+// rewriteItemsPerCmd caps how many elements a single rewritten command
+// carries, so huge collections don't become one giant RESP array.
+const rewriteItemsPerCmd = 64
+
+// writeEntry emits the commands that recreate a single key's value.
+func writeEntry(writeCmd func(cmd string, args ...string) error, e store.SnapshotEntry) error {
+	switch e.Kind {
+	case store.KindHash:
+		args := make([]string, 0, 1+2*rewriteItemsPerCmd)
+		args = append(args, e.Key)
+		for f, v := range e.Hash {
+			args = append(args, f, string(v))
+			if len(args) == 1+2*rewriteItemsPerCmd {
+				if err := writeCmd("HSET", args...); err != nil {
+					return fmt.Errorf("rewrite write HSET: %w", err)
+				}
+				args = args[:1]
+			}
+		}
+		if len(args) > 1 {
+			if err := writeCmd("HSET", args...); err != nil {
+				return fmt.Errorf("rewrite write HSET: %w", err)
+			}
 		}
-	}
 
-	// Make rewritten AOF durable
-	if err := a.w.Flush(); err != nil {
-		return fmt.Errorf("install rewrite flush: %w", err)
-	}
-	if err := a.f.Sync(); err != nil {
-		return fmt.Errorf("install rewrite fsync: %w", err)
+	case store.KindList:
+		for i := 0; i < len(e.List); i += rewriteItemsPerCmd {
+			end := min(i+rewriteItemsPerCmd, len(e.List))
+			args := make([]string, 0, 1+end-i)
+			args = append(args, e.Key)
+			for _, v := range e.List[i:end] {
+				args = append(args, string(v))
+			}
+			if err := writeCmd("RPUSH", args...); err != nil {
+				return fmt.Errorf("rewrite write RPUSH: %w", err)
+			}
+		}
+
+	case store.KindSet:
+		for i := 0; i < len(e.Set); i += rewriteItemsPerCmd {
+			end := min(i+rewriteItemsPerCmd, len(e.Set))
+			args := make([]string, 0, 1+end-i)
+			args = append(args, e.Key)
+			args = append(args, e.Set[i:end]...)
+			if err := writeCmd("SADD", args...); err != nil {
+				return fmt.Errorf("rewrite write SADD: %w", err)
+			}
+		}
+
+	case store.KindZSet:
+		for i := 0; i < len(e.ZSet); i += rewriteItemsPerCmd {
+			end := min(i+rewriteItemsPerCmd, len(e.ZSet))
+			args := make([]string, 0, 1+2*(end-i))
+			args = append(args, e.Key)
+			for _, it := range e.ZSet[i:end] {
+				args = append(args, store.FormatFloat(it.Score), it.Member)
+			}
+			if err := writeCmd("ZADD", args...); err != nil {
+				return fmt.Errorf("rewrite write ZADD: %w", err)
+			}
+		}
+
+	case store.KindStream:
+		if err := writeStream(writeCmd, e.Key, e.Stream); err != nil {
+			return err
+		}
+
+	default:
+		// SET key value
+		if err := writeCmd("SET", e.Key, string(e.Value)); err != nil {
+			return fmt.Errorf("rewrite write SET: %w", err)
+		}
 	}
 	return nil
 }
 
-// appendLocked assumes a.mu is held.
-func (a *FileAOF) appendLocked(cmd string, args []string) error {
-	if a.closed {
-		return fmt.Errorf("aof closed")
+// writeStream emits a stream as its entries, its ID bookkeeping and its
+// consumer groups. Every PEL entry becomes an XCLAIM with FORCE, which
+// restores it even if its message was deleted since.
+func writeStream(writeCmd func(cmd string, args ...string) error, key string, st *store.StreamSnapshot) error {
+	for _, e := range st.Entries {
+		args := append([]string{key, e.ID.String()}, e.Fields...)
+		if err := writeCmd("XADD", args...); err != nil {
+			return fmt.Errorf("rewrite write XADD: %w", err)
+		}
 	}
-
-	_ = resp.WriteArrayHeader(a.w, 1+len(args))
-	if err := resp.WriteBulkString(a.w, []byte(cmd)); err != nil {
-		return err
+	if len(st.Entries) == 0 {
+		// XADD creates the key; MAXLEN 0 drops the entry right away and
+		// XSETID then restores the real last ID.
+		id := st.LastID
+		if id.IsZero() {
+			id = store.StreamID{Seq: 1}
+		}
+		if err := writeCmd("XADD", key, "MAXLEN", "0", id.String(), "x", "y"); err != nil {
+			return fmt.Errorf("rewrite write XADD: %w", err)
+		}
 	}
-	for _, s := range args {
-		if err := resp.WriteBulkString(a.w, []byte(s)); err != nil {
-			return err
+	if err := writeCmd("XSETID", key, st.LastID.String(),
+		"ENTRIESADDED", strconv.FormatUint(st.EntriesAdded, 10),
+		"MAXDELETEDID", st.MaxDeletedID.String()); err != nil {
+		return fmt.Errorf("rewrite write XSETID: %w", err)
+	}
+
+	for _, g := range st.Groups {
+		if err := writeCmd("XGROUP", "CREATE", key, g.Name, g.LastID.String(),
+			"ENTRIESREAD", strconv.FormatInt(g.EntriesRead, 10)); err != nil {
+			return fmt.Errorf("rewrite write XGROUP: %w", err)
+		}
+		for _, c := range g.Consumers {
+			if err := writeCmd("XGROUP", "CREATECONSUMER", key, g.Name, c); err != nil {
+				return fmt.Errorf("rewrite write XGROUP: %w", err)
+			}
+		}
+		for _, p := range g.Pending {
+			if err := writeCmd("XCLAIM", key, g.Name, p.Consumer, "0", p.ID.String(),
+				"TIME", strconv.FormatInt(p.DeliveryTime, 10),
+				"RETRYCOUNT", strconv.FormatInt(p.DeliveryCount, 10), "FORCE", "JUSTID"); err != nil {
+				return fmt.Errorf("rewrite write XCLAIM: %w", err)
+			}
 		}
 	}
 	return nil
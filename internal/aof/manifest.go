@@ -0,0 +1,242 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// An AOF is a directory of parts, as in Redis 7: a base file holding the
+// state at the last rewrite, the incremental files appended since, and a
+// manifest listing them in replay order.
+//
+//	data/appendonlydir/
+//	  appendonly.aof.2.base.rdb
+//	  appendonly.aof.3.incr.aof
+//	  appendonly.aof.4.incr.aof
+//	  appendonly.aof.manifest
+//
+// The manifest is only ever replaced atomically (temp file, fsync, rename,
+// directory fsync), so a crash leaves either the old or the new set of
+// parts. Files of the AOF not listed in it are leftovers of a rewrite and
+// are removed when it is opened.
+
+// DirName is the directory, next to the configured AOF path, holding the
+// parts (Redis' appenddirname).
+const DirName = "appendonlydir"
+
+type partType byte
+
+const (
+	partBase partType = 'b'
+	partIncr partType = 'i'
+)
+
+type part struct {
+	file string // name within the directory
+	seq  int64
+	typ  partType
+}
+
+type manifest struct {
+	base  *part // nil until the first rewrite
+	incrs []part
+}
+
+// parts returns the parts of m in replay order.
+func (m manifest) parts() []part {
+	var out []part
+	if m.base != nil {
+		out = append(out, *m.base)
+	}
+	return append(out, m.incrs...)
+}
+
+// nextSeq returns a sequence number no part of m uses yet.
+func (m manifest) nextSeq() int64 {
+	var n int64
+	for _, p := range m.parts() {
+		n = max(n, p.seq)
+	}
+	return n + 1
+}
+
+func (m manifest) encode() []byte {
+	var b bytes.Buffer
+	for _, p := range m.parts() {
+		fmt.Fprintf(&b, "file %s seq %d type %c\n", p.file, p.seq, p.typ)
+	}
+	return b.Bytes()
+}
+
+func parseManifest(data []byte) (manifest, error) {
+	var m manifest
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 6 || f[0] != "file" || f[2] != "seq" || f[4] != "type" || len(f[5]) != 1 {
+			return manifest{}, fmt.Errorf("aof manifest line %d: malformed", line)
+		}
+		seq, err := strconv.ParseInt(f[3], 10, 64)
+		if err != nil || strings.ContainsAny(f[1], `/\`) {
+			return manifest{}, fmt.Errorf("aof manifest line %d: malformed", line)
+		}
+		p := part{file: f[1], seq: seq, typ: partType(f[5][0])}
+		switch {
+		case p.typ == partBase && m.base == nil && len(m.incrs) == 0:
+			m.base = &p
+		case p.typ == partIncr:
+			m.incrs = append(m.incrs, p)
+		default:
+			return manifest{}, fmt.Errorf("aof manifest line %d: unexpected %s", line, f[1])
+		}
+	}
+	return m, sc.Err()
+}
+
+// partsDir returns the directory and part name prefix of the AOF at path.
+func partsDir(path string) (dir, name string) {
+	return filepath.Join(filepath.Dir(path), DirName), filepath.Base(path)
+}
+
+func manifestName(name string) string { return name + ".manifest" }
+
+func baseName(name string, seq int64, rdbFormat bool) string {
+	ext := "aof"
+	if rdbFormat {
+		ext = "rdb"
+	}
+	return name + "." + strconv.FormatInt(seq, 10) + ".base." + ext
+}
+
+func incrName(name string, seq int64) string {
+	return name + "." + strconv.FormatInt(seq, 10) + ".incr.aof"
+}
+
+func rewriteTempName(name string) string { return name + ".rewrite.tmp" }
+
+func manifestTempName(name string) string { return manifestName(name) + ".tmp" }
+
+// isPartFile reports whether file is named like a part or temp file of
+// name: "<name>.<seq>.base.aof", "<name>.<seq>.base.rdb",
+// "<name>.<seq>.incr.aof" or the temp file of a rewrite or manifest.
+func isPartFile(name, file string) bool {
+	if file == rewriteTempName(name) || file == manifestTempName(name) {
+		return true
+	}
+	rest, ok := strings.CutPrefix(file, name+".")
+	if !ok {
+		return false
+	}
+	seq, kind, ok := strings.Cut(rest, ".")
+	if !ok || seq == "" || strings.Trim(seq, "0123456789") != "" {
+		return false
+	}
+	return kind == "base.aof" || kind == "base.rdb" || kind == "incr.aof"
+}
+
+// readManifest loads the manifest of name in dir; ok is false if there is
+// none.
+func readManifest(dir, name string) (m manifest, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName(name)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return manifest{}, false, nil
+		}
+		return manifest{}, false, fmt.Errorf("read aof manifest: %w", err)
+	}
+	m, err = parseManifest(data)
+	return m, err == nil, err
+}
+
+// writeManifest atomically replaces the manifest of name in dir with m and
+// makes the change durable.
+func writeManifest(dir, name string, m manifest) error {
+	path := filepath.Join(dir, manifestName(name))
+	tmpPath := filepath.Join(dir, manifestTempName(name))
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open aof manifest temp: %w", err)
+	}
+	if _, err := tmp.Write(m.encode()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("write aof manifest: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("fsync aof manifest: %w", err)
+	}
+	_ = tmp.Close()
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("install aof manifest: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes renames and removals in dir durable. Directories cannot be
+// fsynced everywhere (Windows); that is not an error.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("fsync aof dir: %w", err)
+	}
+	return nil
+}
+
+// removeUnlisted deletes the parts of name in dir that m does not list:
+// those replaced by a rewrite that finished just before a crash, and temp
+// files of one that did not finish. Other files, even ones whose names
+// start with name, are left alone.
+func removeUnlisted(dir, name string, m manifest) {
+	keep := map[string]bool{manifestName(name): true}
+	for _, p := range m.parts() {
+		keep[p.file] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() && isPartFile(name, e.Name()) && !keep[e.Name()] {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// Files returns the files of the AOF at path in replay order: the parts its
+// manifest lists or, for an AOF from before multi-part, path itself if it
+// exists.
+func Files(path string) ([]string, error) {
+	dir, name := partsDir(path)
+	m, ok, err := readManifest(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+		return []string{path}, nil
+	}
+	var out []string
+	for _, p := range m.parts() {
+		out = append(out, filepath.Join(dir, p.file))
+	}
+	return out, nil
+}
@@ -0,0 +1,396 @@
+package aof
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// lastPart returns the part of the AOF at path that appends go to.
+func lastPart(t *testing.T, path string) string {
+	t.Helper()
+	files, err := Files(path)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("files of %s: %v, %v", path, files, err)
+	}
+	return files[len(files)-1]
+}
+
+// readParts returns the parts of the AOF at path concatenated.
+func readParts(t *testing.T, path string) []byte {
+	t.Helper()
+	files, err := Files(path)
+	if err != nil {
+		t.Fatalf("files of %s: %v", path, err)
+	}
+	var raw []byte
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read %s: %v", f, err)
+		}
+		raw = append(raw, data...)
+	}
+	return raw
+}
+
+// dirFiles lists the names in the parts directory of the AOF at path.
+func dirFiles(t *testing.T, path string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(path), DirName))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replayedKeys replays the AOF at path and lists the string keys it holds.
+func replayedKeys(t *testing.T, path string) string {
+	t.Helper()
+	st := store.New()
+	if err := Replay(path, applyTo(st)); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return storeKeys(st)
+}
+
+// storeKeys lists the string keys of st as sorted key=value pairs.
+func storeKeys(st *store.Store) string {
+	var keys []string
+	for _, e := range st.Snapshot() {
+		v, _ := st.Get(e.Key)
+		keys = append(keys, e.Key+"="+string(v))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func TestRewrite_ReplacesPartsAndManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	defer aw.Close()
+	if got, want := dirFiles(t, path), []string{"appendonly.aof.1.incr.aof", "appendonly.aof.manifest"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("new aof has %v, want %v", got, want)
+	}
+
+	st := store.New()
+	st.Set("a", []byte("1"))
+	_ = aw.Append("SET", []string{"a", "0"})
+	_ = aw.Append("SET", []string{"a", "1"})
+
+	rw, err := aw.StartRewrite()
+	if err != nil {
+		t.Fatalf("start rewrite: %v", err)
+	}
+	if _, err := aw.StartRewrite(); err == nil {
+		t.Fatal("second rewrite started")
+	}
+	snap := st.Snapshot()
+	// Appends made while the base is written go to the new incr file.
+	_ = aw.Append("SET", []string{"b", "2"})
+	if err := rw.WriteBase(snap); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := aw.FinishRewrite(rw); err != nil {
+		t.Fatalf("finish rewrite: %v", err)
+	}
+	_ = aw.Sync()
+
+	want := []string{"appendonly.aof.2.incr.aof", "appendonly.aof.3.base.aof", "appendonly.aof.manifest"}
+	if got := dirFiles(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after rewrite: %v, want %v", got, want)
+	}
+	manifest, _ := os.ReadFile(filepath.Join(filepath.Dir(path), DirName, "appendonly.aof.manifest"))
+	if got, want := string(manifest), "file appendonly.aof.3.base.aof seq 3 type b\nfile appendonly.aof.2.incr.aof seq 2 type i\n"; got != want {
+		t.Fatalf("manifest:\n%s\nwant:\n%s", got, want)
+	}
+	if got := replayedKeys(t, path); got != "a=1,b=2" {
+		t.Fatalf("replayed %s", got)
+	}
+
+	// A second rewrite with the preamble drops both old parts.
+	st.Set("b", []byte("2"))
+	aw.SetRDBPreamble(true)
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	want = []string{"appendonly.aof.4.incr.aof", "appendonly.aof.5.base.rdb", "appendonly.aof.manifest"}
+	if got := dirFiles(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after second rewrite: %v, want %v", got, want)
+	}
+	if got := replayedKeys(t, path); got != "a=1,b=2" {
+		t.Fatalf("replayed %s", got)
+	}
+}
+
+func TestRewrite_AbortKeepsEverything(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	_ = aw.Append("SET", []string{"a", "1"})
+	rw, err := aw.StartRewrite()
+	if err != nil {
+		t.Fatalf("start rewrite: %v", err)
+	}
+	_ = aw.Append("SET", []string{"b", "2"})
+	if err := rw.WriteBase(nil); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	aw.AbortRewrite(rw)
+	_ = aw.Close()
+
+	want := []string{"appendonly.aof.1.incr.aof", "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}
+	if got := dirFiles(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after abort: %v, want %v", got, want)
+	}
+	if got := replayedKeys(t, path); got != "a=1,b=2" {
+		t.Fatalf("replayed %s", got)
+	}
+
+	// Only the last part may be torn; damage before it is reported.
+	first := filepath.Join(filepath.Dir(path), DirName, "appendonly.aof.1.incr.aof")
+	f, err := os.OpenFile(first, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nx\r\n$5\r\nab"))
+	_ = f.Close()
+	err = Replay(path, applyTo(store.New()))
+	if err == nil || !strings.Contains(err.Error(), "1.incr.aof") {
+		t.Fatalf("torn first part: err = %v", err)
+	}
+}
+
+func TestRewrite_FailedRewritesReuseTheirIncrFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	defer aw.Close()
+	st := store.New()
+	st.Set("a", []byte("1"))
+	_ = aw.Append("SET", []string{"a", "1"})
+
+	failRewrite := func() {
+		t.Helper()
+		rw, err := aw.StartRewrite()
+		if err != nil {
+			t.Fatalf("start rewrite: %v", err)
+		}
+		if err := rw.WriteBase(st.Snapshot()); err == nil {
+			t.Fatal("expected the base write to fail")
+		}
+		aw.AbortRewrite(rw)
+	}
+
+	// A non-empty directory where the base goes fails every attempt.
+	blocker := filepath.Join(filepath.Dir(path), DirName, "appendonly.aof.rewrite.tmp")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		failRewrite()
+	}
+	if files, _ := Files(path); len(files) != 2 {
+		t.Fatalf("after failed rewrites with nothing appended: %v", files)
+	}
+
+	// Once something went to the file, the next rewrite needs a new one.
+	st.Set("b", []byte("2"))
+	_ = aw.Append("SET", []string{"b", "2"})
+	failRewrite()
+	if files, _ := Files(path); len(files) != 3 {
+		t.Fatalf("after a failed rewrite following an append: %v", files)
+	}
+
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if files, _ := Files(path); len(files) != 2 {
+		t.Fatalf("after the rewrite: %v", files)
+	}
+	if got := replayedKeys(t, path); got != "a=1,b=2" {
+		t.Fatalf("replayed %s", got)
+	}
+}
+
+func TestOpen_AdoptsSingleFileAOF(t *testing.T) {
+	for _, preamble := range []bool{false, true} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "appendonly.aof")
+
+		// Build a single-file AOF as older versions wrote it.
+		aw, err := Open(path)
+		if err != nil {
+			t.Fatalf("open aof: %v", err)
+		}
+		st := store.New()
+		st.Set("a", []byte("1"))
+		aw.SetRDBPreamble(preamble)
+		if err := aw.Rewrite(st.Snapshot()); err != nil {
+			t.Fatalf("rewrite: %v", err)
+		}
+		_ = aw.Append("SET", []string{"b", "2"})
+		_ = aw.Close()
+		if err := os.WriteFile(path, readParts(t, path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.RemoveAll(filepath.Join(dir, DirName)); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := replayedKeys(t, path); got != "a=1,b=2" {
+			t.Fatalf("legacy replay: %s", got)
+		}
+		aw, err = Open(path)
+		if err != nil {
+			t.Fatalf("reopen aof: %v", err)
+		}
+		_ = aw.Append("SET", []string{"c", "3"})
+		_ = aw.Close()
+
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("single-file aof left in place: %v", err)
+		}
+		base := "appendonly.aof.1.base.aof"
+		if preamble {
+			base = "appendonly.aof.1.base.rdb"
+		}
+		if got, want := dirFiles(t, path), []string{base, "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("adopted aof has %v, want %v", got, want)
+		}
+		if got := replayedKeys(t, path); got != "a=1,b=2,c=3" {
+			t.Fatalf("replayed %s", got)
+		}
+	}
+}
+
+func TestOpen_RemovesLeftoverParts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	_ = aw.Append("SET", []string{"a", "1"})
+	_ = aw.Close()
+
+	// A crash can leave a half-written base, and the old parts of a rewrite
+	// whose manifest was installed.
+	dir := filepath.Join(filepath.Dir(path), DirName)
+	for _, name := range []string{"appendonly.aof.rewrite.tmp", "appendonly.aof.7.base.rdb", "appendonly.aof.manifest.tmp", "other.aof.1.incr.aof"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("junk"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := replayedKeys(t, path); got != "a=1" {
+		t.Fatalf("replayed %s", got)
+	}
+
+	aw, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen aof: %v", err)
+	}
+	_ = aw.Close()
+	want := []string{"appendonly.aof.1.incr.aof", "appendonly.aof.manifest", "other.aof.1.incr.aof"}
+	if got := dirFiles(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after reopen: %v, want %v", got, want)
+	}
+}
+
+func TestRewrite_KeepsUnrelatedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	st := store.New()
+	st.Set("a", []byte("1"))
+	_ = aw.Append("SET", []string{"a", "1"})
+
+	// A backup and the parts of another AOF whose name starts with ours.
+	dir := filepath.Join(filepath.Dir(path), DirName)
+	stray := []string{"appendonly.aof.bak", "appendonly.aof.old.1.incr.aof", "appendonly.aof.1.incr.aof.orig"}
+	for _, name := range stray {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	_ = aw.Close()
+	aw, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen aof: %v", err)
+	}
+	_ = aw.Close()
+
+	for _, name := range stray {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != "keep" {
+			t.Fatalf("%s after rewrite and reopen: %q, %v", name, data, err)
+		}
+	}
+	if got := replayedKeys(t, path); got != "a=1" {
+		t.Fatalf("replayed %s", got)
+	}
+}
+
+func TestSize_TracksAppendsAndRewrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	st := store.New()
+	for i := 0; i < 10; i++ {
+		st.Set("k", []byte("v"))
+		_ = aw.Append("SET", []string{"k", "v"})
+	}
+	if cur, base := aw.Size(); cur != 0 || base != 0 {
+		t.Fatalf("before flushing: size %d, base %d", cur, base)
+	}
+	_ = aw.Sync()
+	entry := int64(len("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"))
+	if cur, base := aw.Size(); cur != 10*entry || base != 0 {
+		t.Fatalf("after 10 appends: size %d, base %d; want %d, 0", cur, base, 10*entry)
+	}
+
+	// The rewrite compacts ten SETs into one, which becomes the base size.
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if cur, base := aw.Size(); cur != entry || base != entry {
+		t.Fatalf("after rewrite: size %d, base %d; want %d, %d", cur, base, entry, entry)
+	}
+	_ = aw.Append("SET", []string{"k", "v"})
+	_ = aw.Close()
+
+	// Reopening measures the parts on disk.
+	aw, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen aof: %v", err)
+	}
+	defer aw.Close()
+	if cur, base := aw.Size(); cur != 2*entry || base != 2*entry {
+		t.Fatalf("after reopen: size %d, base %d; want %d, %d", cur, base, 2*entry, 2*entry)
+	}
+}
@@ -0,0 +1,228 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+// A record is one logged command, encoded as a RESP array of bulk strings
+// the way clients send it. With checksums on (see FileAOF.SetChecksum) it
+// is preceded by a frame line giving its length and CRC32C:
+//
+//	!27:6466956b\r\n
+//	*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n
+//
+// Replay accepts both forms in any mix, so checksums can be turned on or
+// off between restarts.
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// maxBulkLen caps one argument of a record (Redis' proto-max-bulk-len), so
+// a damaged length cannot make replay allocate without bound.
+const maxBulkLen = 512 << 20
+
+// maxFrameLen caps the payload of a framed record.
+const maxFrameLen = 1 << 30
+
+// errTorn means the data ends inside a record, as a crash in the middle of
+// a write leaves it.
+var errTorn = errors.New("truncated record")
+
+// recordEncoder writes records, framed when checksum is set. It is not safe
+// for concurrent use.
+type recordEncoder struct {
+	checksum bool
+	buf      bytes.Buffer
+	bw       *bufio.Writer // over buf, to encode framed payloads
+}
+
+func (e *recordEncoder) write(w *bufio.Writer, cmd string, args []string) error {
+	if !e.checksum {
+		return writeCommand(w, cmd, args)
+	}
+	if e.bw == nil {
+		e.bw = bufio.NewWriter(&e.buf)
+	}
+	e.buf.Reset()
+	if err := writeCommand(e.bw, cmd, args); err != nil {
+		return err
+	}
+	if err := e.bw.Flush(); err != nil {
+		return err
+	}
+	payload := e.buf.Bytes()
+	if _, err := fmt.Fprintf(w, "!%d:%08x\r\n", len(payload), crc32.Checksum(payload, castagnoli)); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// writeCommand encodes a command as a RESP array of bulk strings.
+func writeCommand(w *bufio.Writer, cmd string, args []string) error {
+	if err := resp.WriteArrayHeader(w, 1+len(args)); err != nil {
+		return err
+	}
+	if err := resp.WriteBulkString(w, []byte(cmd)); err != nil {
+		return err
+	}
+	for _, s := range args {
+		if err := resp.WriteBulkString(w, []byte(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecord reads one record and reports whether it was framed. It returns
+// io.EOF when r is at its end, errTorn when r ends inside the record, and
+// any other error for damaged data.
+func readRecord(r *bufio.Reader) (cmd string, args []string, framed bool, err error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return "", nil, false, err
+	}
+	switch b[0] {
+	case '!':
+		cmd, args, err = readFramed(r)
+		return cmd, args, true, tornAtEOF(err)
+	case '*':
+		cmd, args, err = readCommand(r)
+		return cmd, args, false, tornAtEOF(err)
+	default:
+		return "", nil, false, fmt.Errorf("unexpected byte %q at record start", b[0])
+	}
+}
+
+// tornAtEOF reports running out of data as errTorn.
+func tornAtEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errTorn
+	}
+	return err
+}
+
+func readFramed(r *bufio.Reader) (string, []string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return "", nil, err
+	}
+	n, sum, ok := parseFrame(line)
+	if !ok {
+		return "", nil, fmt.Errorf("malformed frame %s", snippet(line))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", nil, err
+	}
+	if got := crc32.Checksum(payload, castagnoli); got != sum {
+		return "", nil, fmt.Errorf("checksum mismatch (%08x, frame says %08x)", got, sum)
+	}
+
+	pr := bufio.NewReader(bytes.NewReader(payload))
+	cmd, args, err := readCommand(pr)
+	if err == nil && pr.Buffered() > 0 {
+		err = errors.New("trailing bytes")
+	}
+	if err != nil {
+		// The checksum matched, so this was written that way.
+		return "", nil, fmt.Errorf("malformed framed record: %v", err)
+	}
+	return cmd, args, nil
+}
+
+// parseFrame parses a "!<length>:<crc32c hex>" frame line.
+func parseFrame(line string) (n int, sum uint32, ok bool) {
+	lenStr, sumStr, found := strings.Cut(line[1:], ":")
+	if !found || len(sumStr) != 8 {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(lenStr)
+	if err != nil || n < 0 || n > maxFrameLen {
+		return 0, 0, false
+	}
+	s, err := strconv.ParseUint(sumStr, 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, uint32(s), true
+}
+
+// readCommand reads a RESP array of bulk strings. Unlike resp.Decode it
+// accepts nothing else and bounds every length.
+func readCommand(r *bufio.Reader) (string, []string, error) {
+	n, err := readLen(r, '*')
+	if err != nil {
+		return "", nil, err
+	}
+	if n == 0 {
+		return "", nil, errors.New("empty record")
+	}
+	args := make([]string, 0, min(n, 64))
+	for i := 0; i < n; i++ {
+		l, err := readLen(r, '$')
+		if err != nil {
+			return "", nil, err
+		}
+		if l > maxBulkLen {
+			return "", nil, fmt.Errorf("argument of %d bytes", l)
+		}
+		buf := make([]byte, l+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", nil, err
+		}
+		if buf[l] != '\r' || buf[l+1] != '\n' {
+			return "", nil, errors.New("argument not terminated by CRLF")
+		}
+		args = append(args, string(buf[:l]))
+	}
+	return args[0], args[1:], nil
+}
+
+// readLen reads a "<prefix><n>\r\n" line.
+func readLen(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return 0, err
+	}
+	if line[0] != prefix {
+		return 0, fmt.Errorf("expected '%c', got %s", prefix, snippet(line))
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid length %s", snippet(line))
+	}
+	return n, nil
+}
+
+// readLine reads a CRLF-terminated line without the CRLF. Lines are short,
+// so one longer than the reader's buffer is damage.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", errors.New("line too long")
+		}
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed line %s", snippet(string(line)))
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// snippet quotes the start of damaged data for an error message.
+func snippet(s string) string {
+	if len(s) > 32 {
+		return strconv.Quote(s[:32]) + "..."
+	}
+	return strconv.Quote(s)
+}
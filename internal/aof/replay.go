@@ -0,0 +1,405 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/rdb"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// CorruptPolicy is what loading does about a damaged record, one that is
+// not merely cut short at the end of the AOF (aof-load-corrupt).
+type CorruptPolicy int
+
+const (
+	// CorruptStop fails the load, leaving the files as they are.
+	CorruptStop CorruptPolicy = iota
+	// CorruptSkip drops the damaged bytes, and any transaction they are
+	// part of, and goes on from the next record that reads back whole.
+	CorruptSkip
+	// CorruptTruncate keeps what precedes the damaged record and cuts the
+	// AOF there, later parts included.
+	CorruptTruncate
+)
+
+func (p CorruptPolicy) String() string {
+	switch p {
+	case CorruptSkip:
+		return "skip"
+	case CorruptTruncate:
+		return "truncate"
+	default:
+		return "stop"
+	}
+}
+
+// ParseCorruptPolicy maps stop, skip and truncate to a policy.
+func ParseCorruptPolicy(s string) (CorruptPolicy, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "stop":
+		return CorruptStop, true
+	case "skip":
+		return CorruptSkip, true
+	case "truncate":
+		return CorruptTruncate, true
+	default:
+		return CorruptStop, false
+	}
+}
+
+// LoadOptions controls how replay treats damage. The zero value cuts off a
+// torn tail and stops at anything worse, as Redis does by default.
+type LoadOptions struct {
+	// Restore receives the keys of a snapshot preamble; if nil they are
+	// applied as the commands a rewrite without one would have logged.
+	Restore func(store.SnapshotEntry) error
+
+	// RefuseTruncated makes a torn tail fail the load instead of being cut
+	// off (aof-load-truncated no).
+	RefuseTruncated bool
+
+	Corrupt CorruptPolicy
+}
+
+// Replay reads the AOF at path from disk and calls apply(cmd,args) for
+// each entry, going through the parts in manifest order (or the single file
+// at path for an AOF from before multi-part). The keys of a snapshot
+// preamble are applied as the commands a rewrite without one would have
+// logged; ReplayWithRestore loads them directly.
+// Crash-safe: ignores a truncated final entry (common after crash).
+//
+// Commands logged between MULTI and EXEC are applied only once EXEC is
+// read, so a transaction whose end never made it to disk is skipped as a
+// whole. A torn tail (truncated entry or unfinished transaction) is cut off
+// the last part, so that later appends do not end up behind it. Earlier
+// parts were complete when appends moved on, so damage there is an error.
+// ReplayWithOptions chooses other ways of dealing with damage.
+func Replay(path string, apply func(cmd string, args []string) error) error {
+	return ReplayWithOptions(path, LoadOptions{}, apply)
+}
+
+// ReplayWithRestore is Replay with the keys of a snapshot preamble passed
+// to restore instead of being turned into commands. restore may be nil.
+func ReplayWithRestore(path string, restore func(store.SnapshotEntry) error, apply func(cmd string, args []string) error) error {
+	return ReplayWithOptions(path, LoadOptions{Restore: restore}, apply)
+}
+
+// ReplayWithOptions is Replay with damage handled as opts says. Every
+// problem is logged with the file, offset and index of the record. A
+// damaged snapshot preamble always fails the load: keys already restored
+// from it cannot be told apart from the bad ones.
+func ReplayWithOptions(path string, opts LoadOptions, apply func(cmd string, args []string) error) error {
+	dir, name := partsDir(path)
+	m, ok, err := readManifest(dir, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		_, err := replayFile(path, opts, apply, true, false)
+		return err
+	}
+
+	parts := m.parts()
+	cut := false
+	for i, p := range parts {
+		file := filepath.Join(dir, p.file)
+		if cut {
+			log.Printf("[AOF] %s: emptied, it follows the truncated part", p.file)
+			if err := os.Truncate(file, 0); err != nil {
+				return fmt.Errorf("truncate aof part: %w", err)
+			}
+			continue
+		}
+		if cut, err = replayFile(file, opts, apply, i == len(parts)-1, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayFile replays one file of an AOF; last says whether it is the one
+// appends went to, the only one that may be torn. cut reports that the file
+// was truncated at a damaged record, so whatever follows it must go too.
+func replayFile(path string, opts LoadOptions, apply func(cmd string, args []string) error, last, mustExist bool) (cut bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return false, nil
+		}
+		return false, fmt.Errorf("open aof for replay %s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	res, err := replay(f, name, opts, apply)
+	size, _ := f.Seek(0, io.SeekEnd)
+	_ = f.Close()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	if !res.bad {
+		return false, nil
+	}
+
+	where := fmt.Sprintf("offset %d (record #%d)", res.valid, res.index)
+	switch {
+	case res.torn && last && opts.RefuseTruncated:
+		return false, fmt.Errorf("%s: truncated at %s; allow aof-load-truncated to cut it off", name, where)
+	case res.torn && last:
+		log.Printf("[AOF] %s: cutting %d bytes of torn tail at %s", name, size-res.valid, where)
+	case res.torn && opts.Corrupt == CorruptStop:
+		return false, fmt.Errorf("%s: aof part is truncated at %s", name, where)
+	case res.torn:
+		log.Printf("[AOF] %s: cutting %d bytes of torn part end at %s", name, size-res.valid, where)
+	}
+	if err := os.Truncate(path, res.valid); err != nil {
+		return false, fmt.Errorf("truncate torn aof tail: %w", err)
+	}
+	return opts.Corrupt == CorruptTruncate && !(res.torn && last), nil
+}
+
+// replayResult is what replay found in a file.
+type replayResult struct {
+	valid int64 // end of the last whole record or transaction applied
+	bad   bool  // the file must be cut at valid
+	torn  bool  // because it ends in the middle of a record or transaction
+	index int   // record the damage is in, counting from 1
+}
+
+// replay applies the preamble and records read from f, a file called name.
+// Damage is handled as opts says; what is returned tells the caller where
+// to cut the file, if anywhere.
+func replay(f io.ReadSeeker, name string, opts LoadOptions, apply func(cmd string, args []string) error) (res replayResult, err error) {
+	rr := newRecordReader(f)
+
+	if rdb.Detect(rr.r) {
+		restore := opts.Restore
+		if restore == nil {
+			restore = restoreAsCommands(apply)
+		}
+		if err := rdb.Decode(rr.r, restore); err != nil {
+			return res, fmt.Errorf("load aof preamble: %w", err)
+		}
+		res.valid = rr.offset()
+	}
+
+	var tx []Entry // commands of an open transaction
+	inTx, txIndex := false, 0
+	dropping := false // skipping the rest of a damaged transaction
+	framed := false   // the file has checksums
+	index := 0
+
+	for {
+		start := rr.offset()
+		cmd, args, isFramed, err := readRecord(rr.r)
+		if err == io.EOF {
+			if inTx {
+				// A transaction whose EXEC never made it to disk.
+				res.bad, res.torn, res.index = true, true, txIndex
+			}
+			return res, nil
+		}
+		index++
+		if errors.Is(err, errTorn) {
+			// A crash cuts off the last record only. If a whole record
+			// follows, a damaged length ran past the end of the file.
+			next, ok := rr.resync(start+1, framed || isFramed)
+			if !ok {
+				res.bad, res.torn, res.index = true, true, index
+				if inTx {
+					res.index = txIndex
+				}
+				return res, nil
+			}
+			err = fmt.Errorf("record runs past the end of the file, but a whole one follows at offset %d", next)
+		}
+		if err != nil {
+			where := fmt.Sprintf("record #%d at offset %d", index, start)
+			switch opts.Corrupt {
+			case CorruptStop:
+				return res, fmt.Errorf("bad %s: %w", where, err)
+			case CorruptTruncate:
+				log.Printf("[AOF] %s: bad %s: %v; truncating there", name, where, err)
+				res.bad, res.index = true, index
+				if inTx {
+					res.index = txIndex
+				}
+				return res, nil
+			}
+
+			dropTx := inTx || dropping
+			if inTx {
+				log.Printf("[AOF] %s: dropping records #%d-#%d, the start of the transaction the damage is in", name, txIndex, index-1)
+				inTx, tx = false, tx[:0]
+			}
+			dropping = false
+			next, ok := rr.resync(start+1, framed || isFramed)
+			if !ok {
+				// Nothing good follows: cut the rest off like a torn tail.
+				log.Printf("[AOF] %s: bad %s: %v; skipping to the end", name, where, err)
+				res.bad, res.index = true, index
+				return res, nil
+			}
+			log.Printf("[AOF] %s: bad %s: %v; skipped %d bytes", name, where, err, next-start)
+			if dropTx {
+				// The records up to the EXEC are the rest of the transaction;
+				// if anything else comes first, the damage was the EXEC.
+				if dropping, err = rr.txTail(); err != nil {
+					return res, fmt.Errorf("seek aof: %w", err)
+				}
+				if !dropping {
+					log.Printf("[AOF] %s: the transaction ended at the damaged record", name)
+				}
+			}
+			continue
+		}
+		framed = framed || isFramed
+
+		switch {
+		case dropping:
+			log.Printf("[AOF] %s: dropping record #%d at offset %d (%s), the rest of the damaged transaction", name, index, start, cmd)
+			dropping = !strings.EqualFold(cmd, "EXEC")
+		case strings.EqualFold(cmd, "MULTI"):
+			if inTx {
+				return res, fmt.Errorf("invalid aof: nested MULTI at record #%d", index)
+			}
+			inTx, tx, txIndex = true, tx[:0], index
+			continue
+		case strings.EqualFold(cmd, "EXEC"):
+			// An EXEC without MULTI was left behind by rewrites before
+			// multi-part AOF, which could start in the middle of a
+			// transaction; the rewrite holds the effects of the commands
+			// before it.
+			for _, e := range tx {
+				if err := apply(e.Cmd, e.Args); err != nil {
+					return res, fmt.Errorf("apply %s: %w", e.Cmd, err)
+				}
+			}
+			inTx, tx = false, tx[:0]
+		case inTx:
+			tx = append(tx, Entry{Cmd: cmd, Args: args})
+			continue
+		default:
+			if err := apply(cmd, args); err != nil {
+				return res, fmt.Errorf("apply %s: %w", cmd, err)
+			}
+		}
+		res.valid = rr.offset()
+	}
+}
+
+// recordReader reads records from a file it can reposition in.
+type recordReader struct {
+	f    io.ReadSeeker
+	cr   *countingReader
+	r    *bufio.Reader
+	base int64 // offset cr started reading at
+}
+
+func newRecordReader(f io.ReadSeeker) *recordReader {
+	cr := &countingReader{r: f}
+	return &recordReader{f: f, cr: cr, r: bufio.NewReaderSize(cr, 64*1024)}
+}
+
+// offset returns the file offset of the next byte r returns.
+func (rr *recordReader) offset() int64 {
+	return rr.base + rr.cr.n - int64(rr.r.Buffered())
+}
+
+func (rr *recordReader) seek(off int64) error {
+	if _, err := rr.f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	rr.base, rr.cr.n = off, 0
+	rr.r.Reset(rr.cr)
+	return nil
+}
+
+// resync finds the first record after from that reads back whole, and
+// leaves the reader at it. Records start at the beginning of a line; in a
+// file with checksums only a frame that verifies counts, so the payload of
+// a damaged frame is not taken for a record of its own.
+func (rr *recordReader) resync(from int64, framedOnly bool) (int64, bool) {
+	for {
+		if rr.seek(from) != nil {
+			return 0, false
+		}
+		for {
+			b, err := rr.r.ReadByte()
+			if err != nil {
+				return 0, false
+			}
+			if b != '\n' {
+				continue
+			}
+			if p, err := rr.r.Peek(1); err == nil && (p[0] == '!' || (p[0] == '*' && !framedOnly)) {
+				break
+			}
+		}
+		cand := rr.offset()
+		if _, _, _, err := readRecord(rr.r); err == nil {
+			return cand, rr.seek(cand) == nil
+		}
+		from = cand
+	}
+}
+
+// txTail reports whether the records from the reader's position on read
+// back whole up to an EXEC, with no MULTI before it: the rest of a
+// transaction that damage cut into. The reader is left where it was.
+func (rr *recordReader) txTail() (bool, error) {
+	start := rr.offset()
+	tail := false
+	for {
+		cmd, _, _, err := readRecord(rr.r)
+		if err != nil || strings.EqualFold(cmd, "MULTI") {
+			break
+		}
+		if strings.EqualFold(cmd, "EXEC") {
+			tail = true
+			break
+		}
+	}
+	return tail, rr.seek(start)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// restoreAsCommands returns a restore function that applies each key as
+// the commands a rewrite without a preamble logs for it.
+func restoreAsCommands(apply func(cmd string, args []string) error) func(store.SnapshotEntry) error {
+	writeCmd := func(cmd string, args ...string) error { return apply(cmd, args) }
+	db := 0
+	return func(e store.SnapshotEntry) error {
+		if e.DB != db {
+			if err := apply("SELECT", []string{strconv.Itoa(e.DB)}); err != nil {
+				return err
+			}
+			db = e.DB
+		}
+		if err := writeEntry(writeCmd, e); err != nil {
+			return err
+		}
+		if e.ExpiresAt != nil {
+			return apply("PEXPIREAT", []string{e.Key, strconv.FormatInt(*e.ExpiresAt, 10)})
+		}
+		return nil
+	}
+}
@@ -0,0 +1,266 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// replayWith replays the AOF at path into a fresh store and returns its
+// keys as storeKeys does.
+func replayWith(t *testing.T, path string, opts LoadOptions) (string, error) {
+	t.Helper()
+	st := store.New()
+	if err := ReplayWithOptions(path, opts, applyTo(st)); err != nil {
+		return "", err
+	}
+	return storeKeys(st), nil
+}
+
+// writeRecords logs SET k<i> v<i> for each of keys, with checksums as
+// given, and returns the file they went to.
+func writeRecords(t *testing.T, aw *FileAOF, checksum bool, keys ...string) string {
+	t.Helper()
+	aw.SetChecksum(checksum)
+	for _, k := range keys {
+		if err := aw.Append("SET", []string{"k" + k, "v" + k}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	_ = aw.Sync()
+	return aw.f.Name()
+}
+
+func TestChecksum_FramesAppendsAndRewrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	writeRecords(t, aw, false, "1")
+	part := writeRecords(t, aw, true, "2")
+
+	data, _ := os.ReadFile(part)
+	want := "*3\r\n$3\r\nSET\r\n$2\r\nk1\r\n$2\r\nv1\r\n" +
+		"!29:" // the frame of SET k2 v2
+	if !strings.HasPrefix(string(data), want) {
+		t.Fatalf("aof starts with %q, want %q", data, want)
+	}
+	if got := replayedKeys(t, path); got != "k1=v1,k2=v2" {
+		t.Fatalf("replayed %s", got)
+	}
+
+	// A rewrite without a preamble frames the base as well.
+	st := store.New()
+	st.Set("k1", []byte("v1"))
+	st.Set("k2", []byte("v2"))
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	_ = aw.Close()
+	files, _ := Files(path)
+	base, _ := os.ReadFile(files[0])
+	for r := bufio.NewReader(bytes.NewReader(base)); ; {
+		_, _, framed, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil || !framed {
+			t.Fatalf("base record: framed %v, err %v; base %q", framed, err, base)
+		}
+	}
+	if got := replayedKeys(t, path); got != "k1=v1,k2=v2" {
+		t.Fatalf("replayed %s", got)
+	}
+}
+
+func TestReplay_CorruptRecordPolicies(t *testing.T) {
+	for _, policy := range []CorruptPolicy{CorruptStop, CorruptSkip, CorruptTruncate} {
+		t.Run(policy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			aw, err := Open(path)
+			if err != nil {
+				t.Fatalf("open aof: %v", err)
+			}
+			first := writeRecords(t, aw, true, "1", "2", "3", "4")
+			rw, err := aw.StartRewrite()
+			if err != nil {
+				t.Fatalf("start rewrite: %v", err)
+			}
+			aw.AbortRewrite(rw)
+			second := writeRecords(t, aw, true, "5")
+			_ = aw.Close()
+
+			// Flip a byte of the value of record #3.
+			data, _ := os.ReadFile(first)
+			at := bytes.LastIndex(data[:bytes.Index(data, []byte("k3"))], []byte("!"))
+			data[bytes.Index(data, []byte("v3"))] = 'X'
+			if err := os.WriteFile(first, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := replayWith(t, path, LoadOptions{Corrupt: policy})
+			switch policy {
+			case CorruptStop:
+				if err == nil || !strings.Contains(err.Error(), "record #3 at offset "+strconv.Itoa(at)) ||
+					!strings.Contains(err.Error(), "checksum mismatch") {
+					t.Fatalf("err = %v", err)
+				}
+				if after, _ := os.ReadFile(first); !bytes.Equal(after, data) {
+					t.Fatal("stop changed the file")
+				}
+			case CorruptSkip:
+				if err != nil || got != "k1=v1,k2=v2,k4=v4,k5=v5" {
+					t.Fatalf("replayed %q, %v", got, err)
+				}
+				if after, _ := os.ReadFile(first); !bytes.Equal(after, data) {
+					t.Fatal("skip changed the file")
+				}
+			case CorruptTruncate:
+				if err != nil || got != "k1=v1,k2=v2" {
+					t.Fatalf("replayed %q, %v", got, err)
+				}
+				if after, _ := os.ReadFile(first); !bytes.Equal(after, data[:at]) {
+					t.Fatalf("first part is %d bytes, want %d", len(after), at)
+				}
+				if fi, _ := os.Stat(second); fi.Size() != 0 {
+					t.Fatalf("part after the damage kept %d bytes", fi.Size())
+				}
+				// What is left loads cleanly.
+				if got, err := replayWith(t, path, LoadOptions{}); err != nil || got != "k1=v1,k2=v2" {
+					t.Fatalf("reload: %q, %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplay_SkipResyncsPlainRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	part := writeRecords(t, aw, false, "1")
+	_ = aw.Append("MULTI", nil)
+	writeRecords(t, aw, false, "2")
+	_ = aw.Close()
+
+	// Garbage lands inside a transaction; the records after it are good.
+	f, _ := os.OpenFile(part, os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString("#garbage\r\n$$$\r\n")
+	_ = f.Close()
+	aw, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen aof: %v", err)
+	}
+	writeRecords(t, aw, false, "3")
+	_ = aw.Append("EXEC", nil)
+	writeRecords(t, aw, false, "4")
+	_ = aw.Close()
+
+	if _, err := replayWith(t, path, LoadOptions{}); err == nil || !strings.Contains(err.Error(), "record #4") {
+		t.Fatalf("stop: err = %v", err)
+	}
+	// The transaction the garbage is in is dropped as a whole.
+	if got, err := replayWith(t, path, LoadOptions{Corrupt: CorruptSkip}); err != nil || got != "k1=v1,k4=v4" {
+		t.Fatalf("skip: replayed %q, %v", got, err)
+	}
+}
+
+func TestReplay_TruncatedTailPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	part := writeRecords(t, aw, true, "1")
+	_ = aw.Close()
+	good, _ := os.ReadFile(part)
+
+	// Torn inside the length line of the next record's frame.
+	torn := append(bytes.Clone(good), "!2"...)
+	if err := os.WriteFile(part, torn, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayWith(t, path, LoadOptions{RefuseTruncated: true})
+	if err == nil || !strings.Contains(err.Error(), "truncated at offset "+strconv.Itoa(len(good))+" (record #2)") {
+		t.Fatalf("refused: err = %v", err)
+	}
+	if after, _ := os.ReadFile(part); !bytes.Equal(after, torn) {
+		t.Fatal("refusing changed the file")
+	}
+
+	if got, err := replayWith(t, path, LoadOptions{}); err != nil || got != "k1=v1" {
+		t.Fatalf("replayed %q, %v", got, err)
+	}
+	if after, _ := os.ReadFile(part); !bytes.Equal(after, good) {
+		t.Fatalf("torn tail not cut: %q", after)
+	}
+}
+
+func TestReplay_DamagedLengthIsNotATornTail(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "appendonly.aof")
+		aw, err := Open(path)
+		if err != nil {
+			t.Fatalf("open aof: %v", err)
+		}
+		part := writeRecords(t, aw, checksum, "1", "2", "3", "4")
+		_ = aw.Close()
+
+		// Make the first record claim more bytes than the file holds.
+		data, _ := os.ReadFile(part)
+		old, damaged := "$2\r\nv1", "$9999\r\nv1"
+		if checksum {
+			old, damaged = "!29:", "!9929:"
+		}
+		data = bytes.Replace(data, []byte(old), []byte(damaged), 1)
+		if err := os.WriteFile(part, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = replayWith(t, path, LoadOptions{})
+		if err == nil || !strings.Contains(err.Error(), "bad record #1 at offset 0") {
+			t.Fatalf("checksum %v: err = %v", checksum, err)
+		}
+		if after, _ := os.ReadFile(part); !bytes.Equal(after, data) {
+			t.Fatalf("checksum %v: the records after the damage were cut off", checksum)
+		}
+		if got, err := replayWith(t, path, LoadOptions{Corrupt: CorruptSkip}); err != nil || got != "k2=v2,k3=v3,k4=v4" {
+			t.Fatalf("checksum %v: skip replayed %q, %v", checksum, got, err)
+		}
+	}
+}
+
+func TestReplay_SkipDamagedExec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	aw.SetChecksum(true)
+	_ = aw.Append("MULTI", nil)
+	part := writeRecords(t, aw, true, "1")
+	_ = aw.Append("EXEC", nil)
+	writeRecords(t, aw, true, "2", "3")
+	_ = aw.Close()
+
+	data, _ := os.ReadFile(part)
+	data[bytes.Index(data, []byte("EXEC"))+3] = 'X'
+	if err := os.WriteFile(part, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction goes, the records after its EXEC do not.
+	if got, err := replayWith(t, path, LoadOptions{Corrupt: CorruptSkip}); err != nil || got != "k2=v2,k3=v3" {
+		t.Fatalf("replayed %q, %v", got, err)
+	}
+}
@@ -0,0 +1,62 @@
+package aof
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaySkipsTornTransaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	for _, e := range []Entry{
+		{"SET", []string{"a", "1"}},
+		{"MULTI", nil},
+		{"SET", []string{"b", "2"}},
+		{"EXEC", nil},
+		{"MULTI", nil},
+		{"SET", []string{"c", "3"}}, // crash before EXEC
+	} {
+		if err := aw.Append(e.Cmd, e.Args); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	_ = aw.Close()
+
+	replayKeys := func() string {
+		t.Helper()
+		var keys []string
+		if err := Replay(path, func(cmd string, args []string) error {
+			keys = append(keys, args[0])
+			return nil
+		}); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		return strings.Join(keys, ",")
+	}
+	if got := replayKeys(); got != "a,b" {
+		t.Fatalf("replayed %q, want a,b", got)
+	}
+
+	// The torn transaction was cut off, so new appends are not swallowed
+	// by it.
+	aw, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen aof: %v", err)
+	}
+	_ = aw.Append("SET", []string{"d", "4"})
+	_ = aw.Close()
+	if got := replayKeys(); got != "a,b,d" {
+		t.Fatalf("replayed %q after restart, want a,b,d", got)
+	}
+
+	raw := readParts(t, path)
+	if strings.Contains(string(raw), "$1\r\nc\r\n") {
+		t.Fatalf("torn transaction still in the file")
+	}
+}
@@ -0,0 +1,141 @@
+package aof
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// applyTo is a minimal command applier for the commands these tests log.
+func applyTo(st *store.Store) func(cmd string, args []string) error {
+	return func(cmd string, args []string) error {
+		switch cmd {
+		case "SET":
+			st.Set(args[0], []byte(args[1]))
+		case "HSET":
+			var pairs []store.FieldValue
+			for i := 1; i+1 < len(args); i += 2 {
+				pairs = append(pairs, store.FieldValue{Field: args[i], Value: []byte(args[i+1])})
+			}
+			_, _ = st.HSet(args[0], pairs)
+		case "PEXPIREAT":
+			ms, _ := strconv.ParseInt(args[1], 10, 64)
+			st.PExpireAt(args[0], ms, store.ExpireOptions{})
+		}
+		return nil
+	}
+}
+
+// writePreambleAOF rewrites an AOF holding a, h and an expiring b with a
+// preamble, then logs SET c 3 after it.
+func writePreambleAOF(t *testing.T, path string) {
+	t.Helper()
+	st := store.New()
+	st.Set("a", []byte("1"))
+	st.Set("b", []byte("2"))
+	st.PExpireAt("b", time.Now().Add(time.Hour).UnixMilli(), store.ExpireOptions{})
+	st.HSet("h", []store.FieldValue{{Field: "f", Value: []byte("v")}})
+
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	aw.SetRDBPreamble(true)
+	if err := aw.Rewrite(st.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	_ = aw.Append("SET", []string{"c", "3"})
+	_ = aw.Close()
+}
+
+func checkPreambleState(t *testing.T, st *store.Store) {
+	t.Helper()
+	for k, want := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		if v, ok := st.Get(k); !ok || string(v) != want {
+			t.Fatalf("%s = %q, %v; want %q", k, v, ok, want)
+		}
+	}
+	if v, _, _ := st.HGet("h", "f"); string(v) != "v" {
+		t.Fatalf("h.f = %q", v)
+	}
+	if st.TTL("b") <= 0 {
+		t.Fatalf("b lost its TTL: %d", st.TTL("b"))
+	}
+}
+
+func TestRewritePreamble_ReplaysWithAndWithoutRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	writePreambleAOF(t, path)
+
+	files, _ := Files(path)
+	data, _ := os.ReadFile(files[0])
+	if !strings.HasSuffix(files[0], ".base.rdb") || !strings.HasPrefix(string(data), "REDIGO") {
+		t.Fatalf("rewritten file does not start with a preamble: %q", data[:min(len(data), 16)])
+	}
+
+	restored := 0
+	st := store.New()
+	err := ReplayWithRestore(path, func(e store.SnapshotEntry) error {
+		restored++
+		st.Restore(e)
+		return nil
+	}, applyTo(st))
+	if err != nil {
+		t.Fatalf("replay with restore: %v", err)
+	}
+	if restored != 3 {
+		t.Fatalf("restored %d keys, want 3", restored)
+	}
+	checkPreambleState(t, st)
+
+	// Without a restore function the preamble goes through apply.
+	st2 := store.New()
+	if err := Replay(path, applyTo(st2)); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	checkPreambleState(t, st2)
+}
+
+func TestRewritePreamble_TornTailIsCutAfterPreamble(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	writePreambleAOF(t, path)
+	tail := lastPart(t, path)
+	before, _ := os.ReadFile(tail)
+
+	f, err := os.OpenFile(tail, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open append: %v", err)
+	}
+	_, _ = f.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nx\r\n$5\r\nab"))
+	_ = f.Close()
+
+	st := store.New()
+	if err := Replay(path, applyTo(st)); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	checkPreambleState(t, st)
+	if after, _ := os.ReadFile(tail); string(after) != string(before) {
+		t.Fatalf("torn tail not cut: %d bytes, want %d", len(after), len(before))
+	}
+}
+
+func TestRewritePreamble_CorruptionFailsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	writePreambleAOF(t, path)
+
+	files, _ := Files(path)
+	data, _ := os.ReadFile(files[0])
+	data[len("REDIGO")+5] ^= 0xff
+	if err := os.WriteFile(files[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Replay(path, applyTo(store.New()))
+	if err == nil || !strings.Contains(err.Error(), "preamble") {
+		t.Fatalf("expected preamble error, got %v", err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/pranavbrkr/redigo/internal/store"
 )
 
-func TestRewriteInstallAppendsTail(t *testing.T) {
+func TestRewriteKeepsAppendsMadeDuringIt(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "appendonly.aof")
 
@@ -23,23 +23,24 @@ func TestRewriteInstallAppendsTail(t *testing.T) {
 	defer aw.Close()
 
 	// snapshot with a=1
-	snap := st.Snapshot()
-
-	tmp, err := aw.WriteRewriteTemp(snap)
+	rw, err := aw.StartRewrite()
 	if err != nil {
-		t.Fatalf("write temp: %v", err)
+		t.Fatalf("start rewrite: %v", err)
 	}
+	snap := st.Snapshot()
 
-	// tail: set b=2, expire a
+	// while the base is written: set b=2, expire a
 	exp := time.Now().Add(10 * time.Second).Unix()
-	tail := []Entry{
-		{Cmd: "SET", Args: []string{"b", "2"}},
-		{Cmd: "EXPIREAT", Args: []string{"a", strconv.FormatInt(exp, 10)}},
-	}
+	_ = aw.Append("SET", []string{"b", "2"})
+	_ = aw.Append("EXPIREAT", []string{"a", strconv.FormatInt(exp, 10)})
 
-	if err := aw.InstallRewrite(tmp, tail); err != nil {
-		t.Fatalf("install rewrite: %v", err)
+	if err := rw.WriteBase(snap); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := aw.FinishRewrite(rw); err != nil {
+		t.Fatalf("finish rewrite: %v", err)
 	}
+	_ = aw.Sync()
 
 	// Replay new AOF and validate
 	st2 := store.New()
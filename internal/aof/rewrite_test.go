@@ -55,6 +55,11 @@ func TestRewriteCompactsAndReplaysState(t *testing.T) {
 				ts, _ := strconv.ParseInt(args[1], 10, 64)
 				st2.ExpireAt(args[0], ts)
 			}
+		case "PEXPIREAT":
+			if len(args) == 2 {
+				ms, _ := strconv.ParseInt(args[1], 10, 64)
+				st2.PExpireAt(args[0], ms, store.ExpireOptions{})
+			}
 		}
 		return nil
 	}); err != nil {
@@ -70,3 +75,39 @@ func TestRewriteCompactsAndReplaysState(t *testing.T) {
 		t.Fatalf("expected b to have positive ttl after replay, got ttl=%d", ttl)
 	}
 }
+
+func TestRewriteSelectsDatabases(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+
+	dbs := store.NewDatabases(4)
+	dbs.DB(0).Set("zero", []byte("0"))
+	dbs.DB(2).Set("two", []byte("2"))
+	dbs.DB(3).Set("three", []byte("3"))
+
+	aw, err := Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	defer aw.Close()
+	if err := aw.Rewrite(dbs.Snapshot()); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+
+	var got []string
+	if err := Replay(path, func(cmd string, args []string) error {
+		got = append(got, cmd+" "+args[0])
+		return nil
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	want := []string{"SET zero", "SELECT 2", "SET two", "SELECT 3", "SET three"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
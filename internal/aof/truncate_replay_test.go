@@ -25,7 +25,7 @@ func TestReplayIgnoresTruncatedTail(t *testing.T) {
 	_ = aw.Close()
 
 	// Append a truncated command tail (simulate crash mid-write)
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f, err := os.OpenFile(lastPart(t, path), os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
 		t.Fatalf("open append: %v", err)
 	}
@@ -0,0 +1,134 @@
+// Package glob implements Redis-style glob matching as used by KEYS, SCAN
+// MATCH and PSUBSCRIBE.
+package glob
+
+// Match reports whether s matches pattern.
+//
+// Supported syntax:
+//
+//   - any sequence of bytes (including empty)
+//     ?       any single byte
+//     [abc]   one of the listed bytes; [^abc] negates, [a-z] is a range
+//     \x      the literal byte x
+func Match(pattern, s string) bool {
+	return match(pattern, s, false)
+}
+
+// MatchFold is Match with ASCII case-insensitive comparison.
+func MatchFold(pattern, s string) bool {
+	return match(pattern, s, true)
+}
+
+func match(p, s string, fold bool) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			// collapse consecutive stars
+			for len(p) > 1 && p[1] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(p[1:], s[i:], fold) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			p = p[1:]
+
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			var ok bool
+			ok, p = matchClass(p[1:], s[0], fold)
+			if !ok {
+				return false
+			}
+			s = s[1:]
+
+		case '\\':
+			if len(p) >= 2 {
+				p = p[1:]
+			}
+			fallthrough
+
+		default:
+			if len(s) == 0 || !equal(p[0], s[0], fold) {
+				return false
+			}
+			s = s[1:]
+			p = p[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchClass matches c against the class body p (just after '[') and
+// returns the rest of the pattern after the closing ']'.
+func matchClass(p string, c byte, fold bool) (bool, string) {
+	not := false
+	if len(p) > 0 && p[0] == '^' {
+		not = true
+		p = p[1:]
+	}
+
+	matched := false
+	for len(p) > 0 && p[0] != ']' {
+		switch {
+		case p[0] == '\\' && len(p) >= 2:
+			if equal(p[1], c, fold) {
+				matched = true
+			}
+			p = p[2:]
+		case len(p) >= 3 && p[1] == '-' && p[2] != ']':
+			lo, hi := p[0], p[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			cc := c
+			if fold {
+				lo, hi, cc = lower(lo), lower(hi), lower(c)
+			}
+			if cc >= lo && cc <= hi {
+				matched = true
+			}
+			p = p[3:]
+		default:
+			if equal(p[0], c, fold) {
+				matched = true
+			}
+			p = p[1:]
+		}
+	}
+	if len(p) > 0 {
+		p = p[1:] // skip ']'
+	}
+
+	if not {
+		matched = !matched
+	}
+	return matched, p
+}
+
+func equal(a, b byte, fold bool) bool {
+	if fold {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+func lower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
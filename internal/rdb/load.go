@@ -0,0 +1,275 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// maxLen bounds any length read from a snapshot, so a corrupt length fails
+// the load instead of allocating without limit (Redis' proto-max-bulk-len).
+const maxLen = 512 << 20
+
+// decoder reads records from r while hashing them.
+type decoder struct {
+	r   *bufio.Reader
+	crc hash.Hash64
+	err error // first read error; later reads return zero values
+	one [1]byte
+}
+
+func (d *decoder) ReadByte() (byte, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.fail(err)
+		return 0, d.err
+	}
+	d.one[0] = b
+	_, _ = d.crc.Write(d.one[:])
+	return b, nil
+}
+
+func (d *decoder) fail(err error) {
+	if d.err != nil {
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	d.err = err
+}
+
+func (d *decoder) byte() byte {
+	b, _ := d.ReadByte()
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, err := binary.ReadUvarint(d)
+	if err != nil {
+		d.fail(err)
+	}
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, err := binary.ReadVarint(d)
+	if err != nil {
+		d.fail(err)
+	}
+	return v
+}
+
+// length reads a count or a string length.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > maxLen {
+		d.fail(fmt.Errorf("rdb: length %d out of range", n))
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err)
+		return nil
+	}
+	_, _ = d.crc.Write(b)
+	return b
+}
+
+func (d *decoder) fixed64() uint64 {
+	b := d.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *decoder) bytes() []byte { return d.read(d.length()) }
+
+func (d *decoder) string() string { return string(d.bytes()) }
+
+func (d *decoder) id() store.StreamID {
+	return store.StreamID{Ms: d.uvarint(), Seq: d.uvarint()}
+}
+
+// Detect reports whether r starts with a snapshot, without consuming
+// anything.
+func Detect(r *bufio.Reader) bool {
+	b, err := r.Peek(len(magic))
+	return err == nil && string(b) == magic
+}
+
+// Decode reads one snapshot from r and calls apply for every key, in file
+// order. It consumes exactly the snapshot's bytes, so r can go on with
+// other data. The checksum can only be verified at the end: a corrupt file
+// returns ErrChecksum after the keys before the corruption were applied.
+func Decode(r *bufio.Reader, apply func(store.SnapshotEntry) error) error {
+	d := &decoder{r: r, crc: crc64.New(crcTable)}
+
+	head := d.read(len(magic) + 1)
+	if d.err != nil {
+		return fmt.Errorf("read rdb header: %w", d.err)
+	}
+	if !bytes.Equal(head[:len(magic)], []byte(magic)) {
+		return fmt.Errorf("rdb: bad magic %q", head[:len(magic)])
+	}
+	if v := head[len(magic)]; v != version {
+		return fmt.Errorf("rdb: unsupported version %d", v)
+	}
+
+	db, index := 0, 0
+	for {
+		var exp *int64
+		op := d.byte()
+		switch op {
+		case opEOF:
+			if d.err != nil {
+				return fmt.Errorf("read rdb: %w", d.err)
+			}
+			want := d.crc.Sum64()
+			var sum [8]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return fmt.Errorf("read rdb checksum: %w", err)
+			}
+			if binary.LittleEndian.Uint64(sum[:]) != want {
+				return ErrChecksum
+			}
+			return nil
+		case opSelectDB:
+			db = d.length()
+			continue
+		case opExpireMs:
+			ms := int64(d.fixed64())
+			exp = &ms
+			op = d.byte()
+		}
+
+		e, err := d.entry(op)
+		if d.err != nil {
+			return fmt.Errorf("read rdb key #%d: %w", index, d.err)
+		}
+		if err != nil {
+			return err
+		}
+		e.DB, e.ExpiresAt = db, exp
+		if err := apply(e); err != nil {
+			return fmt.Errorf("apply rdb key %q: %w", e.Key, err)
+		}
+		index++
+	}
+}
+
+func (d *decoder) entry(typ byte) (store.SnapshotEntry, error) {
+	e := store.SnapshotEntry{Key: d.string()}
+	switch typ {
+	case typeString:
+		e.Kind = store.KindString
+		e.Value = d.bytes()
+	case typeList:
+		e.Kind = store.KindList
+		n := d.length()
+		e.List = make([][]byte, 0, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			e.List = append(e.List, d.bytes())
+		}
+	case typeSet:
+		e.Kind = store.KindSet
+		n := d.length()
+		e.Set = make([]string, 0, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			e.Set = append(e.Set, d.string())
+		}
+	case typeZSet:
+		e.Kind = store.KindZSet
+		n := d.length()
+		e.ZSet = make([]store.ScoreMember, 0, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			m := d.string()
+			e.ZSet = append(e.ZSet, store.ScoreMember{Member: m, Score: math.Float64frombits(d.fixed64())})
+		}
+	case typeHash:
+		e.Kind = store.KindHash
+		n := d.length()
+		e.Hash = make(map[string][]byte, min(n, 1024))
+		for i := 0; i < n && d.err == nil; i++ {
+			f := d.string()
+			e.Hash[f] = d.bytes()
+		}
+	case typeStream:
+		e.Kind = store.KindStream
+		e.Stream = d.stream()
+	default:
+		return e, fmt.Errorf("rdb: unknown record type 0x%02x", typ)
+	}
+	return e, nil
+}
+
+func (d *decoder) stream() *store.StreamSnapshot {
+	s := &store.StreamSnapshot{}
+	n := d.length()
+	s.Entries = make([]store.StreamEntry, 0, min(n, 1024))
+	for i := 0; i < n && d.err == nil; i++ {
+		ent := store.StreamEntry{ID: d.id()}
+		nf := d.length()
+		ent.Fields = make([]string, 0, min(nf, 1024))
+		for j := 0; j < nf && d.err == nil; j++ {
+			ent.Fields = append(ent.Fields, d.string())
+		}
+		s.Entries = append(s.Entries, ent)
+	}
+	s.LastID = d.id()
+	s.MaxDeletedID = d.id()
+	s.EntriesAdded = d.uvarint()
+
+	ng := d.length()
+	for i := 0; i < ng && d.err == nil; i++ {
+		g := store.StreamGroupSnapshot{Name: d.string(), LastID: d.id(), EntriesRead: d.varint()}
+		nc := d.length()
+		for j := 0; j < nc && d.err == nil; j++ {
+			g.Consumers = append(g.Consumers, d.string())
+		}
+		np := d.length()
+		for j := 0; j < np && d.err == nil; j++ {
+			p := store.PendingEntry{ID: d.id(), Consumer: d.string()}
+			p.DeliveryTime = d.varint()
+			p.DeliveryCount = d.varint()
+			g.Pending = append(g.Pending, p)
+		}
+		s.Groups = append(s.Groups, g)
+	}
+	return s
+}
+
+// Load decodes the snapshot at path into apply. A missing file is not an
+// error: there is simply nothing to load.
+func Load(path string, apply func(store.SnapshotEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("open rdb %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return Decode(bufio.NewReaderSize(f, 64*1024), apply)
+}
@@ -0,0 +1,243 @@
+// Package rdb reads and writes point-in-time snapshots of the keyspace in a
+// compact binary format, in the spirit of Redis' RDB files.
+//
+// A file is the magic "REDIGO", a version byte, a sequence of records and a
+// trailer:
+//
+//	opSelectDB db               following keys belong to database db
+//	[opExpireMs ms] type key v  one key, optionally with an expiry
+//	opEOF crc64                 end of data
+//
+// Integers are (zig-zag) varints, except expiries and scores, which are
+// fixed 8 bytes little endian; strings are a varint length and the bytes.
+// The trailer is the CRC-64/ECMA of everything before it, little endian.
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+const (
+	magic   = "REDIGO"
+	version = 1
+)
+
+// Record opcodes. Values below opExpireMs are value types.
+const (
+	typeString byte = iota
+	typeList
+	typeSet
+	typeZSet
+	typeHash
+	typeStream
+
+	opExpireMs byte = 0xFC
+	opSelectDB byte = 0xFE
+	opEOF      byte = 0xFF
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// ErrChecksum is returned when a snapshot's trailer does not match its
+// contents.
+var ErrChecksum = errors.New("rdb checksum mismatch")
+
+// encoder buffers records; Encode hashes them on the way out.
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) byte(b byte) { _ = e.w.WriteByte(b) }
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	_, _ = e.w.Write(e.buf[:n])
+}
+
+func (e *encoder) varint(v int64) {
+	n := binary.PutVarint(e.buf[:], v)
+	_, _ = e.w.Write(e.buf[:n])
+}
+
+func (e *encoder) fixed64(v uint64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], v)
+	_, _ = e.w.Write(e.buf[:8])
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	_, _ = e.w.WriteString(s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	_, _ = e.w.Write(b)
+}
+
+func (e *encoder) id(id store.StreamID) {
+	e.uvarint(id.Ms)
+	e.uvarint(id.Seq)
+}
+
+// Encode writes snap to w. Entries of one database should be adjacent, as
+// Databases.Snapshot returns them, or the file repeats opSelectDB.
+func Encode(w io.Writer, snap []store.SnapshotEntry) error {
+	crc := crc64.New(crcTable)
+	e := &encoder{w: bufio.NewWriterSize(io.MultiWriter(w, crc), 64*1024)}
+
+	_, _ = e.w.WriteString(magic)
+	e.byte(version)
+	db := -1
+	for _, ent := range snap {
+		if ent.DB != db {
+			e.byte(opSelectDB)
+			e.uvarint(uint64(ent.DB))
+			db = ent.DB
+		}
+		if ent.ExpiresAt != nil {
+			e.byte(opExpireMs)
+			e.fixed64(uint64(*ent.ExpiresAt))
+		}
+		if err := e.entry(ent); err != nil {
+			return err
+		}
+	}
+	e.byte(opEOF)
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("write rdb: %w", err)
+	}
+
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], crc.Sum64())
+	if _, err := w.Write(sum[:]); err != nil {
+		return fmt.Errorf("write rdb checksum: %w", err)
+	}
+	return nil
+}
+
+func (e *encoder) entry(ent store.SnapshotEntry) error {
+	switch ent.Kind {
+	case store.KindString:
+		e.byte(typeString)
+		e.string(ent.Key)
+		e.bytes(ent.Value)
+	case store.KindList:
+		e.byte(typeList)
+		e.string(ent.Key)
+		e.uvarint(uint64(len(ent.List)))
+		for _, v := range ent.List {
+			e.bytes(v)
+		}
+	case store.KindSet:
+		e.byte(typeSet)
+		e.string(ent.Key)
+		e.uvarint(uint64(len(ent.Set)))
+		for _, m := range ent.Set {
+			e.string(m)
+		}
+	case store.KindZSet:
+		e.byte(typeZSet)
+		e.string(ent.Key)
+		e.uvarint(uint64(len(ent.ZSet)))
+		for _, sm := range ent.ZSet {
+			e.string(sm.Member)
+			e.fixed64(math.Float64bits(sm.Score))
+		}
+	case store.KindHash:
+		e.byte(typeHash)
+		e.string(ent.Key)
+		e.uvarint(uint64(len(ent.Hash)))
+		for f, v := range ent.Hash {
+			e.string(f)
+			e.bytes(v)
+		}
+	case store.KindStream:
+		e.byte(typeStream)
+		e.string(ent.Key)
+		e.stream(ent.Stream)
+	default:
+		return fmt.Errorf("rdb: unsupported type %s for key %q", ent.Kind, ent.Key)
+	}
+	return nil
+}
+
+func (e *encoder) stream(s *store.StreamSnapshot) {
+	e.uvarint(uint64(len(s.Entries)))
+	for _, ent := range s.Entries {
+		e.id(ent.ID)
+		e.uvarint(uint64(len(ent.Fields)))
+		for _, f := range ent.Fields {
+			e.string(f)
+		}
+	}
+	e.id(s.LastID)
+	e.id(s.MaxDeletedID)
+	e.uvarint(s.EntriesAdded)
+
+	e.uvarint(uint64(len(s.Groups)))
+	for _, g := range s.Groups {
+		e.string(g.Name)
+		e.id(g.LastID)
+		e.varint(g.EntriesRead)
+		e.uvarint(uint64(len(g.Consumers)))
+		for _, c := range g.Consumers {
+			e.string(c)
+		}
+		e.uvarint(uint64(len(g.Pending)))
+		for _, p := range g.Pending {
+			e.id(p.ID)
+			e.string(p.Consumer)
+			e.varint(p.DeliveryTime)
+			e.varint(p.DeliveryCount)
+		}
+	}
+}
+
+// Save writes snap to path atomically: it is encoded to a temporary file in
+// the same directory, fsynced and renamed over path.
+func Save(path string, snap []store.SnapshotEntry) error {
+	dir := filepath.Dir(path)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create rdb dir: %w", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("open rdb temp: %w", err)
+	}
+	tmpPath := tmp.Name()
+	fail := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := Encode(tmp, snap); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("rdb fsync: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("rdb close: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("rdb rename: %w", err)
+	}
+	return nil
+}
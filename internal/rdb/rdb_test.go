@@ -0,0 +1,116 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// fill puts one key of every type into dbs, spread over two databases.
+func fill(t *testing.T, dbs *store.Databases) {
+	t.Helper()
+	db0, db3 := dbs.DB(0), dbs.DB(3)
+	db0.Set("str", []byte("v\x00\xff"))
+	db0.PExpireAt("str", time.Now().Add(time.Hour).UnixMilli(), store.ExpireOptions{})
+	db0.HSet("h", []store.FieldValue{{Field: "f1", Value: []byte("a")}, {Field: "f2", Value: []byte{}}})
+	db0.RPush("l", [][]byte{[]byte("x"), []byte("y"), []byte("z")})
+	db3.SAdd("s", []string{"m1", "m2"})
+	db3.ZAdd("z", store.ZAddOptions{}, []store.ScoreMember{{Member: "a", Score: 1.5}, {Member: "b", Score: -2}})
+
+	for i := 1; i <= 3; i++ {
+		db3.XAdd("x", store.XAddID{ID: store.StreamID{Ms: uint64(i)}}, []string{"f", "v"}, false, store.StreamTrim{})
+	}
+	db3.XGroupCreate("x", "g", store.GroupStart{Entries: store.NoEntriesRead}, false)
+	db3.XReadGroup("x", "g", "alice", nil, 2, false)
+	db3.XGroupCreateConsumer("x", "g", "bob")
+	db3.XDel("x", []store.StreamID{{Ms: 1}})
+}
+
+// normalize sorts what snapshots return in no particular order.
+func normalize(snap []store.SnapshotEntry) []store.SnapshotEntry {
+	sort.Slice(snap, func(i, j int) bool {
+		if snap[i].DB != snap[j].DB {
+			return snap[i].DB < snap[j].DB
+		}
+		return snap[i].Key < snap[j].Key
+	})
+	for _, e := range snap {
+		sort.Strings(e.Set)
+	}
+	return snap
+}
+
+func TestSaveLoad_RoundTripsEveryType(t *testing.T) {
+	src := store.NewDatabases(4)
+	fill(t, src)
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := Save(path, src.Snapshot()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	dst := store.NewDatabases(4)
+	err := Load(path, func(e store.SnapshotEntry) error {
+		_, err := dst.Restore(e)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want, got := normalize(src.Snapshot()), normalize(dst.Snapshot())
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded state differs:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoad_MissingFileIsEmpty(t *testing.T) {
+	err := Load(filepath.Join(t.TempDir(), "none.rdb"), func(store.SnapshotEntry) error {
+		t.Fatal("apply called")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+}
+
+func TestDecode_DetectsCorruption(t *testing.T) {
+	src := store.NewDatabases(4)
+	fill(t, src)
+	var buf bytes.Buffer
+	if err := Encode(&buf, src.Snapshot()); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	good := buf.Bytes()
+	noop := func(store.SnapshotEntry) error { return nil }
+
+	// A flipped byte inside a value is caught by the checksum.
+	bad := bytes.Clone(good)
+	i := bytes.Index(bad, []byte("m1"))
+	bad[i] = 'M'
+	if err := Decode(bufio.NewReader(bytes.NewReader(bad)), noop); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("flipped byte: err = %v", err)
+	}
+
+	// A truncated file fails to read rather than loading part of it quietly.
+	for _, n := range []int{3, len(good) / 2, len(good) - 1} {
+		if err := Decode(bufio.NewReader(bytes.NewReader(good[:n])), noop); err == nil {
+			t.Fatalf("truncated to %d bytes: no error", n)
+		}
+	}
+
+	// Decode stops right after the trailer.
+	r := bufio.NewReader(bytes.NewReader(append(bytes.Clone(good), "tail"...)))
+	if err := Decode(r, noop); err != nil {
+		t.Fatalf("decode with trailing data: %v", err)
+	}
+	if rest, _ := r.Peek(4); string(rest) != "tail" {
+		t.Fatalf("left %q after the snapshot", rest)
+	}
+}
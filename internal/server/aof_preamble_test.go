@@ -0,0 +1,73 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestBGREWRITEAOF_PreambleAndTailReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	aw.SetRDBPreamble(true)
+	dbs := store.NewDatabases(store.DefaultDatabases)
+	s, addr, err := StartDatabases("127.0.0.1:0", dbs, aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "a", "1")
+	c.mustInt(1, "EXPIRE", "a", "100")
+	c.mustInt(2, "SADD", "s", "x", "y")
+	c.mustOK("SELECT", "4")
+	c.mustInt(1, "ZADD", "z", "1.5", "m")
+
+	reached := make(chan struct{})
+	release := make(chan struct{})
+	s.rewriteMu.Lock()
+	s.testHookBeforeInstall = func() {
+		close(reached)
+		<-release
+	}
+	s.rewriteMu.Unlock()
+	c.mustOK("BGREWRITEAOF")
+	<-reached
+
+	// Writes racing the rewrite land in the tail, after the preamble.
+	c.mustInt(1, "ZADD", "z", "3", "n")
+	c.mustOK("SELECT", "0")
+	c.mustInt(1, "DEL", "s")
+	close(release)
+	s.rewriteWg.Wait()
+	c.mustOK("SET", "b", "2")
+	_ = s.Close()
+
+	files, _ := aof.Files(path)
+	if data, _ := os.ReadFile(files[0]); !strings.HasPrefix(string(data), "REDIGO") {
+		t.Fatal("rewritten AOF has no preamble")
+	}
+	want := sortedSnapshot(dbs)
+	for _, withRestore := range []bool{true, false} {
+		got := store.NewDatabases(store.DefaultDatabases)
+		l := NewDatabasesLoader(got)
+		restore := l.Restore
+		if !withRestore {
+			restore = nil
+		}
+		if err := aof.ReplayWithRestore(path, restore, l.Apply); err != nil {
+			t.Fatalf("replay (restore %v): %v", withRestore, err)
+		}
+		if g := sortedSnapshot(got); !reflect.DeepEqual(g, want) {
+			t.Fatalf("replay (restore %v):\n got %+v\nwant %+v", withRestore, g, want)
+		}
+	}
+}
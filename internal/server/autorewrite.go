@@ -0,0 +1,59 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+)
+
+// Redis' defaults for auto-aof-rewrite-percentage and
+// auto-aof-rewrite-min-size.
+const (
+	defaultAutoRewritePerc    = 100
+	defaultAutoRewriteMinSize = 64 << 20
+)
+
+// rewriteRetryDelay is how long a failed rewrite holds off the next
+// automatic one.
+const rewriteRetryDelay = 5 * time.Second
+
+// SetAutoRewrite sets how much the AOF must have grown since the last
+// rewrite, in percent of its size then (0 disables automatic rewrites), and
+// the size below which it is never rewritten automatically. It is safe to
+// call while serving.
+func (s *Server) SetAutoRewrite(perc, minSize int64) {
+	s.autoRewritePerc.Store(perc)
+	s.autoRewriteMinSize.Store(minSize)
+}
+
+// rewriteIfDue starts a background rewrite once the AOF has grown past
+// auto-aof-rewrite-min-size and by auto-aof-rewrite-percentage since the
+// last rewrite, as Redis' serverCron does.
+func (s *Server) rewriteIfDue(now time.Time) {
+	faof, ok := s.aof.(*aof.FileAOF)
+	perc := s.autoRewritePerc.Load()
+	if !ok || perc == 0 {
+		return
+	}
+	cur, base := faof.Size()
+	if cur < s.autoRewriteMinSize.Load() {
+		return
+	}
+	if base == 0 {
+		base = 1
+	}
+	growth := (cur - base) * 100 / base
+	if growth < perc {
+		return
+	}
+
+	s.rewriteMu.Lock()
+	wait := !s.lastRewriteOK && now.Sub(s.lastRewriteTry) < rewriteRetryDelay
+	s.rewriteMu.Unlock()
+	if wait || !s.tryStartRewrite() {
+		return
+	}
+	log.Printf("[BGREWRITEAOF] starting automatic rewrite: %d%% growth (%d bytes)", growth, cur)
+	s.startRewrite(faof)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// infoField returns the value of field in INFO.
+func infoField(c *testConn, field string) string {
+	for _, line := range strings.Split(string(c.do("INFO").Bulk), "\r\n") {
+		if v, ok := strings.CutPrefix(line, field+":"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func TestAutoRewrite_TriggersOnGrowth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	dbs := store.NewDatabases(store.DefaultDatabases)
+	s, addr, err := StartDatabases("127.0.0.1:0", dbs, aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	c := dialTest(t, addr)
+
+	c.mustStrings([]string{"auto-aof-rewrite-percentage", "100"}, "CONFIG", "GET", "auto-aof-rewrite-percentage")
+	c.mustStrings([]string{"auto-aof-rewrite-min-size", "67108864"}, "CONFIG", "GET", "auto-aof-rewrite-min-size")
+	c.mustErr("non-negative", "CONFIG", "SET", "auto-aof-rewrite-percentage", "-1")
+	waitInfo(t, c, "aof_enabled:1")
+	waitInfo(t, c, "aof_rewrites:0")
+
+	// Disabled: growth alone does not rewrite.
+	c.mustOK("CONFIG", "SET", "auto-aof-rewrite-percentage", "0", "auto-aof-rewrite-min-size", "1kb")
+	for i := 0; i < 50; i++ {
+		c.mustOK("SET", "k", strconv.Itoa(i))
+	}
+	time.Sleep(3 * persistenceCheckInterval)
+	waitInfo(t, c, "aof_rewrites:0")
+	if cur, _ := strconv.Atoi(infoField(c, "aof_current_size")); cur < 1024 {
+		t.Fatalf("aof_current_size %d after 50 writes", cur)
+	}
+
+	// The AOF is past the minimum size and grew from nothing: rewrite. It
+	// compacts to a single key, so it does not trigger again.
+	c.mustOK("CONFIG", "SET", "auto-aof-rewrite-percentage", "100")
+	waitInfo(t, c, "aof_rewrites:1")
+	waitInfo(t, c, "aof_rewrite_in_progress:0")
+	waitInfo(t, c, "aof_last_bgrewrite_status:ok")
+	base, _ := strconv.Atoi(infoField(c, "aof_base_size"))
+	if base == 0 || base >= 1024 {
+		t.Fatalf("aof_base_size %d after the rewrite", base)
+	}
+	time.Sleep(3 * persistenceCheckInterval)
+	waitInfo(t, c, "aof_rewrites:1")
+	if files, _ := aof.Files(path); len(files) != 2 {
+		t.Fatalf("after the rewrite the aof is %v", files)
+	}
+
+	c.mustBulk("49", "GET", "k")
+}
+
+func TestAutoRewrite_NeedsAnAOF(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	waitInfo(t, c, "aof_enabled:0")
+
+	// Without a FileAOF nothing is measured, so nothing triggers either.
+	s.SetAutoRewrite(1, 0)
+	c.mustOK("SET", "k", "v")
+	time.Sleep(3 * persistenceCheckInterval)
+	waitInfo(t, c, "aof_rewrites:0")
+	if strings.Contains(string(c.do("INFO").Bulk), "aof_current_size") {
+		t.Fatal("aof sizes reported without an aof")
+	}
+}
+
+func TestRewrite_FailureIsReported(t *testing.T) {
+	dir := t.TempDir()
+	aw, err := aof.Open(filepath.Join(dir, "appendonly.aof"))
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	s, addr, err := StartDatabases("127.0.0.1:0", store.NewDatabases(store.DefaultDatabases), aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	c := dialTest(t, addr)
+	waitInfo(t, c, "aof_last_bgrewrite_status:ok")
+
+	// With its directory gone the rewrite cannot open a new part.
+	if err := os.RemoveAll(filepath.Join(dir, aof.DirName)); err != nil {
+		t.Fatal(err)
+	}
+	c.mustOK("BGREWRITEAOF")
+	waitInfo(t, c, "aof_last_bgrewrite_status:err")
+	waitInfo(t, c, "aof_rewrites:1")
+	waitInfo(t, c, "aof_rewrite_in_progress:0")
+}
+
+func TestRewrite_RepeatedFailuresDoNotAddParts(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+	c.mustOK("SET", "k", "v")
+
+	// A non-empty directory where the new base goes fails every rewrite
+	// once it has started a part.
+	blocker := filepath.Join(filepath.Dir(path), aof.DirName, "appendonly.aof.rewrite.tmp")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		c.mustOK("BGREWRITEAOF")
+		waitInfo(t, c, "aof_rewrites:"+strconv.Itoa(i))
+		waitInfo(t, c, "aof_rewrite_in_progress:0")
+		waitInfo(t, c, "aof_last_bgrewrite_status:err")
+	}
+	if files, _ := aof.Files(path); len(files) != 2 {
+		t.Fatalf("after 5 failed rewrites the aof is %v", files)
+	}
+
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	c.mustOK("BGREWRITEAOF")
+	waitInfo(t, c, "aof_rewrites:6")
+	waitInfo(t, c, "aof_last_bgrewrite_status:ok")
+	_ = s.Close()
+	if v, _ := replayInto(t, path).Get("k"); string(v) != "v" {
+		t.Fatalf("expected k=v after replay, got %q", v)
+	}
+}
@@ -100,6 +100,14 @@ func TestBGREWRITEAOF_AlreadyInProgress_SecondReturnsError(t *testing.T) {
 	r1 := bufio.NewReader(conn1)
 	r2 := bufio.NewReader(conn2)
 
+	// Installing a rewrite only swaps the manifest, so without holding the
+	// first one back it may be over before the second request arrives.
+	release := make(chan struct{})
+	defer close(release)
+	s.rewriteMu.Lock()
+	s.testHookBeforeInstall = func() { <-release }
+	s.rewriteMu.Unlock()
+
 	// First BGREWRITEAOF
 	writeCommand(conn1, "BGREWRITEAOF")
 	line1, _ := r1.ReadString('\n')
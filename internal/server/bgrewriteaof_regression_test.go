@@ -1,10 +1,7 @@
 package server
 
 import (
-	"bufio"
 	"context"
-	"fmt"
-	"net"
 	"path/filepath"
 	"strconv"
 	"sync"
@@ -13,7 +10,6 @@ import (
 	"time"
 
 	"github.com/pranavbrkr/redigo/internal/aof"
-	"github.com/pranavbrkr/redigo/internal/protocol/resp"
 	"github.com/pranavbrkr/redigo/internal/store"
 )
 
@@ -184,51 +180,3 @@ func TestBGREWRITEAOF_DoesNotLoseConcurrentWrites(t *testing.T) {
 		}
 	}
 }
-
-// ---- test helpers ----
-
-func mustDial(t *testing.T, addr string) (net.Conn, *bufio.Reader, *bufio.Writer) {
-	t.Helper()
-	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
-	if err != nil {
-		t.Fatalf("dial: %v", err)
-	}
-	return conn, bufio.NewReader(conn), bufio.NewWriter(conn)
-}
-
-func sendCmd(conn net.Conn, w *bufio.Writer, parts ...string) error {
-	if len(parts) == 0 {
-		return fmt.Errorf("no command parts")
-	}
-
-	_ = conn.SetWriteDeadline(time.Now().Add(250 * time.Millisecond))
-	defer conn.SetWriteDeadline(time.Time{})
-
-	if err := resp.WriteArrayHeader(w, len(parts)); err != nil {
-		return err
-	}
-	for _, p := range parts {
-		if err := resp.WriteBulkString(w, []byte(p)); err != nil {
-			return err
-		}
-	}
-	return w.Flush()
-}
-
-func expectSimpleOK(conn net.Conn, r *bufio.Reader) error {
-	_ = conn.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
-	defer conn.SetReadDeadline(time.Time{})
-
-	v, err := resp.Decode(r)
-	if err != nil {
-		// Treat timeouts as a normal retry signal for the caller
-		if ne, ok := err.(net.Error); ok && ne.Timeout() {
-			return context.DeadlineExceeded
-		}
-		return err
-	}
-	if v.Type != resp.SimpleString || v.Str != "OK" {
-		return fmt.Errorf("expected +OK, got type=%v str=%q", v.Type, v.Str)
-	}
-	return nil
-}
@@ -0,0 +1,198 @@
+// internal/server/blocking.go
+package server
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// keyWaiters tracks clients blocked on keys (BLPOP and friends) so writers
+// can wake them up.
+type keyWaiters struct {
+	mu sync.Mutex
+	m  map[waitKey]map[chan struct{}]struct{}
+}
+
+// waitKey is a key within a database.
+type waitKey struct {
+	db  int
+	key string
+}
+
+// watchKeys registers interest in keys of database db. The returned channel
+// receives a value whenever one of them may have become ready; call the
+// returned function to unregister.
+func (s *Server) watchKeys(db int, keys []string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	kw := &s.waiters
+	kw.mu.Lock()
+	if kw.m == nil {
+		kw.m = make(map[waitKey]map[chan struct{}]struct{})
+	}
+	for _, k := range keys {
+		wk := waitKey{db, k}
+		set := kw.m[wk]
+		if set == nil {
+			set = make(map[chan struct{}]struct{})
+			kw.m[wk] = set
+		}
+		set[ch] = struct{}{}
+	}
+	kw.mu.Unlock()
+
+	return ch, func() {
+		kw.mu.Lock()
+		for _, k := range keys {
+			wk := waitKey{db, k}
+			if set := kw.m[wk]; set != nil {
+				delete(set, ch)
+				if len(set) == 0 {
+					delete(kw.m, wk)
+				}
+			}
+		}
+		kw.mu.Unlock()
+	}
+}
+
+// signalKeyReady wakes clients blocked on key in database db. Call it after
+// a write that may have made key ready (e.g. a push), once the write has
+// been logged.
+func (s *Server) signalKeyReady(db int, key string) {
+	kw := &s.waiters
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	for ch := range kw.m[waitKey{db, key}] {
+		notify(ch)
+	}
+}
+
+// signalDBReady wakes every client blocked on a key of database db, for
+// changes that replace its whole contents (SWAPDB).
+func (s *Server) signalDBReady(db int) {
+	kw := &s.waiters
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	for wk, set := range kw.m {
+		if wk.db != db {
+			continue
+		}
+		for ch := range set {
+			notify(ch)
+		}
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+// blockOn runs try until it reports done, waking up whenever one of keys is
+// written. try writes the reply itself when it succeeds. blockOn gives up
+// (returning false) once timeout elapses (0 means wait forever); if the
+// client disconnects it returns errCloseConn.
+func (s *Server) blockOn(c *client, keys []string, timeout time.Duration, try func() (bool, error)) (bool, error) {
+	ready, unwatch := s.watchKeys(c.dbIndex, keys)
+	defer unwatch()
+
+	// First attempt happens after registering, so a push cannot slip in
+	// between the attempt and the wait.
+	if done, err := try(); done || err != nil {
+		return done, err
+	}
+	if c.conn == nil || c.inExec {
+		// AOF loading or inside a transaction: never block
+		return false, nil
+	}
+
+	// dispatch holds execMu for reading and the key locks of the command;
+	// let transactions and writes of other clients run while this one waits.
+	s.unlockStripes(c.stripes)
+	s.execMu.RUnlock()
+	defer func() {
+		s.execMu.RLock()
+		s.lockStripes(c.stripes)
+	}()
+	retry := func() (bool, error) {
+		s.execMu.RLock()
+		defer s.execMu.RUnlock()
+		s.lockStripes(c.stripes)
+		defer s.unlockStripes(c.stripes)
+		return try()
+	}
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	gone, stopWatch := c.watchDisconnect()
+	defer stopWatch()
+
+	for {
+		select {
+		case <-ready:
+			if done, err := retry(); done || err != nil {
+				return done, err
+			}
+		case <-timer:
+			return false, nil
+		case <-gone:
+			return false, errCloseConn
+		}
+	}
+}
+
+// watchDisconnect reports (by closing the returned channel) when the peer
+// closes the connection while the client is blocked. The returned stop
+// function must be called before the connection is read again.
+func (c *client) watchDisconnect() (<-chan struct{}, func()) {
+	gone := make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		// Peek returns early with data if the client pipelined more
+		// commands; those are simply served after we unblock.
+		if _, err := c.r.Peek(1); err != nil {
+			select {
+			case <-stop:
+			default:
+				close(gone)
+			}
+		}
+	}()
+
+	return gone, func() {
+		close(stop)
+		_ = c.conn.SetReadDeadline(time.Now())
+		<-done
+		_ = c.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// parseTimeout parses a blocking timeout in (possibly fractional) seconds.
+func parseTimeout(arg string) (time.Duration, string) {
+	secs, err := strconv.ParseFloat(arg, 64)
+	if err != nil || secs != secs {
+		return 0, "ERR timeout is not a float or out of range"
+	}
+	if secs < 0 {
+		return 0, "ERR timeout is negative"
+	}
+	if secs > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, "ERR timeout is out of range"
+	}
+	return time.Duration(secs * float64(time.Second)), ""
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestCommandTable_ArityIsEnforcedCentrally(t *testing.T) {
+	for _, cmd := range commandList {
+		if cmd.fn == nil {
+			t.Fatalf("command %s has no handler", cmd.name)
+		}
+		if cmd.arity == 0 {
+			t.Fatalf("command %s has zero arity", cmd.name)
+		}
+	}
+
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustErr("wrong number of arguments for 'get'", "GET")
+	c.mustErr("wrong number of arguments for 'ttl'", "TTL", "a", "b")
+	c.mustErr("wrong number of arguments for 'del'", "DEL")
+}
+
+func TestCOMMAND_INFO_ReturnsKeySpecsAndNilForUnknown(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	v := c.do("COMMAND", "INFO", "get", "nope")
+	if v.Type != resp.Array || len(v.Array) != 2 {
+		t.Fatalf("expected 2 entries, got %s", fmtValue(v))
+	}
+
+	get := v.Array[0]
+	if len(get.Array) != 6 {
+		t.Fatalf("expected 6-field info for get, got %s", fmtValue(get))
+	}
+	if string(get.Array[0].Bulk) != "get" || get.Array[1].Int != 2 {
+		t.Fatalf("unexpected name/arity: %s", fmtValue(get))
+	}
+	if get.Array[3].Int != 1 || get.Array[4].Int != 1 || get.Array[5].Int != 1 {
+		t.Fatalf("unexpected key positions: %s", fmtValue(get))
+	}
+
+	if v.Array[1].Type != resp.Array || v.Array[1].Array != nil {
+		t.Fatalf("expected nil entry for unknown command, got %s", fmtValue(v.Array[1]))
+	}
+}
+
+func TestCOMMAND_DOCS_ReturnsSummaryAndGroup(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	v := c.do("COMMAND", "DOCS", "set")
+	if v.Type != resp.Array || len(v.Array) != 2 {
+		t.Fatalf("expected name + doc map, got %s", fmtValue(v))
+	}
+	if string(v.Array[0].Bulk) != "set" {
+		t.Fatalf("expected set, got %s", fmtValue(v.Array[0]))
+	}
+	doc := bulkStrings(v.Array[1])
+	if len(doc) != 4 || doc[0] != "summary" || doc[2] != "group" || doc[3] != "string" {
+		t.Fatalf("unexpected docs: %q", doc)
+	}
+}
+
+func TestLoader_ReplaysThroughCommandTable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	future := time.Now().Add(time.Hour).Unix()
+	_ = aw.Append("SET", []string{"a", "1"})
+	_ = aw.Append("SET", []string{"b", "2"})
+	_ = aw.Append("DEL", []string{"a"})
+	_ = aw.Append("EXPIREAT", []string{"b", strconv.FormatInt(future, 10)})
+	_ = aw.Append("NOSUCHCMD", []string{"x"}) // ignored: unknown
+	_ = aw.Append("BGREWRITEAOF", nil)        // ignored: not a write
+	_ = aw.Close()
+
+	st := store.New()
+	if err := aof.Replay(path, NewLoader(st).Apply); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	if st.Exists("a") {
+		t.Fatal("expected a to be deleted")
+	}
+	if v, ok := st.Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("expected b=2, got ok=%v v=%q", ok, v)
+	}
+	if ttl := st.TTL("b"); ttl <= 0 {
+		t.Fatalf("expected b to have ttl, got %d", ttl)
+	}
+}
+
+func TestLoader_FailsOnErrorReply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	_ = aw.Append("SET", []string{"a", "1"})
+	_ = aw.Append("SET", []string{"too", "many", "args"})
+	_ = aw.Append("SET", []string{"b", "2"})
+	_ = aw.Close()
+
+	st := store.New()
+	err = aof.Replay(path, NewLoader(st).Apply)
+	if err == nil || !strings.Contains(err.Error(), "apply SET: ERR syntax error") {
+		t.Fatalf("expected replay to fail on the malformed SET, got %v", err)
+	}
+	if st.Exists("b") {
+		t.Fatal("expected replay to stop at the malformed SET")
+	}
+}
@@ -0,0 +1,575 @@
+// internal/server/commands.go
+package server
+
+import (
+	"bufio"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+// commandFunc executes a command for client c. args excludes the command name.
+// A non-nil error means the connection can no longer be served (e.g. the AOF
+// write failed) and the caller must close it after flushing the reply.
+type commandFunc func(s *Server, c *client, args []string) error
+
+// command is a single entry of the command table.
+//
+// arity follows the Redis convention: it counts the command name itself,
+// a positive value means "exactly N", a negative value means "at least -N".
+// firstKey/lastKey/step describe key positions (1-based, lastKey -1 means
+// "until the last argument"); all zero means the command takes no keys.
+type command struct {
+	name     string
+	arity    int
+	flags    []string
+	firstKey int
+	lastKey  int
+	step     int
+	group    string
+	summary  string
+	fn       commandFunc
+}
+
+func (cmd *command) hasFlag(flag string) bool {
+	for _, f := range cmd.flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the key arguments of an invocation of cmd according to its
+// key positions. args excludes the command name.
+func (cmd *command) keys(args []string) []string {
+	if cmd.firstKey == 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last += len(args) + 1
+	}
+	var out []string
+	for i := cmd.firstKey; i <= last && i <= len(args); i += cmd.step {
+		out = append(out, args[i-1])
+	}
+	return out
+}
+
+// arityOK reports whether argc (including the command name) satisfies arity.
+func (cmd *command) arityOK(argc int) bool {
+	if cmd.arity >= 0 {
+		return argc == cmd.arity
+	}
+	return argc >= -cmd.arity
+}
+
+// errCloseConn is returned by command handlers that already wrote an error
+// reply and need the connection to be dropped.
+var errCloseConn = errors.New("close connection")
+
+var (
+	commandTable = make(map[string]*command)
+	commandList  []*command // registration order, used for COMMAND output
+)
+
+func registerCommands(cmds ...*command) {
+	for _, cmd := range cmds {
+		upper := strings.ToUpper(cmd.name)
+		if _, dup := commandTable[upper]; dup {
+			panic("duplicate command registration: " + cmd.name)
+		}
+		commandTable[upper] = cmd
+		commandList = append(commandList, cmd)
+	}
+}
+
+func lookupCommand(name string) (*command, bool) {
+	cmd, ok := commandTable[strings.ToUpper(name)]
+	return cmd, ok
+}
+
+func init() {
+	registerCommands(
+		&command{name: "ping", arity: -1, flags: []string{"fast"},
+			group: "connection", summary: "Returns the server's liveliness response.", fn: cmdPing},
+		&command{name: "echo", arity: 2, flags: []string{"fast"},
+			group: "connection", summary: "Returns the given string.", fn: cmdEcho},
+		&command{name: "set", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets the string value of a key.", fn: cmdSet},
+		&command{name: "get", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key.", fn: cmdGet},
+		&command{name: "incr", arity: 2, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", fn: cmdIncr},
+		&command{name: "decr", arity: 2, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", fn: cmdDecr},
+		&command{name: "incrby", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", fn: cmdIncrBy},
+		&command{name: "decrby", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", fn: cmdDecrBy},
+		&command{name: "incrbyfloat", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", fn: cmdIncrByFloat},
+		&command{name: "append", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", fn: cmdAppend},
+		&command{name: "strlen", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the length of a string value.", fn: cmdStrLen},
+		&command{name: "getrange", arity: 4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns a substring of the string stored at a key.", fn: cmdGetRange},
+		&command{name: "setrange", arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", fn: cmdSetRange},
+		&command{name: "getdel", arity: 2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key after deleting the key.", fn: cmdGetDel},
+		&command{name: "getex", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the string value of a key after setting its expiration time.", fn: cmdGetEx},
+		&command{name: "getset", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Returns the previous string value of a key after setting it to a new value.", fn: cmdGetSet},
+		&command{name: "setnx", arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Set the string value of a key only when the key doesn't exist.", fn: cmdSetNX},
+		&command{name: "setex", arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.", fn: cmdSetEx},
+		&command{name: "psetex", arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "string", summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.", fn: cmdPSetEx},
+		&command{name: "mget", arity: -2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "string", summary: "Atomically returns the string values of one or more keys.", fn: cmdMGet},
+		&command{name: "mset", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 2,
+			group: "string", summary: "Atomically creates or modifies the string values of one or more keys.", fn: cmdMSet},
+		&command{name: "msetnx", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 2,
+			group: "string", summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", fn: cmdMSetNX},
+		&command{name: "lcs", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "string", summary: "Finds the longest common substring.", fn: cmdLCS},
+		&command{name: "del", arity: -2, flags: []string{"write"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Deletes one or more keys.", fn: cmdDel},
+		&command{name: "exists", arity: -2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Determines whether one or more keys exist.", fn: cmdExists},
+		&command{name: "expire", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key in seconds.", fn: cmdExpire},
+		&command{name: "ttl", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time in seconds of a key.", fn: cmdTTL},
+		&command{name: "info", arity: 1, flags: []string{"readonly"},
+			group: "server", summary: "Returns information and statistics about the server.", fn: cmdInfo},
+		&command{name: "expireat", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key to a Unix timestamp.", fn: cmdExpireAt},
+		&command{name: "pexpire", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key in milliseconds.", fn: cmdPExpire},
+		&command{name: "pexpireat", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", fn: cmdPExpireAt},
+		&command{name: "pttl", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time in milliseconds of a key.", fn: cmdPTTL},
+		&command{name: "expiretime", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time of a key as a Unix timestamp.", fn: cmdExpireTime},
+		&command{name: "pexpiretime", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", fn: cmdPExpireTime},
+		&command{name: "persist", arity: 2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Removes the expiration time of a key.", fn: cmdPersist},
+		&command{name: "keys", arity: 2, flags: []string{"readonly"},
+			group: "generic", summary: "Returns all key names that match a pattern.", fn: cmdKeys},
+		&command{name: "scan", arity: -2, flags: []string{"readonly"},
+			group: "generic", summary: "Iterates over the key names in the database.", fn: cmdScan},
+		&command{name: "move", arity: 3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Moves a key to another database.", fn: cmdMove},
+		&command{name: "rename", arity: 3, flags: []string{"write"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Renames a key and overwrites the destination.", fn: cmdRename},
+		&command{name: "renamenx", arity: 3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Renames a key only when the target key name doesn't exist.", fn: cmdRenameNX},
+		&command{name: "copy", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "generic", summary: "Copies the value of a key to a new key.", fn: cmdCopy},
+		&command{name: "type", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "generic", summary: "Determines the type of value stored at a key.", fn: cmdType},
+		&command{name: "touch", arity: -2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", fn: cmdTouch},
+		&command{name: "randomkey", arity: 1, flags: []string{"readonly"},
+			group: "generic", summary: "Returns a random key name from the database.", fn: cmdRandomKey},
+		&command{name: "unlink", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "generic", summary: "Asynchronously deletes one or more keys.", fn: cmdUnlink},
+		&command{name: "object", arity: -2, flags: []string{"readonly"}, firstKey: 2, lastKey: 2, step: 1,
+			group: "generic", summary: "Returns information about a key's value.", fn: cmdObject},
+
+		// databases
+		&command{name: "select", arity: 2, flags: []string{"fast"},
+			group: "connection", summary: "Changes the selected database.", fn: cmdSelect},
+		&command{name: "swapdb", arity: 3, flags: []string{"write", "fast"},
+			group: "server", summary: "Swaps two Redis databases.", fn: cmdSwapDB},
+		&command{name: "dbsize", arity: 1, flags: []string{"readonly", "fast"},
+			group: "server", summary: "Returns the number of keys in the database.", fn: cmdDBSize},
+		&command{name: "flushdb", arity: -1, flags: []string{"write"},
+			group: "server", summary: "Remove all keys from the current database.", fn: cmdFlushDB},
+		&command{name: "flushall", arity: -1, flags: []string{"write"},
+			group: "server", summary: "Removes all keys from all databases.", fn: cmdFlushAll},
+		&command{name: "bgrewriteaof", arity: 1, flags: []string{"admin", "noscript"},
+			group: "server", summary: "Asynchronously rewrites the append-only file to disk.", fn: cmdBgRewriteAOF},
+		&command{name: "save", arity: 1, flags: []string{"admin", "noscript"},
+			group: "server", summary: "Synchronously saves the database(s) to disk.", fn: cmdSave},
+		&command{name: "bgsave", arity: -1, flags: []string{"admin", "noscript"},
+			group: "server", summary: "Asynchronously saves the database(s) to disk.", fn: cmdBgSave},
+		&command{name: "lastsave", arity: 1, flags: []string{"loading", "stale", "fast"},
+			group: "server", summary: "Returns the Unix timestamp of the last successful save to disk.", fn: cmdLastSave},
+		&command{name: "command", arity: -1, flags: []string{"loading", "stale"},
+			group: "server", summary: "Returns detailed information about all commands.", fn: cmdCommand},
+		&command{name: "config", arity: -2, flags: []string{"admin", "noscript", "loading", "stale"},
+			group: "server", summary: "Gets or sets configuration parameters.", fn: cmdConfig},
+
+		// transactions
+		&command{name: "multi", arity: 1, flags: []string{"noscript", "loading", "stale", "fast"},
+			group: "transactions", summary: "Starts a transaction.", fn: cmdMulti},
+		&command{name: "exec", arity: 1, flags: []string{"noscript", "loading", "stale"},
+			group: "transactions", summary: "Executes all commands in a transaction.", fn: cmdExec},
+		&command{name: "discard", arity: 1, flags: []string{"noscript", "loading", "stale", "fast"},
+			group: "transactions", summary: "Discards a transaction.", fn: cmdDiscard},
+		&command{name: "watch", arity: -2, flags: []string{"noscript", "loading", "stale", "fast"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "transactions", summary: "Monitors changes to keys to determine the execution of a transaction.", fn: cmdWatch},
+		&command{name: "unwatch", arity: 1, flags: []string{"noscript", "loading", "stale", "fast"},
+			group: "transactions", summary: "Forgets about watched keys of a transaction.", fn: cmdUnwatch},
+
+		// pubsub
+		&command{name: "subscribe", arity: -2, flags: []string{"pubsub", "noscript", "loading", "stale"},
+			group: "pubsub", summary: "Listens for messages published to channels.", fn: cmdSubscribe},
+		&command{name: "psubscribe", arity: -2, flags: []string{"pubsub", "noscript", "loading", "stale"},
+			group: "pubsub", summary: "Listens for messages published to channels that match one or more patterns.", fn: cmdPSubscribe},
+		&command{name: "ssubscribe", arity: -2, flags: []string{"pubsub", "noscript", "loading", "stale"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "pubsub", summary: "Listens for messages published to shard channels.", fn: cmdSSubscribe},
+		&command{name: "unsubscribe", arity: -1, flags: []string{"pubsub", "noscript", "loading", "stale"},
+			group: "pubsub", summary: "Stops listening to messages posted to channels.", fn: cmdUnsubscribe},
+		&command{name: "punsubscribe", arity: -1, flags: []string{"pubsub", "noscript", "loading", "stale"},
+			group: "pubsub", summary: "Stops listening to messages published to channels that match one or more patterns.", fn: cmdPUnsubscribe},
+		&command{name: "sunsubscribe", arity: -1, flags: []string{"pubsub", "noscript", "loading", "stale"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "pubsub", summary: "Stops listening to messages posted to shard channels.", fn: cmdSUnsubscribe},
+		&command{name: "publish", arity: 3, flags: []string{"pubsub", "loading", "stale", "fast"},
+			group: "pubsub", summary: "Posts a message to a channel.", fn: cmdPublish},
+		&command{name: "spublish", arity: 3, flags: []string{"pubsub", "loading", "stale", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "pubsub", summary: "Post a message to a shard channel", fn: cmdSPublish},
+		&command{name: "pubsub", arity: -2, flags: []string{"pubsub", "loading", "stale"},
+			group: "pubsub", summary: "Inspects the state of the Pub/Sub subsystem.", fn: cmdPubSub},
+
+		// hash
+		&command{name: "hset", arity: -4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Creates or modifies the value of a field in a hash.", fn: cmdHSet},
+		&command{name: "hmset", arity: -4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Sets the values of multiple fields.", fn: cmdHMSet},
+		&command{name: "hsetnx", arity: 4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Sets the value of a field in a hash only when the field doesn't exist.", fn: cmdHSetNX},
+		&command{name: "hget", arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the value of a field in a hash.", fn: cmdHGet},
+		&command{name: "hmget", arity: -3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the values of all fields in a hash.", fn: cmdHMGet},
+		&command{name: "hdel", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Deletes one or more fields and their values from a hash.", fn: cmdHDel},
+		&command{name: "hexists", arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Determines whether a field exists in a hash.", fn: cmdHExists},
+		&command{name: "hlen", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the number of fields in a hash.", fn: cmdHLen},
+		&command{name: "hstrlen", arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns the length of the value of a field.", fn: cmdHStrLen},
+		&command{name: "hkeys", arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all fields in a hash.", fn: cmdHKeys},
+		&command{name: "hvals", arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all values in a hash.", fn: cmdHVals},
+		&command{name: "hgetall", arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Returns all fields and values in a hash.", fn: cmdHGetAll},
+		&command{name: "hincrby", arity: 4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Increments the integer value of a field in a hash by a number.", fn: cmdHIncrBy},
+		&command{name: "hincrbyfloat", arity: 4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Increments the floating point value of a field by a number.", fn: cmdHIncrByFloat},
+		&command{name: "hscan", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "hash", summary: "Iterates over fields and values of a hash.", fn: cmdHScan},
+
+		// list
+		&command{name: "lpush", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Prepends one or more elements to a list.", fn: cmdLPush},
+		&command{name: "rpush", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Appends one or more elements to a list.", fn: cmdRPush},
+		&command{name: "lpushx", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Prepends elements to a list only when the list exists.", fn: cmdLPushX},
+		&command{name: "rpushx", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Appends elements to a list only when the list exists.", fn: cmdRPushX},
+		&command{name: "lpop", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the first elements in a list after removing it.", fn: cmdLPop},
+		&command{name: "rpop", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns and removes the last elements of a list.", fn: cmdRPop},
+		&command{name: "llen", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the length of a list.", fn: cmdLLen},
+		&command{name: "lrange", arity: 4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns a range of elements from a list.", fn: cmdLRange},
+		&command{name: "lindex", arity: 3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns an element from a list by its index.", fn: cmdLIndex},
+		&command{name: "lset", arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Sets the value of an element in a list by its index.", fn: cmdLSet},
+		&command{name: "lrem", arity: 4, flags: []string{"write"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Removes elements from a list.", fn: cmdLRem},
+		&command{name: "ltrim", arity: 4, flags: []string{"write"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Removes elements from both ends a list.", fn: cmdLTrim},
+		&command{name: "linsert", arity: 5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Inserts an element before or after another element in a list.", fn: cmdLInsert},
+		&command{name: "lpos", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "list", summary: "Returns the index of matching elements in a list.", fn: cmdLPos},
+		&command{name: "lmove", arity: 5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "list", summary: "Returns an element after popping it from one list and pushing it to another.", fn: cmdLMove},
+		&command{name: "rpoplpush", arity: 3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "list", summary: "Returns the last element of a list after removing and pushing it to another list.", fn: cmdRPopLPush},
+		&command{name: "blpop", arity: -3, flags: []string{"write", "blocking"}, firstKey: 1, lastKey: -2, step: 1,
+			group: "list", summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise.", fn: cmdBLPop},
+		&command{name: "brpop", arity: -3, flags: []string{"write", "blocking"}, firstKey: 1, lastKey: -2, step: 1,
+			group: "list", summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise.", fn: cmdBRPop},
+		&command{name: "blmove", arity: 6, flags: []string{"write", "denyoom", "blocking"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "list", summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise.", fn: cmdBLMove},
+		&command{name: "brpoplpush", arity: 4, flags: []string{"write", "denyoom", "blocking"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "list", summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise.", fn: cmdBRPopLPush},
+
+		// set
+		&command{name: "sadd", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", fn: cmdSAdd},
+		&command{name: "srem", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", fn: cmdSRem},
+		&command{name: "sismember", arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Determines whether a member belongs to a set.", fn: cmdSIsMember},
+		&command{name: "smismember", arity: -3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Determines whether multiple members belong to a set.", fn: cmdSMIsMember},
+		&command{name: "smembers", arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns all members of a set.", fn: cmdSMembers},
+		&command{name: "scard", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns the number of members in a set.", fn: cmdSCard},
+		&command{name: "spop", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", fn: cmdSPop},
+		&command{name: "srandmember", arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Get one or multiple random members from a set.", fn: cmdSRandMember},
+		&command{name: "smove", arity: 4, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "set", summary: "Moves a member from one set to another.", fn: cmdSMove},
+		&command{name: "sinter", arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the intersect of multiple sets.", fn: cmdSInter},
+		&command{name: "sunion", arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the union of multiple sets.", fn: cmdSUnion},
+		&command{name: "sdiff", arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Returns the difference of multiple sets.", fn: cmdSDiff},
+		&command{name: "sinterstore", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the intersect of multiple sets in a key.", fn: cmdSInterStore},
+		&command{name: "sunionstore", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the union of multiple sets in a key.", fn: cmdSUnionStore},
+		&command{name: "sdiffstore", arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1,
+			group: "set", summary: "Stores the difference of multiple sets in a key.", fn: cmdSDiffStore},
+		&command{name: "sintercard", arity: -3, flags: []string{"readonly", "movablekeys"}, firstKey: 0, lastKey: 0, step: 0,
+			group: "set", summary: "Returns the number of members of the intersect of multiple sets.", fn: cmdSInterCard},
+		&command{name: "sscan", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "set", summary: "Iterates over members of a set.", fn: cmdSScan},
+
+		// sorted set
+		&command{name: "zadd", arity: -4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", fn: cmdZAdd},
+		&command{name: "zincrby", arity: 4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Increments the score of a member in a sorted set.", fn: cmdZIncrBy},
+		&command{name: "zrem", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", fn: cmdZRem},
+		&command{name: "zscore", arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the score of a member in a sorted set.", fn: cmdZScore},
+		&command{name: "zcard", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the number of members in a sorted set.", fn: cmdZCard},
+		&command{name: "zcount", arity: 4, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the count of members in a sorted set that have scores within a range.", fn: cmdZCount},
+		&command{name: "zrange", arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns members in a sorted set within a range of indexes, scores or lexicographical values.", fn: cmdZRange},
+		&command{name: "zrangestore", arity: -5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1,
+			group: "sorted-set", summary: "Stores a range of members from sorted set in a key.", fn: cmdZRangeStore},
+		&command{name: "zrank", arity: -3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the index of a member in a sorted set ordered by ascending scores.", fn: cmdZRank},
+		&command{name: "zrevrank", arity: -3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the index of a member in a sorted set ordered by descending scores.", fn: cmdZRevRank},
+		&command{name: "zpopmin", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", fn: cmdZPopMin},
+		&command{name: "zpopmax", arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", fn: cmdZPopMax},
+		&command{name: "bzpopmin", arity: -3, flags: []string{"write", "fast", "blocking"}, firstKey: 1, lastKey: -2, step: 1,
+			group: "sorted-set", summary: "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise.", fn: cmdBZPopMin},
+		&command{name: "bzpopmax", arity: -3, flags: []string{"write", "fast", "blocking"}, firstKey: 1, lastKey: -2, step: 1,
+			group: "sorted-set", summary: "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member is available otherwise.", fn: cmdBZPopMax},
+		&command{name: "zunionstore", arity: -4, flags: []string{"write", "denyoom", "movablekeys"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Stores the union of multiple sorted sets in a key.", fn: cmdZUnionStore},
+		&command{name: "zinterstore", arity: -4, flags: []string{"write", "denyoom", "movablekeys"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Stores the intersect of multiple sorted sets in a key.", fn: cmdZInterStore},
+		&command{name: "zscan", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "sorted-set", summary: "Iterates over members and scores of a sorted set.", fn: cmdZScan},
+
+		// stream
+		&command{name: "xadd", arity: -5, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", fn: cmdXAdd},
+		&command{name: "xtrim", arity: -4, flags: []string{"write"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Deletes messages from the beginning of a stream.", fn: cmdXTrim},
+		&command{name: "xdel", arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Returns the number of messages after removing them from a stream.", fn: cmdXDel},
+		&command{name: "xlen", arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Return the number of messages in a stream.", fn: cmdXLen},
+		&command{name: "xrange", arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Returns the messages from a stream within a range of IDs.", fn: cmdXRange},
+		&command{name: "xrevrange", arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Returns the messages from a stream within a range of IDs in reverse order.", fn: cmdXRevRange},
+		&command{name: "xread", arity: -4, flags: []string{"readonly", "blocking", "movablekeys"}, firstKey: 0, lastKey: 0, step: 0,
+			group: "stream", summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", fn: cmdXRead},
+		&command{name: "xsetid", arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "An internal command for replicating stream values.", fn: cmdXSetID},
+		&command{name: "xgroup", arity: -2, flags: []string{"write", "denyoom"}, firstKey: 2, lastKey: 2, step: 1,
+			group: "stream", summary: "Creates, destroys or changes consumer groups and their consumers.", fn: cmdXGroup},
+		&command{name: "xreadgroup", arity: -7, flags: []string{"write", "blocking", "movablekeys"}, firstKey: 0, lastKey: 0, step: 0,
+			group: "stream", summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.", fn: cmdXReadGroup},
+		&command{name: "xack", arity: -4, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.", fn: cmdXAck},
+		&command{name: "xpending", arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Returns the information and entries from a stream consumer group's pending entries list.", fn: cmdXPending},
+		&command{name: "xclaim", arity: -6, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.", fn: cmdXClaim},
+		&command{name: "xautoclaim", arity: -6, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1,
+			group: "stream", summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.", fn: cmdXAutoClaim},
+		&command{name: "xinfo", arity: -2, flags: []string{"readonly"}, firstKey: 2, lastKey: 2, step: 1,
+			group: "stream", summary: "Returns information about a stream, its consumer groups or a group's consumers.", fn: cmdXInfo},
+	)
+}
+
+// dispatch looks up and runs a single command, enforcing arity in one place.
+// Inside MULTI the command is queued instead (see multi.go).
+//
+// Every command runs holding execMu for reading, EXEC and SAVE for writing,
+// so a transaction never interleaves with commands of other clients and
+// SAVE snapshots every database at one point in time. Write commands also
+// hold the key lock stripes of the keys they write until they have logged.
+func (s *Server) dispatch(c *client, name string, args []string) error {
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		c.flagTransaction()
+		writeUnknownCommand(c, name)
+		return nil
+	}
+	if !cmd.arityOK(len(args) + 1) {
+		c.flagTransaction()
+		writeWrongArgs(c.w, cmd.name)
+		return nil
+	}
+
+	if cmd.name == "exec" || cmd.name == "save" {
+		s.execMu.Lock()
+		defer s.execMu.Unlock()
+	} else {
+		s.execMu.RLock()
+		defer s.execMu.RUnlock()
+	}
+	if c.subscribed() && !allowedWhenSubscribed(cmd) {
+		c.flagTransaction()
+		_ = resp.WriteError(c.w, "ERR Can't execute '"+cmd.name+
+			"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING are allowed in this context")
+		return nil
+	}
+	if c.tx != nil && !runsInMulti(cmd) {
+		return s.queueCommand(c, cmd, args)
+	}
+
+	if s.maxmemory.Load() > 0 {
+		ok, err := s.evictIfNeeded()
+		if err != nil {
+			return writeAOFError(c.w)
+		}
+		if !ok && cmd.hasFlag("denyoom") {
+			_ = resp.WriteError(c.w, msgOOM)
+			return nil
+		}
+	}
+	if cmd.hasFlag("write") {
+		c.stripes = writeStripes(cmd, args)
+		s.lockStripes(c.stripes)
+		defer func() {
+			s.unlockStripes(c.stripes)
+			c.stripes = nil
+		}()
+	}
+	c.cmd, c.args = cmd, args
+	return cmd.fn(s, c, args)
+}
+
+func writeUnknownCommand(c *client, name string) {
+	_ = resp.WriteError(c.w, "ERR unknown command '"+strings.ToLower(name)+"'")
+}
+
+// ---- COMMAND ----
+
+func cmdCommand(s *Server, c *client, args []string) error {
+	if len(args) == 0 {
+		_ = resp.WriteArrayHeader(c.w, len(commandList))
+		for _, cmd := range commandList {
+			writeCommandInfo(c.w, cmd)
+		}
+		return nil
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "COUNT":
+		if len(args) != 1 {
+			writeWrongArgs(c.w, "command|count")
+			return nil
+		}
+		_ = resp.WriteInteger(c.w, int64(len(commandList)))
+
+	case "INFO":
+		names := args[1:]
+		if len(names) == 0 {
+			_ = resp.WriteArrayHeader(c.w, len(commandList))
+			for _, cmd := range commandList {
+				writeCommandInfo(c.w, cmd)
+			}
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, len(names))
+		for _, name := range names {
+			cmd, ok := lookupCommand(name)
+			if !ok {
+				_ = resp.WriteNullArray(c.w)
+				continue
+			}
+			writeCommandInfo(c.w, cmd)
+		}
+
+	case "DOCS":
+		var cmds []*command
+		if len(args) == 1 {
+			cmds = append(cmds, commandList...)
+			sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+		} else {
+			for _, name := range args[1:] {
+				if cmd, ok := lookupCommand(name); ok {
+					cmds = append(cmds, cmd)
+				}
+			}
+		}
+		_ = resp.WriteArrayHeader(c.w, 2*len(cmds))
+		for _, cmd := range cmds {
+			_ = resp.WriteBulkString(c.w, []byte(cmd.name))
+			writeCommandDocs(c.w, cmd)
+		}
+
+	default:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try COMMAND HELP.")
+	}
+	return nil
+}
+
+// writeCommandInfo writes the COMMAND / COMMAND INFO reply for one command:
+// name, arity, flags, first key, last key, step.
+func writeCommandInfo(w *bufio.Writer, cmd *command) {
+	_ = resp.WriteArrayHeader(w, 6)
+	_ = resp.WriteBulkString(w, []byte(cmd.name))
+	_ = resp.WriteInteger(w, int64(cmd.arity))
+
+	_ = resp.WriteArrayHeader(w, len(cmd.flags))
+	for _, f := range cmd.flags {
+		_ = resp.WriteSimpleString(w, f)
+	}
+
+	_ = resp.WriteInteger(w, int64(cmd.firstKey))
+	_ = resp.WriteInteger(w, int64(cmd.lastKey))
+	_ = resp.WriteInteger(w, int64(cmd.step))
+}
+
+// writeCommandDocs writes the COMMAND DOCS map for one command as a flat array.
+func writeCommandDocs(w *bufio.Writer, cmd *command) {
+	_ = resp.WriteArrayHeader(w, 4)
+	_ = resp.WriteBulkString(w, []byte("summary"))
+	_ = resp.WriteBulkString(w, []byte(cmd.summary))
+	_ = resp.WriteBulkString(w, []byte("group"))
+	_ = resp.WriteBulkString(w, []byte(cmd.group))
+}
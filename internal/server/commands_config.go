@@ -0,0 +1,208 @@
+// internal/server/commands_config.go
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/glob"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// configParam is a parameter exposed through CONFIG GET / CONFIG SET.
+// parse validates a new value and returns the function that applies it, so
+// a CONFIG SET with several parameters changes nothing unless all of them
+// are valid.
+type configParam struct {
+	name  string
+	get   func(s *Server) string
+	parse func(val string) (apply func(s *Server), errMsg string)
+}
+
+var configParams = []configParam{
+	{
+		name: "maxmemory",
+		get:  func(s *Server) string { return strconv.FormatInt(s.maxmemory.Load(), 10) },
+		parse: func(val string) (func(*Server), string) {
+			n, err := ParseMemory(val)
+			if err != nil {
+				return nil, err.Error()
+			}
+			return func(s *Server) { s.maxmemory.Store(n) }, ""
+		},
+	},
+	{
+		name: "maxmemory-policy",
+		get: func(s *Server) string {
+			return store.EvictionPolicy(s.maxmemoryPolicy.Load()).String()
+		},
+		parse: func(val string) (func(*Server), string) {
+			p, ok := store.ParseEvictionPolicy(strings.ToLower(val))
+			if !ok {
+				return nil, "argument must be one of: noeviction, allkeys-lru, allkeys-lfu, allkeys-random, " +
+					"volatile-lru, volatile-lfu, volatile-random, volatile-ttl"
+			}
+			return func(s *Server) { s.maxmemoryPolicy.Store(uint32(p)) }, ""
+		},
+	},
+	{
+		name: "save",
+		get: func(s *Server) string {
+			s.saveMu.Lock()
+			defer s.saveMu.Unlock()
+			return formatSaveParams(s.saveParams)
+		},
+		parse: func(val string) (func(*Server), string) {
+			params, err := ParseSaveParams(val)
+			if err != nil {
+				return nil, err.Error()
+			}
+			return func(s *Server) {
+				s.saveMu.Lock()
+				s.saveParams = params
+				s.saveMu.Unlock()
+			}, ""
+		},
+	},
+	{
+		name: "auto-aof-rewrite-percentage",
+		get:  func(s *Server) string { return strconv.FormatInt(s.autoRewritePerc.Load(), 10) },
+		parse: func(val string) (func(*Server), string) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || n < 0 {
+				return nil, "argument must be a non-negative integer"
+			}
+			return func(s *Server) { s.autoRewritePerc.Store(n) }, ""
+		},
+	},
+	{
+		name: "auto-aof-rewrite-min-size",
+		get:  func(s *Server) string { return strconv.FormatInt(s.autoRewriteMinSize.Load(), 10) },
+		parse: func(val string) (func(*Server), string) {
+			n, err := ParseMemory(val)
+			if err != nil {
+				return nil, err.Error()
+			}
+			return func(s *Server) { s.autoRewriteMinSize.Store(n) }, ""
+		},
+	},
+	{
+		name: "notify-keyspace-events",
+		get:  func(s *Server) string { return formatNotifyFlags(s.notifyFlags.Load()) },
+		parse: func(val string) (func(*Server), string) {
+			flags, ok := parseNotifyFlags(val)
+			if !ok {
+				return nil, "Invalid event class character. Use 'Ag$lshzxetKE'."
+			}
+			return func(s *Server) { s.notifyFlags.Store(flags) }, ""
+		},
+	},
+	{
+		// Only the pubsub class exists: normal clients are never pushed
+		// data they did not ask for.
+		name: "client-output-buffer-limit",
+		get:  func(s *Server) string { return s.pubsubLimit.String() },
+		parse: func(val string) (func(*Server), string) {
+			const usage = "argument must be 'pubsub <hard> <soft> <soft seconds>'"
+			f := strings.Fields(val)
+			if len(f) != 4 || !strings.EqualFold(f[0], "pubsub") {
+				return nil, usage
+			}
+			hard, err1 := ParseMemory(f[1])
+			soft, err2 := ParseMemory(f[2])
+			secs, err3 := strconv.ParseInt(f[3], 10, 64)
+			if err1 != nil || err2 != nil || err3 != nil || secs < 0 {
+				return nil, usage
+			}
+			return func(s *Server) {
+				s.pubsubLimit.hard.Store(hard)
+				s.pubsubLimit.soft.Store(soft)
+				s.pubsubLimit.softSeconds.Store(secs)
+			}, ""
+		},
+	},
+}
+
+func lookupConfigParam(name string) (*configParam, bool) {
+	for i := range configParams {
+		if strings.EqualFold(configParams[i].name, name) {
+			return &configParams[i], true
+		}
+	}
+	return nil, false
+}
+
+func cmdConfig(s *Server, c *client, args []string) error {
+	sub := strings.ToUpper(args[0])
+	switch sub {
+	case "HELP":
+		writeStringArray(c.w, []string{
+			"CONFIG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"GET <pattern>",
+			"    Return parameters matching the glob-like <pattern> and their values.",
+			"SET <directive> <value>",
+			"    Set the configuration <directive> to <value>.",
+		})
+		return nil
+	case "GET":
+		if len(args) < 2 {
+			writeWrongArgs(c.w, "config|get")
+			return nil
+		}
+		return configGet(s, c, args[1:])
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			writeWrongArgs(c.w, "config|set")
+			return nil
+		}
+		return configSet(s, c, args[1:])
+	default:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try CONFIG HELP.")
+		return nil
+	}
+}
+
+// configGet replies with a flat name/value array of the parameters matching
+// any of patterns.
+func configGet(s *Server, c *client, patterns []string) error {
+	var out []string
+	for _, p := range configParams {
+		for _, pat := range patterns {
+			if glob.Match(strings.ToLower(pat), p.name) {
+				out = append(out, p.name, p.get(s))
+				break
+			}
+		}
+	}
+	writeStringArray(c.w, out)
+	return nil
+}
+
+func configSet(s *Server, c *client, pairs []string) error {
+	applies := make([]func(*Server), 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		p, ok := lookupConfigParam(pairs[i])
+		if !ok {
+			_ = resp.WriteError(c.w, "ERR Unknown option or number of arguments for CONFIG SET - '"+pairs[i]+"'")
+			return nil
+		}
+		apply, msg := p.parse(pairs[i+1])
+		if apply == nil {
+			_ = resp.WriteError(c.w, "ERR CONFIG SET failed (possibly related to argument '"+p.name+"') - "+msg)
+			return nil
+		}
+		applies = append(applies, apply)
+	}
+	for _, apply := range applies {
+		apply(s)
+	}
+
+	// Like Redis, lowering maxmemory evicts right away instead of waiting
+	// for the next command.
+	if _, err := s.evictIfNeeded(); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
@@ -0,0 +1,126 @@
+// internal/server/commands_db.go
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+// parseDBIndex parses a database index. On failure it returns the error
+// message to reply with; notInt is used when arg is not an integer.
+func (s *Server) parseDBIndex(arg, notInt string) (int, string) {
+	i, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, notInt
+	}
+	if i < 0 || i >= s.dbs.Len() {
+		return 0, "ERR DB index is out of range"
+	}
+	return i, ""
+}
+
+// SELECT index
+func cmdSelect(s *Server, c *client, args []string) error {
+	i, msg := s.parseDBIndex(args[0], msgNotInteger)
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+	s.selectDB(c, i)
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// SWAPDB index1 index2
+func cmdSwapDB(s *Server, c *client, args []string) error {
+	i, msg := s.parseDBIndex(args[0], "ERR invalid first DB index")
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+	j, msg := s.parseDBIndex(args[1], "ERR invalid second DB index")
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+
+	s.dbs.Swap(i, j)
+	if err := s.appendAOF(c, "SWAPDB", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	// Both databases have new contents: let blocked clients re-check.
+	s.signalDBReady(i)
+	s.signalDBReady(j)
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// MOVE key db
+func cmdMove(s *Server, c *client, args []string) error {
+	dst, msg := s.parseDBIndex(args[1], msgNotInteger)
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+	if dst == c.dbIndex {
+		_ = resp.WriteError(c.w, "ERR source and destination objects are the same")
+		return nil
+	}
+
+	if !s.dbs.Move(args[0], c.dbIndex, dst) {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "MOVE", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	s.signalKeyReady(dst, args[0])
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+func cmdDBSize(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, int64(c.db.Len()))
+	return nil
+}
+
+// FLUSHDB [ASYNC|SYNC]
+func cmdFlushDB(s *Server, c *client, args []string) error {
+	if !parseFlushMode(c, args) {
+		return nil
+	}
+	c.db.Flush()
+	if err := s.appendAOF(c, "FLUSHDB", nil); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// FLUSHALL [ASYNC|SYNC]
+func cmdFlushAll(s *Server, c *client, args []string) error {
+	if !parseFlushMode(c, args) {
+		return nil
+	}
+	s.dbs.FlushAll()
+	if err := s.appendAOF(c, "FLUSHALL", nil); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// parseFlushMode accepts the optional ASYNC / SYNC argument. Both modes are
+// the same here: see store.Store.Flush.
+func parseFlushMode(c *client, args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	if mode := strings.ToUpper(args[0]); len(args) == 1 && (mode == "ASYNC" || mode == "SYNC") {
+		return true
+	}
+	_ = resp.WriteError(c.w, msgSyntax)
+	return false
+}
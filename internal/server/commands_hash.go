@@ -0,0 +1,253 @@
+// internal/server/commands_hash.go
+package server
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func cmdHSet(s *Server, c *client, args []string) error {
+	return s.hset(c, "HSET", args)
+}
+
+// HMSET is the deprecated form of HSET that replies +OK.
+func cmdHMSet(s *Server, c *client, args []string) error {
+	return s.hset(c, "HMSET", args)
+}
+
+func (s *Server) hset(c *client, name string, args []string) error {
+	if len(args)%2 != 1 {
+		writeWrongArgs(c.w, name)
+		return nil
+	}
+
+	key := args[0]
+	pairs := make([]store.FieldValue, 0, (len(args)-1)/2)
+	for i := 1; i < len(args); i += 2 {
+		pairs = append(pairs, store.FieldValue{Field: args[i], Value: []byte(args[i+1])})
+	}
+
+	added, err := c.db.HSet(key, pairs)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "HSET", args); err != nil {
+		return writeAOFError(c.w)
+	}
+
+	if name == "HMSET" {
+		_ = resp.WriteSimpleString(c.w, "OK")
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(added))
+	return nil
+}
+
+func cmdHSetNX(s *Server, c *client, args []string) error {
+	set, err := c.db.HSetNX(args[0], args[1], []byte(args[2]))
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if set {
+		if err := s.appendAOF(c, "HSET", args); err != nil {
+			return writeAOFError(c.w)
+		}
+		_ = resp.WriteInteger(c.w, 1)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, 0)
+	return nil
+}
+
+func cmdHGet(s *Server, c *client, args []string) error {
+	val, ok, err := c.db.HGet(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, val)
+	return nil
+}
+
+func cmdHMGet(s *Server, c *client, args []string) error {
+	vals, err := c.db.HMGet(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	writeBulkArray(c.w, vals)
+	return nil
+}
+
+func cmdHDel(s *Server, c *client, args []string) error {
+	removed, err := c.db.HDel(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if removed > 0 {
+		if err := s.appendAOF(c, "HDEL", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(removed))
+	return nil
+}
+
+func cmdHExists(s *Server, c *client, args []string) error {
+	ok, err := c.db.HExists(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if ok {
+		_ = resp.WriteInteger(c.w, 1)
+	} else {
+		_ = resp.WriteInteger(c.w, 0)
+	}
+	return nil
+}
+
+func cmdHLen(s *Server, c *client, args []string) error {
+	n, err := c.db.HLen(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdHStrLen(s *Server, c *client, args []string) error {
+	val, _, err := c.db.HGet(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(len(val)))
+	return nil
+}
+
+// hgetAll returns the hash sorted by field so replies are deterministic.
+func (s *Server) hgetAll(c *client, key string) ([]store.FieldValue, bool) {
+	pairs, err := c.db.HGetAll(key)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil, false
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Field < pairs[j].Field })
+	return pairs, true
+}
+
+func cmdHKeys(s *Server, c *client, args []string) error {
+	pairs, ok := s.hgetAll(c, args[0])
+	if !ok {
+		return nil
+	}
+	_ = resp.WriteArrayHeader(c.w, len(pairs))
+	for _, p := range pairs {
+		_ = resp.WriteBulkString(c.w, []byte(p.Field))
+	}
+	return nil
+}
+
+func cmdHVals(s *Server, c *client, args []string) error {
+	pairs, ok := s.hgetAll(c, args[0])
+	if !ok {
+		return nil
+	}
+	_ = resp.WriteArrayHeader(c.w, len(pairs))
+	for _, p := range pairs {
+		_ = resp.WriteBulkString(c.w, p.Value)
+	}
+	return nil
+}
+
+func cmdHGetAll(s *Server, c *client, args []string) error {
+	pairs, ok := s.hgetAll(c, args[0])
+	if !ok {
+		return nil
+	}
+	_ = resp.WriteArrayHeader(c.w, 2*len(pairs))
+	for _, p := range pairs {
+		_ = resp.WriteBulkString(c.w, []byte(p.Field))
+		_ = resp.WriteBulkString(c.w, p.Value)
+	}
+	return nil
+}
+
+func cmdHIncrBy(s *Server, c *client, args []string) error {
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	n, err := c.db.HIncrBy(args[0], args[1], delta)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	// Log the resulting value so replay is idempotent.
+	if err := s.appendAOF(c, "HSET", []string{args[0], args[1], strconv.FormatInt(n, 10)}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, n)
+	return nil
+}
+
+func cmdHIncrByFloat(s *Server, c *client, args []string) error {
+	delta, ok := parseFloatArg(args[2])
+	if !ok {
+		_ = resp.WriteError(c.w, msgNotFloat)
+		return nil
+	}
+
+	val, err := c.db.HIncrByFloat(args[0], args[1], delta)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	if err := s.appendAOF(c, "HSET", []string{args[0], args[1], val}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteBulkString(c.w, []byte(val))
+	return nil
+}
+
+func cmdHScan(s *Server, c *client, args []string) error {
+	cursor, opts, ok := parseScanArgs(c.w, args[1:], "NOVALUES")
+	if !ok {
+		return nil
+	}
+
+	pairs, next, err := c.db.HScan(args[0], cursor, opts.count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	items := make([]string, 0, 2*len(pairs))
+	for _, p := range pairs {
+		if !opts.matches(p.Field) {
+			continue
+		}
+		items = append(items, p.Field)
+		if !opts.noValues {
+			items = append(items, string(p.Value))
+		}
+	}
+	writeScanReply(c.w, next, items)
+	return nil
+}
@@ -0,0 +1,376 @@
+// internal/server/commands_keys.go
+package server
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func cmdDel(s *Server, c *client, args []string) error {
+	st := c.db
+
+	// Decide what will actually be deleted (EXISTS purges expired keys too)
+	toDelete := make([]string, 0, len(args))
+	for _, key := range args {
+		if st.Exists(key) {
+			toDelete = append(toDelete, key)
+		}
+	}
+
+	if len(toDelete) == 0 {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+
+	// AOF first (durability), then apply
+	if err := s.appendAOF(c, "DEL", toDelete); err != nil {
+		return writeAOFError(c.w)
+	}
+
+	var removed int64
+	for _, key := range toDelete {
+		if st.Del(key) {
+			removed++
+		}
+	}
+
+	_ = resp.WriteInteger(c.w, removed)
+	return nil
+}
+
+func cmdExists(s *Server, c *client, args []string) error {
+	var count int64 = 0
+	for _, key := range args {
+		if c.db.Exists(key) {
+			count++
+		}
+	}
+	_ = resp.WriteInteger(c.w, count)
+	return nil
+}
+
+func cmdExpire(s *Server, c *client, args []string) error {
+	return s.expireGeneric(c, "expire", args, 1000, true)
+}
+
+func cmdPExpire(s *Server, c *client, args []string) error {
+	return s.expireGeneric(c, "pexpire", args, 1, true)
+}
+
+func cmdExpireAt(s *Server, c *client, args []string) error {
+	return s.expireGeneric(c, "expireat", args, 1000, false)
+}
+
+func cmdPExpireAt(s *Server, c *client, args []string) error {
+	return s.expireGeneric(c, "pexpireat", args, 1, false)
+}
+
+// expireGeneric implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT:
+// "key amount [NX|XX|GT|LT]", where amount is in units of unitMs
+// milliseconds and is relative to now when relative is set. The result is
+// logged as an absolute PEXPIREAT so replay is deterministic.
+func (s *Server) expireGeneric(c *client, name string, args []string, unitMs int64, relative bool) error {
+	key := args[0]
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	var opts store.ExpireOptions
+	for _, a := range args[2:] {
+		switch strings.ToUpper(a) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		default:
+			_ = resp.WriteError(c.w, "ERR Unsupported option "+a)
+			return nil
+		}
+	}
+	if opts.NX && (opts.XX || opts.GT || opts.LT) {
+		_ = resp.WriteError(c.w, "ERR NX and XX, GT or LT options at the same time are not compatible")
+		return nil
+	}
+	if opts.GT && opts.LT {
+		_ = resp.WriteError(c.w, "ERR GT and LT options at the same time are not compatible")
+		return nil
+	}
+
+	ms, ok := scaleExpire(n, unitMs, relative)
+	if !ok {
+		_ = resp.WriteError(c.w, "ERR invalid expire time in '"+name+"' command")
+		return nil
+	}
+
+	if !c.db.PExpireAt(key, ms, opts) {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "PEXPIREAT", []string{key, strconv.FormatInt(ms, 10)}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+// scaleExpire converts n units of unitMs milliseconds (relative to now if
+// relative is set) to an absolute unix time in milliseconds, reporting false
+// on overflow.
+func scaleExpire(n, unitMs int64, relative bool) (int64, bool) {
+	if n > math.MaxInt64/unitMs || n < math.MinInt64/unitMs {
+		return 0, false
+	}
+	n *= unitMs
+	if relative {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, false
+		}
+		n += now
+	}
+	return n, true
+}
+
+func cmdTTL(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, c.db.TTL(args[0]))
+	return nil
+}
+
+func cmdPTTL(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, c.db.PTTL(args[0]))
+	return nil
+}
+
+func cmdExpireTime(s *Server, c *client, args []string) error {
+	ms := c.db.ExpireTimeMs(args[0])
+	if ms > 0 {
+		ms /= 1000
+	}
+	_ = resp.WriteInteger(c.w, ms)
+	return nil
+}
+
+func cmdPExpireTime(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, c.db.ExpireTimeMs(args[0]))
+	return nil
+}
+
+func cmdPersist(s *Server, c *client, args []string) error {
+	if !c.db.Persist(args[0]) {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "PERSIST", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+// KEYS pattern
+func cmdKeys(s *Server, c *client, args []string) error {
+	keys := c.db.Keys(args[0])
+	sort.Strings(keys)
+	writeStringArray(c.w, keys)
+	return nil
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func cmdScan(s *Server, c *client, args []string) error {
+	cursor, opts, ok := parseScanArgs(c.w, args, "TYPE")
+	if !ok {
+		return nil
+	}
+
+	keys, next := c.db.Scan(cursor, opts.count, opts.typ)
+	items := keys[:0]
+	for _, k := range keys {
+		if opts.matches(k) {
+			items = append(items, k)
+		}
+	}
+	writeScanReply(c.w, next, items)
+	return nil
+}
+
+// RENAME key newkey
+func cmdRename(s *Server, c *client, args []string) error {
+	return s.rename(c, "RENAME", args, false)
+}
+
+// RENAMENX key newkey
+func cmdRenameNX(s *Server, c *client, args []string) error {
+	return s.rename(c, "RENAMENX", args, true)
+}
+
+func (s *Server) rename(c *client, name string, args []string, nx bool) error {
+	renamed, err := c.db.Rename(args[0], args[1], nx)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !renamed {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if args[0] != args[1] {
+		if err := s.appendAOF(c, name, args); err != nil {
+			return writeAOFError(c.w)
+		}
+		s.signalKeyReady(c.dbIndex, args[1])
+	}
+	if nx {
+		_ = resp.WriteInteger(c.w, 1)
+	} else {
+		_ = resp.WriteSimpleString(c.w, "OK")
+	}
+	return nil
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func cmdCopy(s *Server, c *client, args []string) error {
+	dstDB := c.dbIndex
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "REPLACE":
+			replace = true
+		case opt == "DB" && i+1 < len(args):
+			db, msg := s.parseDBIndex(args[i+1], msgNotInteger)
+			if msg != "" {
+				_ = resp.WriteError(c.w, msg)
+				return nil
+			}
+			dstDB = db
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+	if dstDB == c.dbIndex && args[0] == args[1] {
+		_ = resp.WriteError(c.w, "ERR source and destination objects are the same")
+		return nil
+	}
+
+	if !s.dbs.Copy(args[0], args[1], c.dbIndex, dstDB, replace) {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "COPY", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	s.signalKeyReady(dstDB, args[1])
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+func cmdType(s *Server, c *client, args []string) error {
+	_ = resp.WriteSimpleString(c.w, c.db.Type(args[0]))
+	return nil
+}
+
+// TOUCH key [key ...]
+func cmdTouch(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, int64(c.db.Touch(args)))
+	return nil
+}
+
+func cmdRandomKey(s *Server, c *client, args []string) error {
+	key, ok := c.db.RandomKey()
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, []byte(key))
+	return nil
+}
+
+// UNLINK key [key ...]
+//
+// Like DEL, but only the keys that actually existed are logged.
+func cmdUnlink(s *Server, c *client, args []string) error {
+	removed := c.db.Unlink(args)
+	if len(removed) > 0 {
+		if err := s.appendAOF(c, "UNLINK", removed); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(len(removed)))
+	return nil
+}
+
+const (
+	msgPolicySwitch = "Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."
+	msgNoFreq       = "ERR An LFU maxmemory policy is not selected, access frequency not tracked. " + msgPolicySwitch
+	msgNoIdleTime   = "ERR An LFU maxmemory policy is selected, idle time not tracked. " + msgPolicySwitch
+)
+
+// OBJECT ENCODING|IDLETIME|FREQ|REFCOUNT key
+func cmdObject(s *Server, c *client, args []string) error {
+	sub := strings.ToUpper(args[0])
+	switch sub {
+	case "HELP":
+		writeStringArray(c.w, []string{
+			"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"ENCODING <key>",
+			"    Return the kind of internal representation used in order to store the value",
+			"    associated with a <key>.",
+			"FREQ <key>",
+			"    Return the access frequency index of the <key>.",
+			"IDLETIME <key>",
+			"    Return the idle time of the <key>, that is the approximated number of",
+			"    seconds elapsed since the last access to the key.",
+			"REFCOUNT <key>",
+			"    Return the number of references of the value associated with the specified",
+			"    <key>.",
+		})
+		return nil
+	case "ENCODING", "IDLETIME", "FREQ", "REFCOUNT":
+	default:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try OBJECT HELP.")
+		return nil
+	}
+	if len(args) != 2 {
+		writeWrongArgs(c.w, "object|"+strings.ToLower(sub))
+		return nil
+	}
+
+	info, ok := c.db.Object(args[1])
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	lfu := store.EvictionPolicy(s.maxmemoryPolicy.Load()).LFU()
+	switch sub {
+	case "ENCODING":
+		_ = resp.WriteBulkString(c.w, []byte(info.Encoding))
+	case "IDLETIME":
+		if lfu {
+			_ = resp.WriteError(c.w, msgNoIdleTime)
+			return nil
+		}
+		_ = resp.WriteInteger(c.w, int64(info.Idle/time.Second))
+	case "FREQ":
+		if !lfu {
+			_ = resp.WriteError(c.w, msgNoFreq)
+			return nil
+		}
+		_ = resp.WriteInteger(c.w, int64(info.Freq))
+	case "REFCOUNT":
+		_ = resp.WriteInteger(c.w, 1)
+	}
+	return nil
+}
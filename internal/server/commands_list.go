@@ -0,0 +1,438 @@
+// internal/server/commands_list.go
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+func cmdLPush(s *Server, c *client, args []string) error {
+	return s.push(c, "LPUSH", args, c.db.LPush)
+}
+
+func cmdRPush(s *Server, c *client, args []string) error {
+	return s.push(c, "RPUSH", args, c.db.RPush)
+}
+
+func cmdLPushX(s *Server, c *client, args []string) error {
+	return s.push(c, "LPUSHX", args, c.db.LPushX)
+}
+
+func cmdRPushX(s *Server, c *client, args []string) error {
+	return s.push(c, "RPUSHX", args, c.db.RPushX)
+}
+
+func (s *Server) push(c *client, name string, args []string, fn func(string, [][]byte) (int, error)) error {
+	key := args[0]
+	vals := make([][]byte, 0, len(args)-1)
+	for _, a := range args[1:] {
+		vals = append(vals, []byte(a))
+	}
+
+	n, err := fn(key, vals)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if n > 0 {
+		if err := s.appendAOF(c, name, args); err != nil {
+			return writeAOFError(c.w)
+		}
+		s.signalKeyReady(c.dbIndex, key)
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdLPop(s *Server, c *client, args []string) error {
+	return s.pop(c, "LPOP", args, c.db.LPop)
+}
+
+func cmdRPop(s *Server, c *client, args []string) error {
+	return s.pop(c, "RPOP", args, c.db.RPop)
+}
+
+func (s *Server) pop(c *client, name string, args []string, fn func(string, int) ([][]byte, error)) error {
+	if len(args) > 2 {
+		writeWrongArgs(c.w, name)
+		return nil
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			_ = resp.WriteError(c.w, "ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	vals, err := fn(args[0], count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	if len(vals) > 0 {
+		if err := s.appendAOF(c, name, []string{args[0], strconv.Itoa(len(vals))}); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+
+	if len(args) == 1 {
+		if len(vals) == 0 {
+			_ = resp.WriteBulkString(c.w, nil)
+			return nil
+		}
+		_ = resp.WriteBulkString(c.w, vals[0])
+		return nil
+	}
+	if vals == nil {
+		_ = resp.WriteNullArray(c.w)
+		return nil
+	}
+	writeBulkArray(c.w, vals)
+	return nil
+}
+
+func cmdLLen(s *Server, c *client, args []string) error {
+	n, err := c.db.LLen(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdLRange(s *Server, c *client, args []string) error {
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	vals, err := c.db.LRange(args[0], start, stop)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	writeBulkArray(c.w, vals)
+	return nil
+}
+
+func cmdLIndex(s *Server, c *client, args []string) error {
+	idx, err := strconv.Atoi(args[1])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	v, ok, err := c.db.LIndex(args[0], idx)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, v)
+	return nil
+}
+
+func cmdLSet(s *Server, c *client, args []string) error {
+	idx, err := strconv.Atoi(args[1])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	if err := c.db.LSet(args[0], idx, []byte(args[2])); err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "LSET", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+func cmdLRem(s *Server, c *client, args []string) error {
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	n, err := c.db.LRem(args[0], count, []byte(args[2]))
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if n > 0 {
+		if err := s.appendAOF(c, "LREM", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdLTrim(s *Server, c *client, args []string) error {
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+
+	if err := c.db.LTrim(args[0], start, stop); err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "LTRIM", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+func cmdLInsert(s *Server, c *client, args []string) error {
+	var before bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+
+	n, err := c.db.LInsert(args[0], before, []byte(args[2]), []byte(args[3]))
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if n > 0 {
+		if err := s.appendAOF(c, "LINSERT", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdLPos(s *Server, c *client, args []string) error {
+	rank, count, maxLen := 1, -1, 0
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if i+1 >= len(args) {
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			_ = resp.WriteError(c.w, msgNotInteger)
+			return nil
+		}
+		i++
+
+		switch opt {
+		case "RANK":
+			if n == 0 {
+				_ = resp.WriteError(c.w, "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return nil
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				_ = resp.WriteError(c.w, "ERR COUNT can't be negative")
+				return nil
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				_ = resp.WriteError(c.w, "ERR MAXLEN can't be negative")
+				return nil
+			}
+			maxLen = n
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	limit := count
+	if count < 0 {
+		limit = 1
+	}
+	pos, err := c.db.LPos(args[0], []byte(args[1]), rank, limit, maxLen)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	if count < 0 {
+		if len(pos) == 0 {
+			_ = resp.WriteBulkString(c.w, nil)
+			return nil
+		}
+		_ = resp.WriteInteger(c.w, int64(pos[0]))
+		return nil
+	}
+	_ = resp.WriteArrayHeader(c.w, len(pos))
+	for _, p := range pos {
+		_ = resp.WriteInteger(c.w, int64(p))
+	}
+	return nil
+}
+
+// parseDirection parses LEFT / RIGHT; true means left.
+func parseDirection(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+func cmdLMove(s *Server, c *client, args []string) error {
+	from, ok1 := parseDirection(args[2])
+	to, ok2 := parseDirection(args[3])
+	if !ok1 || !ok2 {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+	return s.lmoveOrNil(c, args[0], args[1], from, to)
+}
+
+func cmdRPopLPush(s *Server, c *client, args []string) error {
+	return s.lmoveOrNil(c, args[0], args[1], false, true)
+}
+
+func (s *Server) lmoveOrNil(c *client, src, dst string, fromLeft, toLeft bool) error {
+	done, err := s.lmove(c, src, dst, fromLeft, toLeft)
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteBulkString(c.w, nil)
+	}
+	return nil
+}
+
+// lmove moves one element and writes the reply if there was one to move.
+// It reports whether src had an element (or a reply was written).
+func (s *Server) lmove(c *client, src, dst string, fromLeft, toLeft bool) (bool, error) {
+	v, ok, err := c.db.LMove(src, dst, fromLeft, toLeft)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return true, nil
+	}
+	if !ok {
+		return false, nil
+	}
+
+	if err := s.appendAOF(c, "LMOVE", []string{src, dst, directionName(fromLeft), directionName(toLeft)}); err != nil {
+		return true, writeAOFError(c.w)
+	}
+	s.signalKeyReady(c.dbIndex, dst)
+	_ = resp.WriteBulkString(c.w, v)
+	return true, nil
+}
+
+func directionName(left bool) string {
+	if left {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
+
+func cmdBLPop(s *Server, c *client, args []string) error {
+	return s.bpop(c, "LPOP", args, c.db.LPop)
+}
+
+func cmdBRPop(s *Server, c *client, args []string) error {
+	return s.bpop(c, "RPOP", args, c.db.RPop)
+}
+
+// bpop implements BLPOP / BRPOP: pop from the first non-empty key, blocking
+// until one is pushed to. The pop is logged as its non-blocking form.
+func (s *Server) bpop(c *client, logCmd string, args []string, fn func(string, int) ([][]byte, error)) error {
+	timeout, msg := parseTimeout(args[len(args)-1])
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+	keys := args[:len(args)-1]
+
+	done, err := s.blockOn(c, keys, timeout, func() (bool, error) {
+		for _, key := range keys {
+			vals, err := fn(key, 1)
+			if err != nil {
+				writeStoreError(c.w, err)
+				return true, nil
+			}
+			if len(vals) == 0 {
+				continue
+			}
+			if err := s.appendAOF(c, logCmd, []string{key}); err != nil {
+				return true, writeAOFError(c.w)
+			}
+			_ = resp.WriteArrayHeader(c.w, 2)
+			_ = resp.WriteBulkString(c.w, []byte(key))
+			_ = resp.WriteBulkString(c.w, vals[0])
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteNullArray(c.w)
+	}
+	return nil
+}
+
+func cmdBLMove(s *Server, c *client, args []string) error {
+	from, ok1 := parseDirection(args[2])
+	to, ok2 := parseDirection(args[3])
+	if !ok1 || !ok2 {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+	return s.blmove(c, args[0], args[1], from, to, args[4])
+}
+
+func cmdBRPopLPush(s *Server, c *client, args []string) error {
+	return s.blmove(c, args[0], args[1], false, true, args[2])
+}
+
+func (s *Server) blmove(c *client, src, dst string, fromLeft, toLeft bool, timeoutArg string) error {
+	timeout, msg := parseTimeout(timeoutArg)
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+
+	done, err := s.blockOn(c, []string{src}, timeout, func() (bool, error) {
+		return s.lmove(c, src, dst, fromLeft, toLeft)
+	})
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteBulkString(c.w, nil)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// internal/server/commands_server.go
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func cmdPing(s *Server, c *client, args []string) error {
+	if c.subscribed() {
+		// In subscribed mode replies are pushed messages, PONG included.
+		if len(args) > 1 {
+			writeWrongArgs(c.w, "PING")
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, 2)
+		_ = resp.WriteBulkString(c.w, []byte("pong"))
+		_ = resp.WriteBulkString(c.w, []byte(strings.Join(args, "")))
+		return nil
+	}
+	switch len(args) {
+	case 0:
+		_ = resp.WriteSimpleString(c.w, "PONG")
+	case 1:
+		_ = resp.WriteBulkString(c.w, []byte(args[0]))
+	default:
+		writeWrongArgs(c.w, "PING")
+	}
+	return nil
+}
+
+func cmdEcho(s *Server, c *client, args []string) error {
+	_ = resp.WriteBulkString(c.w, []byte(args[0]))
+	return nil
+}
+
+func cmdInfo(s *Server, c *client, args []string) error {
+	port := ""
+	if s.ln != nil {
+		if a, ok := s.ln.Addr().(*net.TCPAddr); ok {
+			port = strconv.Itoa(a.Port)
+		}
+	}
+
+	if port == "" {
+		port = "6379"
+	}
+
+	s.saveMu.Lock()
+	bgsaveInProgress, lastSave, bgsaveStatus := "0", s.lastSave, "ok"
+	if s.saveRunning {
+		bgsaveInProgress = "1"
+	}
+	if !s.lastBgsaveOK {
+		bgsaveStatus = "err"
+	}
+	s.saveMu.Unlock()
+
+	s.rewriteMu.Lock()
+	rewriteInProgress, rewrites, rewriteStatus := "0", s.rewrites, "ok"
+	if s.rewriteRunning {
+		rewriteInProgress = "1"
+	}
+	if !s.lastRewriteOK {
+		rewriteStatus = "err"
+	}
+	s.rewriteMu.Unlock()
+
+	// Like Redis, the sizes are only reported with the AOF on.
+	aofEnabled, aofSizes := "0", ""
+	if faof, ok := s.aof.(*aof.FileAOF); ok {
+		cur, base := faof.Size()
+		aofEnabled = "1"
+		aofSizes = "aof_current_size:" + strconv.FormatInt(cur, 10) + "\r\n" +
+			"aof_base_size:" + strconv.FormatInt(base, 10) + "\r\n"
+	}
+
+	exp := s.dbs.ExpireStats()
+	channels, patterns, shardChannels := s.pubsubCounts()
+	info := []byte(
+		"# Server\r\n" +
+			"redis_version:0.0.1\r\n" +
+			"redigo:1\r\n" +
+			"tcp_port:" + port + "\r\n" +
+			"\r\n# Memory\r\n" +
+			"used_memory:" + strconv.FormatInt(s.dbs.UsedMemory(), 10) + "\r\n" +
+			"maxmemory:" + strconv.FormatInt(s.maxmemory.Load(), 10) + "\r\n" +
+			"maxmemory_policy:" + store.EvictionPolicy(s.maxmemoryPolicy.Load()).String() + "\r\n" +
+			"\r\n# Persistence\r\n" +
+			"rdb_changes_since_last_save:" + strconv.FormatInt(s.dirty.Load(), 10) + "\r\n" +
+			"rdb_bgsave_in_progress:" + bgsaveInProgress + "\r\n" +
+			"rdb_last_save_time:" + strconv.FormatInt(lastSave.Unix(), 10) + "\r\n" +
+			"rdb_last_bgsave_status:" + bgsaveStatus + "\r\n" +
+			"aof_enabled:" + aofEnabled + "\r\n" +
+			"aof_rewrite_in_progress:" + rewriteInProgress + "\r\n" +
+			"aof_rewrites:" + strconv.FormatInt(rewrites, 10) + "\r\n" +
+			"aof_last_bgrewrite_status:" + rewriteStatus + "\r\n" +
+			aofSizes +
+			"\r\n# Stats\r\n" +
+			"expired_keys:" + strconv.FormatInt(exp.ExpiredKeys, 10) + "\r\n" +
+			"expired_stale_perc:" + strconv.FormatFloat(exp.StalePerc, 'f', 2, 64) + "\r\n" +
+			"expired_time_cap_reached_count:" + strconv.FormatInt(exp.TimeCapReached, 10) + "\r\n" +
+			"expire_cycles:" + strconv.FormatInt(exp.Cycles, 10) + "\r\n" +
+			"expire_cycle_cpu_milliseconds:" + strconv.FormatInt(exp.CycleTime.Milliseconds(), 10) + "\r\n" +
+			"expire_cycle_last_microseconds:" + strconv.FormatInt(exp.LastCycleTime.Microseconds(), 10) + "\r\n" +
+			"evicted_keys:" + strconv.FormatInt(s.evictedKeys.Load(), 10) + "\r\n" +
+			"pubsub_channels:" + strconv.Itoa(channels) + "\r\n" +
+			"pubsub_patterns:" + strconv.Itoa(patterns) + "\r\n" +
+			"pubsubshard_channels:" + strconv.Itoa(shardChannels) + "\r\n" +
+			"client_output_buffer_limit_disconnections:" + strconv.FormatInt(s.outputLimitDisconnects.Load(), 10) + "\r\n",
+	)
+	_ = resp.WriteBulkString(c.w, info)
+	return nil
+}
+
+func cmdBgRewriteAOF(s *Server, c *client, args []string) error {
+	faof, ok := s.aof.(*aof.FileAOF)
+	if !ok {
+		_ = resp.WriteError(c.w, "ERR aof rewrite not supported")
+		return nil
+	}
+
+	if !s.tryStartRewrite() {
+		_ = resp.WriteError(c.w, "ERR aof rewrite already in progress")
+		return nil
+	}
+
+	s.startRewrite(faof)
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
@@ -0,0 +1,304 @@
+// internal/server/commands_set.go
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+func cmdSAdd(s *Server, c *client, args []string) error {
+	added, err := c.db.SAdd(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if added > 0 {
+		if err := s.appendAOF(c, "SADD", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(added))
+	return nil
+}
+
+func cmdSRem(s *Server, c *client, args []string) error {
+	removed, err := c.db.SRem(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if removed > 0 {
+		if err := s.appendAOF(c, "SREM", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(removed))
+	return nil
+}
+
+func cmdSIsMember(s *Server, c *client, args []string) error {
+	ok, err := c.db.SIsMember(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if ok {
+		_ = resp.WriteInteger(c.w, 1)
+	} else {
+		_ = resp.WriteInteger(c.w, 0)
+	}
+	return nil
+}
+
+func cmdSMIsMember(s *Server, c *client, args []string) error {
+	found, err := c.db.SMIsMember(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteArrayHeader(c.w, len(found))
+	for _, ok := range found {
+		if ok {
+			_ = resp.WriteInteger(c.w, 1)
+		} else {
+			_ = resp.WriteInteger(c.w, 0)
+		}
+	}
+	return nil
+}
+
+// writeSortedMembers replies with members sorted so replies are deterministic.
+func writeSortedMembers(c *client, members []string, err error) {
+	if err != nil {
+		writeStoreError(c.w, err)
+		return
+	}
+	sort.Strings(members)
+	writeStringArray(c.w, members)
+}
+
+func cmdSMembers(s *Server, c *client, args []string) error {
+	members, err := c.db.SMembers(args[0])
+	writeSortedMembers(c, members, err)
+	return nil
+}
+
+func cmdSCard(s *Server, c *client, args []string) error {
+	n, err := c.db.SCard(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdSPop(s *Server, c *client, args []string) error {
+	if len(args) > 2 {
+		writeWrongArgs(c.w, "SPOP")
+		return nil
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			_ = resp.WriteError(c.w, "ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	popped, err := c.db.SPop(args[0], count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	// Log the members that were actually chosen so replay is deterministic.
+	if len(popped) > 0 {
+		if err := s.appendAOF(c, "SREM", append([]string{args[0]}, popped...)); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+
+	if len(args) == 1 {
+		if len(popped) == 0 {
+			_ = resp.WriteBulkString(c.w, nil)
+			return nil
+		}
+		_ = resp.WriteBulkString(c.w, []byte(popped[0]))
+		return nil
+	}
+	writeStringArray(c.w, popped)
+	return nil
+}
+
+func cmdSRandMember(s *Server, c *client, args []string) error {
+	if len(args) > 2 {
+		writeWrongArgs(c.w, "SRANDMEMBER")
+		return nil
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			_ = resp.WriteError(c.w, msgNotInteger)
+			return nil
+		}
+		count = n
+	}
+
+	members, err := c.db.SRandMember(args[0], count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	if len(args) == 1 {
+		if len(members) == 0 {
+			_ = resp.WriteBulkString(c.w, nil)
+			return nil
+		}
+		_ = resp.WriteBulkString(c.w, []byte(members[0]))
+		return nil
+	}
+	writeStringArray(c.w, members)
+	return nil
+}
+
+func cmdSMove(s *Server, c *client, args []string) error {
+	moved, err := c.db.SMove(args[0], args[1], args[2])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !moved {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if args[0] != args[1] {
+		if err := s.appendAOF(c, "SMOVE", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+func cmdSInter(s *Server, c *client, args []string) error {
+	members, err := c.db.SInter(args)
+	writeSortedMembers(c, members, err)
+	return nil
+}
+
+func cmdSUnion(s *Server, c *client, args []string) error {
+	members, err := c.db.SUnion(args)
+	writeSortedMembers(c, members, err)
+	return nil
+}
+
+func cmdSDiff(s *Server, c *client, args []string) error {
+	members, err := c.db.SDiff(args)
+	writeSortedMembers(c, members, err)
+	return nil
+}
+
+func cmdSInterStore(s *Server, c *client, args []string) error {
+	return s.setOpStore(c, "SINTERSTORE", args, c.db.SInterStore)
+}
+
+func cmdSUnionStore(s *Server, c *client, args []string) error {
+	return s.setOpStore(c, "SUNIONSTORE", args, c.db.SUnionStore)
+}
+
+func cmdSDiffStore(s *Server, c *client, args []string) error {
+	return s.setOpStore(c, "SDIFFSTORE", args, c.db.SDiffStore)
+}
+
+// setOpStore runs one of the *STORE commands. They always overwrite (or
+// delete) the destination, so they are always logged.
+func (s *Server) setOpStore(c *client, name string, args []string, fn func(string, []string) (int, error)) error {
+	n, err := fn(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, name, args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func cmdSInterCard(s *Server, c *client, args []string) error {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	if numKeys <= 0 {
+		_ = resp.WriteError(c.w, "ERR numkeys should be greater than 0")
+		return nil
+	}
+	if numKeys > len(args)-1 {
+		_ = resp.WriteError(c.w, "ERR Number of keys can't be greater than number of args")
+		return nil
+	}
+
+	keys := args[1 : 1+numKeys]
+	limit := 0
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(rest[i]) != "LIMIT" || i+1 >= len(rest) {
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+		n, err := strconv.Atoi(rest[i+1])
+		if err != nil {
+			_ = resp.WriteError(c.w, msgNotInteger)
+			return nil
+		}
+		if n < 0 {
+			_ = resp.WriteError(c.w, "ERR LIMIT can't be negative")
+			return nil
+		}
+		limit = n
+		i++
+	}
+
+	n, err := c.db.SInterCard(keys, limit)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdSScan(s *Server, c *client, args []string) error {
+	cursor, opts, ok := parseScanArgs(c.w, args[1:])
+	if !ok {
+		return nil
+	}
+
+	members, next, err := c.db.SScan(args[0], cursor, opts.count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	items := make([]string, 0, len(members))
+	for _, m := range members {
+		if opts.matches(m) {
+			items = append(items, m)
+		}
+	}
+	writeScanReply(c.w, next, items)
+	return nil
+}
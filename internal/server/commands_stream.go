@@ -0,0 +1,1205 @@
+// internal/server/commands_stream.go
+package server
+
+import (
+	"bufio"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+const (
+	msgInvalidStreamID = "ERR Invalid stream ID specified as stream command argument"
+	msgXGroupNoKey     = "ERR The XGROUP subcommand requires the key to exist. " +
+		"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."
+)
+
+// writeStreamEntry writes [id, [field, value, ...]]; the fields are nil for
+// a pending entry whose message was deleted.
+func writeStreamEntry(w *bufio.Writer, e store.StreamEntry) {
+	_ = resp.WriteArrayHeader(w, 2)
+	_ = resp.WriteBulkString(w, []byte(e.ID.String()))
+	if e.Fields == nil {
+		_ = resp.WriteNullArray(w)
+		return
+	}
+	writeStringArray(w, e.Fields)
+}
+
+func writeStreamEntries(w *bufio.Writer, entries []store.StreamEntry) {
+	_ = resp.WriteArrayHeader(w, len(entries))
+	for _, e := range entries {
+		writeStreamEntry(w, e)
+	}
+}
+
+func writeStreamIDs(w *bufio.Writer, ids []store.StreamID) {
+	_ = resp.WriteArrayHeader(w, len(ids))
+	for _, id := range ids {
+		_ = resp.WriteBulkString(w, []byte(id.String()))
+	}
+}
+
+// writeClaimed writes the reply of XCLAIM and XAUTOCLAIM: the entries, or
+// only their IDs with JUSTID.
+func writeClaimed(w *bufio.Writer, claimed []store.StreamEntry, justID bool) {
+	if !justID {
+		writeStreamEntries(w, claimed)
+		return
+	}
+	_ = resp.WriteArrayHeader(w, len(claimed))
+	for _, e := range claimed {
+		_ = resp.WriteBulkString(w, []byte(e.ID.String()))
+	}
+}
+
+// parseRangeID parses an XRANGE-style bound: "-", "+", an ID, or an ID
+// prefixed with "(" to make it exclusive. A missing sequence number is 0
+// for a start bound and the largest possible for an end bound.
+func parseRangeID(w *bufio.Writer, arg string, end bool) (store.StreamID, bool) {
+	switch arg {
+	case "-":
+		return store.StreamID{}, true
+	case "+":
+		return store.MaxStreamID, true
+	}
+	var missingSeq uint64
+	if end {
+		missingSeq = math.MaxUint64
+	}
+	exclusive := strings.HasPrefix(arg, "(")
+	id, ok := store.ParseStreamID(strings.TrimPrefix(arg, "("), missingSeq)
+	if !ok {
+		_ = resp.WriteError(w, msgInvalidStreamID)
+		return id, false
+	}
+	if !exclusive {
+		return id, true
+	}
+	if end {
+		id, ok = id.Prev()
+	} else {
+		id, ok = id.Next()
+	}
+	if !ok {
+		which := "start"
+		if end {
+			which = "end"
+		}
+		_ = resp.WriteError(w, "ERR invalid "+which+" ID for the interval")
+	}
+	return id, ok
+}
+
+// parseStreamIDs parses a list of IDs, writing an error reply if one is
+// invalid.
+func parseStreamIDs(w *bufio.Writer, args []string) ([]store.StreamID, bool) {
+	ids := make([]store.StreamID, 0, len(args))
+	for _, a := range args {
+		id, ok := store.ParseStreamID(a, 0)
+		if !ok {
+			_ = resp.WriteError(w, msgInvalidStreamID)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// parseStreamTrim parses the XADD / XTRIM options "[NOMKSTREAM] [MAXLEN|MINID
+// [=|~] threshold] [LIMIT count]" at the start of args, stopping at the
+// first argument that is not one of them, and returns how many it used.
+// NOMKSTREAM is only accepted when allowNoMk is set.
+func parseStreamTrim(w *bufio.Writer, args []string, allowNoMk bool) (trim store.StreamTrim, noMk bool, n int, ok bool) {
+	limit := int64(-1)
+opts:
+	for n < len(args) {
+		switch opt := strings.ToUpper(args[n]); {
+		case opt == "NOMKSTREAM" && allowNoMk:
+			noMk = true
+			n++
+		case (opt == "MAXLEN" || opt == "MINID") && n+1 < len(args):
+			strategy := store.TrimMaxLen
+			if opt == "MINID" {
+				strategy = store.TrimMinID
+			}
+			if trim.Strategy != store.TrimNone && trim.Strategy != strategy {
+				_ = resp.WriteError(w, "ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+				return trim, noMk, n, false
+			}
+			trim.Strategy = strategy
+			n++
+			if (args[n] == "~" || args[n] == "=") && n+1 < len(args) {
+				trim.Approx = args[n] == "~"
+				n++
+			}
+			if strategy == store.TrimMaxLen {
+				maxLen, err := strconv.ParseInt(args[n], 10, 64)
+				if err != nil {
+					_ = resp.WriteError(w, msgNotInteger)
+					return trim, noMk, n, false
+				}
+				if maxLen < 0 {
+					_ = resp.WriteError(w, "ERR The MAXLEN argument must be >= 0.")
+					return trim, noMk, n, false
+				}
+				trim.MaxLen = maxLen
+			} else {
+				id, ok := store.ParseStreamID(args[n], 0)
+				if !ok {
+					_ = resp.WriteError(w, msgInvalidStreamID)
+					return trim, noMk, n, false
+				}
+				trim.MinID = id
+			}
+			n++
+		case opt == "LIMIT" && n+1 < len(args):
+			l, err := strconv.ParseInt(args[n+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(w, msgNotInteger)
+				return trim, noMk, n, false
+			}
+			if l < 0 {
+				_ = resp.WriteError(w, "ERR The LIMIT argument must be >= 0.")
+				return trim, noMk, n, false
+			}
+			limit = l
+			n += 2
+		default:
+			break opts
+		}
+	}
+	switch {
+	case limit >= 0 && !trim.Approx:
+		_ = resp.WriteError(w, "ERR syntax error, LIMIT cannot be used without the special ~ option")
+		return trim, noMk, n, false
+	case limit >= 0:
+		trim.Limit = limit
+	case trim.Approx:
+		trim.Limit = store.DefaultTrimLimit
+	}
+	return trim, noMk, n, true
+}
+
+// appendTrimAOF logs a trim as the exact MAXLEN it resulted in, so replay
+// removes the same entries even where an approximate trim would not.
+func (s *Server) appendTrimAOF(c *client, key string, length int) error {
+	return s.appendAOF(c, "XTRIM", []string{key, "MAXLEN", "=", strconv.Itoa(length)})
+}
+
+// XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func cmdXAdd(s *Server, c *client, args []string) error {
+	key := args[0]
+	trim, noMk, n, ok := parseStreamTrim(c.w, args[1:], true)
+	if !ok {
+		return nil
+	}
+	rest := args[1+n:]
+	if len(rest) < 3 || len(rest)%2 == 0 {
+		writeWrongArgs(c.w, "xadd")
+		return nil
+	}
+
+	var id store.XAddID
+	switch {
+	case rest[0] == "*":
+		id.AutoMs = true
+	case strings.HasSuffix(rest[0], "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(rest[0], "-*"), 10, 64)
+		if err != nil {
+			_ = resp.WriteError(c.w, msgInvalidStreamID)
+			return nil
+		}
+		id = store.XAddID{ID: store.StreamID{Ms: ms}, AutoSeq: true}
+	default:
+		if id.ID, ok = store.ParseStreamID(rest[0], 0); !ok {
+			_ = resp.WriteError(c.w, msgInvalidStreamID)
+			return nil
+		}
+	}
+
+	added, ok, err := c.db.XAdd(key, id, rest[1:], noMk, trim)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+
+	// Logged with the ID it got, so replay adds the same entry.
+	logArgs := append([]string{key, added.ID.String()}, rest[1:]...)
+	if err := s.appendAOF(c, "XADD", logArgs); err != nil {
+		return writeAOFError(c.w)
+	}
+	if added.Trimmed > 0 {
+		if err := s.appendTrimAOF(c, key, added.Length); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	s.signalKeyReady(c.dbIndex, key)
+	_ = resp.WriteBulkString(c.w, []byte(added.ID.String()))
+	return nil
+}
+
+// XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func cmdXTrim(s *Server, c *client, args []string) error {
+	trim, _, n, ok := parseStreamTrim(c.w, args[1:], false)
+	if !ok {
+		return nil
+	}
+	if n != len(args)-1 || trim.Strategy == store.TrimNone {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+
+	removed, length, err := c.db.XTrim(args[0], trim)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if removed > 0 {
+		if err := s.appendTrimAOF(c, args[0], length); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, removed)
+	return nil
+}
+
+func cmdXDel(s *Server, c *client, args []string) error {
+	ids, ok := parseStreamIDs(c.w, args[1:])
+	if !ok {
+		return nil
+	}
+	n, err := c.db.XDel(args[0], ids)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if n > 0 {
+		if err := s.appendAOF(c, "XDEL", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdXLen(s *Server, c *client, args []string) error {
+	n, err := c.db.XLen(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdXRange(s *Server, c *client, args []string) error {
+	return xrange(c, args[0], args[1], args[2], args[3:], false)
+}
+
+func cmdXRevRange(s *Server, c *client, args []string) error {
+	return xrange(c, args[0], args[2], args[1], args[3:], true)
+}
+
+// xrange implements XRANGE key start end [COUNT count] and XREVRANGE, which
+// takes end before start.
+func xrange(c *client, key, startArg, endArg string, opts []string, rev bool) error {
+	count := -1
+	if len(opts) > 0 {
+		if len(opts) != 2 || !strings.EqualFold(opts[0], "COUNT") {
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+		n, err := strconv.Atoi(opts[1])
+		if err != nil {
+			_ = resp.WriteError(c.w, msgNotInteger)
+			return nil
+		}
+		count = max(n, 0)
+	}
+	start, ok := parseRangeID(c.w, startArg, false)
+	if !ok {
+		return nil
+	}
+	end, ok := parseRangeID(c.w, endArg, true)
+	if !ok {
+		return nil
+	}
+
+	entries, err := c.db.XRange(key, start, end, count, rev)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	writeStreamEntries(c.w, entries)
+	return nil
+}
+
+// streamReadArgs are the options shared by XREAD and XREADGROUP.
+type streamReadArgs struct {
+	count   int // -1: no limit
+	block   bool
+	timeout time.Duration
+	noAck   bool
+	keys    []string
+	ids     []string
+}
+
+// parseStreamRead parses "[COUNT count] [BLOCK ms] [NOACK] STREAMS key ...
+// id ...", accepting NOACK only for XREADGROUP.
+func parseStreamRead(w *bufio.Writer, name string, args []string) (streamReadArgs, bool) {
+	ra := streamReadArgs{count: -1}
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "COUNT" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				_ = resp.WriteError(w, msgNotInteger)
+				return ra, false
+			}
+			if n > 0 {
+				ra.count = n
+			}
+			i++
+		case opt == "BLOCK" && i+1 < len(args):
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(w, "ERR timeout is not an integer or out of range")
+				return ra, false
+			}
+			if ms < 0 {
+				_ = resp.WriteError(w, "ERR timeout is negative")
+				return ra, false
+			}
+			ra.block, ra.timeout = true, time.Duration(min(ms, math.MaxInt64/int64(time.Millisecond)))*time.Millisecond
+			i++
+		case opt == "NOACK" && name == "xreadgroup":
+			ra.noAck = true
+		case opt == "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				_ = resp.WriteError(w, "ERR Unbalanced '"+name+"' list of streams: for each stream key an ID or '$' must be specified.")
+				return ra, false
+			}
+			ra.keys, ra.ids = rest[:len(rest)/2], rest[len(rest)/2:]
+			return ra, true
+		default:
+			_ = resp.WriteError(w, msgSyntax)
+			return ra, false
+		}
+	}
+	_ = resp.WriteError(w, msgSyntax)
+	return ra, false
+}
+
+// streamResult is the entries read from one stream.
+type streamResult struct {
+	key     string
+	entries []store.StreamEntry
+}
+
+func writeStreamResults(w *bufio.Writer, results []streamResult) {
+	_ = resp.WriteArrayHeader(w, len(results))
+	for _, r := range results {
+		_ = resp.WriteArrayHeader(w, 2)
+		_ = resp.WriteBulkString(w, []byte(r.key))
+		writeStreamEntries(w, r.entries)
+	}
+}
+
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+func cmdXRead(s *Server, c *client, args []string) error {
+	ra, ok := parseStreamRead(c.w, "xread", args)
+	if !ok {
+		return nil
+	}
+
+	// "$" means entries added from now on, so it is resolved before
+	// blocking.
+	after := make([]store.StreamID, len(ra.keys))
+	for i, arg := range ra.ids {
+		switch arg {
+		case "$":
+			last, _, err := c.db.XLastID(ra.keys[i])
+			if err != nil {
+				writeStoreError(c.w, err)
+				return nil
+			}
+			after[i] = last
+		case ">":
+			_ = resp.WriteError(c.w, "ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+			return nil
+		default:
+			if after[i], ok = store.ParseStreamID(arg, 0); !ok {
+				_ = resp.WriteError(c.w, msgInvalidStreamID)
+				return nil
+			}
+		}
+	}
+
+	try := func() (bool, error) {
+		var results []streamResult
+		for i, key := range ra.keys {
+			start, ok := after[i].Next()
+			if !ok {
+				continue
+			}
+			entries, err := c.db.XRange(key, start, store.MaxStreamID, ra.count, false)
+			if err != nil {
+				writeStoreError(c.w, err)
+				return true, nil
+			}
+			if len(entries) > 0 {
+				results = append(results, streamResult{key, entries})
+			}
+		}
+		if len(results) == 0 {
+			return false, nil
+		}
+		writeStreamResults(c.w, results)
+		return true, nil
+	}
+
+	var done bool
+	var err error
+	if ra.block {
+		done, err = s.blockOn(c, ra.keys, ra.timeout, try)
+	} else {
+		done, err = try()
+	}
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteNullArray(c.w)
+	}
+	return nil
+}
+
+// claimArgs are the arguments of the XCLAIM an entry delivered to consumer
+// is logged as, restoring its PEL entry exactly.
+func claimArgs(key, group string, p store.PendingEntry) []string {
+	return []string{key, group, p.Consumer, "0", p.ID.String(),
+		"TIME", strconv.FormatInt(p.DeliveryTime, 10),
+		"RETRYCOUNT", strconv.FormatInt(p.DeliveryCount, 10), "FORCE", "JUSTID"}
+}
+
+// appendClaimsAOF logs PEL changes: the delivered entries as XCLAIMs, the
+// pending entries found deleted as an XACK. They are bookkeeping, not
+// events, so they are not published as keyspace notifications.
+func (s *Server) appendClaimsAOF(c *client, key, group string, delivered []store.PendingEntry, deleted []store.StreamID, extra ...string) error {
+	for _, p := range delivered {
+		if err := s.appendAOFDB(c.dbIndex, "XCLAIM", append(claimArgs(key, group, p), extra...)); err != nil {
+			return err
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	args := []string{key, group}
+	for _, id := range deleted {
+		args = append(args, id.String())
+	}
+	return s.appendAOFDB(c.dbIndex, "XACK", args)
+}
+
+func writeNoGroupRead(w *bufio.Writer, key, group string) {
+	_ = resp.WriteError(w, "NOGROUP No such key '"+key+"' or consumer group '"+group+"' in XREADGROUP with GROUP option")
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+//
+// It is logged as what it changed: the consumer's creation, the group's new
+// position and an XCLAIM per delivered entry.
+func cmdXReadGroup(s *Server, c *client, args []string) error {
+	if !strings.EqualFold(args[0], "GROUP") {
+		_ = resp.WriteError(c.w, "ERR Missing GROUP option for XREADGROUP")
+		return nil
+	}
+	group, consumer := args[1], args[2]
+	ra, ok := parseStreamRead(c.w, "xreadgroup", args[3:])
+	if !ok {
+		return nil
+	}
+
+	after := make([]*store.StreamID, len(ra.keys))
+	history := false
+	for i, arg := range ra.ids {
+		if arg == ">" {
+			continue
+		}
+		id, ok := store.ParseStreamID(arg, 0)
+		if !ok {
+			_ = resp.WriteError(c.w, msgInvalidStreamID)
+			return nil
+		}
+		after[i], history = &id, true
+	}
+	for _, key := range ra.keys {
+		exists, err := c.db.XGroupExists(key, group)
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if !exists {
+			writeNoGroupRead(c.w, key, group)
+			return nil
+		}
+	}
+
+	try := func() (bool, error) {
+		var results []streamResult
+		for i, key := range ra.keys {
+			res, err := c.db.XReadGroup(key, group, consumer, after[i], ra.count, ra.noAck)
+			if err == store.ErrNoGroup {
+				// Deleted while we were blocked.
+				writeNoGroupRead(c.w, key, group)
+				return true, nil
+			}
+			if err != nil {
+				writeStoreError(c.w, err)
+				return true, nil
+			}
+			if err := s.appendReadGroupAOF(c, key, group, consumer, res); err != nil {
+				return true, writeAOFError(c.w)
+			}
+			if after[i] != nil || len(res.Entries) > 0 {
+				results = append(results, streamResult{key, res.Entries})
+			}
+		}
+		if len(results) == 0 {
+			return false, nil
+		}
+		writeStreamResults(c.w, results)
+		return true, nil
+	}
+
+	var done bool
+	var err error
+	if ra.block && !history {
+		done, err = s.blockOn(c, ra.keys, ra.timeout, try)
+	} else {
+		done, err = try()
+	}
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteNullArray(c.w)
+	}
+	return nil
+}
+
+func (s *Server) appendReadGroupAOF(c *client, key, group, consumer string, res store.StreamRead) error {
+	if res.CreatedConsumer {
+		if err := s.appendAOF(c, "XGROUP", []string{"CREATECONSUMER", key, group, consumer}); err != nil {
+			return err
+		}
+	}
+	if res.Advanced {
+		// Before the XCLAIMs: FORCE only creates entries the group has read.
+		setID := []string{"SETID", key, group, res.LastID.String(), "ENTRIESREAD", strconv.FormatInt(res.EntriesRead, 10)}
+		if err := s.appendAOFDB(c.dbIndex, "XGROUP", setID); err != nil {
+			return err
+		}
+	}
+	return s.appendClaimsAOF(c, key, group, res.Delivered, nil)
+}
+
+// parseGroupStart parses "id|$ [MKSTREAM] [ENTRIESREAD entries-read]" of
+// XGROUP CREATE and SETID, accepting MKSTREAM only when allowMk is set.
+func parseGroupStart(w *bufio.Writer, args []string, allowMk bool) (store.GroupStart, bool, bool) {
+	start := store.GroupStart{Entries: store.NoEntriesRead}
+	if args[0] == "$" {
+		start.Last = true
+	} else {
+		id, ok := store.ParseStreamID(args[0], 0)
+		if !ok {
+			_ = resp.WriteError(w, msgInvalidStreamID)
+			return start, false, false
+		}
+		start.ID = id
+	}
+
+	mk := false
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "MKSTREAM" && allowMk:
+			mk = true
+		case opt == "ENTRIESREAD" && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(w, msgNotInteger)
+				return start, false, false
+			}
+			if n < -1 {
+				_ = resp.WriteError(w, "ERR value for ENTRIESREAD must be positive or -1")
+				return start, false, false
+			}
+			start.Entries = n
+			i++
+		default:
+			_ = resp.WriteError(w, msgSyntax)
+			return start, false, false
+		}
+	}
+	return start, mk, true
+}
+
+func writeNoGroupForKey(w *bufio.Writer, key, group string) {
+	_ = resp.WriteError(w, "NOGROUP No such consumer group '"+group+"' for key name '"+key+"'")
+}
+
+// XGROUP CREATE | SETID | DESTROY | CREATECONSUMER | DELCONSUMER | HELP
+func cmdXGroup(s *Server, c *client, args []string) error {
+	sub := strings.ToLower(args[0])
+	arity := map[string]int{"create": -4, "setid": -4, "destroy": 3, "createconsumer": 4, "delconsumer": 4}
+	switch n, known := arity[sub]; {
+	case sub == "help":
+		writeStringArray(c.w, []string{
+			"XGROUP <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CREATE <key> <groupname> <id|$> [option]",
+			"    Create a new consumer group. Options are:",
+			"    * MKSTREAM",
+			"      Create the empty stream if it does not exist.",
+			"    * ENTRIESREAD entries_read",
+			"      Set the group's entries_read counter (internal use).",
+			"CREATECONSUMER <key> <groupname> <consumer>",
+			"    Create a new consumer in the specified group.",
+			"DELCONSUMER <key> <groupname> <consumer>",
+			"    Remove the specified consumer.",
+			"DESTROY <key> <groupname>",
+			"    Remove the specified group.",
+			"SETID <key> <groupname> <id|$> [ENTRIESREAD entries_read]",
+			"    Set the current group ID and entries_read counter.",
+		})
+		return nil
+	case !known:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try XGROUP HELP.")
+		return nil
+	case (n >= 0 && len(args) != n) || len(args) < -n:
+		writeWrongArgs(c.w, "xgroup|"+sub)
+		return nil
+	}
+
+	key, group := args[1], args[2]
+	_, exists, err := c.db.XLastID(key)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	mkStream := false
+	var start store.GroupStart
+	if sub == "create" || sub == "setid" {
+		var ok bool
+		if start, mkStream, ok = parseGroupStart(c.w, args[3:], sub == "create"); !ok {
+			return nil
+		}
+	}
+	if !exists && !mkStream {
+		_ = resp.WriteError(c.w, msgXGroupNoKey)
+		return nil
+	}
+
+	switch sub {
+	case "create", "setid":
+		var id store.StreamID
+		var read int64
+		if sub == "create" {
+			id, read, err = c.db.XGroupCreate(key, group, start, mkStream)
+		} else {
+			id, read, err = c.db.XGroupSetID(key, group, start)
+		}
+		if err == store.ErrNoGroup {
+			writeNoGroupForKey(c.w, key, group)
+			return nil
+		}
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		logArgs := []string{strings.ToUpper(sub), key, group, id.String(), "ENTRIESREAD", strconv.FormatInt(read, 10)}
+		if mkStream {
+			logArgs = append(logArgs, "MKSTREAM")
+		}
+		if err := s.appendAOF(c, "XGROUP", logArgs); err != nil {
+			return writeAOFError(c.w)
+		}
+		_ = resp.WriteSimpleString(c.w, "OK")
+
+	case "destroy":
+		destroyed, err := c.db.XGroupDestroy(key, group)
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if destroyed {
+			if err := s.appendAOF(c, "XGROUP", args); err != nil {
+				return writeAOFError(c.w)
+			}
+			// Wake XREADGROUP clients blocked on the group so they fail.
+			s.signalKeyReady(c.dbIndex, key)
+			_ = resp.WriteInteger(c.w, 1)
+		} else {
+			_ = resp.WriteInteger(c.w, 0)
+		}
+
+	case "createconsumer":
+		created, err := c.db.XGroupCreateConsumer(key, group, args[3])
+		if err == store.ErrNoGroup {
+			writeNoGroupForKey(c.w, key, group)
+			return nil
+		}
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if created {
+			if err := s.appendAOF(c, "XGROUP", args); err != nil {
+				return writeAOFError(c.w)
+			}
+			_ = resp.WriteInteger(c.w, 1)
+		} else {
+			_ = resp.WriteInteger(c.w, 0)
+		}
+
+	case "delconsumer":
+		pending, existed, err := c.db.XGroupDelConsumer(key, group, args[3])
+		if err == store.ErrNoGroup {
+			writeNoGroupForKey(c.w, key, group)
+			return nil
+		}
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if existed {
+			if err := s.appendAOF(c, "XGROUP", args); err != nil {
+				return writeAOFError(c.w)
+			}
+		}
+		_ = resp.WriteInteger(c.w, int64(pending))
+	}
+	return nil
+}
+
+// XSETID key last-id [ENTRIESADDED entries-added] [MAXDELETEDID max-deleted-id]
+func cmdXSetID(s *Server, c *client, args []string) error {
+	lastID, ok := store.ParseStreamID(args[1], 0)
+	if !ok {
+		_ = resp.WriteError(c.w, msgInvalidStreamID)
+		return nil
+	}
+	var entriesAdded *uint64
+	var maxDeleted *store.StreamID
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+		switch strings.ToUpper(args[i]) {
+		case "ENTRIESADDED":
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(c.w, msgNotInteger)
+				return nil
+			}
+			if n < 0 {
+				_ = resp.WriteError(c.w, "ERR entries_added must be positive")
+				return nil
+			}
+			u := uint64(n)
+			entriesAdded = &u
+		case "MAXDELETEDID":
+			id, ok := store.ParseStreamID(args[i+1], 0)
+			if !ok {
+				_ = resp.WriteError(c.w, msgInvalidStreamID)
+				return nil
+			}
+			maxDeleted = &id
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	if err := c.db.XSetID(args[0], lastID, entriesAdded, maxDeleted); err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "XSETID", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// XACK key group id [id ...]
+func cmdXAck(s *Server, c *client, args []string) error {
+	ids, ok := parseStreamIDs(c.w, args[2:])
+	if !ok {
+		return nil
+	}
+	n, err := c.db.XAck(args[0], args[1], ids)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if n > 0 {
+		if err := s.appendAOF(c, "XACK", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func writeNoGroup(w *bufio.Writer, key, group string) {
+	_ = resp.WriteError(w, "NOGROUP No such key '"+key+"' or consumer group '"+group+"'")
+}
+
+// XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func cmdXPending(s *Server, c *client, args []string) error {
+	key, group := args[0], args[1]
+	if len(args) == 2 {
+		sum, err := c.db.XPendingSummary(key, group)
+		if err == store.ErrNoGroup {
+			writeNoGroup(c.w, key, group)
+			return nil
+		}
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, 4)
+		_ = resp.WriteInteger(c.w, int64(sum.Count))
+		if sum.Count == 0 {
+			_ = resp.WriteBulkString(c.w, nil)
+			_ = resp.WriteBulkString(c.w, nil)
+			_ = resp.WriteNullArray(c.w)
+			return nil
+		}
+		_ = resp.WriteBulkString(c.w, []byte(sum.Min.String()))
+		_ = resp.WriteBulkString(c.w, []byte(sum.Max.String()))
+		_ = resp.WriteArrayHeader(c.w, len(sum.Consumers))
+		for _, cp := range sum.Consumers {
+			writeStringArray(c.w, []string{cp.Name, strconv.Itoa(cp.Pending)})
+		}
+		return nil
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if strings.EqualFold(rest[0], "IDLE") && len(rest) > 1 {
+		ms, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			_ = resp.WriteError(c.w, msgNotInteger)
+			return nil
+		}
+		minIdle = time.Duration(max(ms, 0)) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) < 3 || len(rest) > 4 {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+	start, ok := parseRangeID(c.w, rest[0], false)
+	if !ok {
+		return nil
+	}
+	end, ok := parseRangeID(c.w, rest[1], true)
+	if !ok {
+		return nil
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = rest[3]
+	}
+
+	pending, err := c.db.XPending(key, group, start, end, max(count, 0), consumer, minIdle)
+	if err == store.ErrNoGroup {
+		writeNoGroup(c.w, key, group)
+		return nil
+	}
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	_ = resp.WriteArrayHeader(c.w, len(pending))
+	for _, p := range pending {
+		_ = resp.WriteArrayHeader(c.w, 4)
+		_ = resp.WriteBulkString(c.w, []byte(p.ID.String()))
+		_ = resp.WriteBulkString(c.w, []byte(p.Consumer))
+		_ = resp.WriteInteger(c.w, max(now-p.DeliveryTime, 0))
+		_ = resp.WriteInteger(c.w, p.DeliveryCount)
+	}
+	return nil
+}
+
+// parseMinIdle parses the min-idle-time argument of XCLAIM and XAUTOCLAIM.
+func parseMinIdle(w *bufio.Writer, name, arg string) (time.Duration, bool) {
+	ms, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		_ = resp.WriteError(w, "ERR Invalid min-idle-time argument for "+name)
+		return 0, false
+	}
+	return time.Duration(min(max(ms, 0), math.MaxInt64/int64(time.Millisecond))) * time.Millisecond, true
+}
+
+// XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+// [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func cmdXClaim(s *Server, c *client, args []string) error {
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, ok := parseMinIdle(c.w, "XCLAIM", args[3])
+	if !ok {
+		return nil
+	}
+
+	i := 4
+	var ids []store.StreamID
+	for ; i < len(args); i++ {
+		id, ok := store.ParseStreamID(args[i], 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		_ = resp.WriteError(c.w, msgInvalidStreamID)
+		return nil
+	}
+
+	opts := store.ClaimOptions{RetryCount: -1}
+	var extra []string // LASTID, repeated when logging
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "FORCE":
+			opts.Force = true
+		case opt == "JUSTID":
+			opts.JustID = true
+		case (opt == "IDLE" || opt == "TIME" || opt == "RETRYCOUNT") && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(c.w, "ERR Invalid "+opt+" option argument for XCLAIM")
+				return nil
+			}
+			switch opt {
+			case "IDLE":
+				opts.Time = time.Now().UnixMilli() - max(n, 0)
+			case "TIME":
+				opts.Time = n
+			case "RETRYCOUNT":
+				opts.RetryCount = max(n, 0)
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			id, ok := store.ParseStreamID(args[i+1], 0)
+			if !ok {
+				_ = resp.WriteError(c.w, msgInvalidStreamID)
+				return nil
+			}
+			opts.LastID = &id
+			extra = []string{"LASTID", args[i+1]}
+			i++
+		default:
+			_ = resp.WriteError(c.w, "ERR Unrecognized XCLAIM option '"+args[i]+"'")
+			return nil
+		}
+	}
+
+	claimed, delivered, deleted, err := c.db.XClaim(key, group, consumer, minIdle, ids, opts)
+	if err == store.ErrNoGroup {
+		writeNoGroup(c.w, key, group)
+		return nil
+	}
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendClaimsAOF(c, key, group, delivered, deleted, extra...); err != nil {
+		return writeAOFError(c.w)
+	}
+	writeClaimed(c.w, claimed, opts.JustID)
+	return nil
+}
+
+// XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func cmdXAutoClaim(s *Server, c *client, args []string) error {
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, ok := parseMinIdle(c.w, "XAUTOCLAIM", args[3])
+	if !ok {
+		return nil
+	}
+	start, ok := parseRangeID(c.w, args[4], false)
+	if !ok {
+		return nil
+	}
+
+	count, justID := 100, false
+	for i := 5; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "JUSTID":
+			justID = true
+		case opt == "COUNT" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				_ = resp.WriteError(c.w, msgNotInteger)
+				return nil
+			}
+			if n < 1 || n > math.MaxInt/10 {
+				_ = resp.WriteError(c.w, "ERR COUNT must be > 0")
+				return nil
+			}
+			count = n
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	next, claimed, delivered, deleted, err := c.db.XAutoClaim(key, group, consumer, minIdle, start, count, justID)
+	if err == store.ErrNoGroup {
+		writeNoGroup(c.w, key, group)
+		return nil
+	}
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendClaimsAOF(c, key, group, delivered, deleted); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteArrayHeader(c.w, 3)
+	_ = resp.WriteBulkString(c.w, []byte(next.String()))
+	writeClaimed(c.w, claimed, justID)
+	writeStreamIDs(c.w, deleted)
+	return nil
+}
+
+// XINFO STREAM key | GROUPS key | CONSUMERS key group | HELP
+func cmdXInfo(s *Server, c *client, args []string) error {
+	sub := strings.ToUpper(args[0])
+	switch {
+	case sub == "HELP":
+		writeStringArray(c.w, []string{
+			"XINFO <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CONSUMERS <key> <groupname>",
+			"    Show consumers of <groupname>.",
+			"GROUPS <key>",
+			"    Show the stream consumer groups.",
+			"STREAM <key>",
+			"    Show information about the stream.",
+		})
+	case sub == "STREAM" && len(args) == 2:
+		info, ok, err := c.db.XInfoStream(args[1])
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if !ok {
+			_ = resp.WriteError(c.w, "ERR no such key")
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, 20)
+		writeInfoInt(c.w, "length", int64(info.Length))
+		writeInfoInt(c.w, "radix-tree-keys", int64(info.Nodes))
+		writeInfoInt(c.w, "radix-tree-nodes", int64(info.Nodes))
+		writeInfoID(c.w, "last-generated-id", info.LastID)
+		writeInfoID(c.w, "max-deleted-entry-id", info.MaxDeletedID)
+		writeInfoInt(c.w, "entries-added", int64(info.EntriesAdded))
+		writeInfoID(c.w, "recorded-first-entry-id", info.FirstID)
+		writeInfoInt(c.w, "groups", int64(info.Groups))
+		for _, e := range []struct {
+			name  string
+			entry *store.StreamEntry
+		}{{"first-entry", info.First}, {"last-entry", info.Last}} {
+			_ = resp.WriteBulkString(c.w, []byte(e.name))
+			if e.entry == nil {
+				_ = resp.WriteBulkString(c.w, nil)
+			} else {
+				writeStreamEntry(c.w, *e.entry)
+			}
+		}
+	case sub == "GROUPS" && len(args) == 2:
+		groups, ok, err := c.db.XInfoGroups(args[1])
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		if !ok {
+			_ = resp.WriteError(c.w, "ERR no such key")
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, len(groups))
+		for _, g := range groups {
+			_ = resp.WriteArrayHeader(c.w, 12)
+			_ = resp.WriteBulkString(c.w, []byte("name"))
+			_ = resp.WriteBulkString(c.w, []byte(g.Name))
+			writeInfoInt(c.w, "consumers", int64(g.Consumers))
+			writeInfoInt(c.w, "pending", int64(g.Pending))
+			writeInfoID(c.w, "last-delivered-id", g.LastID)
+			writeInfoOptInt(c.w, "entries-read", g.EntriesRead)
+			writeInfoOptInt(c.w, "lag", g.Lag)
+		}
+	case sub == "CONSUMERS" && len(args) == 3:
+		consumers, err := c.db.XInfoConsumers(args[1], args[2])
+		if err == store.ErrNoGroup {
+			writeNoGroupForKey(c.w, args[1], args[2])
+			return nil
+		}
+		if err != nil {
+			writeStoreError(c.w, err)
+			return nil
+		}
+		_ = resp.WriteArrayHeader(c.w, len(consumers))
+		for _, ci := range consumers {
+			_ = resp.WriteArrayHeader(c.w, 8)
+			_ = resp.WriteBulkString(c.w, []byte("name"))
+			_ = resp.WriteBulkString(c.w, []byte(ci.Name))
+			writeInfoInt(c.w, "pending", int64(ci.Pending))
+			writeInfoInt(c.w, "idle", ci.Idle.Milliseconds())
+			writeInfoInt(c.w, "inactive", max(ci.Inactive.Milliseconds(), -1))
+		}
+	case sub == "STREAM" || sub == "GROUPS" || sub == "CONSUMERS":
+		writeWrongArgs(c.w, "xinfo|"+strings.ToLower(sub))
+	default:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try XINFO HELP.")
+	}
+	return nil
+}
+
+func writeInfoInt(w *bufio.Writer, name string, n int64) {
+	_ = resp.WriteBulkString(w, []byte(name))
+	_ = resp.WriteInteger(w, n)
+}
+
+// writeInfoOptInt writes n, or nil if it is unknown (negative).
+func writeInfoOptInt(w *bufio.Writer, name string, n int64) {
+	if n < 0 {
+		_ = resp.WriteBulkString(w, []byte(name))
+		_ = resp.WriteBulkString(w, nil)
+		return
+	}
+	writeInfoInt(w, name, n)
+}
+
+func writeInfoID(w *bufio.Writer, name string, id store.StreamID) {
+	_ = resp.WriteBulkString(w, []byte(name))
+	_ = resp.WriteBulkString(w, []byte(id.String()))
+}
@@ -0,0 +1,531 @@
+// internal/server/commands_string.go
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// SET key value [NX|XX] [GET] [EX s|PX ms|EXAT ts|PXAT ms-ts|KEEPTTL]
+func cmdSet(s *Server, c *client, args []string) error {
+	key := args[0]
+	val := args[1]
+
+	var opts store.SetOptions
+	var expireMs int64
+	expiry := "" // which of EX/PX/EXAT/PXAT/KEEPTTL was given
+
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "NX" && !opts.XX:
+			opts.NX = true
+		case opt == "XX" && !opts.NX:
+			opts.XX = true
+		case opt == "GET":
+			opts.Get = true
+		case opt == "KEEPTTL" && expiry == "":
+			opts.KeepTTL = true
+			expiry = opt
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && expiry == "" && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(c.w, msgNotInteger)
+				return nil
+			}
+			ms, ok := absExpireMs(opt, n)
+			if !ok {
+				_ = resp.WriteError(c.w, "ERR invalid expire time in 'set' command")
+				return nil
+			}
+			expireMs = ms
+			expiry = opt
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	if expiry != "" && !opts.KeepTTL {
+		at := time.UnixMilli(expireMs)
+		opts.ExpireAt = &at
+	}
+
+	old, hadOld, written, err := c.db.SetWithOptions(key, []byte(val), opts)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	if written {
+		// Relative expiries are logged as absolute ones so replay does not
+		// extend them; NX/XX/GET have already been decided.
+		logArgs := []string{key, val}
+		switch {
+		case opts.KeepTTL:
+			logArgs = append(logArgs, "KEEPTTL")
+		case opts.ExpireAt != nil:
+			logArgs = append(logArgs, "PXAT", strconv.FormatInt(expireMs, 10))
+		}
+		if err := s.appendAOF(c, "SET", logArgs); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+
+	switch {
+	case opts.Get && hadOld:
+		_ = resp.WriteBulkString(c.w, old)
+	case opts.Get || !written:
+		_ = resp.WriteBulkString(c.w, nil)
+	default:
+		_ = resp.WriteSimpleString(c.w, "OK")
+	}
+	return nil
+}
+
+// absExpireMs converts an EX / PX / EXAT / PXAT argument to an absolute unix
+// time in milliseconds. It reports false for non-positive or overflowing
+// values.
+func absExpireMs(unit string, n int64) (int64, bool) {
+	if n <= 0 {
+		return 0, false
+	}
+	switch unit {
+	case "EX", "EXAT":
+		if n > math.MaxInt64/1000 {
+			return 0, false
+		}
+		n *= 1000
+	}
+	if unit == "EX" || unit == "PX" {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, false
+		}
+		n += now
+	}
+	return n, true
+}
+
+func cmdGet(s *Server, c *client, args []string) error {
+	val, ok, err := c.db.GetString(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, val)
+	return nil
+}
+
+func cmdIncr(s *Server, c *client, args []string) error {
+	return s.incrBy(c, args[0], 1)
+}
+
+func cmdDecr(s *Server, c *client, args []string) error {
+	return s.incrBy(c, args[0], -1)
+}
+
+func cmdIncrBy(s *Server, c *client, args []string) error {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	return s.incrBy(c, args[0], delta)
+}
+
+func cmdDecrBy(s *Server, c *client, args []string) error {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	if delta == math.MinInt64 {
+		_ = resp.WriteError(c.w, "ERR decrement would overflow")
+		return nil
+	}
+	return s.incrBy(c, args[0], -delta)
+}
+
+// incrBy applies an integer increment and logs the resulting value (keeping
+// the key's TTL) so replay is idempotent.
+func (s *Server) incrBy(c *client, key string, delta int64) error {
+	n, err := c.db.IncrBy(key, delta)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "SET", []string{key, strconv.FormatInt(n, 10), "KEEPTTL"}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, n)
+	return nil
+}
+
+func cmdIncrByFloat(s *Server, c *client, args []string) error {
+	delta, ok := parseFloatArg(args[1])
+	if !ok {
+		_ = resp.WriteError(c.w, msgNotFloat)
+		return nil
+	}
+
+	val, err := c.db.IncrByFloat(args[0], delta)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "SET", []string{args[0], val, "KEEPTTL"}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteBulkString(c.w, []byte(val))
+	return nil
+}
+
+// GETSET key value
+func cmdGetSet(s *Server, c *client, args []string) error {
+	old, hadOld, _, err := c.db.SetWithOptions(args[0], []byte(args[1]), store.SetOptions{Get: true})
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "SET", []string{args[0], args[1]}); err != nil {
+		return writeAOFError(c.w)
+	}
+	if !hadOld {
+		old = nil
+	}
+	_ = resp.WriteBulkString(c.w, old)
+	return nil
+}
+
+// SETNX key value
+func cmdSetNX(s *Server, c *client, args []string) error {
+	_, _, written, err := c.db.SetWithOptions(args[0], []byte(args[1]), store.SetOptions{NX: true})
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !written {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "SET", []string{args[0], args[1]}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+// SETEX key seconds value
+func cmdSetEx(s *Server, c *client, args []string) error {
+	return s.setWithExpiry(c, "setex", "EX", args)
+}
+
+// PSETEX key milliseconds value
+func cmdPSetEx(s *Server, c *client, args []string) error {
+	return s.setWithExpiry(c, "psetex", "PX", args)
+}
+
+// setWithExpiry implements SETEX / PSETEX. Like SET EX, the expiry is logged
+// as an absolute PXAT.
+func (s *Server) setWithExpiry(c *client, name, unit string, args []string) error {
+	key, val := args[0], args[2]
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	ms, ok := absExpireMs(unit, n)
+	if !ok {
+		_ = resp.WriteError(c.w, "ERR invalid expire time in '"+name+"' command")
+		return nil
+	}
+
+	at := time.UnixMilli(ms)
+	if _, _, _, err := c.db.SetWithOptions(key, []byte(val), store.SetOptions{ExpireAt: &at}); err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "SET", []string{key, val, "PXAT", strconv.FormatInt(ms, 10)}); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// APPEND key value
+func cmdAppend(s *Server, c *client, args []string) error {
+	n, err := c.db.Append(args[0], []byte(args[1]))
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "APPEND", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdStrLen(s *Server, c *client, args []string) error {
+	n, err := c.db.StrLen(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+// GETRANGE key start end
+func cmdGetRange(s *Server, c *client, args []string) error {
+	start, err1 := strconv.Atoi(args[1])
+	end, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	val, err := c.db.GetRange(args[0], start, end)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, val)
+	return nil
+}
+
+// SETRANGE key offset value
+func cmdSetRange(s *Server, c *client, args []string) error {
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	if offset < 0 {
+		_ = resp.WriteError(c.w, "ERR offset is out of range")
+		return nil
+	}
+
+	n, err := c.db.SetRange(args[0], offset, []byte(args[2]))
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	// An empty value never modifies the key.
+	if args[2] != "" {
+		if err := s.appendAOF(c, "SETRANGE", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+// GETDEL key
+func cmdGetDel(s *Server, c *client, args []string) error {
+	val, ok, err := c.db.GetDel(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	if err := s.appendAOF(c, "DEL", args[:1]); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteBulkString(c.w, val)
+	return nil
+}
+
+// GETEX key [EX s|PX ms|EXAT ts|PXAT ms-ts|PERSIST]
+func cmdGetEx(s *Server, c *client, args []string) error {
+	key := args[0]
+	var opts store.GetExOptions
+	var expireMs int64
+
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "PERSIST" && len(args) == 2:
+			opts.Persist = true
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && len(args) == 3:
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				_ = resp.WriteError(c.w, msgNotInteger)
+				return nil
+			}
+			ms, ok := absExpireMs(opt, n)
+			if !ok {
+				_ = resp.WriteError(c.w, "ERR invalid expire time in 'getex' command")
+				return nil
+			}
+			expireMs = ms
+			at := time.UnixMilli(ms)
+			opts.ExpireAt = &at
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	val, ok, err := c.db.GetEx(key, opts)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+
+	switch {
+	case opts.Persist:
+		err = s.appendAOF(c, "PERSIST", []string{key})
+	case opts.ExpireAt != nil:
+		err = s.appendAOF(c, "PEXPIREAT", []string{key, strconv.FormatInt(expireMs, 10)})
+	}
+	if err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteBulkString(c.w, val)
+	return nil
+}
+
+// MGET key [key ...]
+func cmdMGet(s *Server, c *client, args []string) error {
+	writeBulkArray(c.w, c.db.MGet(args))
+	return nil
+}
+
+// MSET key value [key value ...]
+//
+// The whole batch is applied under one store lock and logged as a single AOF
+// entry, so replay never sees half of it.
+func cmdMSet(s *Server, c *client, args []string) error {
+	pairs, ok := parseKeyValues(c, "mset", args)
+	if !ok {
+		return nil
+	}
+	c.db.MSet(pairs)
+	if err := s.appendAOF(c, "MSET", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// MSETNX key value [key value ...]
+func cmdMSetNX(s *Server, c *client, args []string) error {
+	pairs, ok := parseKeyValues(c, "msetnx", args)
+	if !ok {
+		return nil
+	}
+	if !c.db.MSetNX(pairs) {
+		_ = resp.WriteInteger(c.w, 0)
+		return nil
+	}
+	if err := s.appendAOF(c, "MSETNX", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	_ = resp.WriteInteger(c.w, 1)
+	return nil
+}
+
+// parseKeyValues turns MSET-style arguments into pairs, replying with an
+// arity error if a key has no value.
+func parseKeyValues(c *client, name string, args []string) ([]store.KeyValue, bool) {
+	if len(args)%2 != 0 {
+		writeWrongArgs(c.w, name)
+		return nil, false
+	}
+	pairs := make([]store.KeyValue, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs = append(pairs, store.KeyValue{Key: args[i], Value: []byte(args[i+1])})
+	}
+	return pairs, true
+}
+
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func cmdLCS(s *Server, c *client, args []string) error {
+	var getLen, getIdx, withMatchLen bool
+	minMatchLen := 0
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				_ = resp.WriteError(c.w, msgNotInteger)
+				return nil
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+	if getLen && getIdx {
+		_ = resp.WriteError(c.w, "ERR If you want both the length and indexes, please just use IDX.")
+		return nil
+	}
+
+	seq, matches, err := c.db.LCS(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	switch {
+	case getLen:
+		_ = resp.WriteInteger(c.w, int64(len(seq)))
+	case getIdx:
+		kept := make([]store.LCSMatch, 0, len(matches))
+		for _, m := range matches {
+			if m.Len >= minMatchLen {
+				kept = append(kept, m)
+			}
+		}
+		_ = resp.WriteArrayHeader(c.w, 4)
+		_ = resp.WriteBulkString(c.w, []byte("matches"))
+		_ = resp.WriteArrayHeader(c.w, len(kept))
+		for _, m := range kept {
+			fields := 2
+			if withMatchLen {
+				fields = 3
+			}
+			_ = resp.WriteArrayHeader(c.w, fields)
+			for _, r := range [][2]int{m.A, m.B} {
+				_ = resp.WriteArrayHeader(c.w, 2)
+				_ = resp.WriteInteger(c.w, int64(r[0]))
+				_ = resp.WriteInteger(c.w, int64(r[1]))
+			}
+			if withMatchLen {
+				_ = resp.WriteInteger(c.w, int64(m.Len))
+			}
+		}
+		_ = resp.WriteBulkString(c.w, []byte("len"))
+		_ = resp.WriteInteger(c.w, int64(len(seq)))
+	default:
+		_ = resp.WriteBulkString(c.w, seq)
+	}
+	return nil
+}
@@ -0,0 +1,575 @@
+// internal/server/commands_zset.go
+package server
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+const msgNotScore = "ERR min or max is not a float"
+
+// writeScoreMembers writes a flat [member, score, ...] array, or just the
+// members when withScores is false.
+func writeScoreMembers(w *bufio.Writer, items []store.ScoreMember, withScores bool) {
+	if withScores {
+		_ = resp.WriteArrayHeader(w, 2*len(items))
+	} else {
+		_ = resp.WriteArrayHeader(w, len(items))
+	}
+	for _, it := range items {
+		_ = resp.WriteBulkString(w, []byte(it.Member))
+		if withScores {
+			_ = resp.WriteBulkString(w, []byte(store.FormatFloat(it.Score)))
+		}
+	}
+}
+
+// parseScoreBound parses a ZRANGE-style score bound: a float, optionally
+// prefixed with "(" to make it exclusive.
+func parseScoreBound(arg string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	f, ok := parseFloatArg(arg)
+	return f, exclusive, ok
+}
+
+func parseScoreRange(minArg, maxArg string) (store.ScoreRange, bool) {
+	var r store.ScoreRange
+	var ok1, ok2 bool
+	r.Min, r.MinExclusive, ok1 = parseScoreBound(minArg)
+	r.Max, r.MaxExclusive, ok2 = parseScoreBound(maxArg)
+	return r, ok1 && ok2
+}
+
+// parseLexBound parses "-", "+", "[member" or "(member".
+func parseLexBound(arg string) (store.LexBound, bool) {
+	switch {
+	case arg == "-":
+		return store.LexBound{Inf: -1}, true
+	case arg == "+":
+		return store.LexBound{Inf: 1}, true
+	case strings.HasPrefix(arg, "["):
+		return store.LexBound{Value: arg[1:]}, true
+	case strings.HasPrefix(arg, "("):
+		return store.LexBound{Value: arg[1:], Exclusive: true}, true
+	default:
+		return store.LexBound{}, false
+	}
+}
+
+// parseZRangeSpec parses "start stop [BYSCORE|BYLEX] [REV] [LIMIT offset
+// count] [WITHSCORES]" and writes an error reply when the arguments are
+// invalid. WITHSCORES is only accepted when allowWithScores is set.
+func parseZRangeSpec(w *bufio.Writer, args []string, allowWithScores bool) (store.ZRangeSpec, bool, bool) {
+	spec := store.ZRangeSpec{Count: -1}
+	withScores, limit := false, false
+
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "BYSCORE":
+			spec.By = store.ZByScore
+		case opt == "BYLEX":
+			spec.By = store.ZByLex
+		case opt == "REV":
+			spec.Rev = true
+		case opt == "WITHSCORES" && allowWithScores:
+			withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			off, err1 := strconv.Atoi(args[i+1])
+			cnt, err2 := strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				_ = resp.WriteError(w, msgNotInteger)
+				return spec, false, false
+			}
+			spec.Offset, spec.Count, limit = off, cnt, true
+			i += 2
+		default:
+			_ = resp.WriteError(w, msgSyntax)
+			return spec, false, false
+		}
+	}
+
+	if limit && spec.By == store.ZByRank {
+		_ = resp.WriteError(w, "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return spec, false, false
+	}
+	if withScores && spec.By == store.ZByLex {
+		_ = resp.WriteError(w, "ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		return spec, false, false
+	}
+
+	// With REV, score and lex ranges are given as max then min.
+	minArg, maxArg := args[0], args[1]
+	if spec.Rev && spec.By != store.ZByRank {
+		minArg, maxArg = maxArg, minArg
+	}
+
+	switch spec.By {
+	case store.ZByRank:
+		start, err1 := strconv.Atoi(args[0])
+		stop, err2 := strconv.Atoi(args[1])
+		if err1 != nil || err2 != nil {
+			_ = resp.WriteError(w, msgNotInteger)
+			return spec, false, false
+		}
+		spec.Start, spec.Stop = start, stop
+	case store.ZByScore:
+		r, ok := parseScoreRange(minArg, maxArg)
+		if !ok {
+			_ = resp.WriteError(w, msgNotScore)
+			return spec, false, false
+		}
+		spec.Score = r
+	case store.ZByLex:
+		lo, ok1 := parseLexBound(minArg)
+		hi, ok2 := parseLexBound(maxArg)
+		if !ok1 || !ok2 {
+			_ = resp.WriteError(w, "ERR min or max not valid string range item")
+			return spec, false, false
+		}
+		spec.Lex = store.LexRange{Min: lo, Max: hi}
+	}
+	return spec, withScores, true
+}
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func cmdZAdd(s *Server, c *client, args []string) error {
+	key := args[0]
+	var opts store.ZAddOptions
+	ch, incr := false, false
+
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+	if opts.NX && opts.XX {
+		_ = resp.WriteError(c.w, "ERR XX and NX options at the same time are not compatible")
+		return nil
+	}
+	if (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)) {
+		_ = resp.WriteError(c.w, "ERR GT, LT, and/or NX options at the same time are not compatible")
+		return nil
+	}
+	if incr && len(rest) > 2 {
+		_ = resp.WriteError(c.w, "ERR INCR option supports a single increment-element pair")
+		return nil
+	}
+
+	items := make([]store.ScoreMember, 0, len(rest)/2)
+	for j := 0; j < len(rest); j += 2 {
+		score, ok := parseFloatArg(rest[j])
+		if !ok {
+			_ = resp.WriteError(c.w, msgNotFloat)
+			return nil
+		}
+		items = append(items, store.ScoreMember{Member: rest[j+1], Score: score})
+	}
+
+	if incr {
+		return s.zincr(c, key, opts, items[0].Score, items[0].Member)
+	}
+
+	added, updated, err := c.db.ZAdd(key, opts, items)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if added+updated > 0 {
+		if err := s.appendAOF(c, "ZADD", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	if added > 0 {
+		s.signalKeyReady(c.dbIndex, key)
+	}
+
+	if ch {
+		_ = resp.WriteInteger(c.w, int64(added+updated))
+	} else {
+		_ = resp.WriteInteger(c.w, int64(added))
+	}
+	return nil
+}
+
+func cmdZIncrBy(s *Server, c *client, args []string) error {
+	delta, ok := parseFloatArg(args[1])
+	if !ok {
+		_ = resp.WriteError(c.w, msgNotFloat)
+		return nil
+	}
+	return s.zincr(c, args[0], store.ZAddOptions{}, delta, args[2])
+}
+
+// zincr implements ZINCRBY and ZADD ... INCR. The resulting score is logged
+// so replay is idempotent.
+func (s *Server) zincr(c *client, key string, opts store.ZAddOptions, delta float64, member string) error {
+	score, applied, err := c.db.ZIncr(key, opts, delta, member)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !applied {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+
+	formatted := store.FormatFloat(score)
+	if err := s.appendAOF(c, "ZADD", []string{key, formatted, member}); err != nil {
+		return writeAOFError(c.w)
+	}
+	s.signalKeyReady(c.dbIndex, key)
+	_ = resp.WriteBulkString(c.w, []byte(formatted))
+	return nil
+}
+
+func cmdZRem(s *Server, c *client, args []string) error {
+	removed, err := c.db.ZRem(args[0], args[1:])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if removed > 0 {
+		if err := s.appendAOF(c, "ZREM", args); err != nil {
+			return writeAOFError(c.w)
+		}
+	}
+	_ = resp.WriteInteger(c.w, int64(removed))
+	return nil
+}
+
+func cmdZScore(s *Server, c *client, args []string) error {
+	score, ok, err := c.db.ZScore(args[0], args[1])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteBulkString(c.w, nil)
+		return nil
+	}
+	_ = resp.WriteBulkString(c.w, []byte(store.FormatFloat(score)))
+	return nil
+}
+
+func cmdZCard(s *Server, c *client, args []string) error {
+	n, err := c.db.ZCard(args[0])
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdZCount(s *Server, c *client, args []string) error {
+	r, ok := parseScoreRange(args[1], args[2])
+	if !ok {
+		_ = resp.WriteError(c.w, msgNotScore)
+		return nil
+	}
+	n, err := c.db.ZCount(args[0], r)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+// ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func cmdZRange(s *Server, c *client, args []string) error {
+	spec, withScores, ok := parseZRangeSpec(c.w, args[1:], true)
+	if !ok {
+		return nil
+	}
+	items, err := c.db.ZRange(args[0], spec)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	writeScoreMembers(c.w, items, withScores)
+	return nil
+}
+
+// ZRANGESTORE dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]
+func cmdZRangeStore(s *Server, c *client, args []string) error {
+	spec, _, ok := parseZRangeSpec(c.w, args[2:], false)
+	if !ok {
+		return nil
+	}
+	n, err := c.db.ZRangeStore(args[0], args[1], spec)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, "ZRANGESTORE", args); err != nil {
+		return writeAOFError(c.w)
+	}
+	if n > 0 {
+		s.signalKeyReady(c.dbIndex, args[0])
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdZRank(s *Server, c *client, args []string) error {
+	return s.zrank(c, "ZRANK", args, false)
+}
+
+func cmdZRevRank(s *Server, c *client, args []string) error {
+	return s.zrank(c, "ZREVRANK", args, true)
+}
+
+func (s *Server) zrank(c *client, name string, args []string, rev bool) error {
+	withScore := false
+	switch {
+	case len(args) == 3 && strings.ToUpper(args[2]) == "WITHSCORE":
+		withScore = true
+	case len(args) > 2:
+		writeWrongArgs(c.w, name)
+		return nil
+	}
+
+	rank, score, ok, err := c.db.ZRank(args[0], args[1], rev)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	switch {
+	case !ok && withScore:
+		_ = resp.WriteNullArray(c.w)
+	case !ok:
+		_ = resp.WriteBulkString(c.w, nil)
+	case withScore:
+		_ = resp.WriteArrayHeader(c.w, 2)
+		_ = resp.WriteInteger(c.w, int64(rank))
+		_ = resp.WriteBulkString(c.w, []byte(store.FormatFloat(score)))
+	default:
+		_ = resp.WriteInteger(c.w, int64(rank))
+	}
+	return nil
+}
+
+func cmdZPopMin(s *Server, c *client, args []string) error {
+	return s.zpop(c, "ZPOPMIN", args, false)
+}
+
+func cmdZPopMax(s *Server, c *client, args []string) error {
+	return s.zpop(c, "ZPOPMAX", args, true)
+}
+
+func (s *Server) zpop(c *client, name string, args []string, max bool) error {
+	if len(args) > 2 {
+		writeWrongArgs(c.w, name)
+		return nil
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			_ = resp.WriteError(c.w, "ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	items, err := c.db.ZPop(args[0], count, max)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.logZPop(c, args[0], items); err != nil {
+		return writeAOFError(c.w)
+	}
+	writeScoreMembers(c.w, items, true)
+	return nil
+}
+
+// logZPop logs popped members as ZREM so replay removes exactly them.
+func (s *Server) logZPop(c *client, key string, items []store.ScoreMember) error {
+	if len(items) == 0 {
+		return nil
+	}
+	args := make([]string, 0, 1+len(items))
+	args = append(args, key)
+	for _, it := range items {
+		args = append(args, it.Member)
+	}
+	return s.appendAOF(c, "ZREM", args)
+}
+
+func cmdBZPopMin(s *Server, c *client, args []string) error {
+	return s.bzpop(c, args, false)
+}
+
+func cmdBZPopMax(s *Server, c *client, args []string) error {
+	return s.bzpop(c, args, true)
+}
+
+// bzpop implements BZPOPMIN / BZPOPMAX: pop from the first non-empty sorted
+// set, blocking until one is written to.
+func (s *Server) bzpop(c *client, args []string, max bool) error {
+	timeout, msg := parseTimeout(args[len(args)-1])
+	if msg != "" {
+		_ = resp.WriteError(c.w, msg)
+		return nil
+	}
+	keys := args[:len(args)-1]
+
+	done, err := s.blockOn(c, keys, timeout, func() (bool, error) {
+		for _, key := range keys {
+			items, err := c.db.ZPop(key, 1, max)
+			if err != nil {
+				writeStoreError(c.w, err)
+				return true, nil
+			}
+			if len(items) == 0 {
+				continue
+			}
+			if err := s.logZPop(c, key, items); err != nil {
+				return true, writeAOFError(c.w)
+			}
+			_ = resp.WriteArrayHeader(c.w, 3)
+			_ = resp.WriteBulkString(c.w, []byte(key))
+			_ = resp.WriteBulkString(c.w, []byte(items[0].Member))
+			_ = resp.WriteBulkString(c.w, []byte(store.FormatFloat(items[0].Score)))
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !done {
+		_ = resp.WriteNullArray(c.w)
+	}
+	return nil
+}
+
+func cmdZUnionStore(s *Server, c *client, args []string) error {
+	return s.zcombineStore(c, "ZUNIONSTORE", args, c.db.ZUnionStore)
+}
+
+func cmdZInterStore(s *Server, c *client, args []string) error {
+	return s.zcombineStore(c, "ZINTERSTORE", args, c.db.ZInterStore)
+}
+
+// zcombineStore implements
+// Z{UNION,INTER}STORE dst numkeys key [key ...] [WEIGHTS w ...] [AGGREGATE SUM|MIN|MAX].
+func (s *Server) zcombineStore(c *client, name string, args []string,
+	fn func(string, []string, []float64, store.ZAggregate) (int, error)) error {
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil {
+		_ = resp.WriteError(c.w, msgNotInteger)
+		return nil
+	}
+	if numKeys <= 0 {
+		_ = resp.WriteError(c.w, "ERR at least 1 input key is needed for '"+strings.ToLower(name)+"' command")
+		return nil
+	}
+	if numKeys > len(args)-2 {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+
+	keys := args[2 : 2+numKeys]
+	var weights []float64
+	agg := store.ZAggSum
+	rest := args[2+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		switch opt := strings.ToUpper(rest[i]); {
+		case opt == "WEIGHTS" && i+numKeys < len(rest):
+			weights = make([]float64, numKeys)
+			for j := range weights {
+				w, ok := parseFloatArg(rest[i+1+j])
+				if !ok {
+					_ = resp.WriteError(c.w, "ERR weight value is not a float")
+					return nil
+				}
+				weights[j] = w
+			}
+			i += numKeys
+		case opt == "AGGREGATE" && i+1 < len(rest):
+			switch strings.ToUpper(rest[i+1]) {
+			case "SUM":
+				agg = store.ZAggSum
+			case "MIN":
+				agg = store.ZAggMin
+			case "MAX":
+				agg = store.ZAggMax
+			default:
+				_ = resp.WriteError(c.w, msgSyntax)
+				return nil
+			}
+			i++
+		default:
+			_ = resp.WriteError(c.w, msgSyntax)
+			return nil
+		}
+	}
+
+	n, err := fn(args[0], keys, weights, agg)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+	if err := s.appendAOF(c, name, args); err != nil {
+		return writeAOFError(c.w)
+	}
+	if n > 0 {
+		s.signalKeyReady(c.dbIndex, args[0])
+	}
+	_ = resp.WriteInteger(c.w, int64(n))
+	return nil
+}
+
+func cmdZScan(s *Server, c *client, args []string) error {
+	cursor, opts, ok := parseScanArgs(c.w, args[1:])
+	if !ok {
+		return nil
+	}
+
+	items, next, err := c.db.ZScan(args[0], cursor, opts.count)
+	if err != nil {
+		writeStoreError(c.w, err)
+		return nil
+	}
+
+	out := make([]string, 0, 2*len(items))
+	for _, it := range items {
+		if opts.matches(it.Member) {
+			out = append(out, it.Member, store.FormatFloat(it.Score))
+		}
+	}
+	writeScanReply(c.w, next, out)
+	return nil
+}
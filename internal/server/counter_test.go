@@ -0,0 +1,169 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestCounterCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(1, "INCR", "views")
+	c.mustInt(11, "INCRBY", "views", "10")
+	c.mustInt(10, "DECR", "views")
+	c.mustInt(-5, "DECRBY", "views", "15")
+	c.mustBulk("-5", "GET", "views")
+
+	c.mustOK("SET", "max", "9223372036854775807")
+	c.mustErr("increment or decrement would overflow", "INCR", "max")
+	c.mustErr("decrement would overflow", "DECRBY", "views", "-9223372036854775808")
+	c.mustOK("SET", "text", "abc")
+	c.mustErr("value is not an integer or out of range", "INCR", "text")
+	c.mustErr("value is not an integer or out of range", "INCRBY", "views", "1.5")
+
+	c.mustBulk("10.5", "INCRBYFLOAT", "f", "10.5")
+	c.mustBulk("10.6", "INCRBYFLOAT", "f", "0.1")
+	c.mustOK("SET", "g", "5.0e3")
+	c.mustBulk("5200", "INCRBYFLOAT", "g", "2.0e2")
+	c.mustErr("value is not a valid float", "INCRBYFLOAT", "text", "1")
+	c.mustErr("value is not a valid float", "INCRBYFLOAT", "f", "nan")
+	c.mustErr("increment would produce NaN or Infinity", "INCRBYFLOAT", "f", "inf")
+
+	c.mustInt(1, "HSET", "h", "f", "1")
+	c.mustErr("WRONGTYPE", "INCR", "h")
+
+	c.mustOK("SET", "ttl", "1", "EX", "100")
+	c.mustInt(2, "INCR", "ttl")
+	c.mustInt(100, "TTL", "ttl")
+}
+
+func TestCounters_LogResultingValue(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "INCRBY", "n", "3")
+	c.mustInt(2, "DECR", "n")
+	c.mustBulk("0.1", "INCRBYFLOAT", "f", "0.1")
+	c.mustBulk("0.30000000000000004", "INCRBYFLOAT", "f", "0.2")
+	c.mustBulk("0.30000000000000004", "GET", "f")
+	c.mustOK("SET", "t", "7", "PX", "100000")
+	c.mustInt(8, "INCR", "t")
+	_ = s.Close()
+
+	raw := readAOF(t, path)
+	if strings.Contains(string(raw), "INCR") || strings.Contains(string(raw), "DECR") {
+		t.Fatalf("expected only resulting values in the AOF, got %q", raw)
+	}
+
+	// Replaying twice over the same store must give the same result.
+	st2 := store.New()
+	for i := 0; i < 2; i++ {
+		if err := aof.Replay(path, NewLoader(st2).Apply); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+	}
+	if v, _ := st2.Get("n"); string(v) != "2" {
+		t.Fatalf("expected n=2, got %q", v)
+	}
+	if v, _ := st2.Get("f"); string(v) != "0.30000000000000004" {
+		t.Fatalf("expected exact float, got %q", v)
+	}
+	if v, _ := st2.Get("t"); string(v) != "8" {
+		t.Fatalf("expected t=8, got %q", v)
+	}
+	if ttl := st2.TTL("t"); ttl < 99 {
+		t.Fatalf("expected t to keep its ttl, got %d", ttl)
+	}
+}
+
+func TestCounters_LogInTheOrderTheyApply(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+
+	keys := distinctStripeKeys(4)
+
+	// With the log stalled, the first increment of each key is applied and
+	// waits to be logged; the second must not apply before the first is
+	// logged, or the log could end up with the older value last.
+	cmds := [][]string{{"INCR", keys[0]}, {"INCRBYFLOAT", keys[1], "1"}, {"HINCRBY", keys[2], "f", "1"}, {"HINCRBYFLOAT", keys[3], "f", "1"}}
+	var n, f, hi, hf []byte
+	sendWhileLogStalled(t, s, addr, append(cmds, cmds...), func() {
+		db := s.dbs.DB(0)
+		n, _ = db.Get(keys[0])
+		f, _ = db.Get(keys[1])
+		hi, _, _ = db.HGet(keys[2], "f")
+		hf, _, _ = db.HGet(keys[3], "f")
+	})
+	if got := strings.Join([]string{string(n), string(f), string(hi), string(hf)}, ","); got != "1,1,1,1" {
+		t.Fatalf("second increments applied while the first were not logged yet: %s", got)
+	}
+	_ = s.Close()
+
+	st := replayInto(t, path)
+	n, _ = st.Get(keys[0])
+	f, _ = st.Get(keys[1])
+	hi, _, _ = st.HGet(keys[2], "f")
+	hf, _, _ = st.HGet(keys[3], "f")
+	if got := strings.Join([]string{string(n), string(f), string(hi), string(hf)}, ","); got != "2,2,2,2" {
+		t.Fatalf("replayed counters %s, want 2,2,2,2", got)
+	}
+}
+
+func TestWrites_LogInTheOrderTheyApply(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	keys := distinctStripeKeys(3)
+
+	// Each pair writes one key; the second write must wait until the first
+	// is logged, whichever commands they are.
+	cmds := [][]string{
+		{"SET", keys[0], "x"}, {"SET", keys[1], "5"}, {"HSET", keys[2], "f", "5"},
+		{"APPEND", keys[0], "y"}, {"INCR", keys[1]}, {"HINCRBY", keys[2], "f", "1"},
+	}
+	var a, n, h []byte
+	sendWhileLogStalled(t, s, addr, cmds, func() {
+		db := s.dbs.DB(0)
+		a, _ = db.Get(keys[0])
+		n, _ = db.Get(keys[1])
+		h, _, _ = db.HGet(keys[2], "f")
+	})
+	if got := strings.Join([]string{string(a), string(n), string(h)}, ","); got != "x,5,5" {
+		t.Fatalf("second writes applied while the first were not logged yet: %s", got)
+	}
+
+	// Clients racing mixed writes to the same keys.
+	pipelineFromClients(t, addr, 8, 300, func(client, i int) []string {
+		id := strconv.Itoa(client)
+		switch i % 3 {
+		case 0:
+			return []string{"APPEND", keys[0], id}
+		case 1:
+			if client%2 == 0 {
+				return []string{"SET", keys[1], id}
+			}
+			return []string{"INCRBY", keys[1], id}
+		default:
+			if client%2 == 0 {
+				return []string{"HSET", keys[2], "f", id}
+			}
+			return []string{"HINCRBY", keys[2], "f", id}
+		}
+	})
+	_ = s.Close()
+
+	live := s.dbs.DB(0)
+	st := replayInto(t, path)
+	for _, key := range keys[:2] {
+		want, _ := live.Get(key)
+		if got, _ := st.Get(key); string(got) != string(want) {
+			t.Fatalf("%s: replayed %q, live %q", key, got, want)
+		}
+	}
+	want, _, _ := live.HGet(keys[2], "f")
+	if got, _, _ := st.HGet(keys[2], "f"); string(got) != string(want) {
+		t.Fatalf("%s: replayed %q, live %q", keys[2], got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestSelectIsolatesDatabases(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	other := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "db0")
+	c.mustOK("SELECT", "1")
+	c.mustNil("GET", "k")
+	c.mustOK("SET", "k", "db1")
+	c.mustInt(1, "DBSIZE")
+
+	// Every connection starts in database 0.
+	other.mustBulk("db0", "GET", "k")
+
+	c.mustErr("DB index is out of range", "SELECT", "16")
+	c.mustErr("DB index is out of range", "SELECT", "-1")
+	c.mustErr("value is not an integer or out of range", "SELECT", "x")
+	c.mustBulk("db1", "GET", "k")
+}
+
+func TestMoveAndSwapDB(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	other := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "v", "EX", "100")
+	c.mustInt(1, "MOVE", "k", "2")
+	c.mustInt(0, "MOVE", "k", "2")
+	c.mustErr("source and destination objects are the same", "MOVE", "k", "0")
+	c.mustErr("DB index is out of range", "MOVE", "k", "99")
+	c.mustOK("SELECT", "2")
+	c.mustBulk("v", "GET", "k")
+	c.mustInt(100, "TTL", "k")
+
+	other.mustOK("SET", "in0", "x")
+	c.mustOK("SWAPDB", "0", "2")
+	other.mustBulk("v", "GET", "k")
+	other.mustNil("GET", "in0")
+	c.mustBulk("x", "GET", "in0")
+
+	c.mustErr("invalid first DB index", "SWAPDB", "a", "0")
+	c.mustErr("invalid second DB index", "SWAPDB", "0", "b")
+	c.mustErr("DB index is out of range", "SWAPDB", "0", "16")
+}
+
+func TestSwapDBWakesBlockedClients(t *testing.T) {
+	_, addr := startTestServer(t)
+	blocked := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	c.mustOK("SELECT", "1")
+	c.mustInt(1, "RPUSH", "q", "job")
+
+	if err := sendCmd(blocked.conn, blocked.w, "BLPOP", "q", "5"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	c.mustOK("SWAPDB", "0", "1")
+
+	if got := fmtValue(blocked.read()); got != `["q" "job"]` {
+		t.Fatalf("expected the swapped-in element, got %s", got)
+	}
+}
+
+func TestFlushDBAndFlushAll(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MSET", "a", "1", "b", "2")
+	c.mustOK("SELECT", "3")
+	c.mustOK("SET", "c", "3")
+	c.mustOK("FLUSHDB")
+	c.mustInt(0, "DBSIZE")
+	c.mustOK("SELECT", "0")
+	c.mustInt(2, "DBSIZE")
+
+	c.mustOK("FLUSHALL", "ASYNC")
+	c.mustInt(0, "DBSIZE")
+	c.mustOK("FLUSHDB", "sync")
+	c.mustErr("syntax error", "FLUSHALL", "LATER")
+}
+
+func TestDatabases_AOFReplayAndRewrite(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c1 := dialTest(t, addr)
+	c2 := dialTest(t, addr)
+
+	c1.mustOK("SELECT", "5")
+	c1.mustOK("SET", "a", "5")
+	c2.mustOK("SET", "b", "0")
+	c1.mustInt(1, "RPUSH", "l", "x")
+	c2.mustOK("SET", "moved", "m")
+	c2.mustInt(1, "MOVE", "moved", "7")
+
+	// Writes made while a rewrite is in progress land in the tail, which
+	// must carry its own SELECT context.
+	reached := make(chan struct{})
+	release := make(chan struct{})
+	s.rewriteMu.Lock()
+	s.testHookBeforeInstall = func() {
+		close(reached)
+		<-release
+	}
+	s.rewriteMu.Unlock()
+	c2.mustOK("BGREWRITEAOF")
+	<-reached
+	c2.mustOK("SET", "tail0", "t")
+	c1.mustOK("SET", "tail5", "t")
+	close(release)
+	s.rewriteWg.Wait()
+
+	// The first append after the install must not assume a database.
+	c1.mustOK("SET", "after", "t")
+	_ = s.Close()
+
+	want := map[int][]string{0: {"b", "tail0"}, 5: {"a", "l", "tail5", "after"}, 7: {"moved"}}
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		for db, keys := range want {
+			for _, k := range keys {
+				if !dbs.DB(db).Exists(k) {
+					t.Fatalf("%s: expected %q in db %d", stage, k, db)
+				}
+			}
+			if n := dbs.DB(db).Len(); n != len(keys) {
+				t.Fatalf("%s: expected %d keys in db %d, got %d", stage, len(keys), db, n)
+			}
+		}
+	})
+}
+
+func TestDatabases_ReplayFailsWithTooFewDatabases(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+	c.mustOK("SET", "k0", "zero")
+	c.mustOK("SELECT", "9")
+	c.mustOK("SET", "k0", "nine")
+	_ = s.Close()
+
+	// Carrying on in database 0 would overwrite k0 there.
+	dbs := store.NewDatabases(4)
+	err := aof.Replay(path, NewDatabasesLoader(dbs).Apply)
+	if err == nil || !strings.Contains(err.Error(), "DB index is out of range") {
+		t.Fatalf("expected replay to fail on SELECT 9, got %v", err)
+	}
+	if v, _ := dbs.DB(0).Get("k0"); string(v) != "zero" {
+		t.Fatalf("expected k0=zero in db 0, got %q", v)
+	}
+}
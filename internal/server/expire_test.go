@@ -0,0 +1,134 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestMillisecondExpiryCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "v")
+	c.mustInt(-1, "PTTL", "k")
+	c.mustInt(-1, "EXPIRETIME", "k")
+	c.mustInt(-2, "PEXPIRETIME", "missing")
+	c.mustInt(0, "PEXPIRE", "missing", "100")
+
+	c.mustInt(1, "PEXPIRE", "k", "1500")
+	if v := c.do("PTTL", "k"); v.Int <= 1400 || v.Int > 1500 {
+		t.Fatalf("expected pttl ~1500, got %s", fmtValue(v))
+	}
+	c.mustInt(2, "TTL", "k")
+
+	at := time.Now().Add(time.Hour).UnixMilli()
+	c.mustInt(1, "PEXPIREAT", "k", strconv.FormatInt(at, 10))
+	c.mustInt(at, "PEXPIRETIME", "k")
+	c.mustInt(at/1000, "EXPIRETIME", "k")
+
+	c.mustInt(1, "PERSIST", "k")
+	c.mustInt(0, "PERSIST", "k")
+	c.mustInt(-1, "TTL", "k")
+
+	c.mustOK("SET", "gone", "v")
+	c.mustInt(1, "PEXPIRE", "gone", "-1")
+	c.mustInt(0, "EXISTS", "gone")
+
+	c.mustOK("SET", "short", "v", "PX", "30")
+	time.Sleep(60 * time.Millisecond)
+	c.mustInt(0, "EXISTS", "short")
+}
+
+func TestEXPIRE_Flags(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "v")
+	c.mustInt(0, "EXPIRE", "k", "100", "XX")
+	c.mustInt(0, "EXPIRE", "k", "100", "GT")
+	c.mustInt(1, "EXPIRE", "k", "100", "NX")
+	c.mustInt(0, "EXPIRE", "k", "200", "NX")
+	c.mustInt(0, "EXPIRE", "k", "50", "GT")
+	c.mustInt(1, "EXPIRE", "k", "50", "LT")
+	c.mustInt(50, "TTL", "k")
+	c.mustInt(1, "PEXPIRE", "k", "90000", "XX", "GT")
+	c.mustInt(90, "TTL", "k")
+
+	c.mustErr("NX and XX, GT or LT options at the same time are not compatible", "EXPIRE", "k", "10", "NX", "XX")
+	c.mustErr("GT and LT options at the same time are not compatible", "EXPIRE", "k", "10", "GT", "LT")
+	c.mustErr("Unsupported option", "EXPIRE", "k", "10", "SOON")
+	c.mustErr("invalid expire time in 'expire' command", "EXPIRE", "k", "9223372036854775807")
+	c.mustErr("not an integer", "PEXPIREAT", "k", "later")
+}
+
+func TestExpiry_LoggedAsPEXPIREATAndRewrittenInMilliseconds(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "a", "1")
+	c.mustInt(1, "PEXPIRE", "a", "123456")
+	c.mustOK("SET", "b", "2")
+	c.mustInt(1, "EXPIRE", "b", "100")
+	c.mustInt(1, "PERSIST", "b")
+	c.mustOK("SET", "c", "3")
+	c.mustInt(0, "EXPIRE", "c", "100", "XX") // not applied, not logged
+	_ = s.Close()
+
+	raw := readAOF(t, path)
+	if strings.Contains(string(raw), "\r\nEXPIRE\r\n") || strings.Count(string(raw), "PEXPIREAT") != 2 {
+		t.Fatalf("expected two PEXPIREAT entries, got %q", raw)
+	}
+
+	wantA := s.dbs.DB(0).ExpireTimeMs("a")
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		st := dbs.DB(0)
+		if got := st.ExpireTimeMs("a"); got != wantA {
+			t.Fatalf("%s: expected a to expire at %d, got %d", stage, wantA, got)
+		}
+		if got := st.TTL("b"); got != -1 {
+			t.Fatalf("%s: expected b persisted, got ttl %d", stage, got)
+		}
+		if got := st.TTL("c"); got != -1 {
+			t.Fatalf("%s: expected c without expiry, got ttl %d", stage, got)
+		}
+	})
+}
+
+func TestINFO_ReportsExpiryStats(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	// One key expires lazily on access, the others are left to the reaper.
+	c.mustOK("SET", "lazy", "v", "PX", "1")
+	for _, k := range numbered(5) {
+		c.mustOK("SET", k, "v", "PX", "1")
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.mustNil("GET", "lazy")
+
+	deadline := time.Now().Add(2 * time.Second)
+	var info string
+	for {
+		info = string(c.do("INFO").Bulk)
+		if strings.Contains(info, "expired_keys:6\r\n") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reaper did not expire the keys: %q", info)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.mustInt(0, "DBSIZE")
+	for _, field := range []string{"expired_stale_perc:", "expired_time_cap_reached_count:", "expire_cycle_cpu_milliseconds:", "expire_cycle_last_microseconds:"} {
+		if !strings.Contains(info, field) {
+			t.Fatalf("INFO lacks %s: %q", field, info)
+		}
+	}
+	if strings.Contains(info, "expire_cycles:0\r\n") {
+		t.Fatalf("no expire cycle recorded: %q", info)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestHashCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(2, "HSET", "h", "a", "1", "b", "2")
+	c.mustInt(0, "HSET", "h", "a", "10")
+	c.mustBulk("10", "HGET", "h", "a")
+	c.mustNil("HGET", "h", "missing")
+	c.mustStrings([]string{"10", "<nil>", "2"}, "HMGET", "h", "a", "x", "b")
+	c.mustInt(1, "HEXISTS", "h", "a")
+	c.mustInt(2, "HLEN", "h")
+	c.mustInt(2, "HSTRLEN", "h", "a")
+	c.mustStrings([]string{"a", "b"}, "HKEYS", "h")
+	c.mustStrings([]string{"10", "2"}, "HVALS", "h")
+	c.mustStrings([]string{"a", "10", "b", "2"}, "HGETALL", "h")
+
+	c.mustInt(0, "HSETNX", "h", "a", "x")
+	c.mustInt(1, "HSETNX", "h", "c", "3")
+
+	c.mustInt(15, "HINCRBY", "h", "a", "5")
+	c.mustInt(1, "HSET", "h", "s", "x")
+	c.mustErr("hash value is not an integer", "HINCRBY", "h", "s", "1")
+	c.mustInt(1, "HDEL", "h", "s")
+	c.mustErr("wrong number of arguments for 'hset'", "HSET", "h", "a")
+	c.mustBulk("2.5", "HINCRBYFLOAT", "h", "b", "0.5")
+
+	c.mustInt(2, "HDEL", "h", "a", "b", "nope")
+	c.mustInt(1, "HLEN", "h")
+	c.mustInt(1, "HDEL", "h", "c")
+	c.mustInt(0, "EXISTS", "h")
+
+	c.mustStrings(nil, "HGETALL", "missing")
+}
+
+func TestHash_WrongTypeErrors(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "s", "v")
+	c.mustErr("WRONGTYPE", "HSET", "s", "f", "v")
+	c.mustErr("WRONGTYPE", "HGETALL", "s")
+
+	c.mustInt(1, "HSET", "h", "f", "v")
+	c.mustErr("WRONGTYPE", "GET", "h")
+
+	// SET overwrites regardless of type
+	c.mustOK("SET", "h", "now-a-string")
+	c.mustBulk("now-a-string", "GET", "h")
+}
+
+func TestHSCAN_MatchAndNoValues(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "HSET", "h", "user:1", "a", "user:2", "b", "other", "c")
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		v := c.do("HSCAN", "h", cursor, "MATCH", "user:*", "NOVALUES", "COUNT", "1")
+		if v.Type != resp.Array || len(v.Array) != 2 {
+			t.Fatalf("unexpected reply %s", fmtValue(v))
+		}
+		for _, f := range bulkStrings(v.Array[1]) {
+			seen[f] = true
+		}
+		cursor = string(v.Array[0].Bulk)
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 2 || !seen["user:1"] || !seen["user:2"] {
+		t.Fatalf("unexpected fields: %v", seen)
+	}
+}
+
+func TestHash_SurvivesAOFRewriteAndReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(2, "HSET", "h", "a", "1", "b", "2")
+	c.mustInt(3, "HINCRBY", "h", "a", "2")
+	c.mustBulk("2.5", "HINCRBYFLOAT", "h", "b", "0.5")
+	c.mustInt(1, "HDEL", "h", "b")
+	_ = s.Close()
+
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		st := dbs.DB(0)
+		if v, ok, _ := st.HGet("h", "a"); !ok || string(v) != "3" {
+			t.Fatalf("%s: expected a=3, got ok=%v v=%q", stage, ok, v)
+		}
+		if ok, _ := st.HExists("h", "b"); ok {
+			t.Fatalf("%s: expected b deleted", stage)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func mustDial(t *testing.T, addr string) (net.Conn, *bufio.Reader, *bufio.Writer) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+}
+
+func sendCmd(conn net.Conn, w *bufio.Writer, parts ...string) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("no command parts")
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(250 * time.Millisecond))
+	defer conn.SetWriteDeadline(time.Time{})
+
+	if err := resp.WriteArrayHeader(w, len(parts)); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if err := resp.WriteBulkString(w, []byte(p)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func expectSimpleOK(conn net.Conn, r *bufio.Reader) error {
+	_ = conn.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+
+	v, err := resp.Decode(r)
+	if err != nil {
+		// Treat timeouts as a normal retry signal for the caller
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return context.DeadlineExceeded
+		}
+		return err
+	}
+	if v.Type != resp.SimpleString || v.Str != "OK" {
+		return fmt.Errorf("expected +OK, got type=%v str=%q", v.Type, v.Str)
+	}
+	return nil
+}
+
+// startTestServer starts a server on an ephemeral port with no AOF and
+// registers cleanup with t.
+func startTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	s, addr, err := Start("127.0.0.1:0", store.New(), nil, aof.FsyncEverySecond)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, addr
+}
+
+// startAOFServer is startTestServer with an AOF in a temporary directory,
+// fsynced after every write. It also returns the AOF's path; tests close the
+// server before reading the file.
+func startAOFServer(t *testing.T) (*Server, string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	s, addr, err := Start("127.0.0.1:0", store.New(), aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, addr, path
+}
+
+// replayDatabases replays the AOF at path into fresh databases.
+func replayDatabases(t *testing.T, path string) *store.Databases {
+	t.Helper()
+	dbs := store.NewDatabases(store.DefaultDatabases)
+	if err := aof.Replay(path, NewDatabasesLoader(dbs).Apply); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return dbs
+}
+
+// replayInto replays the AOF at path and returns the resulting database 0.
+func replayInto(t *testing.T, path string) *store.Store {
+	t.Helper()
+	return replayDatabases(t, path).DB(0)
+}
+
+// rewriteAndReplay loads the AOF at path, left by a closed server, three
+// ways and runs check on each: replayed as logged, replayed after a rewrite
+// and replayed after a rewrite with a snapshot preamble.
+func rewriteAndReplay(t *testing.T, path string, check func(dbs *store.Databases, stage string)) {
+	t.Helper()
+	dbs := replayDatabases(t, path)
+	check(dbs, "replay")
+
+	for _, preamble := range []bool{false, true} {
+		aw, err := aof.Open(path)
+		if err != nil {
+			t.Fatalf("reopen aof: %v", err)
+		}
+		aw.SetRDBPreamble(preamble)
+		if err := aw.Rewrite(dbs.Snapshot()); err != nil {
+			t.Fatalf("rewrite: %v", err)
+		}
+		_ = aw.Close()
+
+		dbs = store.NewDatabases(store.DefaultDatabases)
+		l := NewDatabasesLoader(dbs)
+		if err := aof.ReplayWithRestore(path, l.Restore, l.Apply); err != nil {
+			t.Fatalf("replay rewritten: %v", err)
+		}
+		stage := "rewrite"
+		if preamble {
+			stage = "preamble"
+		}
+		check(dbs, stage)
+	}
+}
+
+// readAOF returns the contents of the AOF at path, its parts concatenated
+// in replay order.
+func readAOF(t *testing.T, path string) []byte {
+	t.Helper()
+	files, err := aof.Files(path)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("aof files of %s: %v, %v", path, files, err)
+	}
+	var raw []byte
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read aof: %v", err)
+		}
+		raw = append(raw, data...)
+	}
+	return raw
+}
+
+// lastAOFPart returns the part of the AOF at path that appends go to.
+func lastAOFPart(t *testing.T, path string) string {
+	t.Helper()
+	files, err := aof.Files(path)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("aof files of %s: %v, %v", path, files, err)
+	}
+	return files[len(files)-1]
+}
+
+// sendWhileLogStalled sends each of cmds from a connection of its own while
+// appends to the AOF are held up, giving each time to get as far as it can
+// before the next is sent. inspect runs before the log is let go; it returns
+// once every command has replied.
+func sendWhileLogStalled(t *testing.T, s *Server, addr string, cmds [][]string, inspect func()) {
+	t.Helper()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	s.aofMu.Lock()
+	defer s.aofMu.Unlock()
+
+	for _, cmd := range cmds {
+		conn, r, w := mustDial(t, addr)
+		t.Cleanup(func() { _ = conn.Close() })
+		if err := sendCmd(conn, w, cmd...); err != nil {
+			t.Fatalf("send %v: %v", cmd, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = resp.Decode(r)
+		}()
+		time.Sleep(50 * time.Millisecond)
+	}
+	inspect()
+}
+
+// pipelineFromClients has each of clients connections send n commands,
+// built by cmd, without waiting for replies, and then read the replies.
+func pipelineFromClients(t *testing.T, addr string, clients, n int, cmd func(client, i int) []string) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for client := 0; client < clients; client++ {
+		conn, r, w := mustDial(t, addr)
+		t.Cleanup(func() { _ = conn.Close() })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if err := sendCmd(conn, w, cmd(client, i)...); err != nil {
+					t.Errorf("send: %v", err)
+					return
+				}
+			}
+			for i := 0; i < n; i++ {
+				if _, err := resp.Decode(r); err != nil {
+					t.Errorf("read reply: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// distinctStripeKeys returns n keys on different key lock stripes, for
+// tests that need writes to separate keys not to wait on each other.
+func distinctStripeKeys(n int) []string {
+	var keys []string
+	used := make(map[uint64]bool)
+	for i := 0; len(keys) < n; i++ {
+		k := "k" + strconv.Itoa(i)
+		if stripe := maphash.String(keyLockSeed, k) % keyLockCount; !used[stripe] {
+			used[stripe] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// testConn is a RESP client connection for tests.
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func dialTest(t *testing.T, addr string) *testConn {
+	t.Helper()
+	conn, r, w := mustDial(t, addr)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testConn{t: t, conn: conn, r: r, w: w}
+}
+
+// do sends one command and decodes one reply.
+func (tc *testConn) do(parts ...string) resp.Value {
+	tc.t.Helper()
+	if err := sendCmd(tc.conn, tc.w, parts...); err != nil {
+		tc.t.Fatalf("send %v: %v", parts, err)
+	}
+	return tc.read()
+}
+
+func (tc *testConn) read() resp.Value {
+	tc.t.Helper()
+	_ = tc.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer tc.conn.SetReadDeadline(time.Time{})
+	v, err := resp.Decode(tc.r)
+	if err != nil {
+		tc.t.Fatalf("decode reply: %v", err)
+	}
+	return v
+}
+
+func (tc *testConn) mustInt(want int64, parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if v.Type != resp.Integer || v.Int != want {
+		tc.t.Fatalf("%v: expected :%d, got %s", parts, want, fmtValue(v))
+	}
+}
+
+func (tc *testConn) mustBulk(want string, parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if v.Type != resp.BulkString || v.Bulk == nil || string(v.Bulk) != want {
+		tc.t.Fatalf("%v: expected bulk %q, got %s", parts, want, fmtValue(v))
+	}
+}
+
+func (tc *testConn) mustNil(parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if !(v.Type == resp.BulkString && v.Bulk == nil) && !(v.Type == resp.Array && v.Array == nil) {
+		tc.t.Fatalf("%v: expected nil, got %s", parts, fmtValue(v))
+	}
+}
+
+func (tc *testConn) mustOK(parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if v.Type != resp.SimpleString || v.Str != "OK" {
+		tc.t.Fatalf("%v: expected +OK, got %s", parts, fmtValue(v))
+	}
+}
+
+func (tc *testConn) mustErr(contains string, parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if v.Type != resp.Error || !strings.Contains(v.Str, contains) {
+		tc.t.Fatalf("%v: expected error containing %q, got %s", parts, contains, fmtValue(v))
+	}
+}
+
+// mustReply sends a command and checks its reply, formatted by fmtValue.
+func (tc *testConn) mustReply(want string, parts ...string) {
+	tc.t.Helper()
+	if got := fmtValue(tc.do(parts...)); got != want {
+		tc.t.Fatalf("%v: expected %s, got %s", parts, want, got)
+	}
+}
+
+// mustStrings expects an array of bulk strings (nil elements render as "<nil>").
+func (tc *testConn) mustStrings(want []string, parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	got := bulkStrings(v)
+	if v.Type != resp.Array || strings.Join(got, ",") != strings.Join(want, ",") || len(got) != len(want) {
+		tc.t.Fatalf("%v: expected %q, got %s", parts, want, fmtValue(v))
+	}
+}
+
+func bulkStrings(v resp.Value) []string {
+	out := make([]string, 0, len(v.Array))
+	for _, el := range v.Array {
+		if el.Bulk == nil {
+			out = append(out, "<nil>")
+			continue
+		}
+		out = append(out, string(el.Bulk))
+	}
+	return out
+}
+
+func fmtValue(v resp.Value) string {
+	switch v.Type {
+	case resp.SimpleString:
+		return "+" + v.Str
+	case resp.Error:
+		return "-" + v.Str
+	case resp.Integer:
+		return ":" + strconv.FormatInt(v.Int, 10)
+	case resp.BulkString:
+		if v.Bulk == nil {
+			return "(nil)"
+		}
+		return strconv.Quote(string(v.Bulk))
+	case resp.Array:
+		if v.Array == nil {
+			return "(nil array)"
+		}
+		parts := make([]string, 0, len(v.Array))
+		for _, el := range v.Array {
+			parts = append(parts, fmtValue(el))
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	return "?"
+}
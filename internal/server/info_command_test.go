@@ -82,8 +82,8 @@ func TestCOMMAND_NoArgs_ReturnsArrayOfCommandDocs(t *testing.T) {
 	if v.Type != resp.Array || v.Array == nil {
 		t.Fatalf("expected array, got type=%v", v.Type)
 	}
-	if len(v.Array) != 11 {
-		t.Fatalf("expected 11 command docs, got %d", len(v.Array))
+	if len(v.Array) != len(commandList) {
+		t.Fatalf("expected %d command docs, got %d", len(commandList), len(v.Array))
 	}
 	// First doc should be PING (name, arity, flags)
 	pingDoc := v.Array[0]
@@ -95,7 +95,7 @@ func TestCOMMAND_NoArgs_ReturnsArrayOfCommandDocs(t *testing.T) {
 	}
 }
 
-func TestCOMMAND_COUNT_ReturnsInteger11(t *testing.T) {
+func TestCOMMAND_COUNT_ReturnsTableSize(t *testing.T) {
 	st := store.New()
 	s, addr, err := Start("127.0.0.1:0", st, nil, aof.FsyncEverySecond)
 	if err != nil {
@@ -116,8 +116,9 @@ func TestCOMMAND_COUNT_ReturnsInteger11(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read: %v", err)
 	}
-	if line != ":11\r\n" {
-		t.Fatalf("expected :11\\r\\n, got %q", line)
+	want := ":" + strconv.Itoa(len(commandList)) + "\r\n"
+	if line != want {
+		t.Fatalf("expected %q, got %q", want, line)
 	}
 }
 
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+func TestRenameAndCopy(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "a", "1", "EX", "100")
+	c.mustOK("RENAME", "a", "b")
+	c.mustNil("GET", "a")
+	c.mustInt(100, "TTL", "b")
+	c.mustErr("no such key", "RENAME", "a", "b")
+	c.mustOK("RENAME", "b", "b")
+
+	c.mustOK("SET", "c", "3")
+	c.mustInt(0, "RENAMENX", "c", "b")
+	c.mustInt(1, "RENAMENX", "c", "d")
+
+	c.mustInt(1, "COPY", "b", "b2")
+	c.mustInt(0, "COPY", "b", "d")
+	c.mustInt(1, "COPY", "b", "d", "REPLACE")
+	c.mustBulk("1", "GET", "d")
+	c.mustInt(1, "COPY", "b", "b", "DB", "3")
+	c.mustErr("source and destination objects are the same", "COPY", "b", "b")
+	c.mustErr("DB index is out of range", "COPY", "b", "x", "DB", "16")
+	c.mustErr("syntax error", "COPY", "b", "x", "NOW")
+	c.mustOK("SELECT", "3")
+	c.mustBulk("1", "GET", "b")
+	c.mustInt(100, "TTL", "b")
+}
+
+func TestTypeTouchRandomKeyUnlinkObject(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustNil("RANDOMKEY")
+	c.mustOK("SET", "s", "12")
+	c.mustInt(2, "LPUSH", "l", "a", "b")
+	c.mustInt(1, "SADD", "set", "m")
+
+	for key, want := range map[string]string{"s": "string", "l": "list", "set": "set", "nope": "none"} {
+		if got := fmtValue(c.do("TYPE", key)); got != "+"+want {
+			t.Fatalf("TYPE %s: expected %s, got %s", key, want, got)
+		}
+	}
+	c.mustInt(2, "TOUCH", "s", "l", "nope")
+	if got := fmtValue(c.do("RANDOMKEY")); got != `"s"` && got != `"l"` && got != `"set"` {
+		t.Fatalf("unexpected RANDOMKEY reply %s", got)
+	}
+
+	c.mustBulk("int", "OBJECT", "ENCODING", "s")
+	c.mustBulk("quicklist", "OBJECT", "encoding", "l")
+	c.mustInt(0, "OBJECT", "IDLETIME", "s")
+	c.mustInt(1, "OBJECT", "REFCOUNT", "s")
+	c.mustNil("OBJECT", "ENCODING", "nope")
+	c.mustErr("unknown subcommand", "OBJECT", "NOPE", "s")
+	c.mustErr("wrong number of arguments for 'object|encoding' command", "OBJECT", "ENCODING")
+	c.mustErr("wrong number of arguments for 'object|freq' command", "OBJECT", "Freq")
+	c.mustErr("An LFU maxmemory policy is not selected", "OBJECT", "FREQ", "s")
+	c.mustNil("OBJECT", "FREQ", "nope")
+	c.mustOK("CONFIG", "SET", "maxmemory-policy", "allkeys-lfu")
+	if v := c.do("OBJECT", "FREQ", "s"); v.Type != resp.Integer {
+		t.Fatalf("OBJECT FREQ under LFU: %s", fmtValue(v))
+	}
+	c.mustErr("An LFU maxmemory policy is selected", "OBJECT", "IDLETIME", "s")
+	c.mustOK("CONFIG", "SET", "maxmemory-policy", "noeviction")
+
+	c.mustInt(2, "UNLINK", "s", "l", "nope")
+	c.mustInt(1, "DBSIZE")
+}
+
+func TestKeyManagement_AOFReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "a", "1", "PX", "100000")
+	c.mustOK("RENAME", "a", "b")
+	c.mustOK("SET", "c", "3")
+	c.mustInt(1, "RENAMENX", "c", "d")
+	c.mustInt(1, "COPY", "b", "copied", "DB", "2")
+	c.mustOK("SET", "gone", "x")
+	c.mustInt(1, "UNLINK", "gone", "never")
+	_ = s.Close()
+
+	dbs2 := replayDatabases(t, path)
+	db0 := dbs2.DB(0)
+	if db0.Exists("a") || db0.Exists("c") || db0.Exists("gone") {
+		t.Fatalf("expected renamed and unlinked keys to be gone")
+	}
+	if v, _ := db0.Get("b"); string(v) != "1" || db0.TTL("b") < 99 {
+		t.Fatalf("expected b=1 with its ttl, got %q", v)
+	}
+	if v, _ := db0.Get("d"); string(v) != "3" {
+		t.Fatalf("expected d=3, got %q", v)
+	}
+	if v, _ := dbs2.DB(2).Get("copied"); string(v) != "1" || dbs2.DB(2).TTL("copied") < 99 {
+		t.Fatalf("expected the copy in db 2, got %q", v)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+func TestKeysCommand(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MSET", "user:1", "a", "user:2", "b", "order:1", "c")
+	c.mustStrings([]string{"user:1", "user:2"}, "KEYS", "user:*")
+	c.mustStrings([]string{"order:1", "user:1", "user:2"}, "KEYS", "*")
+	c.mustStrings([]string{"order:1"}, "KEYS", "[o]rder:?")
+	c.mustStrings([]string{}, "KEYS", "nothing*")
+}
+
+func TestScanCommand(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	for i := 0; i < 50; i++ {
+		c.mustOK("SET", "k:"+strconv.Itoa(i), "v")
+	}
+	c.mustInt(1, "HSET", "h:1", "f", "v")
+	c.mustInt(1, "SADD", "s:1", "m")
+
+	seen := make(map[string]int)
+	cursor := "0"
+	for {
+		v := c.do("SCAN", cursor, "MATCH", "k:*", "COUNT", "7")
+		if v.Type != resp.Array || len(v.Array) != 2 {
+			t.Fatalf("unexpected SCAN reply %s", fmtValue(v))
+		}
+		for _, k := range bulkStrings(v.Array[1]) {
+			seen[k]++
+		}
+		cursor = string(v.Array[0].Bulk)
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 50 {
+		t.Fatalf("expected 50 keys, saw %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Fatalf("key %q returned %d times", k, n)
+		}
+	}
+
+	v := c.do("SCAN", "0", "TYPE", "hash", "COUNT", "1000")
+	if got := fmtValue(v); got != `["0" ["h:1"]]` {
+		t.Fatalf("expected only the hash, got %s", got)
+	}
+
+	c.mustErr("invalid cursor", "SCAN", "abc")
+	c.mustErr("syntax error", "SCAN", "0", "COUNT", "0")
+	c.mustErr("syntax error", "SCAN", "0", "NOVALUES")
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestListCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "RPUSH", "l", "a", "b", "c")
+	c.mustInt(5, "LPUSH", "l", "y", "x")
+	c.mustStrings([]string{"x", "y", "a", "b", "c"}, "LRANGE", "l", "0", "-1")
+	c.mustInt(0, "LPUSHX", "nope", "v")
+	c.mustInt(0, "EXISTS", "nope")
+	c.mustInt(5, "LLEN", "l")
+	c.mustBulk("c", "LINDEX", "l", "-1")
+	c.mustNil("LINDEX", "l", "99")
+
+	c.mustOK("LSET", "l", "0", "X")
+	c.mustErr("index out of range", "LSET", "l", "9", "v")
+	c.mustErr("no such key", "LSET", "missing", "0", "v")
+
+	c.mustInt(6, "LINSERT", "l", "BEFORE", "a", "b")
+	c.mustInt(-1, "LINSERT", "l", "AFTER", "zz", "v")
+	c.mustInt(2, "LREM", "l", "0", "b")
+	c.mustStrings([]string{"X", "y", "a", "c"}, "LRANGE", "l", "0", "-1")
+
+	c.mustBulk("X", "LPOP", "l")
+	c.mustStrings([]string{"c", "a"}, "RPOP", "l", "2")
+	c.mustNil("LPOP", "missing")
+	c.mustNil("LPOP", "missing", "2")
+	c.mustErr("value is out of range", "LPOP", "l", "-1")
+
+	c.mustInt(4, "RPUSH", "p", "a", "b", "c", "b")
+	c.mustInt(1, "LPOS", "p", "b")
+	c.mustInt(3, "LPOS", "p", "b", "RANK", "-1")
+	c.mustStrings(nil, "LPOS", "p", "b", "RANK", "3", "COUNT", "0")
+	c.mustNil("LPOS", "p", "zz")
+	c.mustErr("RANK can't be zero", "LPOS", "p", "b", "RANK", "0")
+
+	c.mustOK("LTRIM", "p", "1", "2")
+	c.mustStrings([]string{"b", "c"}, "LRANGE", "p", "0", "-1")
+
+	c.mustBulk("c", "RPOPLPUSH", "p", "q")
+	c.mustBulk("b", "LMOVE", "p", "q", "LEFT", "RIGHT")
+	c.mustStrings([]string{"c", "b"}, "LRANGE", "q", "0", "-1")
+	c.mustInt(0, "EXISTS", "p")
+	c.mustNil("LMOVE", "p", "q", "LEFT", "LEFT")
+	c.mustErr("syntax error", "LMOVE", "q", "p", "UP", "LEFT")
+}
+
+func TestList_WrongTypeErrors(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "s", "v")
+	c.mustErr("WRONGTYPE", "LPUSH", "s", "x")
+	c.mustErr("WRONGTYPE", "LRANGE", "s", "0", "-1")
+	c.mustErr("WRONGTYPE", "BLPOP", "s", "0.1")
+
+	c.mustInt(1, "RPUSH", "l", "x")
+	c.mustErr("WRONGTYPE", "GET", "l")
+	c.mustErr("WRONGTYPE", "LMOVE", "l", "s", "LEFT", "LEFT")
+	c.mustInt(1, "LLEN", "l")
+}
+
+func TestBLPOP_WokenByPushFromAnotherClient(t *testing.T) {
+	_, addr := startTestServer(t)
+	waiter := dialTest(t, addr)
+	pusher := dialTest(t, addr)
+
+	if err := sendCmd(waiter.conn, waiter.w, "BLPOP", "a", "b", "0"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	// Give the waiter time to block before pushing.
+	time.Sleep(50 * time.Millisecond)
+	pusher.mustInt(1, "RPUSH", "b", "hello")
+
+	v := waiter.read()
+	if got := bulkStrings(v); v.Type != resp.Array || len(got) != 2 || got[0] != "b" || got[1] != "hello" {
+		t.Fatalf("expected [b hello], got %s", fmtValue(v))
+	}
+	pusher.mustInt(0, "EXISTS", "b")
+}
+
+func TestBLMOVE_WokenByPush(t *testing.T) {
+	_, addr := startTestServer(t)
+	waiter := dialTest(t, addr)
+	pusher := dialTest(t, addr)
+
+	if err := sendCmd(waiter.conn, waiter.w, "BLMOVE", "src", "dst", "LEFT", "RIGHT", "1"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	pusher.mustInt(1, "LPUSH", "src", "v")
+
+	v := waiter.read()
+	if v.Type != resp.BulkString || string(v.Bulk) != "v" {
+		t.Fatalf("expected \"v\", got %s", fmtValue(v))
+	}
+	pusher.mustStrings([]string{"v"}, "LRANGE", "dst", "0", "-1")
+}
+
+func TestBLPOP_TimeoutReturnsNullArray(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	start := time.Now()
+	v := c.do("BRPOP", "empty", "0.1")
+	if v.Type != resp.Array || v.Array != nil {
+		t.Fatalf("expected null array, got %s", fmtValue(v))
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatalf("returned before timeout: %v", d)
+	}
+	c.mustNil("BRPOPLPUSH", "empty", "dst", "0.05")
+	c.mustErr("timeout is negative", "BLPOP", "empty", "-1")
+	c.mustErr("timeout is not a float or out of range", "BLPOP", "empty", "abc")
+
+	// The connection is still usable afterwards.
+	c.mustInt(1, "RPUSH", "empty", "x")
+	c.mustStrings([]string{"empty", "x"}, "BLPOP", "empty", "0")
+}
+
+func TestList_SurvivesAOFRewriteAndReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(200, append([]string{"RPUSH", "l"}, numbered(200)...)...)
+	c.mustStrings([]string{"0", "1"}, "LPOP", "l", "2")
+	c.mustBulk("199", "RPOPLPUSH", "l", "l")
+	c.mustStrings([]string{"l", "199"}, "BLPOP", "l", "0")
+	c.mustOK("LSET", "l", "0", "first")
+	c.mustInt(1, "RPUSH", "other", "x")
+	c.mustBulk("x", "RPOP", "other")
+	_ = s.Close()
+
+	want := []string{"first", "3", "4"}
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		st := dbs.DB(0)
+		if n, _ := st.LLen("l"); n != 197 {
+			t.Fatalf("%s: expected len 197, got %d", stage, n)
+		}
+		items, _ := st.LRange("l", 0, 2)
+		for i, v := range items {
+			if string(v) != want[i] {
+				t.Fatalf("%s: expected %q at %d, got %q", stage, want[i], i, v)
+			}
+		}
+		if items, _ := st.LRange("l", -1, -1); len(items) != 1 || string(items[0]) != "198" {
+			t.Fatalf("%s: expected last element 198, got %q", stage, items)
+		}
+		if st.Exists("other") {
+			t.Fatalf("%s: expected emptied list to be gone", stage)
+		}
+	})
+}
+
+func TestList_ConcurrentPushAndPopReplayToTheSameList(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+
+	// A pop must not apply before the push it follows is logged.
+	var n int
+	cmds := [][]string{{"RPUSH", "l", "a", "b"}, {"LPOP", "l"}}
+	sendWhileLogStalled(t, s, addr, cmds, func() {
+		n, _ = s.dbs.DB(0).LLen("l")
+	})
+	if n != 2 {
+		t.Fatalf("LPOP applied while the RPUSH was not logged yet: len %d", n)
+	}
+
+	pipelineFromClients(t, addr, 8, 300, func(client, i int) []string {
+		v := strconv.Itoa(client) + "-" + strconv.Itoa(i)
+		switch i % 3 {
+		case 0:
+			return []string{"RPUSH", "l", v}
+		case 1:
+			return []string{"LPUSH", "l", v}
+		default:
+			return []string{"LPOP", "l"}
+		}
+	})
+	_ = s.Close()
+
+	want, _ := s.dbs.DB(0).LRange("l", 0, -1)
+	got, _ := replayInto(t, path).LRange("l", 0, -1)
+	if len(got) != len(want) {
+		t.Fatalf("replayed %d elements, live %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i]) != string(want[i]) {
+			t.Fatalf("element %d: replayed %q, live %q", i, got[i], want[i])
+		}
+	}
+}
+
+func numbered(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = strconv.Itoa(i)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+// internal/server/maxmemory.go
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// SetMaxMemory sets the memory limit in bytes (0 disables it) and the
+// policy used to get back under it. It is safe to call while serving.
+func (s *Server) SetMaxMemory(limit int64, policy store.EvictionPolicy) {
+	s.maxmemory.Store(limit)
+	s.maxmemoryPolicy.Store(uint32(policy))
+}
+
+// evictIfNeeded evicts keys under the configured policy until the estimated
+// memory is back under maxmemory, logging a DEL for every evicted key so
+// replay ends up with the same data. It reports whether memory is within
+// the limit; a non-nil error means the AOF write failed.
+func (s *Server) evictIfNeeded() (bool, error) {
+	limit := s.maxmemory.Load()
+	if limit <= 0 {
+		return true, nil
+	}
+	policy := store.EvictionPolicy(s.maxmemoryPolicy.Load())
+
+	for s.dbs.UsedMemory() > limit {
+		db, key, ok := s.dbs.Evict(policy)
+		if !ok {
+			return false, nil
+		}
+		if key == "" {
+			continue
+		}
+		s.evictedKeys.Add(1)
+		if err := s.appendAOFDB(db, "DEL", []string{key}); err != nil {
+			return false, err
+		}
+		s.notifyKeyspaceEvent(notifyEvicted, "evicted", db, key)
+	}
+	return true, nil
+}
+
+var errBadMemory = errors.New("argument must be a memory value")
+
+// ParseMemory parses a Redis memory value: a byte count with an optional
+// unit, where k/m/g are powers of 1000 and kb/mb/gb powers of 1024
+// ("100mb", "1gb", "4096").
+func ParseMemory(s string) (int64, error) {
+	s = strings.ToLower(s)
+	num, mul := s, int64(1)
+	for _, u := range []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	} {
+		if strings.HasSuffix(s, u.suffix) {
+			num, mul = strings.TrimSuffix(s, u.suffix), u.mul
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/mul {
+		return 0, errBadMemory
+	}
+	return n * mul, nil
+}
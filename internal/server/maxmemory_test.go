@@ -0,0 +1,130 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := map[string]int64{
+		"0":     0,
+		"4096":  4096,
+		"100b":  100,
+		"1k":    1000,
+		"1kb":   1024,
+		"2mb":   2 << 20,
+		"3M":    3000000,
+		"1GB":   1 << 30,
+		"10g":   10000000000,
+		"512kB": 512 << 10,
+	}
+	for in, want := range tests {
+		got, err := ParseMemory(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseMemory(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "-1", "mb", "1tb", "1.5mb", "99999999999gb"} {
+		if _, err := ParseMemory(bad); err == nil {
+			t.Fatalf("ParseMemory(%q): expected error", bad)
+		}
+	}
+}
+
+func TestConfig_GetSet(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustStrings([]string{"maxmemory", "0", "maxmemory-policy", "noeviction"}, "CONFIG", "GET", "maxmemory*")
+	c.mustOK("CONFIG", "SET", "maxmemory", "10mb", "maxmemory-policy", "ALLKEYS-LRU")
+	c.mustStrings([]string{"maxmemory", "10485760"}, "CONFIG", "GET", "maxmemory")
+	c.mustStrings([]string{"maxmemory-policy", "allkeys-lru"}, "config", "get", "MAXMEMORY-POLICY")
+	c.mustStrings([]string{}, "CONFIG", "GET", "nosuch")
+
+	// An invalid pair rejects the whole CONFIG SET.
+	c.mustErr("argument must be a memory value", "CONFIG", "SET", "maxmemory-policy", "volatile-ttl", "maxmemory", "lots")
+	c.mustStrings([]string{"maxmemory-policy", "allkeys-lru"}, "CONFIG", "GET", "maxmemory-policy")
+	c.mustErr("maxmemory-policy", "CONFIG", "SET", "maxmemory-policy", "fifo")
+	c.mustErr("Unknown option", "CONFIG", "SET", "appendonly", "yes")
+	c.mustErr("wrong number of arguments for 'config|set'", "CONFIG", "SET", "maxmemory")
+	c.mustErr("unknown subcommand 'REWRITE'", "CONFIG", "REWRITE")
+}
+
+func TestMaxMemory_NoEvictionRejectsDenyOOMWrites(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "a", strings.Repeat("x", 1000))
+	s.SetMaxMemory(100, store.NoEviction)
+
+	c.mustErr("OOM command not allowed when used memory > 'maxmemory'.", "SET", "b", "1")
+	c.mustErr("OOM", "RPUSH", "l", "1")
+	c.mustInt(1000, "STRLEN", "a")
+	c.mustInt(1, "EXPIRE", "a", "100")
+	c.mustInt(1, "DEL", "a")
+	c.mustOK("SET", "b", "1")
+	if !strings.Contains(string(c.do("INFO").Bulk), "maxmemory_policy:noeviction") {
+		t.Fatalf("INFO does not report the policy")
+	}
+}
+
+func TestMaxMemory_EvictionIsLoggedAsDEL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	dbs := store.NewDatabases(4)
+	s, addr, err := StartDatabases("127.0.0.1:0", dbs, aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	c := dialTest(t, addr)
+
+	c.mustOK("SELECT", "2")
+	for _, k := range numbered(20) {
+		c.mustOK("SET", k, strings.Repeat("v", 100))
+	}
+	limit := dbs.UsedMemory() / 2
+	c.mustOK("CONFIG", "SET", "maxmemory-policy", "allkeys-random", "maxmemory", "100")
+	c.mustOK("CONFIG", "SET", "maxmemory", "0")
+	if n := dbs.UsedMemory(); n > 100 {
+		t.Fatalf("used memory %d still above the limit", n)
+	}
+	if n := dbs.DB(2).Len(); n != 0 {
+		t.Fatalf("expected every key to be evicted, %d left", n)
+	}
+
+	// Writes keep succeeding under an eviction policy, evicting older keys.
+	s.SetMaxMemory(limit, store.AllKeysLRU)
+	for _, k := range numbered(20) {
+		c.mustOK("SET", "new"+k, strings.Repeat("v", 100))
+	}
+	if n := dbs.UsedMemory(); n > limit+300 {
+		t.Fatalf("used memory %d well above the limit %d", n, limit)
+	}
+	info := string(c.do("INFO").Bulk)
+	if !strings.Contains(info, "evicted_keys:") || strings.Contains(info, "evicted_keys:0\r\n") {
+		t.Fatalf("INFO does not count evictions: %q", info)
+	}
+	_ = s.Close()
+
+	replayed := store.NewDatabases(4)
+	if err := aof.Replay(path, NewDatabasesLoader(replayed).Apply); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	live, got := dbs.DB(2).Keys("*"), replayed.DB(2).Keys("*")
+	if len(live) != len(got) {
+		t.Fatalf("replay has %d keys, live server had %d", len(got), len(live))
+	}
+	for _, k := range live {
+		if !replayed.DB(2).Exists(k) {
+			t.Fatalf("key %q missing after replay", k)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestMGetMSet(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MSET", "a", "1", "b", "2", "c", "3")
+	c.mustStrings([]string{"1", "2", "<nil>", "3"}, "MGET", "a", "b", "missing", "c")
+	c.mustInt(1, "HSET", "h", "f", "v")
+	c.mustStrings([]string{"<nil>", "1"}, "MGET", "h", "a")
+	c.mustErr("wrong number of arguments for 'mset' command", "MSET", "a", "1", "b")
+	c.mustErr("wrong number of arguments", "MGET")
+
+	c.mustOK("MSET", "h", "now-a-string")
+	c.mustBulk("now-a-string", "GET", "h")
+}
+
+func TestMSet_SingleAOFEntry(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	args := []string{"MSET"}
+	for _, key := range numbered(100) {
+		args = append(args, key, "v")
+	}
+	c.mustOK(args...)
+	_ = s.Close()
+
+	raw := readAOF(t, path)
+	if n := strings.Count(string(raw), "MSET"); n != 1 {
+		t.Fatalf("expected one MSET entry, got %d", n)
+	}
+
+	st2 := replayInto(t, path)
+	for _, key := range numbered(100) {
+		if !st2.Exists(key) {
+			t.Fatalf("expected %s after replay", key)
+		}
+	}
+
+	// A crash in the middle of the entry leaves nothing half applied.
+	if err := os.WriteFile(lastAOFPart(t, path), raw[:len(raw)-10], 0o644); err != nil {
+		t.Fatalf("truncate aof: %v", err)
+	}
+	st3 := store.New()
+	if err := aof.Replay(path, NewLoader(st3).Apply); err != nil {
+		t.Fatalf("replay truncated: %v", err)
+	}
+	for _, key := range numbered(100) {
+		if st3.Exists(key) {
+			t.Fatalf("expected no keys from a truncated MSET, found %s", key)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// internal/server/multi.go
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+// transaction is the state of a client between MULTI and EXEC / DISCARD.
+type transaction struct {
+	queue  []queuedCommand
+	failed bool // a command was rejected while queueing: EXEC aborts
+}
+
+type queuedCommand struct {
+	cmd  *command
+	args []string
+}
+
+// watchedKey is a key WATCHed by a client. expiresAt (unix milliseconds, 0
+// if none) is the expiry the key had at WATCH time: EXEC aborts once it has
+// passed, since the key expiring changes it as much as a write would.
+type watchedKey struct {
+	waitKey
+	expiresAt int64
+}
+
+// watchers tracks which clients WATCH which keys, so writes can mark their
+// transactions dirty.
+type watchers struct {
+	mu sync.Mutex
+	m  map[waitKey]map[*client]struct{}
+	n  atomic.Int64 // len(m), read without mu so writes skip the lock when nobody watches
+}
+
+// runsInMulti reports whether cmd runs right away inside MULTI instead of
+// being queued.
+func runsInMulti(cmd *command) bool {
+	switch cmd.name {
+	case "multi", "exec", "discard", "watch":
+		return true
+	}
+	return false
+}
+
+// queueCommand queues cmd for the pending transaction of c, or flags the
+// transaction if cmd is rejected.
+func (s *Server) queueCommand(c *client, cmd *command, args []string) error {
+	if s.maxmemory.Load() > 0 && cmd.hasFlag("denyoom") {
+		ok, err := s.evictIfNeeded()
+		if err != nil {
+			return writeAOFError(c.w)
+		}
+		if !ok {
+			c.tx.failed = true
+			_ = resp.WriteError(c.w, msgOOM)
+			return nil
+		}
+	}
+	c.tx.queue = append(c.tx.queue, queuedCommand{cmd: cmd, args: args})
+	_ = resp.WriteSimpleString(c.w, "QUEUED")
+	return nil
+}
+
+// flagTransaction makes the pending transaction of c (if any) abort at
+// EXEC, after a command was rejected while queueing.
+func (c *client) flagTransaction() {
+	if c.tx != nil {
+		c.tx.failed = true
+	}
+}
+
+func cmdMulti(s *Server, c *client, args []string) error {
+	if c.tx != nil {
+		_ = resp.WriteError(c.w, "ERR MULTI calls can not be nested")
+		return nil
+	}
+	c.tx = &transaction{}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+func cmdDiscard(s *Server, c *client, args []string) error {
+	if c.tx == nil {
+		_ = resp.WriteError(c.w, "ERR DISCARD without MULTI")
+		return nil
+	}
+	c.tx = nil
+	s.unwatchAll(c)
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// EXEC runs the queued commands with every other client held off (dispatch
+// takes execMu for writing), so no command of theirs interleaves. Writes are
+// logged between MULTI and EXEC so that replay skips a transaction the AOF
+// lost the end of.
+func cmdExec(s *Server, c *client, args []string) error {
+	tx := c.tx
+	if tx == nil {
+		_ = resp.WriteError(c.w, "ERR EXEC without MULTI")
+		return nil
+	}
+	c.tx = nil
+	dirty := s.watchedKeysChanged(c)
+	s.unwatchAll(c)
+
+	if tx.failed {
+		_ = resp.WriteError(c.w, "EXECABORT Transaction discarded because of previous errors.")
+		return nil
+	}
+	if dirty {
+		_ = resp.WriteNullArray(c.w)
+		return nil
+	}
+
+	s.beginExecLog()
+	c.inExec = true
+	_ = resp.WriteArrayHeader(c.w, len(tx.queue))
+	var err error
+	for _, q := range tx.queue {
+		c.cmd, c.args = q.cmd, q.args
+		if err = q.cmd.fn(s, c, q.args); err != nil {
+			break
+		}
+	}
+	c.inExec = false
+	if endErr := s.endExecLog(); endErr != nil && err == nil {
+		// Every reply is out already; dropping the connection is the only
+		// way left to tell the client the transaction may not be durable.
+		return errCloseConn
+	}
+	return err
+}
+
+// WATCH key [key ...]
+func cmdWatch(s *Server, c *client, args []string) error {
+	if c.tx != nil {
+		_ = resp.WriteError(c.w, "ERR WATCH inside MULTI is not allowed")
+		return nil
+	}
+
+	w := &s.watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.m == nil {
+		w.m = make(map[waitKey]map[*client]struct{})
+	}
+	for _, key := range args {
+		wk := waitKey{c.dbIndex, key}
+		set := w.m[wk]
+		if _, ok := set[c]; ok {
+			continue
+		}
+		if set == nil {
+			set = make(map[*client]struct{})
+			w.m[wk] = set
+		}
+		set[c] = struct{}{}
+		c.watched = append(c.watched, watchedKey{waitKey: wk, expiresAt: max(c.db.ExpireTimeMs(key), 0)})
+	}
+	w.n.Store(int64(len(w.m)))
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+func cmdUnwatch(s *Server, c *client, args []string) error {
+	s.unwatchAll(c)
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+// unwatchAll forgets every key c watches and clears its dirty flag.
+func (s *Server) unwatchAll(c *client) {
+	if len(c.watched) > 0 {
+		w := &s.watchers
+		w.mu.Lock()
+		for _, wk := range c.watched {
+			if set := w.m[wk.waitKey]; set != nil {
+				delete(set, c)
+				if len(set) == 0 {
+					delete(w.m, wk.waitKey)
+				}
+			}
+		}
+		w.n.Store(int64(len(w.m)))
+		w.mu.Unlock()
+		c.watched = nil
+	}
+	c.dirty.Store(false)
+}
+
+// watchedKeysChanged reports whether a key c watches was written to or
+// expired since WATCH.
+func (s *Server) watchedKeysChanged(c *client) bool {
+	if c.dirty.Load() {
+		return true
+	}
+	now := time.Now().UnixMilli()
+	for _, wk := range c.watched {
+		if wk.expiresAt != 0 && now >= wk.expiresAt {
+			return true
+		}
+	}
+	return false
+}
+
+// touchWatched marks the transactions watching the keys written by a logged
+// command as dirty. It is called for everything that goes to the AOF, so
+// evictions and commands spanning databases are covered too.
+func (s *Server) touchWatched(db int, name string, args []string) {
+	w := &s.watchers
+	if w.n.Load() == 0 {
+		return
+	}
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch cmd.name {
+	case "flushall":
+		w.touchDBLocked(-1)
+		return
+	case "flushdb":
+		w.touchDBLocked(db)
+		return
+	case "swapdb":
+		for _, a := range args {
+			if i, err := strconv.Atoi(a); err == nil {
+				w.touchDBLocked(i)
+			}
+		}
+		return
+	case "move":
+		if dst, err := strconv.Atoi(args[1]); err == nil {
+			w.touchLocked(waitKey{dst, args[0]})
+		}
+	case "copy":
+		for i := 2; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "DB") {
+				if dst, err := strconv.Atoi(args[i+1]); err == nil {
+					w.touchLocked(waitKey{dst, args[1]})
+				}
+			}
+		}
+	}
+	for _, key := range cmd.keys(args) {
+		w.touchLocked(waitKey{db, key})
+	}
+}
+
+func (w *watchers) touchLocked(wk waitKey) {
+	for c := range w.m[wk] {
+		c.dirty.Store(true)
+	}
+}
+
+// touchDBLocked touches every watched key of database db, or of all
+// databases if db is -1.
+func (w *watchers) touchDBLocked(db int) {
+	for wk, set := range w.m {
+		if db >= 0 && wk.db != db {
+			continue
+		}
+		for c := range set {
+			c.dirty.Store(true)
+		}
+	}
+}
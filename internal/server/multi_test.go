@@ -0,0 +1,289 @@
+package server
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func (tc *testConn) mustQueued(parts ...string) {
+	tc.t.Helper()
+	v := tc.do(parts...)
+	if v.Type != resp.SimpleString || v.Str != "QUEUED" {
+		tc.t.Fatalf("%v: expected +QUEUED, got %s", parts, fmtValue(v))
+	}
+}
+
+func TestMulti_ExecRunsQueuedCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "a", "1")
+	c.mustQueued("INCR", "a")
+	c.mustQueued("GET", "a")
+	c.mustQueued("LPUSH", "a", "x") // fails at run time, the rest still runs
+	c.mustQueued("INCR", "a")
+	v := c.do("EXEC")
+	if v.Type != resp.Array || len(v.Array) != 5 {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+	if r := v.Array; r[0].Str != "OK" || r[1].Int != 2 || string(r[2].Bulk) != "2" ||
+		r[3].Type != resp.Error || !strings.HasPrefix(r[3].Str, "WRONGTYPE") || r[4].Int != 3 {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+
+	c.mustOK("MULTI")
+	v = c.do("EXEC")
+	if v.Type != resp.Array || v.Array == nil || len(v.Array) != 0 {
+		t.Fatalf("expected an empty array, got %s", fmtValue(v))
+	}
+}
+
+func TestMulti_QueueErrorsAbortExec(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "a", "1")
+	c.mustErr("unknown command 'nosuch'", "NOSUCH")
+	c.mustErr("wrong number of arguments for 'get'", "GET")
+	c.mustErr("EXECABORT Transaction discarded because of previous errors.", "EXEC")
+	c.mustNil("GET", "a")
+
+	// Out of memory at queue time aborts too.
+	c.mustOK("SET", "big", strings.Repeat("x", 1000))
+	s.SetMaxMemory(100, store.NoEviction)
+	c.mustOK("MULTI")
+	c.mustQueued("DEL", "big")
+	c.mustErr("OOM", "SET", "a", "1")
+	c.mustErr("EXECABORT", "EXEC")
+	c.mustInt(1000, "STRLEN", "big")
+	s.SetMaxMemory(0, store.NoEviction)
+
+	c.mustErr("ERR EXEC without MULTI", "EXEC")
+	c.mustErr("ERR DISCARD without MULTI", "DISCARD")
+
+	c.mustOK("MULTI")
+	c.mustErr("ERR MULTI calls can not be nested", "MULTI")
+	c.mustErr("ERR WATCH inside MULTI is not allowed", "WATCH", "a")
+	c.mustQueued("SET", "a", "1")
+	c.mustOK("DISCARD")
+	c.mustNil("GET", "a")
+}
+
+func TestMulti_BlockingCommandsDoNotBlock(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("MULTI")
+	c.mustQueued("BLPOP", "empty", "0")
+	c.mustQueued("RPUSH", "l", "x")
+	c.mustQueued("BLPOP", "l", "0")
+	v := c.do("EXEC")
+	if len(v.Array) != 3 || v.Array[0].Array != nil || bulkStrings(v.Array[2])[1] != "x" {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+}
+
+func TestMulti_BlockedClientDoesNotHoldUpExec(t *testing.T) {
+	_, addr := startTestServer(t)
+	waiter := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	if err := sendCmd(waiter.conn, waiter.w, "BLPOP", "l", "0"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	c.mustOK("MULTI")
+	c.mustQueued("RPUSH", "l", "x")
+	if v := c.do("EXEC"); len(v.Array) != 1 || v.Array[0].Int != 1 {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+	if got := bulkStrings(waiter.read()); len(got) != 2 || got[1] != "x" {
+		t.Fatalf("blocked client got %v", got)
+	}
+}
+
+func TestMulti_ExecIsAtomic(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		other := dialTest(t, addr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := sendCmd(other.conn, other.w, "INCR", "n"); err != nil {
+					return
+				}
+				if _, err := resp.Decode(other.r); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	for round := 0; round < 20; round++ {
+		c.mustOK("MULTI")
+		for i := 0; i < 50; i++ {
+			c.mustQueued("INCR", "n")
+		}
+		c.mustQueued("GET", "n")
+		v := c.do("EXEC")
+		if len(v.Array) != 51 {
+			t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+		}
+		first, last := v.Array[0].Int, v.Array[49].Int
+		if last-first != 49 || string(v.Array[50].Bulk) != strconv.FormatInt(last, 10) {
+			t.Fatalf("round %d: other clients interleaved (first=%d last=%d get=%s)",
+				round, first, last, v.Array[50].Bulk)
+		}
+	}
+}
+
+func TestWatch_AbortsExecWhenKeyChanges(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	other := dialTest(t, addr)
+
+	// Another client writes the watched key.
+	c.mustOK("SET", "k", "1")
+	c.mustOK("WATCH", "k")
+	other.mustOK("SET", "k", "2")
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "k", "3")
+	c.mustNil("EXEC")
+	c.mustBulk("2", "GET", "k")
+
+	// EXEC forgets the watched keys, whatever its outcome.
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "k", "3")
+	if v := c.do("EXEC"); len(v.Array) != 1 || v.Array[0].Str != "OK" {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+
+	// Writes to other keys and reads do not count.
+	c.mustOK("WATCH", "k")
+	other.mustOK("SET", "unrelated", "1")
+	other.mustBulk("3", "GET", "k")
+	c.mustOK("MULTI")
+	c.mustQueued("INCR", "k")
+	if v := c.do("EXEC"); len(v.Array) != 1 || v.Array[0].Int != 4 {
+		t.Fatalf("unexpected EXEC reply: %s", fmtValue(v))
+	}
+
+	// UNWATCH drops the watch.
+	c.mustOK("WATCH", "k")
+	c.mustOK("UNWATCH")
+	other.mustOK("SET", "k", "x")
+	c.mustOK("MULTI")
+	c.mustQueued("GET", "k")
+	c.mustStrings([]string{"x"}, "EXEC")
+
+	// A watched key in another database is not the same key.
+	c.mustOK("WATCH", "k")
+	other.mustOK("SELECT", "1")
+	other.mustOK("SET", "k", "db1")
+	c.mustOK("MULTI")
+	c.mustQueued("GET", "k")
+	c.mustStrings([]string{"x"}, "EXEC")
+
+	// FLUSHALL touches every key.
+	c.mustOK("WATCH", "k")
+	other.mustOK("FLUSHALL")
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "k", "1")
+	c.mustNil("EXEC")
+}
+
+func TestWatch_AbortsExecWhenKeyExpiresOrIsEvicted(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "v", "PX", "50")
+	c.mustOK("WATCH", "k")
+	time.Sleep(100 * time.Millisecond)
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "k", "new")
+	c.mustNil("EXEC")
+
+	c.mustOK("SET", "k", strings.Repeat("v", 1000))
+	c.mustOK("WATCH", "k")
+	s.SetMaxMemory(100, store.AllKeysRandom)
+	c.mustOK("MULTI") // evicts k before running
+	c.mustQueued("GET", "k")
+	c.mustNil("EXEC")
+}
+
+func TestMulti_LoggedAsTransaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+	aw, err := aof.Open(path)
+	if err != nil {
+		t.Fatalf("open aof: %v", err)
+	}
+	s, addr, err := Start("127.0.0.1:0", store.New(), aw, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "before", "1")
+	c.mustOK("MULTI")
+	c.mustQueued("SET", "a", "1")
+	c.mustQueued("SELECT", "2")
+	c.mustQueued("SET", "b", "2")
+	c.do("EXEC")
+	// A read-only transaction logs nothing.
+	c.mustOK("MULTI")
+	c.mustQueued("GET", "a")
+	c.do("EXEC")
+	_ = s.Close()
+
+	var cmds []string
+	if err := aof.Replay(path, func(cmd string, args []string) error {
+		cmds = append(cmds, cmd)
+		return nil
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if got := strings.Join(cmds, " "); got != "SELECT SET SET SELECT SET" {
+		t.Fatalf("replayed %q", got)
+	}
+	raw := readAOF(t, path)
+	if strings.Count(string(raw), "MULTI") != 1 || strings.Count(string(raw), "EXEC") != 1 {
+		t.Fatalf("transaction not wrapped in MULTI/EXEC:\n%q", raw)
+	}
+
+	dbs := store.NewDatabases(store.DefaultDatabases)
+	if err := aof.Replay(path, NewDatabasesLoader(dbs).Apply); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if _, ok := dbs.DB(0).Get("a"); !ok {
+		t.Fatalf("a missing after replay")
+	}
+	if _, ok := dbs.DB(2).Get("b"); !ok {
+		t.Fatalf("b missing from db 2 after replay")
+	}
+}
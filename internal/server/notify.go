@@ -0,0 +1,262 @@
+// internal/server/notify.go
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyspace notification classes, selected by the characters of the
+// notify-keyspace-events parameter.
+const (
+	notifyKeyspace uint32 = 1 << iota // K: __keyspace@<db>__:<key> <event>
+	notifyKeyevent                    // E: __keyevent@<db>__:<event> <key>
+	notifyGeneric                     // g: DEL, EXPIRE, RENAME, ...
+	notifyString                      // $
+	notifyList                        // l
+	notifySet                         // s
+	notifyHash                        // h
+	notifyZSet                        // z
+	notifyExpired                     // x: a key's expiry passed
+	notifyEvicted                     // e: a key was evicted by maxmemory
+	notifyStream                      // t
+
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet |
+		notifyHash | notifyZSet | notifyExpired | notifyEvicted | notifyStream // A
+)
+
+// notifyClasses lists the class characters in the order CONFIG GET prints
+// them.
+var notifyClasses = []struct {
+	c     byte
+	class uint32
+}{
+	{'g', notifyGeneric}, {'$', notifyString}, {'l', notifyList}, {'s', notifySet},
+	{'h', notifyHash}, {'z', notifyZSet}, {'x', notifyExpired}, {'e', notifyEvicted},
+	{'t', notifyStream}, {'K', notifyKeyspace}, {'E', notifyKeyevent},
+}
+
+// parseNotifyFlags parses a notify-keyspace-events value.
+func parseNotifyFlags(val string) (uint32, bool) {
+	var flags uint32
+outer:
+	for i := 0; i < len(val); i++ {
+		if val[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		for _, nc := range notifyClasses {
+			if val[i] == nc.c {
+				flags |= nc.class
+				continue outer
+			}
+		}
+		return 0, false
+	}
+	return flags, true
+}
+
+// formatNotifyFlags is the inverse of parseNotifyFlags, using A when every
+// class is selected.
+func formatNotifyFlags(flags uint32) string {
+	var b strings.Builder
+	if flags&notifyAll == notifyAll {
+		b.WriteByte('A')
+	}
+	for _, nc := range notifyClasses {
+		if flags&nc.class != 0 && (nc.class&notifyAll == 0 || flags&notifyAll != notifyAll) {
+			b.WriteByte(nc.c)
+		}
+	}
+	return b.String()
+}
+
+// notifyKeyspaceEvent publishes event on key of database db, if its class
+// and at least one of K and E are enabled.
+func (s *Server) notifyKeyspaceEvent(class uint32, event string, db int, key string) {
+	flags := s.notifyFlags.Load()
+	if flags&class == 0 {
+		return
+	}
+	prefix := "@" + strconv.Itoa(db) + "__:"
+	if flags&notifyKeyspace != 0 {
+		s.publish(subChannel, "__keyspace"+prefix+key, event)
+	}
+	if flags&notifyKeyevent != 0 {
+		s.publish(subChannel, "__keyevent"+prefix+event, key)
+	}
+}
+
+// notifyWrite publishes the keyspace events of exec, the executing command
+// with execArgs, which was logged to the AOF as cmd with args. Commands that are logged as
+// another one report their own event, taking the key from the logged form
+// (INCR, logged as SET, reports incrby); the others report the events of
+// the logged form, which carries their result (SETEX, logged as SET with
+// PXAT, reports set and expire).
+func (s *Server) notifyWrite(db int, exec *command, execArgs []string, cmd string, args []string) {
+	if s.notifyFlags.Load() == 0 {
+		return
+	}
+
+	if exec != nil {
+		switch exec.name {
+		case "incr", "incrby":
+			s.notifyKeyspaceEvent(notifyString, "incrby", db, args[0])
+			return
+		case "decr", "decrby":
+			s.notifyKeyspaceEvent(notifyString, "decrby", db, args[0])
+			return
+		case "incrbyfloat":
+			s.notifyKeyspaceEvent(notifyString, "incrbyfloat", db, args[0])
+			return
+		case "hincrby", "hincrbyfloat":
+			s.notifyKeyspaceEvent(notifyHash, exec.name, db, args[0])
+			return
+		case "spop":
+			s.notifyKeyspaceEvent(notifySet, "spop", db, args[0])
+			s.notifyIfDeleted(db, args[0])
+			return
+		case "zincrby":
+			s.notifyKeyspaceEvent(notifyZSet, "zincr", db, args[0])
+			return
+		case "zadd":
+			if zaddIncr(execArgs) {
+				s.notifyKeyspaceEvent(notifyZSet, "zincr", db, args[0])
+				return
+			}
+		case "zpopmin", "zpopmax", "bzpopmin", "bzpopmax":
+			s.notifyKeyspaceEvent(notifyZSet, strings.TrimPrefix(exec.name, "b"), db, args[0])
+			s.notifyIfDeleted(db, args[0])
+			return
+		}
+	}
+
+	name := strings.ToLower(cmd)
+	switch name {
+	case "set":
+		s.notifyKeyspaceEvent(notifyString, "set", db, args[0])
+		for i := 2; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "PXAT") {
+				s.notifyExpireAt(db, args[0], args[i+1])
+			}
+		}
+	case "mset", "msetnx":
+		for i := 0; i+1 < len(args); i += 2 {
+			s.notifyKeyspaceEvent(notifyString, "set", db, args[i])
+		}
+	case "append", "setrange":
+		s.notifyKeyspaceEvent(notifyString, name, db, args[0])
+	case "del", "unlink":
+		for _, key := range args {
+			s.notifyKeyspaceEvent(notifyGeneric, "del", db, key)
+		}
+	case "pexpireat":
+		s.notifyExpireAt(db, args[0], args[1])
+	case "persist":
+		s.notifyKeyspaceEvent(notifyGeneric, "persist", db, args[0])
+	case "rename", "renamenx":
+		s.notifyKeyspaceEvent(notifyGeneric, "rename_from", db, args[0])
+		s.notifyKeyspaceEvent(notifyGeneric, "rename_to", db, args[1])
+	case "move":
+		if dst, err := strconv.Atoi(args[1]); err == nil {
+			s.notifyKeyspaceEvent(notifyGeneric, "move_from", db, args[0])
+			s.notifyKeyspaceEvent(notifyGeneric, "move_to", dst, args[0])
+		}
+	case "copy":
+		dst := db
+		for i := 2; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "DB") {
+				if n, err := strconv.Atoi(args[i+1]); err == nil {
+					dst = n
+				}
+			}
+		}
+		s.notifyKeyspaceEvent(notifyGeneric, "copy_to", dst, args[1])
+
+	case "hset":
+		s.notifyKeyspaceEvent(notifyHash, "hset", db, args[0])
+	case "hdel":
+		s.notifyKeyspaceEvent(notifyHash, "hdel", db, args[0])
+		s.notifyIfDeleted(db, args[0])
+
+	case "lpush", "rpush", "lpushx", "rpushx":
+		s.notifyKeyspaceEvent(notifyList, strings.TrimSuffix(name, "x"), db, args[0])
+	case "lset", "linsert":
+		s.notifyKeyspaceEvent(notifyList, name, db, args[0])
+	case "lpop", "rpop", "lrem", "ltrim":
+		s.notifyKeyspaceEvent(notifyList, name, db, args[0])
+		s.notifyIfDeleted(db, args[0])
+	case "lmove":
+		s.notifyKeyspaceEvent(notifyList, strings.ToLower(args[2])[:1]+"pop", db, args[0])
+		s.notifyIfDeleted(db, args[0])
+		s.notifyKeyspaceEvent(notifyList, strings.ToLower(args[3])[:1]+"push", db, args[1])
+
+	case "sadd":
+		s.notifyKeyspaceEvent(notifySet, "sadd", db, args[0])
+	case "srem":
+		s.notifyKeyspaceEvent(notifySet, "srem", db, args[0])
+		s.notifyIfDeleted(db, args[0])
+	case "smove":
+		s.notifyKeyspaceEvent(notifySet, "srem", db, args[0])
+		s.notifyIfDeleted(db, args[0])
+		s.notifyKeyspaceEvent(notifySet, "sadd", db, args[1])
+	case "sinterstore", "sunionstore", "sdiffstore":
+		s.notifyStore(notifySet, name, db, args[0])
+
+	case "zadd":
+		s.notifyKeyspaceEvent(notifyZSet, "zadd", db, args[0])
+	case "zrem":
+		s.notifyKeyspaceEvent(notifyZSet, "zrem", db, args[0])
+		s.notifyIfDeleted(db, args[0])
+	case "zrangestore", "zunionstore", "zinterstore":
+		s.notifyStore(notifyZSet, name, db, args[0])
+
+	case "xadd", "xtrim", "xdel", "xsetid":
+		s.notifyKeyspaceEvent(notifyStream, name, db, args[0])
+	case "xgroup":
+		s.notifyKeyspaceEvent(notifyStream, "xgroup-"+strings.ToLower(args[0]), db, args[1])
+	}
+}
+
+// zaddIncr reports whether the arguments of ZADD carry the INCR option.
+func zaddIncr(args []string) bool {
+	for _, arg := range args[1:] {
+		switch strings.ToUpper(arg) {
+		case "INCR":
+			return true
+		case "NX", "XX", "GT", "LT", "CH":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// notifyExpireAt reports an expiry set to unix milliseconds ms: expire, or
+// del if it has passed and the key is gone already.
+func (s *Server) notifyExpireAt(db int, key, ms string) {
+	at, err := strconv.ParseInt(ms, 10, 64)
+	if err == nil && at <= time.Now().UnixMilli() {
+		s.notifyKeyspaceEvent(notifyGeneric, "del", db, key)
+		return
+	}
+	s.notifyKeyspaceEvent(notifyGeneric, "expire", db, key)
+}
+
+// notifyIfDeleted reports del for a collection that a removal left empty.
+func (s *Server) notifyIfDeleted(db int, key string) {
+	if !s.dbs.DB(db).Exists(key) {
+		s.notifyKeyspaceEvent(notifyGeneric, "del", db, key)
+	}
+}
+
+// notifyStore reports a *STORE command, which deletes dst instead when the
+// result is empty.
+func (s *Server) notifyStore(class uint32, event string, db int, dst string) {
+	if s.dbs.DB(db).Exists(dst) {
+		s.notifyKeyspaceEvent(class, event, db, dst)
+		return
+	}
+	s.notifyKeyspaceEvent(notifyGeneric, "del", db, dst)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// mustEvents reads pattern messages and checks their "channel message"
+// pairs.
+func (tc *testConn) mustEvents(want ...string) {
+	tc.t.Helper()
+	for _, w := range want {
+		v := tc.read()
+		got := bulkStrings(v)
+		if len(got) != 4 || got[0] != "pmessage" || got[2]+" "+got[3] != w {
+			tc.t.Fatalf("expected event %q, got %s", w, fmtValue(v))
+		}
+	}
+}
+
+func TestNotify_Config(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustStrings([]string{"notify-keyspace-events", ""}, "CONFIG", "GET", "notify-keyspace-events")
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "KEA")
+	c.mustStrings([]string{"notify-keyspace-events", "AKE"}, "CONFIG", "GET", "notify-keyspace-events")
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "Ex$g")
+	c.mustStrings([]string{"notify-keyspace-events", "g$xE"}, "CONFIG", "GET", "notify-keyspace-events")
+	c.mustErr("ERR CONFIG SET failed", "CONFIG", "SET", "notify-keyspace-events", "KEQ")
+	c.mustStrings([]string{"notify-keyspace-events", "g$xE"}, "CONFIG", "GET", "notify-keyspace-events")
+}
+
+func TestNotify_WritesPublishKeyspaceAndKeyevent(t *testing.T) {
+	_, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "KEA")
+	sub.mustReply(`["psubscribe" "__key*@*__:*" :1]`, "PSUBSCRIBE", "__key*@*__:*")
+
+	c.mustOK("SET", "k", "v")
+	sub.mustEvents("__keyspace@0__:k set", "__keyevent@0__:set k")
+
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "EA")
+	c.mustOK("SET", "k", "v", "EX", "100")
+	sub.mustEvents("__keyevent@0__:set k", "__keyevent@0__:expire k")
+	c.mustOK("RENAME", "k", "k2")
+	sub.mustEvents("__keyevent@0__:rename_from k", "__keyevent@0__:rename_to k2")
+	c.mustInt(1, "DEL", "k2")
+	sub.mustEvents("__keyevent@0__:del k2")
+	c.mustInt(0, "DEL", "k2") // nothing deleted, nothing published
+
+	c.mustInt(1, "RPUSH", "l", "a")
+	c.mustBulk("a", "LPOP", "l")
+	sub.mustEvents("__keyevent@0__:rpush l", "__keyevent@0__:lpop l", "__keyevent@0__:del l")
+	c.mustInt(1, "HSET", "h", "f", "v")
+	c.mustOK("SELECT", "3")
+	c.mustInt(1, "SADD", "s", "m")
+	sub.mustEvents("__keyevent@0__:hset h", "__keyevent@3__:sadd s")
+
+	// Only the selected classes are published.
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "Eg")
+	c.mustOK("SET", "k", "v")
+	c.mustInt(1, "DEL", "k")
+	sub.mustEvents("__keyevent@3__:del k")
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "g") // neither K nor E
+	c.mustInt(1, "DEL", "s")
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "E$")
+	c.mustOK("SET", "last", "v")
+	sub.mustEvents("__keyevent@3__:set last")
+}
+
+func TestNotify_ExpiredAndEvicted(t *testing.T) {
+	s, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "Exe")
+	sub.mustReply(`["psubscribe" "__keyevent@*__:*" :1]`, "PSUBSCRIBE", "__keyevent@*__:*")
+
+	// Nobody touches the key: the reaper finds it.
+	c.mustOK("SET", "reaped", "v", "PX", "20")
+	sub.mustEvents("__keyevent@0__:expired reaped")
+
+	// A read finds it first; the reaper must not report it again.
+	c.mustOK("SET", "read", "v", "PX", "20")
+	time.Sleep(30 * time.Millisecond)
+	c.mustNil("GET", "read")
+	sub.mustEvents("__keyevent@0__:expired read")
+
+	c.mustOK("SET", "big", strings.Repeat("x", 1000))
+	s.SetMaxMemory(100, store.AllKeysRandom)
+	c.mustNil("GET", "big") // evicts big before running
+	sub.mustEvents("__keyevent@0__:evicted big")
+	s.SetMaxMemory(0, store.NoEviction)
+
+	c.mustOK("SET", "marker", "v", "PX", "1")
+	sub.mustEvents("__keyevent@0__:expired marker")
+}
+
+func TestNotify_StreamEvents(t *testing.T) {
+	_, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "Et")
+	sub.mustReply(`["psubscribe" "__keyevent@*__:*" :1]`, "PSUBSCRIBE", "__keyevent@*__:*")
+
+	c.mustBulk("1-0", "XADD", "s", "1-0", "f", "v")
+	c.mustBulk("2-0", "XADD", "s", "MAXLEN", "1", "2-0", "f", "v")
+	sub.mustEvents("__keyevent@0__:xadd s", "__keyevent@0__:xadd s", "__keyevent@0__:xtrim s")
+	c.mustOK("XGROUP", "CREATE", "s", "g", "0")
+	c.mustReply(`[["s" [["2-0" ["f" "v"]]]]]`, "XREADGROUP", "GROUP", "g", "c", "STREAMS", "s", ">")
+	c.mustInt(1, "XACK", "s", "g", "2-0") // no event
+	c.mustInt(1, "XDEL", "s", "2-0")
+	sub.mustEvents("__keyevent@0__:xgroup-create s", "__keyevent@0__:xgroup-createconsumer s", "__keyevent@0__:xdel s")
+}
+
+func TestNotify_EventsNameTheCommandThatRan(t *testing.T) {
+	_, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	c.mustOK("CONFIG", "SET", "notify-keyspace-events", "EA")
+	sub.mustReply(`["psubscribe" "__keyevent@*__:*" :1]`, "PSUBSCRIBE", "__keyevent@*__:*")
+
+	c.mustInt(1, "INCR", "n")
+	c.mustInt(3, "INCRBY", "n", "2")
+	c.mustInt(2, "DECR", "n")
+	c.mustInt(0, "DECRBY", "n", "2")
+	c.mustBulk("1.5", "INCRBYFLOAT", "n", "1.5")
+	sub.mustEvents("__keyevent@0__:incrby n", "__keyevent@0__:incrby n", "__keyevent@0__:decrby n",
+		"__keyevent@0__:decrby n", "__keyevent@0__:incrbyfloat n")
+
+	c.mustInt(1, "HINCRBY", "h", "i", "1")
+	c.mustBulk("0.5", "HINCRBYFLOAT", "h", "f", "0.5")
+	sub.mustEvents("__keyevent@0__:hincrby h", "__keyevent@0__:hincrbyfloat h")
+
+	c.mustInt(1, "SADD", "s", "m")
+	c.mustBulk("m", "SPOP", "s")
+	sub.mustEvents("__keyevent@0__:sadd s", "__keyevent@0__:spop s", "__keyevent@0__:del s")
+
+	c.mustBulk("2", "ZINCRBY", "z", "2", "a")
+	c.mustBulk("3", "ZADD", "z", "XX", "INCR", "1", "a")
+	c.mustInt(1, "ZADD", "z", "1", "b")
+	c.mustInt(1, "ZADD", "z", "1", "incr") // a member, not the option
+	c.mustReply(`["b" "1" "incr" "1"]`, "ZPOPMIN", "z", "2")
+	c.mustReply(`["a" "3"]`, "ZPOPMAX", "z")
+	sub.mustEvents("__keyevent@0__:zincr z", "__keyevent@0__:zincr z", "__keyevent@0__:zadd z",
+		"__keyevent@0__:zadd z", "__keyevent@0__:zpopmin z", "__keyevent@0__:zpopmax z", "__keyevent@0__:del z")
+
+	// Inside a transaction too.
+	c.mustOK("MULTI")
+	c.mustReply("+QUEUED", "INCR", "m")
+	c.mustReply("+QUEUED", "ZADD", "z", "INCR", "1", "a")
+	c.mustReply(`[:1 "1"]`, "EXEC")
+	sub.mustEvents("__keyevent@0__:incrby m", "__keyevent@0__:zincr z")
+}
@@ -0,0 +1,427 @@
+// internal/server/pubsub.go
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/glob"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+)
+
+// subKind is one of the three subscription namespaces.
+type subKind int
+
+const (
+	subChannel subKind = iota // SUBSCRIBE / PUBLISH
+	subPattern                // PSUBSCRIBE, matched against PUBLISH channels
+	subShard                  // SSUBSCRIBE / SPUBLISH
+	numSubKinds
+)
+
+// subReplies are the confirmation names of each kind: subscribe, unsubscribe.
+var subReplies = [numSubKinds][2]string{
+	subChannel: {"subscribe", "unsubscribe"},
+	subPattern: {"psubscribe", "punsubscribe"},
+	subShard:   {"ssubscribe", "sunsubscribe"},
+}
+
+// pubsub is the broker: who is subscribed to what. A client's own view of
+// its subscriptions (client.subs) is only touched by its connection
+// goroutine; the broker maps are shared with publishers under mu.
+type pubsub struct {
+	mu   sync.RWMutex
+	subs [numSubKinds]map[string]map[*client]struct{}
+}
+
+// outputLimit is a client-output-buffer-limit: a subscriber whose unsent
+// messages exceed hard bytes, or stay above soft bytes for softSeconds, is
+// disconnected instead of slowing down PUBLISH. Zero disables a limit.
+type outputLimit struct {
+	hard        atomic.Int64
+	soft        atomic.Int64
+	softSeconds atomic.Int64
+}
+
+// Redis' defaults for the pubsub class: 32mb 8mb 60.
+const (
+	defaultPubsubHardLimit   = 32 << 20
+	defaultPubsubSoftLimit   = 8 << 20
+	defaultPubsubSoftSeconds = 60
+)
+
+func (l *outputLimit) String() string {
+	return "pubsub " + strconv.FormatInt(l.hard.Load(), 10) + " " +
+		strconv.FormatInt(l.soft.Load(), 10) + " " + strconv.FormatInt(l.softSeconds.Load(), 10)
+}
+
+// exceeded reports whether an outbox holding n bytes, above the soft limit
+// since softSince, is over the limit at now.
+func (l *outputLimit) exceeded(n int64, softSince, now time.Time) bool {
+	if hard := l.hard.Load(); hard > 0 && n > hard {
+		return true
+	}
+	soft := l.soft.Load()
+	return soft > 0 && n > soft && !softSince.IsZero() &&
+		now.Sub(softSince) >= time.Duration(l.softSeconds.Load())*time.Second
+}
+
+// outbox queues the messages published to a client until its pusher
+// goroutine writes them, so a slow reader never blocks the publisher.
+type outbox struct {
+	mu        sync.Mutex
+	pending   [][]byte
+	bytes     int64
+	softSince time.Time // when bytes went above the soft limit
+	closed    bool      // disconnected for exceeding the limit
+	ready     chan struct{}
+	started   bool // pusher running; only touched by the connection goroutine
+}
+
+// deliver queues msg for c. It returns false if c is being disconnected,
+// which happens right here if msg takes it over its output limit.
+func (s *Server) deliver(c *client, msg []byte) bool {
+	o := &c.out
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	o.pending = append(o.pending, msg)
+	o.bytes += int64(len(msg))
+	if soft := s.pubsubLimit.soft.Load(); soft > 0 && o.bytes > soft {
+		if o.softSince.IsZero() {
+			o.softSince = now
+		}
+	} else {
+		o.softSince = time.Time{}
+	}
+	over := s.pubsubLimit.exceeded(o.bytes, o.softSince, now)
+	if over {
+		o.closed = true
+		o.pending, o.bytes = nil, 0
+	}
+	o.mu.Unlock()
+
+	if over {
+		s.outputLimitDisconnects.Add(1)
+		log.Printf("client %s closed for overcoming of output buffer limits", c.conn.RemoteAddr())
+		_ = c.conn.Close()
+		return false
+	}
+	notify(o.ready)
+	return true
+}
+
+// writePending writes the queued messages of c to c.w. Caller must hold
+// c.wmu.
+func (c *client) writePending() {
+	o := &c.out
+	o.mu.Lock()
+	msgs := o.pending
+	o.pending, o.bytes, o.softSince = nil, 0, time.Time{}
+	o.mu.Unlock()
+
+	for _, m := range msgs {
+		_, _ = c.w.Write(m)
+	}
+}
+
+// startPusher starts the goroutine sending published messages to c while
+// its connection goroutine waits for the next command.
+func (s *Server) startPusher(c *client) {
+	if c.out.started || c.conn == nil {
+		return
+	}
+	c.out.started = true
+	go func() {
+		for {
+			select {
+			case <-c.out.ready:
+			case <-c.done:
+				return
+			}
+			c.wmu.Lock()
+			c.writePending()
+			err := c.w.Flush()
+			c.wmu.Unlock()
+			if err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// encodePush encodes a pushed message as an array of bulk strings.
+func encodePush(parts ...string) []byte {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	_ = resp.WriteArrayHeader(w, len(parts))
+	for _, p := range parts {
+		_ = resp.WriteBulkString(w, []byte(p))
+	}
+	_ = w.Flush()
+	return buf.Bytes()
+}
+
+// subscriptions returns the count reported in (un)subscribe replies of kind:
+// channels and patterns count together, shard channels on their own.
+func (c *client) subscriptions(kind subKind) int {
+	if kind == subShard {
+		return len(c.subs[subShard])
+	}
+	return len(c.subs[subChannel]) + len(c.subs[subPattern])
+}
+
+// subscribed reports whether c is in subscribed mode.
+func (c *client) subscribed() bool {
+	return len(c.subs[subChannel])+len(c.subs[subPattern])+len(c.subs[subShard]) > 0
+}
+
+// allowedWhenSubscribed reports whether cmd may run in subscribed mode.
+func allowedWhenSubscribed(cmd *command) bool {
+	switch cmd.name {
+	case "subscribe", "psubscribe", "ssubscribe", "unsubscribe", "punsubscribe", "sunsubscribe", "ping":
+		return true
+	}
+	return false
+}
+
+func (s *Server) subscribe(c *client, kind subKind, names []string) {
+	s.startPusher(c)
+	ps := &s.pubsub
+	for _, name := range names {
+		if _, ok := c.subs[kind][name]; !ok {
+			if c.subs[kind] == nil {
+				c.subs[kind] = make(map[string]struct{})
+			}
+			c.subs[kind][name] = struct{}{}
+
+			ps.mu.Lock()
+			if ps.subs[kind] == nil {
+				ps.subs[kind] = make(map[string]map[*client]struct{})
+			}
+			set := ps.subs[kind][name]
+			if set == nil {
+				set = make(map[*client]struct{})
+				ps.subs[kind][name] = set
+			}
+			set[c] = struct{}{}
+			ps.mu.Unlock()
+		}
+		writeSubReply(c, subReplies[kind][0], name, c.subscriptions(kind))
+	}
+}
+
+// unsubscribe removes c from names, or from everything of kind if names is
+// empty. reply is false when the connection is going away.
+func (s *Server) unsubscribe(c *client, kind subKind, names []string, reply bool) {
+	if len(names) == 0 {
+		for name := range c.subs[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 && reply {
+			_ = resp.WriteArrayHeader(c.w, 3)
+			_ = resp.WriteBulkString(c.w, []byte(subReplies[kind][1]))
+			_ = resp.WriteBulkString(c.w, nil)
+			_ = resp.WriteInteger(c.w, int64(c.subscriptions(kind)))
+			return
+		}
+	}
+
+	ps := &s.pubsub
+	for _, name := range names {
+		if _, ok := c.subs[kind][name]; ok {
+			delete(c.subs[kind], name)
+
+			ps.mu.Lock()
+			if set := ps.subs[kind][name]; set != nil {
+				delete(set, c)
+				if len(set) == 0 {
+					delete(ps.subs[kind], name)
+				}
+			}
+			ps.mu.Unlock()
+		}
+		if reply {
+			// Nothing is published to name anymore: what was is sent ahead
+			// of the confirmation.
+			c.writePending()
+			writeSubReply(c, subReplies[kind][1], name, c.subscriptions(kind))
+		}
+	}
+}
+
+func writeSubReply(c *client, kind, name string, count int) {
+	_ = resp.WriteArrayHeader(c.w, 3)
+	_ = resp.WriteBulkString(c.w, []byte(kind))
+	_ = resp.WriteBulkString(c.w, []byte(name))
+	_ = resp.WriteInteger(c.w, int64(count))
+}
+
+// publish sends msg to the subscribers of channel (and, for regular
+// channels, of the patterns matching it) and returns how many received it.
+func (s *Server) publish(kind subKind, channel, msg string) int {
+	ps := &s.pubsub
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	n := 0
+	if set := ps.subs[kind][channel]; len(set) > 0 {
+		name := "message"
+		if kind == subShard {
+			name = "smessage"
+		}
+		m := encodePush(name, channel, msg)
+		for c := range set {
+			if s.deliver(c, m) {
+				n++
+			}
+		}
+	}
+	if kind == subChannel {
+		for pat, set := range ps.subs[subPattern] {
+			if !glob.Match(pat, channel) {
+				continue
+			}
+			m := encodePush("pmessage", pat, channel, msg)
+			for c := range set {
+				if s.deliver(c, m) {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// pubsubCounts returns the number of channels, patterns and shard channels
+// with at least one subscriber.
+func (s *Server) pubsubCounts() (channels, patterns, shard int) {
+	ps := &s.pubsub
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return len(ps.subs[subChannel]), len(ps.subs[subPattern]), len(ps.subs[subShard])
+}
+
+func cmdSubscribe(s *Server, c *client, args []string) error {
+	s.subscribe(c, subChannel, args)
+	return nil
+}
+
+func cmdPSubscribe(s *Server, c *client, args []string) error {
+	s.subscribe(c, subPattern, args)
+	return nil
+}
+
+func cmdSSubscribe(s *Server, c *client, args []string) error {
+	s.subscribe(c, subShard, args)
+	return nil
+}
+
+func cmdUnsubscribe(s *Server, c *client, args []string) error {
+	s.unsubscribe(c, subChannel, args, true)
+	return nil
+}
+
+func cmdPUnsubscribe(s *Server, c *client, args []string) error {
+	s.unsubscribe(c, subPattern, args, true)
+	return nil
+}
+
+func cmdSUnsubscribe(s *Server, c *client, args []string) error {
+	s.unsubscribe(c, subShard, args, true)
+	return nil
+}
+
+// PUBLISH channel message
+func cmdPublish(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, int64(s.publish(subChannel, args[0], args[1])))
+	return nil
+}
+
+// SPUBLISH shardchannel message
+func cmdSPublish(s *Server, c *client, args []string) error {
+	_ = resp.WriteInteger(c.w, int64(s.publish(subShard, args[0], args[1])))
+	return nil
+}
+
+func cmdPubSub(s *Server, c *client, args []string) error {
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "HELP":
+		writeStringArray(c.w, []string{
+			"PUBSUB <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CHANNELS [<pattern>]",
+			"    Return the currently active channels matching a <pattern> (default: '*').",
+			"NUMPAT",
+			"    Return number of subscriptions to patterns.",
+			"NUMSUB [<channel> ...]",
+			"    Return the number of subscribers for the specified channels, excluding",
+			"    pattern subscriptions(default: no channels).",
+			"SHARDCHANNELS [<pattern>]",
+			"    Return the currently active shard level channels matching a <pattern> (default: '*').",
+			"SHARDNUMSUB [<shardchannel> ...]",
+			"    Return the number of subscribers for the specified shard level channel(s)",
+		})
+	case "CHANNELS", "SHARDCHANNELS":
+		if len(args) > 2 {
+			writeWrongArgs(c.w, "pubsub|"+strings.ToLower(sub))
+			return nil
+		}
+		kind := subChannel
+		if sub == "SHARDCHANNELS" {
+			kind = subShard
+		}
+		writeStringArray(c.w, s.activeChannels(kind, args[1:]))
+	case "NUMSUB", "SHARDNUMSUB":
+		kind := subChannel
+		if sub == "SHARDNUMSUB" {
+			kind = subShard
+		}
+		ps := &s.pubsub
+		ps.mu.RLock()
+		_ = resp.WriteArrayHeader(c.w, 2*len(args[1:]))
+		for _, ch := range args[1:] {
+			_ = resp.WriteBulkString(c.w, []byte(ch))
+			_ = resp.WriteInteger(c.w, int64(len(ps.subs[kind][ch])))
+		}
+		ps.mu.RUnlock()
+	case "NUMPAT":
+		if len(args) != 1 {
+			writeWrongArgs(c.w, "pubsub|numpat")
+			return nil
+		}
+		_, patterns, _ := s.pubsubCounts()
+		_ = resp.WriteInteger(c.w, int64(patterns))
+	default:
+		_ = resp.WriteError(c.w, "ERR unknown subcommand '"+args[0]+"'. Try PUBSUB HELP.")
+	}
+	return nil
+}
+
+// activeChannels returns the channels of kind with subscribers, optionally
+// filtered by a glob pattern, sorted.
+func (s *Server) activeChannels(kind subKind, pattern []string) []string {
+	ps := &s.pubsub
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	out := make([]string, 0, len(ps.subs[kind]))
+	for ch := range ps.subs[kind] {
+		if len(pattern) == 0 || glob.Match(pattern[0], ch) {
+			out = append(out, ch)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
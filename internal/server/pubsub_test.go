@@ -0,0 +1,167 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustPush reads a pushed message and checks it, formatted by fmtValue.
+func (tc *testConn) mustPush(want string) {
+	tc.t.Helper()
+	if got := fmtValue(tc.read()); got != want {
+		tc.t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestPubSub_PublishReachesChannelAndPatternSubscribers(t *testing.T) {
+	_, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	psub := dialTest(t, addr)
+	pub := dialTest(t, addr)
+
+	sub.mustReply(`["subscribe" "news" :1]`, "SUBSCRIBE", "news")
+	if err := sendCmd(sub.conn, sub.w, "SUBSCRIBE", "news", "sport"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sub.mustPush(`["subscribe" "news" :1]`)
+	sub.mustPush(`["subscribe" "sport" :2]`)
+	psub.mustReply(`["psubscribe" "n*" :1]`, "PSUBSCRIBE", "n*")
+
+	pub.mustInt(2, "PUBLISH", "news", "hello")
+	sub.mustPush(`["message" "news" "hello"]`)
+	psub.mustPush(`["pmessage" "n*" "news" "hello"]`)
+	pub.mustInt(1, "PUBLISH", "sport", "goal")
+	sub.mustPush(`["message" "sport" "goal"]`)
+	pub.mustInt(0, "PUBLISH", "weather", "rain")
+
+	if err := sendCmd(sub.conn, sub.w, "UNSUBSCRIBE"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	sub.mustPush(`["unsubscribe" "news" :1]`)
+	sub.mustPush(`["unsubscribe" "sport" :0]`)
+	sub.mustReply(`["unsubscribe" (nil) :0]`, "UNSUBSCRIBE")
+	pub.mustInt(1, "PUBLISH", "news", "again")
+	psub.mustPush(`["pmessage" "n*" "news" "again"]`)
+
+	// Back in normal mode once nothing is subscribed.
+	sub.mustOK("SET", "k", "v")
+	sub.mustReply("+PONG", "PING")
+}
+
+func TestPubSub_SubscribedModeRestrictsCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustReply(`["psubscribe" "a*" :1]`, "PSUBSCRIBE", "a*")
+	c.mustErr("ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING are allowed in this context", "GET", "k")
+	c.mustReply(`["pong" ""]`, "PING")
+	c.mustReply(`["pong" "hi"]`, "PING", "hi")
+	c.mustReply(`["subscribe" "b" :2]`, "SUBSCRIBE", "b")
+	c.mustReply(`["punsubscribe" "a*" :1]`, "PUNSUBSCRIBE", "a*")
+	c.mustReply(`["unsubscribe" "b" :0]`, "UNSUBSCRIBE", "b")
+	c.mustNil("GET", "k")
+}
+
+func TestPubSub_Introspection(t *testing.T) {
+	_, addr := startTestServer(t)
+	a := dialTest(t, addr)
+	b := dialTest(t, addr)
+	c := dialTest(t, addr)
+
+	a.mustReply(`["subscribe" "news.tech" :1]`, "SUBSCRIBE", "news.tech")
+	b.mustReply(`["subscribe" "news.tech" :1]`, "SUBSCRIBE", "news.tech")
+	b.mustReply(`["subscribe" "sport" :2]`, "SUBSCRIBE", "sport")
+	b.mustReply(`["psubscribe" "news.*" :3]`, "PSUBSCRIBE", "news.*")
+	b.mustReply(`["ssubscribe" "orders" :1]`, "SSUBSCRIBE", "orders")
+
+	c.mustStrings([]string{"news.tech", "sport"}, "PUBSUB", "CHANNELS")
+	c.mustStrings([]string{"news.tech"}, "PUBSUB", "CHANNELS", "news.*")
+	c.mustReply(`["news.tech" :2 "sport" :1 "none" :0]`, "PUBSUB", "NUMSUB", "news.tech", "sport", "none")
+	c.mustReply(`[]`, "PUBSUB", "NUMSUB")
+	c.mustInt(1, "PUBSUB", "NUMPAT")
+	c.mustStrings([]string{"orders"}, "PUBSUB", "SHARDCHANNELS")
+	c.mustReply(`["orders" :1]`, "PUBSUB", "SHARDNUMSUB", "orders")
+	c.mustErr("ERR unknown subcommand", "PUBSUB", "NOSUCH")
+
+	info := string(c.do("INFO").Bulk)
+	for _, want := range []string{"pubsub_channels:2", "pubsub_patterns:1", "pubsubshard_channels:1"} {
+		if !strings.Contains(info, want) {
+			t.Fatalf("INFO lacks %q:\n%s", want, info)
+		}
+	}
+
+	// Subscriptions go away with the connection.
+	_ = b.conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v := c.do("PUBSUB", "NUMPAT"); v.Int == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscriptions of a closed connection were not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.mustStrings([]string{"news.tech"}, "PUBSUB", "CHANNELS")
+	c.mustStrings([]string{}, "PUBSUB", "SHARDCHANNELS")
+}
+
+func TestPubSub_ShardChannels(t *testing.T) {
+	_, addr := startTestServer(t)
+	sub := dialTest(t, addr)
+	pub := dialTest(t, addr)
+
+	sub.mustReply(`["ssubscribe" "orders" :1]`, "SSUBSCRIBE", "orders")
+	sub.mustReply(`["subscribe" "orders" :1]`, "SUBSCRIBE", "orders")
+	sub.mustErr("ERR Can't execute 'set'", "SET", "k", "v")
+
+	pub.mustInt(1, "SPUBLISH", "orders", "o1")
+	sub.mustPush(`["smessage" "orders" "o1"]`)
+	pub.mustInt(1, "PUBLISH", "orders", "o2")
+	sub.mustPush(`["message" "orders" "o2"]`)
+
+	sub.mustReply(`["sunsubscribe" "orders" :0]`, "SUNSUBSCRIBE")
+	pub.mustInt(0, "SPUBLISH", "orders", "o3")
+	sub.mustReply(`["unsubscribe" "orders" :0]`, "UNSUBSCRIBE", "orders")
+}
+
+func TestPubSub_SlowSubscriberIsDisconnected(t *testing.T) {
+	_, addr := startTestServer(t)
+	slow := dialTest(t, addr)
+	fast := dialTest(t, addr)
+	pub := dialTest(t, addr)
+
+	pub.mustOK("CONFIG", "SET", "client-output-buffer-limit", "pubsub 256kb 0 0")
+	pub.mustStrings([]string{"client-output-buffer-limit", "pubsub 262144 0 0"},
+		"CONFIG", "GET", "client-output-buffer-limit")
+	pub.mustErr("ERR CONFIG SET failed", "CONFIG", "SET", "client-output-buffer-limit", "normal 0 0 0")
+
+	slow.mustReply(`["subscribe" "ch" :1]`, "SUBSCRIBE", "ch")
+	fast.mustReply(`["subscribe" "ch" :1]`, "SUBSCRIBE", "ch")
+
+	// slow never reads: once the socket buffers are full its messages pile
+	// up in the server until it crosses the limit and is dropped. PUBLISH
+	// keeps answering meanwhile, and fast keeps receiving.
+	msg := strings.Repeat("x", 64<<10)
+	start := time.Now()
+	dropped := false
+	for i := 0; i < 2000 && !dropped; i++ {
+		n := pub.do("PUBLISH", "ch", msg).Int
+		if n == 1 {
+			dropped = true
+		}
+		if v := fast.read(); string(v.Array[2].Bulk) != msg {
+			t.Fatalf("fast subscriber got %.40s", fmtValue(v))
+		}
+	}
+	if !dropped {
+		t.Fatalf("slow subscriber was never disconnected")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("publishing stalled for %s", d)
+	}
+	if info := string(pub.do("INFO").Bulk); !strings.Contains(info, "client_output_buffer_limit_disconnections:1\r\n") {
+		t.Fatalf("disconnect not counted:\n%s", info)
+	}
+}
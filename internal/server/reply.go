@@ -0,0 +1,133 @@
+// internal/server/reply.go
+package server
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/pranavbrkr/redigo/internal/glob"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+const (
+	msgNotInteger = "ERR value is not an integer or out of range"
+	msgNotFloat   = "ERR value is not a valid float"
+	msgSyntax     = "ERR syntax error"
+	msgWrongType  = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	msgOOM        = "OOM command not allowed when used memory > 'maxmemory'."
+)
+
+// writeStoreError maps errors returned by store operations to Redis replies.
+func writeStoreError(w *bufio.Writer, err error) {
+	switch {
+	case errors.Is(err, store.ErrWrongType):
+		_ = resp.WriteError(w, msgWrongType)
+	case errors.Is(err, store.ErrNoGroup), errors.Is(err, store.ErrBusyGroup):
+		_ = resp.WriteError(w, err.Error()) // carry their own error code
+	default:
+		_ = resp.WriteError(w, "ERR "+err.Error())
+	}
+}
+
+// writeBulkArray writes an array of bulk strings; nil elements are null bulks.
+func writeBulkArray(w *bufio.Writer, items [][]byte) {
+	_ = resp.WriteArrayHeader(w, len(items))
+	for _, it := range items {
+		_ = resp.WriteBulkString(w, it)
+	}
+}
+
+func writeStringArray(w *bufio.Writer, items []string) {
+	_ = resp.WriteArrayHeader(w, len(items))
+	for _, it := range items {
+		_ = resp.WriteBulkString(w, []byte(it))
+	}
+}
+
+// scanOptions holds the MATCH / COUNT / TYPE / NOVALUES modifiers shared by
+// the SCAN family.
+type scanOptions struct {
+	match    string // empty means no filter
+	count    int
+	typ      string
+	noValues bool
+}
+
+func (o scanOptions) matches(name string) bool {
+	return o.match == "" || glob.Match(o.match, name)
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count] ..." and writes
+// an error reply when the arguments are invalid. allowed lists the optional
+// modifiers (besides MATCH and COUNT) that the calling command accepts.
+func parseScanArgs(w *bufio.Writer, args []string, allowed ...string) (uint64, scanOptions, bool) {
+	opts := scanOptions{count: 10}
+
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		_ = resp.WriteError(w, "ERR invalid cursor")
+		return 0, opts, false
+	}
+
+	allow := func(name string) bool {
+		for _, a := range allowed {
+			if a == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch {
+		case opt == "MATCH" && i+1 < len(args):
+			opts.match = args[i+1]
+			if opts.match == "*" {
+				opts.match = ""
+			}
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				_ = resp.WriteError(w, msgNotInteger)
+				return 0, opts, false
+			}
+			if n < 1 {
+				_ = resp.WriteError(w, msgSyntax)
+				return 0, opts, false
+			}
+			opts.count = n
+			i++
+		case opt == "TYPE" && allow("TYPE") && i+1 < len(args):
+			opts.typ = strings.ToLower(args[i+1])
+			i++
+		case opt == "NOVALUES" && allow("NOVALUES"):
+			opts.noValues = true
+		default:
+			_ = resp.WriteError(w, msgSyntax)
+			return 0, opts, false
+		}
+	}
+	return cursor, opts, true
+}
+
+// writeScanReply writes the two-element [cursor, items] SCAN-family reply.
+func writeScanReply(w *bufio.Writer, next uint64, items []string) {
+	_ = resp.WriteArrayHeader(w, 2)
+	_ = resp.WriteBulkString(w, []byte(strconv.FormatUint(next, 10)))
+	writeStringArray(w, items)
+}
+
+// parseFloatArg parses a float argument the way Redis does: "inf", "+inf"
+// and "-inf" are accepted, NaN is not.
+func parseFloatArg(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != f {
+		return 0, false
+	}
+	return f, true
+}
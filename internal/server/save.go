@@ -0,0 +1,263 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/rdb"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// SaveParam is one "save <seconds> <changes>" point: snapshot once at least
+// Changes writes happened and Seconds have passed since the last save.
+type SaveParam struct {
+	Seconds int64
+	Changes int64
+}
+
+// ParseSaveParams parses a save schedule such as "3600 1 300 100". An empty
+// string means no automatic saves.
+func ParseSaveParams(s string) ([]SaveParam, error) {
+	f := strings.Fields(s)
+	if len(f)%2 != 0 {
+		return nil, errors.New("save schedule must be pairs of <seconds> <changes>")
+	}
+	params := make([]SaveParam, 0, len(f)/2)
+	for i := 0; i < len(f); i += 2 {
+		secs, err1 := strconv.ParseInt(f[i], 10, 64)
+		changes, err2 := strconv.ParseInt(f[i+1], 10, 64)
+		if err1 != nil || err2 != nil || secs < 1 || changes < 0 {
+			return nil, errors.New("invalid save point '" + f[i] + " " + f[i+1] + "'")
+		}
+		params = append(params, SaveParam{Seconds: secs, Changes: changes})
+	}
+	return params, nil
+}
+
+func formatSaveParams(params []SaveParam) string {
+	parts := make([]string, 0, 2*len(params))
+	for _, p := range params {
+		parts = append(parts, strconv.FormatInt(p.Seconds, 10), strconv.FormatInt(p.Changes, 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// persistenceCheckInterval is how often the save schedule and the
+// automatic AOF rewrite thresholds are checked.
+const persistenceCheckInterval = 100 * time.Millisecond
+
+// saveRetryDelay is how long a failed background save holds off the next
+// scheduled one (Redis' CONFIG_BGSAVE_RETRY_DELAY).
+const saveRetryDelay = 5 * time.Second
+
+// SetSnapshot sets the file SAVE and BGSAVE write (empty disables them) and
+// the schedule of automatic background saves. It is safe to call while
+// serving.
+func (s *Server) SetSnapshot(path string, params []SaveParam) {
+	s.saveMu.Lock()
+	s.rdbPath = path
+	s.saveParams = append([]SaveParam(nil), params...)
+	s.saveMu.Unlock()
+}
+
+func (s *Server) snapshotPath() string {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return s.rdbPath
+}
+
+// tryStartSaveLocked claims the right to write the snapshot file; only one
+// SAVE or BGSAVE runs at a time. Caller must hold saveMu.
+func (s *Server) tryStartSaveLocked() bool {
+	if s.shuttingDown.Load() || s.saveRunning {
+		return false
+	}
+	s.saveRunning = true
+	return true
+}
+
+// snapshotLocked copies every database, returning the number of changes
+// the copy holds. Caller must hold execMu for writing, so that no command,
+// such as a MOVE, SWAPDB or EXEC, is half applied in it.
+func (s *Server) snapshotLocked() (int64, []store.SnapshotEntry) {
+	return s.dirty.Load(), s.dbs.Snapshot()
+}
+
+// snapshot is snapshotLocked for callers not holding execMu.
+func (s *Server) snapshot() (int64, []store.SnapshotEntry) {
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+	return s.snapshotLocked()
+}
+
+// runSave writes snap, which holds dirty changes, to path and releases the
+// claim taken by tryStartSaveLocked. Writes landing while the file is
+// written still count as changes afterwards.
+func (s *Server) runSave(path string, dirty int64, snap []store.SnapshotEntry) (keys int, err error) {
+	err = rdb.Save(path, snap)
+
+	s.saveMu.Lock()
+	s.saveRunning = false
+	if err == nil {
+		s.lastSave = time.Now()
+		s.dirty.Add(-dirty)
+	}
+	s.saveMu.Unlock()
+	return len(snap), err
+}
+
+// startBgSaveLocked runs a background save to path. Caller must hold saveMu
+// and have claimed the save with tryStartSaveLocked.
+func (s *Server) startBgSaveLocked(path string) {
+	s.lastBgsaveTry = time.Now()
+	s.saveWg.Add(1)
+	go func() {
+		defer s.saveWg.Done()
+		start := time.Now()
+		dirty, snap := s.snapshot()
+		keys, err := s.runSave(path, dirty, snap)
+
+		s.saveMu.Lock()
+		s.lastBgsaveOK = err == nil
+		s.saveMu.Unlock()
+
+		if err != nil {
+			log.Printf("[BGSAVE] failed: %v", err)
+			return
+		}
+		log.Printf("[BGSAVE] completed (keys=%d) in %s", keys, time.Since(start))
+	}()
+}
+
+// saveIfDue starts a background save when one of the save points is
+// reached. After a failed background save it waits saveRetryDelay first.
+func (s *Server) saveIfDue(now time.Time) {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	if s.rdbPath == "" || s.saveRunning {
+		return
+	}
+	if !s.lastBgsaveOK && now.Sub(s.lastBgsaveTry) < saveRetryDelay {
+		return
+	}
+	dirty := s.dirty.Load()
+	elapsed := now.Sub(s.lastSave)
+	for _, p := range s.saveParams {
+		if dirty >= p.Changes && elapsed >= time.Duration(p.Seconds)*time.Second {
+			if !s.tryStartSaveLocked() {
+				return
+			}
+			log.Printf("[BGSAVE] %d changes in %d seconds. Saving...", p.Changes, p.Seconds)
+			s.startBgSaveLocked(s.rdbPath)
+			return
+		}
+	}
+}
+
+// startPersistenceCron checks the save schedule and whether the AOF is due
+// for a rewrite every interval. The returned stop function waits for the
+// loop to exit, so no save or rewrite starts after it returns.
+func startPersistenceCron(s *Server, interval time.Duration) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				s.saveIfDue(now)
+				s.rewriteIfDue(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// saveOnShutdown writes a final snapshot when a save schedule is set and
+// something changed since the last save, as Redis does on SHUTDOWN. Caller
+// must have waited for background saves to finish.
+func (s *Server) saveOnShutdown() {
+	s.saveMu.Lock()
+	path, scheduled := s.rdbPath, len(s.saveParams) > 0
+	s.saveMu.Unlock()
+
+	if path == "" || !scheduled || s.dirty.Load() == 0 {
+		return
+	}
+	dirty, snap := s.snapshot()
+	if _, err := s.runSave(path, dirty, snap); err != nil {
+		log.Printf("[SAVE] failed on shutdown: %v", err)
+	}
+}
+
+func cmdSave(s *Server, c *client, args []string) error {
+	s.saveMu.Lock()
+	path := s.rdbPath
+	ok := path != "" && s.tryStartSaveLocked()
+	s.saveMu.Unlock()
+
+	if path == "" {
+		_ = resp.WriteError(c.w, "ERR snapshots are not configured")
+		return nil
+	}
+	if !ok {
+		_ = resp.WriteError(c.w, "ERR Background save already in progress")
+		return nil
+	}
+	// dispatch runs SAVE holding execMu for writing.
+	dirty, snap := s.snapshotLocked()
+	if _, err := s.runSave(path, dirty, snap); err != nil {
+		log.Printf("[SAVE] failed: %v", err)
+		_ = resp.WriteError(c.w, "ERR snapshot save failed")
+		return nil
+	}
+	_ = resp.WriteSimpleString(c.w, "OK")
+	return nil
+}
+
+func cmdBgSave(s *Server, c *client, args []string) error {
+	// SCHEDULE is accepted for compatibility: a background save never has to
+	// wait for an AOF rewrite here.
+	if len(args) > 1 || (len(args) == 1 && !strings.EqualFold(args[0], "SCHEDULE")) {
+		_ = resp.WriteError(c.w, msgSyntax)
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	if s.rdbPath == "" {
+		_ = resp.WriteError(c.w, "ERR snapshots are not configured")
+		return nil
+	}
+	if !s.tryStartSaveLocked() {
+		_ = resp.WriteError(c.w, "ERR Background save already in progress")
+		return nil
+	}
+	s.startBgSaveLocked(s.rdbPath)
+	_ = resp.WriteSimpleString(c.w, "Background saving started")
+	return nil
+}
+
+func cmdLastSave(s *Server, c *client, args []string) error {
+	s.saveMu.Lock()
+	last := s.lastSave
+	s.saveMu.Unlock()
+
+	_ = resp.WriteInteger(c.w, last.Unix())
+	return nil
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/aof"
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/rdb"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+// loadSnapshot reads the snapshot at path into fresh databases.
+func loadSnapshot(t *testing.T, path string) *store.Databases {
+	t.Helper()
+	dbs := store.NewDatabases(store.DefaultDatabases)
+	err := rdb.Load(path, func(e store.SnapshotEntry) error {
+		_, err := dbs.Restore(e)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("load %s: %v", path, err)
+	}
+	return dbs
+}
+
+// sortedSnapshot returns the snapshot of dbs ordered by database and key.
+func sortedSnapshot(dbs *store.Databases) []store.SnapshotEntry {
+	snap := dbs.Snapshot()
+	sort.Slice(snap, func(i, j int) bool {
+		if snap[i].DB != snap[j].DB {
+			return snap[i].DB < snap[j].DB
+		}
+		return snap[i].Key < snap[j].Key
+	})
+	return snap
+}
+
+// waitInfo polls INFO until it contains field.
+func waitInfo(t *testing.T, c *testConn, field string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(string(c.do("INFO").Bulk), field+"\r\n") {
+		if time.Now().After(deadline) {
+			t.Fatalf("INFO never reported %s", field)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSaveBgSaveAndLastSave(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	c.mustErr("not configured", "SAVE")
+	c.mustErr("not configured", "BGSAVE")
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	s.SetSnapshot(path, nil)
+	before := time.Now().Unix()
+
+	c.mustOK("SET", "a", "1")
+	c.mustInt(2, "RPUSH", "l", "x", "y")
+	c.mustOK("SELECT", "2")
+	c.mustInt(1, "HSET", "h", "f", "v")
+	waitInfo(t, c, "rdb_changes_since_last_save:3")
+
+	c.mustOK("SAVE")
+	if last := c.do("LASTSAVE").Int; last < before {
+		t.Fatalf("LASTSAVE %d before the save at %d", last, before)
+	}
+	waitInfo(t, c, "rdb_changes_since_last_save:0")
+	if got, want := sortedSnapshot(loadSnapshot(t, path)), sortedSnapshot(s.dbs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SAVE wrote %+v, want %+v", got, want)
+	}
+
+	c.mustOK("SET", "b", "2")
+	c.mustReply("+Background saving started", "BGSAVE")
+	waitInfo(t, c, "rdb_bgsave_in_progress:0")
+	waitInfo(t, c, "rdb_last_bgsave_status:ok")
+	if v, ok := loadSnapshot(t, path).DB(2).Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("BGSAVE snapshot has b = %q, %v", v, ok)
+	}
+	c.mustErr("syntax error", "BGSAVE", "NOW")
+}
+
+func TestBgSave_FailureIsReported(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	// The snapshot's directory is a file, so the temp file cannot be made.
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSnapshot(filepath.Join(blocker, "dump.rdb"), nil)
+
+	c.mustOK("SET", "a", "1")
+	c.mustErr("save failed", "SAVE")
+	c.mustReply("+Background saving started", "BGSAVE")
+	waitInfo(t, c, "rdb_last_bgsave_status:err")
+	waitInfo(t, c, "rdb_changes_since_last_save:1")
+}
+
+func TestSaveSchedule(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	s.SetSnapshot(path, nil)
+
+	c.mustOK("CONFIG", "SET", "save", "1 2 3600 1")
+	c.mustStrings([]string{"save", "1 2 3600 1"}, "CONFIG", "GET", "save")
+	c.mustErr("invalid save point", "CONFIG", "SET", "save", "0 1")
+	c.mustErr("pairs", "CONFIG", "SET", "save", "60")
+
+	// One change is not enough for "1 2"; the second triggers a save once
+	// a second has passed since startup.
+	c.mustOK("SET", "a", "1")
+	time.Sleep(1200 * time.Millisecond)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("saved after one change: %v", err)
+	}
+	c.mustOK("SET", "b", "2")
+	waitInfo(t, c, "rdb_changes_since_last_save:0")
+	if got := loadSnapshot(t, path).DB(0).Len(); got != 2 {
+		t.Fatalf("scheduled save has %d keys, want 2", got)
+	}
+}
+
+func TestClose_SavesPendingChangesWhenScheduled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	s, addr, err := Start("127.0.0.1:0", store.New(), nil, aof.FsyncNever)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	s.SetSnapshot(path, []SaveParam{{Seconds: 3600, Changes: 1}})
+
+	c := dialTest(t, addr)
+	c.mustOK("SET", "k", "v")
+	_ = s.Close()
+
+	if v, ok := loadSnapshot(t, path).DB(0).Get("k"); !ok || string(v) != "v" {
+		t.Fatalf("snapshot after shutdown has k = %q, %v", v, ok)
+	}
+}
+
+func TestSave_SnapshotIsPointInTime(t *testing.T) {
+	s, addr := startTestServer(t)
+	c := dialTest(t, addr)
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	s.SetSnapshot(path, nil)
+
+	// A background save started halfway through a transaction (which holds
+	// execMu for writing) snapshots once the transaction is over.
+	s.execMu.Lock()
+	s.dbs.DB(0).Set("a", []byte("1"))
+	s.saveMu.Lock()
+	s.tryStartSaveLocked()
+	s.startBgSaveLocked(path)
+	s.saveMu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	s.dbs.DB(1).Set("b", []byte("2"))
+	s.execMu.Unlock()
+	s.saveWg.Wait()
+	if got := len(loadSnapshot(t, path).Snapshot()); got != 2 {
+		t.Fatalf("BGSAVE snapshot has %d keys, want 2", got)
+	}
+
+	// SAVE waits for commands in flight, such as a MOVE, and holds off the
+	// ones that come after it.
+	s.execMu.RLock()
+	s.dbs.Move("a", 0, 1)
+	saved := make(chan resp.Value, 1)
+	go func() {
+		_ = sendCmd(c.conn, c.w, "SAVE")
+		v, _ := resp.Decode(c.r)
+		saved <- v
+	}()
+	time.Sleep(50 * time.Millisecond)
+	s.dbs.DB(1).Set("c", []byte("3"))
+	s.execMu.RUnlock()
+	if v := <-saved; v.Str != "OK" {
+		t.Fatalf("SAVE: %s", fmtValue(v))
+	}
+	if got := len(loadSnapshot(t, path).DB(1).Snapshot()); got != 3 {
+		t.Fatalf("SAVE snapshot has %d keys in database 1, want 3", got)
+	}
+}
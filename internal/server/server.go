@@ -4,9 +4,11 @@ package server
 import (
 	"bufio"
 	"errors"
+	"hash/maphash"
 	"io"
 	"log"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,18 +22,63 @@ import (
 
 type Server struct {
 	ln          *net.TCPListener
-	store       *store.Store
+	dbs         *store.Databases
 	stopReaper  func()
 	aof         aof.Writer
 	fsyncPolicy aof.FsyncPolicy
 	stopFsync   func()
 	aofMu       sync.Mutex
+	aofDB       int // database the AOF is positioned in; -1 forces a SELECT
+	aofTx       txLogState
+
+	// held by write commands for the keys they write, see writeStripes
+	keyLocks [keyLockCount]sync.Mutex
+
+	// held for reading by every command and for writing by EXEC and SAVE
+	execMu   sync.RWMutex
+	watchers watchers
 
 	// BGREWRITEAOF state
 	rewriteMu      sync.Mutex
-	rewriteRunning bool
-	rewriteTail    []aof.Entry
+	rewriteRunning bool // true from start until the install has finished
 	rewriteWg      sync.WaitGroup
+	rewrites       int64 // started since startup
+	lastRewriteTry time.Time
+	lastRewriteOK  bool
+
+	// automatic rewrites, see autorewrite.go
+	autoRewritePerc    atomic.Int64 // growth in percent; 0 disables them
+	autoRewriteMinSize atomic.Int64 // bytes
+
+	// testHookBeforeInstall, if set, runs before a rewrite is installed.
+	// Guarded by rewriteMu.
+	testHookBeforeInstall func()
+
+	// SAVE / BGSAVE state, see save.go
+	saveMu        sync.Mutex
+	rdbPath       string // snapshot file; empty disables SAVE and BGSAVE
+	saveParams    []SaveParam
+	saveRunning   bool
+	lastSave      time.Time // of the last successful save, or startup
+	lastBgsaveTry time.Time
+	lastBgsaveOK  bool
+	dirty         atomic.Int64 // changes since the last save
+	saveWg        sync.WaitGroup
+	stopCron      func()
+
+	// clients blocked in BLPOP & co.
+	waiters keyWaiters
+
+	// pub/sub subscriptions, see pubsub.go
+	pubsub                 pubsub
+	pubsubLimit            outputLimit
+	outputLimitDisconnects atomic.Int64
+	notifyFlags            atomic.Uint32 // notify-keyspace-events, see notify.go
+
+	// maxmemory settings, changed at runtime by CONFIG SET
+	maxmemory       atomic.Int64  // bytes; 0 means no limit
+	maxmemoryPolicy atomic.Uint32 // store.EvictionPolicy
+	evictedKeys     atomic.Int64
 
 	// shutdown flag (single source of truth)
 	shuttingDown atomic.Bool
@@ -42,7 +89,23 @@ type Server struct {
 	connWg sync.WaitGroup
 }
 
+// Start serves st as database 0 of store.DefaultDatabases databases.
 func Start(addr string, st *store.Store, aw aof.Writer, fsyncPolicy aof.FsyncPolicy) (*Server, string, error) {
+	return StartDatabases(addr, withDB0(st, store.DefaultDatabases), aw, fsyncPolicy)
+}
+
+// withDB0 returns n databases whose database 0 is st.
+func withDB0(st *store.Store, n int) *store.Databases {
+	stores := make([]*store.Store, n)
+	stores[0] = st
+	for i := 1; i < n; i++ {
+		stores[i] = store.New()
+	}
+	return store.NewDatabasesFrom(stores...)
+}
+
+// StartDatabases serves dbs; every connection starts in database 0.
+func StartDatabases(addr string, dbs *store.Databases, aw aof.Writer, fsyncPolicy aof.FsyncPolicy) (*Server, string, error) {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
 	if err != nil {
 		return nil, "", err
@@ -57,18 +120,33 @@ func Start(addr string, st *store.Store, aw aof.Writer, fsyncPolicy aof.FsyncPol
 	}
 
 	s := &Server{
-		ln:          ln,
-		store:       st,
-		aof:         aw,
-		fsyncPolicy: fsyncPolicy,
-		conns:       make(map[net.Conn]struct{}),
+		ln:            ln,
+		dbs:           dbs,
+		aof:           aw,
+		aofDB:         -1,
+		fsyncPolicy:   fsyncPolicy,
+		conns:         make(map[net.Conn]struct{}),
+		lastSave:      time.Now(),
+		lastBgsaveOK:  true,
+		lastRewriteOK: true,
 	}
+	s.autoRewritePerc.Store(defaultAutoRewritePerc)
+	s.autoRewriteMinSize.Store(defaultAutoRewriteMinSize)
+	s.pubsubLimit.hard.Store(defaultPubsubHardLimit)
+	s.pubsubLimit.soft.Store(defaultPubsubSoftLimit)
+	s.pubsubLimit.softSeconds.Store(defaultPubsubSoftSeconds)
+
+	dbs.OnExpire(func(db int, key string) {
+		s.notifyKeyspaceEvent(notifyExpired, "expired", db, key)
+	})
 
-	s.stopReaper = st.StartReaper(500 * time.Millisecond)
+	// 100ms is Redis' default hz of 10.
+	s.stopReaper = dbs.StartReaper(100 * time.Millisecond)
 
 	if s.fsyncPolicy == aof.FsyncEverySecond {
 		s.stopFsync = startFsyncLoop(s, 1*time.Second)
 	}
+	s.stopCron = startPersistenceCron(s, persistenceCheckInterval)
 
 	go s.acceptLoop()
 
@@ -96,6 +174,10 @@ func (s *Server) Close() error {
 		s.stopFsync()
 		s.stopFsync = nil
 	}
+	if s.stopCron != nil {
+		s.stopCron()
+		s.stopCron = nil
+	}
 
 	// 4) force-close all active client connections
 	s.connMu.Lock()
@@ -110,7 +192,11 @@ func (s *Server) Close() error {
 	// 6) wait for any BGREWRITEAOF installs to finish
 	s.rewriteWg.Wait()
 
-	// 7) safely close AOF (no installRewrite can race now)
+	// 7) wait for any BGSAVE, then save the changes made since the last one
+	s.saveWg.Wait()
+	s.saveOnShutdown()
+
+	// 8) safely close AOF (no installRewrite can race now)
 	s.aofMu.Lock()
 	defer s.aofMu.Unlock()
 
@@ -148,11 +234,49 @@ func (s *Server) acceptLoop() {
 	}
 }
 
+// client holds per-connection state.
+type client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	db      *store.Store // the selected database
+	dbIndex int
+	cmd     *command // the command executing, whose keyspace events are published
+	args    []string // its arguments
+	stripes []int    // keyLocks held by the write command executing
+
+	// transactions, see multi.go
+	tx      *transaction // non-nil between MULTI and EXEC / DISCARD
+	inExec  bool         // running the commands of EXEC: never block
+	watched []watchedKey
+	dirty   atomic.Bool // a watched key changed; set by other clients
+
+	// pub/sub, see pubsub.go. wmu guards w, which published messages are
+	// written to from the pusher goroutine as well.
+	subs [numSubKinds]map[string]struct{}
+	out  outbox
+	wmu  sync.Mutex
+	done chan struct{} // closed when the connection goroutine exits
+}
+
+// selectDB switches c to database i, which must be in range.
+func (s *Server) selectDB(c *client, i int) {
+	c.db = s.dbs.DB(i)
+	c.dbIndex = i
+}
+
 func (s *Server) handleConn(conn net.Conn) {
-	st := s.store
+	c := &client{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), done: make(chan struct{})}
+	c.out.ready = make(chan struct{}, 1)
+	s.selectDB(c, 0)
 
 	defer func() {
 		_ = conn.Close()
+		s.unwatchAll(c)
+		for kind := range numSubKinds {
+			s.unsubscribe(c, kind, nil, false)
+		}
+		close(c.done)
 
 		s.connMu.Lock()
 		delete(s.conns, conn)
@@ -161,277 +285,35 @@ func (s *Server) handleConn(conn net.Conn) {
 		s.connWg.Done()
 	}()
 
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
-
 	for {
-		v, err := resp.Decode(reader)
+		v, err := resp.Decode(c.r)
 		if err != nil {
 			if errors.Is(err, io.EOF) || isConnReset(err) {
 				return
 			}
-			_ = resp.WriteError(writer, "ERR protocol error")
-			_ = writer.Flush()
+			c.wmu.Lock()
+			_ = resp.WriteError(c.w, "ERR protocol error")
+			_ = c.w.Flush()
+			c.wmu.Unlock()
 			return
 		}
 
 		cmd, args, ok := decodeCommandParts(v)
 		if !ok {
-			_ = resp.WriteError(writer, "ERR expected array of bulk strings")
-			_ = writer.Flush()
+			c.wmu.Lock()
+			_ = resp.WriteError(c.w, "ERR expected array of bulk strings")
+			_ = c.w.Flush()
+			c.wmu.Unlock()
 			continue
 		}
 
-		switch cmd {
-		case "PING":
-			if len(args) == 0 {
-				_ = resp.WriteSimpleString(writer, "PONG")
-				break
-			}
-			if len(args) == 1 {
-				_ = resp.WriteBulkString(writer, []byte(args[0]))
-				break
-			}
-			writeWrongArgs(writer, "PING")
-
-		case "ECHO":
-			if len(args) != 1 {
-				writeWrongArgs(writer, "ECHO")
-				break
-			}
-			_ = resp.WriteBulkString(writer, []byte(args[0]))
-
-		case "SET":
-			if len(args) != 2 {
-				writeWrongArgs(writer, "SET")
-				break
-			}
-			key := args[0]
-			val := args[1]
-
-			// AOF first, then apply
-			if err := s.appendAOF("SET", []string{key, val}); err != nil {
-				writeAOFError(writer, "ERR aof write failed")
-				return
-			}
-
-			st.Set(key, []byte(val))
-			_ = resp.WriteSimpleString(writer, "OK")
-
-		case "GET":
-			if len(args) != 1 {
-				writeWrongArgs(writer, "GET")
-				break
-			}
-			key := args[0]
-			val, ok := st.Get(key)
-			if !ok {
-				_ = resp.WriteBulkString(writer, nil)
-				break
-			}
-			_ = resp.WriteBulkString(writer, val)
-
-		case "DEL":
-			if len(args) < 1 {
-				writeWrongArgs(writer, "DEL")
-				break
-			}
-
-			// Decide what will actually be deleted (EXISTS purges expired keys too)
-			toDelete := make([]string, 0, len(args))
-			for _, key := range args {
-				if st.Exists(key) {
-					toDelete = append(toDelete, key)
-				}
-			}
-
-			if len(toDelete) == 0 {
-				_ = resp.WriteInteger(writer, 0)
-				break
-			}
-
-			// AOF first (durability), then apply
-			if err := s.appendAOF("DEL", toDelete); err != nil {
-				_ = resp.WriteError(writer, "ERR aof write failed")
-				_ = writer.Flush()
-				return
-			}
-
-			var removed int64
-			for _, key := range toDelete {
-				if st.Del(key) {
-					removed++
-				}
-			}
-
-			_ = resp.WriteInteger(writer, removed)
-
-		case "EXISTS":
-			if len(args) < 1 {
-				writeWrongArgs(writer, "EXISTS")
-				break
-			}
-
-			var count int64 = 0
-			for _, key := range args {
-				if st.Exists(key) {
-					count++
-				}
-			}
-			_ = resp.WriteInteger(writer, count)
-
-		case "EXPIRE":
-			if len(args) != 2 {
-				writeWrongArgs(writer, "EXPIRE")
-				break
-			}
-
-			seconds, err := strconv.ParseInt(args[1], 10, 64)
-			if err != nil {
-				_ = resp.WriteError(writer, "ERR value is not an integer or out of range")
-				break
-			}
-
-			unix := time.Now().Add(time.Duration(seconds) * time.Second).Unix()
-
-			// Check existence without mutating (Exists purges expired)
-			if !st.Exists(args[0]) {
-				_ = resp.WriteInteger(writer, 0)
-				break
-			}
-
-			// Persist absolute expiry first
-			if err := s.appendAOF("EXPIREAT", []string{args[0], strconv.FormatInt(unix, 10)}); err != nil {
-				_ = resp.WriteError(writer, "ERR aof write failed")
-				_ = writer.Flush()
-				return
-			}
-
-			ok := st.ExpireAt(args[0], unix)
-			if !ok {
-				_ = resp.WriteInteger(writer, 0)
-				break
-			}
-			_ = resp.WriteInteger(writer, 1)
-
-		case "EXPIREAT":
-			if len(args) != 2 {
-				writeWrongArgs(writer, "EXPIREAT")
-				break
-			}
-
-			ts, err := strconv.ParseInt(args[1], 10, 64)
-			if err != nil {
-				_ = resp.WriteError(writer, "ERR value is not an integer or out of range")
-				break
-			}
-
-			if !st.Exists(args[0]) {
-				_ = resp.WriteInteger(writer, 0)
-				break
-			}
-
-			if err := s.appendAOF("EXPIREAT", []string{args[0], args[1]}); err != nil {
-				_ = resp.WriteError(writer, "ERR aof write failed")
-				_ = writer.Flush()
-				return
-			}
-
-			ok := st.ExpireAt(args[0], ts)
-			if !ok {
-				_ = resp.WriteInteger(writer, 0)
-				break
-			}
-			_ = resp.WriteInteger(writer, 1)
-
-		case "TTL":
-			if len(args) != 1 {
-				writeWrongArgs(writer, "TTL")
-				break
-			}
-			ttl := st.TTL(args[0])
-			_ = resp.WriteInteger(writer, ttl)
-
-		case "COMMAND":
-			if len(args) == 0 {
-				_ = resp.WriteArrayHeader(writer, 11)
-
-				writeCommandDoc(writer, "PING", -1, []string{"fast"})
-				writeCommandDoc(writer, "ECHO", 2, []string{"fast"})
-				writeCommandDoc(writer, "SET", 3, []string{"write"})
-				writeCommandDoc(writer, "GET", 2, []string{"readonly", "fast"})
-				writeCommandDoc(writer, "DEL", -2, []string{"write"})
-				writeCommandDoc(writer, "EXISTS", -2, []string{"readonly", "fast"})
-				writeCommandDoc(writer, "EXPIRE", 3, []string{"write", "fast"})
-				writeCommandDoc(writer, "TTL", 2, []string{"readonly", "fast"})
-				writeCommandDoc(writer, "INFO", -1, []string{"readonly"})
-				writeCommandDoc(writer, "EXPIREAT", 3, []string{"write", "fast"})
-				writeCommandDoc(writer, "BGREWRITEAOF", 1, []string{"admin", "write"})
-				break
-			}
-
-			if len(args) == 1 && strings.ToUpper(args[0]) == "COUNT" {
-				_ = resp.WriteInteger(writer, 11)
-				break
-			}
-
-			writeWrongArgs(writer, "COMMAND")
-
-		case "INFO":
-			if len(args) != 0 {
-				writeWrongArgs(writer, "INFO")
-				break
-			}
-
-			port := ""
-			if s.ln != nil {
-				if a, ok := s.ln.Addr().(*net.TCPAddr); ok {
-					port = strconv.Itoa(a.Port)
-				}
-			}
-
-			if port == "" {
-				port = "6379"
-			}
-
-			info := []byte(
-				"# Server\r\n" +
-					"redis_version:0.0.1\r\n" +
-					"redigo:1\r\n" +
-					"tcp_port:" + port + "\r\n",
-			)
-			_ = resp.WriteBulkString(writer, info)
-
-		case "BGREWRITEAOF":
-			if len(args) != 0 {
-				writeWrongArgs(writer, "BGREWRITEAOF")
-				break
-			}
-
-			faof, ok := s.aof.(*aof.FileAOF)
-			if !ok {
-				_ = resp.WriteError(writer, "ERR aof rewrite not supported")
-				break
-			}
-
-			if !s.tryStartRewrite() {
-				_ = resp.WriteError(writer, "ERR aof rewrite already in progress")
-				break
-			}
-
-			s.rewriteWg.Add(1)
-			go func() {
-				defer s.rewriteWg.Done()
-				s.runRewrite(faof)
-			}()
-
-			_ = resp.WriteSimpleString(writer, "OK")
-
-		default:
-			_ = resp.WriteError(writer, "ERR unknown command '"+strings.ToLower(cmd)+"'")
-		}
-
-		if err := writer.Flush(); err != nil {
+		c.wmu.Lock()
+		// Messages published before this command was read go out first.
+		c.writePending()
+		err = s.dispatch(c, cmd, args)
+		flushErr := c.w.Flush()
+		c.wmu.Unlock()
+		if err != nil || flushErr != nil {
 			return
 		}
 	}
@@ -484,34 +366,157 @@ func startFsyncLoop(s *Server, interval time.Duration) func() {
 	return func() { close(done) }
 }
 
-func (s *Server) appendAOF(cmd string, args []string) error {
+// keyLockCount is the number of stripes of Server.keyLocks.
+const keyLockCount = 64
+
+var keyLockSeed = maphash.MakeSeed()
+
+// writeStripes returns the stripes of Server.keyLocks that write command
+// cmd must hold, in lock order. A write applies to the store and then logs,
+// and the stripes keep two writes to one key from logging in the opposite
+// order from how they applied (replay would rebuild a different value).
+// Keys share stripes, so unrelated keys may wait on each other briefly;
+// stripes are not per database, so MOVE and COPY DB are covered too.
+// Commands that change whole databases take every stripe.
+func writeStripes(cmd *command, args []string) []int {
+	var keys []string
+	switch cmd.name {
+	case "flushdb", "flushall", "swapdb":
+		all := make([]int, keyLockCount)
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	case "zunionstore", "zinterstore":
+		keys = args[:1]
+		if n, err := strconv.Atoi(args[1]); err == nil && n > 0 && n <= len(args)-2 {
+			keys = args[:2+n]
+		}
+	case "xreadgroup":
+		for i := 3; i < len(args); i++ {
+			if strings.EqualFold(args[i], "STREAMS") {
+				rest := args[i+1:]
+				keys = rest[:len(rest)/2]
+				break
+			}
+		}
+	default:
+		keys = cmd.keys(args)
+	}
+
+	seen := make(map[int]bool, len(keys))
+	var stripes []int
+	for _, key := range keys {
+		i := int(maphash.String(keyLockSeed, key) % keyLockCount)
+		if !seen[i] {
+			seen[i] = true
+			stripes = append(stripes, i)
+		}
+	}
+	sort.Ints(stripes)
+	return stripes
+}
+
+func (s *Server) lockStripes(stripes []int) {
+	for _, i := range stripes {
+		s.keyLocks[i].Lock()
+	}
+}
+
+func (s *Server) unlockStripes(stripes []int) {
+	for _, i := range stripes {
+		s.keyLocks[i].Unlock()
+	}
+}
+
+// appendAOF logs a command executed by c in its selected database and
+// publishes the keyspace events of the command c is executing.
+func (s *Server) appendAOF(c *client, cmd string, args []string) error {
+	if err := s.appendAOFDB(c.dbIndex, cmd, args); err != nil {
+		return err
+	}
+	s.notifyWrite(c.dbIndex, c.cmd, c.args, cmd, args)
+	return nil
+}
+
+// appendAOFDB logs a command that applies to database db, preceded by a
+// SELECT whenever db differs from the one the log is positioned in, so
+// replay applies it to the same database. Everything logged is a change, so
+// this is also where WATCHed keys are marked dirty and changes since the
+// last save are counted.
+func (s *Server) appendAOFDB(db int, cmd string, args []string) error {
+	s.touchWatched(db, cmd, args)
+	s.dirty.Add(1)
 	if s.aof == nil {
 		return nil
 	}
 
-	// Lock order: rewriteMu -> aofMu (consistent; avoids deadlocks).
-	s.rewriteMu.Lock()
-	defer s.rewriteMu.Unlock()
-
 	s.aofMu.Lock()
 	defer s.aofMu.Unlock()
 
+	if s.aofTx == txPending {
+		// First write of an EXEC: open the transaction in the log.
+		if err := s.aof.Append("MULTI", nil); err != nil {
+			return err
+		}
+		s.aofTx = txOpen
+	}
+	if db != s.aofDB {
+		if err := s.aof.Append("SELECT", []string{strconv.Itoa(db)}); err != nil {
+			return err
+		}
+		s.aofDB = db
+	}
 	if err := s.aof.Append(cmd, args); err != nil {
 		return err
 	}
 
-	if s.fsyncPolicy == aof.FsyncAlways {
+	// Inside EXEC, fsync once the whole transaction is logged.
+	if s.fsyncPolicy == aof.FsyncAlways && s.aofTx == txNone {
 		if err := s.aof.Sync(); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	if s.rewriteRunning {
-		cp := make([]string, len(args))
-		copy(cp, args)
-		s.rewriteTail = append(s.rewriteTail, aof.Entry{Cmd: cmd, Args: cp})
+// txLogState tracks the MULTI / EXEC wrapping of a transaction in the AOF.
+type txLogState uint8
+
+const (
+	txNone    txLogState = iota
+	txPending            // EXEC is running but has not written anything yet
+	txOpen               // MULTI is logged, EXEC is not
+)
+
+// beginExecLog makes the writes of the EXEC about to run go between MULTI
+// and EXEC in the log. A transaction that writes nothing logs nothing.
+func (s *Server) beginExecLog() {
+	s.aofMu.Lock()
+	s.aofTx = txPending
+	s.aofMu.Unlock()
+}
+
+// endExecLog closes the transaction opened by beginExecLog.
+func (s *Server) endExecLog() error {
+	if s.aof == nil {
+		return nil
 	}
 
+	s.aofMu.Lock()
+	defer s.aofMu.Unlock()
+
+	open := s.aofTx == txOpen
+	s.aofTx = txNone
+	if !open {
+		return nil
+	}
+	if err := s.aof.Append("EXEC", nil); err != nil {
+		return err
+	}
+	if s.fsyncPolicy == aof.FsyncAlways {
+		return s.aof.Sync()
+	}
 	return nil
 }
 
@@ -530,20 +535,11 @@ func writeWrongArgs(w *bufio.Writer, cmd string) {
 	_ = resp.WriteError(w, "ERR wrong number of arguments for '"+strings.ToLower(cmd)+"' command")
 }
 
-func writeAOFError(w *bufio.Writer, msg string) {
-	_ = resp.WriteError(w, msg)
-	_ = w.Flush()
-}
-
-func writeCommandDoc(w *bufio.Writer, name string, arity int64, flags []string) {
-	_ = resp.WriteArrayHeader(w, 3)
-	_ = resp.WriteBulkString(w, []byte(strings.ToLower(name)))
-	_ = resp.WriteInteger(w, arity)
-
-	_ = resp.WriteArrayHeader(w, int(len(flags)))
-	for _, f := range flags {
-		_ = resp.WriteBulkString(w, []byte(f))
-	}
+// writeAOFError replies with the AOF failure error and returns errCloseConn
+// so the connection is dropped (clients must not assume the write happened).
+func writeAOFError(w *bufio.Writer) error {
+	_ = resp.WriteError(w, "ERR aof write failed")
+	return errCloseConn
 }
 
 func (s *Server) tryStartRewrite() bool {
@@ -558,55 +554,72 @@ func (s *Server) tryStartRewrite() bool {
 	}
 
 	s.rewriteRunning = true
-	s.rewriteTail = s.rewriteTail[:0]
+	s.rewrites++
+	s.lastRewriteTry = time.Now()
 	return true
 }
 
-// finishRewriteLocked assumes rewriteMu is held.
-// Caller may hold aofMu as well (recommended during install swap).
-func (s *Server) finishRewriteLocked() []aof.Entry {
-	tail := append([]aof.Entry(nil), s.rewriteTail...) // copy
+// startRewrite runs a rewrite claimed with tryStartRewrite in the
+// background.
+func (s *Server) startRewrite(faof *aof.FileAOF) {
+	s.rewriteWg.Add(1)
+	go func() {
+		defer s.rewriteWg.Done()
+		s.runRewrite(faof)
+	}()
+}
+
+func (s *Server) endRewrite(err error) {
+	s.rewriteMu.Lock()
 	s.rewriteRunning = false
-	s.rewriteTail = nil
-	return tail
+	s.lastRewriteOK = err == nil
+	s.rewriteMu.Unlock()
 }
 
 func (s *Server) runRewrite(faof *aof.FileAOF) {
 	start := time.Now()
 
-	// 1) snapshot fast (brief store lock)
-	snap := s.store.Snapshot()
-
-	// 2) write compact temp AOF
-	tmpPath, err := faof.WriteRewriteTemp(snap)
+	// 1) with no command running, move appends to a new incremental file
+	// and snapshot: the base holds exactly what the older parts did.
+	s.execMu.Lock()
+	s.aofMu.Lock()
+	rw, err := faof.StartRewrite()
+	// The new file starts in database 0 on replay.
+	s.aofDB = -1
+	s.aofMu.Unlock()
+	var snap []store.SnapshotEntry
+	if err == nil {
+		snap = s.dbs.Snapshot()
+	}
+	s.execMu.Unlock()
 	if err != nil {
-		s.rewriteMu.Lock()
-		s.rewriteRunning = false
-		s.rewriteTail = nil
-		s.rewriteMu.Unlock()
+		s.endRewrite(err)
+		log.Printf("[BGREWRITEAOF] failed to start: %v", err)
+		return
+	}
 
-		log.Printf("[BGREWRITEAOF] failed to write temp: %v", err)
+	// 2) write the new base while commands go on
+	if err := rw.WriteBase(snap); err != nil {
+		faof.AbortRewrite(rw)
+		s.endRewrite(err)
+		log.Printf("[BGREWRITEAOF] failed to write base: %v", err)
 		return
 	}
 
-	// 3) atomically capture tail and swap under locks
-	// Lock order: rewriteMu -> aofMu (matches appendAOF; avoids deadlocks).
 	s.rewriteMu.Lock()
-	s.aofMu.Lock()
-
-	tail := s.finishRewriteLocked()
+	hook := s.testHookBeforeInstall
 	s.rewriteMu.Unlock()
+	if hook != nil {
+		hook()
+	}
 
-	installErr := faof.InstallRewrite(tmpPath, tail)
-
-	s.aofMu.Unlock()
-
-	if installErr != nil {
-		log.Printf("[BGREWRITEAOF] failed to install rewrite (tmp=%s, tail_ops=%d): %v",
-			tmpPath, len(tail), installErr)
+	// 3) switch the manifest to the new base and drop the parts it replaces
+	err = faof.FinishRewrite(rw)
+	s.endRewrite(err)
+	if err != nil {
+		log.Printf("[BGREWRITEAOF] failed to install rewrite: %v", err)
 		return
 	}
 
-	log.Printf("[BGREWRITEAOF] completed (keys=%d, tail_ops=%d) in %s",
-		len(snap), len(tail), time.Since(start))
+	log.Printf("[BGREWRITEAOF] completed (keys=%d) in %s", len(snap), time.Since(start))
 }
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSET_Options(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	// distributed-lock idiom
+	c.mustOK("SET", "lock", "owner1", "EX", "10", "NX")
+	c.mustNil("SET", "lock", "owner2", "EX", "10", "NX")
+	c.mustBulk("owner1", "GET", "lock")
+	c.mustInt(10, "TTL", "lock")
+
+	c.mustNil("SET", "missing", "v", "XX")
+	c.mustInt(0, "EXISTS", "missing")
+	c.mustOK("SET", "lock", "owner3", "XX", "KEEPTTL")
+	c.mustInt(10, "TTL", "lock")
+
+	c.mustBulk("owner3", "SET", "lock", "owner4", "GET")
+	c.mustInt(-1, "TTL", "lock")
+	c.mustNil("SET", "fresh", "v", "GET")
+	c.mustBulk("v", "SET", "fresh", "w", "NX", "GET")
+	c.mustBulk("v", "GET", "fresh")
+
+	c.mustOK("SET", "px", "v", "PX", "100000")
+	c.mustInt(100, "TTL", "px")
+	future := time.Now().Add(50 * time.Second).Unix()
+	c.mustOK("SET", "exat", "v", "EXAT", strconv.FormatInt(future, 10))
+	if v := c.do("TTL", "exat"); v.Int < 49 || v.Int > 50 {
+		t.Fatalf("expected ttl ~50, got %s", fmtValue(v))
+	}
+	c.mustOK("SET", "pxat", "v", "PXAT", "1")
+	c.mustInt(0, "EXISTS", "pxat")
+
+	c.mustErr("syntax error", "SET", "k", "v", "NX", "XX")
+	c.mustErr("syntax error", "SET", "k", "v", "EX", "10", "PX", "100")
+	c.mustErr("syntax error", "SET", "k", "v", "EX", "10", "KEEPTTL")
+	c.mustErr("syntax error", "SET", "k", "v", "EX")
+	c.mustErr("syntax error", "SET", "k", "v", "BOGUS")
+	c.mustErr("invalid expire time in 'set' command", "SET", "k", "v", "EX", "0")
+	c.mustErr("invalid expire time in 'set' command", "SET", "k", "v", "EX", "9223372036854775807")
+	c.mustErr("not an integer", "SET", "k", "v", "PX", "soon")
+	c.mustInt(0, "EXISTS", "k")
+
+	c.mustInt(1, "HSET", "h", "f", "v")
+	c.mustErr("WRONGTYPE", "SET", "h", "v", "GET")
+	c.mustOK("SET", "h", "v")
+}
+
+func TestSET_LogsAbsoluteExpiry(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "k", "v", "EX", "100", "NX")
+	c.mustNil("SET", "k", "other", "NX") // not written, not logged
+	c.mustOK("SET", "kept", "v", "PX", "50000")
+	c.mustOK("SET", "kept", "v2", "KEEPTTL")
+	_ = s.Close()
+
+	raw := readAOF(t, path)
+	log := string(raw)
+	if strings.Contains(log, "other") {
+		t.Fatal("a SET that did not write must not be logged")
+	}
+	if strings.Contains(log, "\r\nEX\r\n") || strings.Contains(log, "\r\nNX\r\n") || !strings.Contains(log, "PXAT") {
+		t.Fatalf("expected relative expiry to be logged as PXAT, got %q", log)
+	}
+
+	st2 := replayInto(t, path)
+	if ttl := st2.TTL("k"); ttl < 99 || ttl > 100 {
+		t.Fatalf("expected ttl ~100 after replay, got %d", ttl)
+	}
+	if v, _ := st2.Get("kept"); string(v) != "v2" {
+		t.Fatalf("expected kept=v2, got %q", v)
+	}
+	if ttl := st2.TTL("kept"); ttl < 49 || ttl > 50 {
+		t.Fatalf("expected KEEPTTL to survive replay, got %d", ttl)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestSetCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "SADD", "s", "c", "a", "b", "a")
+	c.mustInt(0, "SADD", "s", "a")
+	c.mustStrings([]string{"a", "b", "c"}, "SMEMBERS", "s")
+	c.mustInt(3, "SCARD", "s")
+	c.mustInt(1, "SISMEMBER", "s", "a")
+	c.mustInt(0, "SISMEMBER", "s", "z")
+
+	v := c.do("SMISMEMBER", "s", "a", "z", "c")
+	if v.Type != resp.Array || len(v.Array) != 3 || v.Array[0].Int != 1 || v.Array[1].Int != 0 || v.Array[2].Int != 1 {
+		t.Fatalf("unexpected SMISMEMBER reply: %s", fmtValue(v))
+	}
+
+	c.mustInt(1, "SREM", "s", "c", "zz")
+	c.mustInt(1, "SMOVE", "s", "t", "b")
+	c.mustInt(0, "SMOVE", "s", "t", "b")
+	c.mustStrings([]string{"a"}, "SMEMBERS", "s")
+	c.mustStrings([]string{"b"}, "SMEMBERS", "t")
+
+	c.mustInt(3, "SADD", "x", "1", "2", "3")
+	c.mustInt(2, "SADD", "y", "2", "3")
+	c.mustStrings([]string{"2", "3"}, "SINTER", "x", "y")
+	c.mustStrings([]string{"1", "2", "3"}, "SUNION", "x", "y", "missing")
+	c.mustStrings([]string{"1"}, "SDIFF", "x", "y")
+	c.mustInt(2, "SINTERSTORE", "dst", "x", "y")
+	c.mustStrings([]string{"2", "3"}, "SMEMBERS", "dst")
+	c.mustInt(0, "SDIFFSTORE", "dst", "y", "x")
+	c.mustInt(0, "EXISTS", "dst")
+	c.mustInt(3, "SUNIONSTORE", "dst", "x", "y")
+
+	c.mustInt(2, "SINTERCARD", "2", "x", "y")
+	c.mustInt(1, "SINTERCARD", "2", "x", "y", "LIMIT", "1")
+	c.mustErr("numkeys should be greater than 0", "SINTERCARD", "0", "x")
+	c.mustErr("Number of keys can't be greater than number of args", "SINTERCARD", "3", "x", "y")
+	c.mustErr("LIMIT can't be negative", "SINTERCARD", "1", "x", "LIMIT", "-1")
+	c.mustErr("syntax error", "SINTERCARD", "1", "x", "BOGUS")
+
+	c.mustBulk("a", "SRANDMEMBER", "s")
+	c.mustNil("SRANDMEMBER", "missing")
+	c.mustStrings([]string{"a", "a", "a"}, "SRANDMEMBER", "s", "-3")
+	c.mustBulk("a", "SPOP", "s")
+	c.mustInt(0, "EXISTS", "s")
+	c.mustNil("SPOP", "s")
+	c.mustStrings(nil, "SPOP", "s", "2")
+	c.mustErr("must be positive", "SPOP", "x", "-1")
+}
+
+func TestSet_WrongTypeErrors(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "str", "v")
+	c.mustInt(1, "SADD", "s", "a")
+	c.mustErr("WRONGTYPE", "SADD", "str", "a")
+	c.mustErr("WRONGTYPE", "SINTER", "s", "str")
+	c.mustErr("WRONGTYPE", "SMOVE", "s", "str", "a")
+	c.mustErr("WRONGTYPE", "GET", "s")
+	c.mustInt(1, "SCARD", "s")
+}
+
+func TestSSCAN_Match(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(4, "SADD", "s", "user:1", "user:2", "admin:1", "user:3")
+
+	var got []string
+	cursor := "0"
+	for {
+		v := c.do("SSCAN", "s", cursor, "MATCH", "user:*", "COUNT", "2")
+		if v.Type != resp.Array || len(v.Array) != 2 {
+			t.Fatalf("unexpected SSCAN reply: %s", fmtValue(v))
+		}
+		got = append(got, bulkStrings(v.Array[1])...)
+		cursor = string(v.Array[0].Bulk)
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(got)
+	if len(got) != 3 || got[0] != "user:1" || got[2] != "user:3" {
+		t.Fatalf("unexpected members: %q", got)
+	}
+	c.mustErr("syntax error", "SSCAN", "s", "0", "NOVALUES")
+}
+
+func TestSet_SurvivesAOFRewriteAndReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	members := append([]string{"SADD", "big"}, numbered(150)...)
+	c.mustInt(150, members...)
+	v := c.do("SPOP", "big", "10")
+	if len(v.Array) != 10 {
+		t.Fatalf("expected 10 popped, got %s", fmtValue(v))
+	}
+	c.mustInt(2, "SADD", "a", "x", "y")
+	c.mustInt(2, "SINTERSTORE", "b", "a", "a")
+	c.mustInt(1, "SMOVE", "a", "b", "x")
+	_ = s.Close()
+
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		st := dbs.DB(0)
+		if n, _ := st.SCard("big"); n != 140 {
+			t.Fatalf("%s: expected 140 members, got %d", stage, n)
+		}
+		for _, m := range bulkStrings(v) {
+			if ok, _ := st.SIsMember("big", m); ok {
+				t.Fatalf("%s: popped member %q is back", stage, m)
+			}
+		}
+		got, _ := st.SMembers("b")
+		sort.Strings(got)
+		if len(got) != 2 || got[0] != "x" || got[1] != "y" {
+			t.Fatalf("%s: unexpected b: %q", stage, got)
+		}
+		if n, _ := st.SCard("a"); n != 1 {
+			t.Fatalf("%s: expected a to keep 1 member, got %d", stage, n)
+		}
+	})
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestStreamCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustBulk("1-1", "XADD", "s", "1-1", "a", "1")
+	c.mustBulk("1-2", "XADD", "s", "1-*", "b", "2")
+	c.mustBulk("5-0", "XADD", "s", "5", "c", "3")
+	c.mustErr("equal or smaller than the target stream top item", "XADD", "s", "5-0", "d", "4")
+	c.mustErr("must be greater than 0-0", "XADD", "new", "0-0", "d", "4")
+	c.mustErr("wrong number of arguments for 'xadd'", "XADD", "s", "*", "odd")
+	c.mustNil("XADD", "missing", "NOMKSTREAM", "*", "f", "v")
+	c.mustInt(0, "EXISTS", "missing")
+	c.mustReply(`+stream`, "TYPE", "s")
+	c.mustInt(3, "XLEN", "s")
+
+	c.mustReply(`[["1-1" ["a" "1"]] ["1-2" ["b" "2"]] ["5-0" ["c" "3"]]]`, "XRANGE", "s", "-", "+")
+	c.mustReply(`[["1-2" ["b" "2"]]]`, "XRANGE", "s", "(1-1", "(5-0")
+	c.mustReply(`[["1-1" ["a" "1"]] ["1-2" ["b" "2"]]]`, "XRANGE", "s", "1", "1")
+	c.mustReply(`[["5-0" ["c" "3"]] ["1-2" ["b" "2"]]]`, "XREVRANGE", "s", "+", "-", "COUNT", "2")
+	c.mustReply(`[]`, "XRANGE", "s", "-", "+", "COUNT", "0")
+	c.mustErr("Invalid stream ID", "XRANGE", "s", "x", "+")
+
+	c.mustInt(1, "XDEL", "s", "1-2", "9-9")
+	c.mustInt(2, "XLEN", "s")
+	c.mustReply(`["length" :2 "radix-tree-keys" :1 "radix-tree-nodes" :1 "last-generated-id" "5-0" `+
+		`"max-deleted-entry-id" "1-2" "entries-added" :3 "recorded-first-entry-id" "1-1" "groups" :0 `+
+		`"first-entry" ["1-1" ["a" "1"]] "last-entry" ["5-0" ["c" "3"]]]`, "XINFO", "STREAM", "s")
+
+	for i := 0; i < 10; i++ {
+		c.do("XADD", "t", "*", "i", "x")
+	}
+	c.mustInt(7, "XTRIM", "t", "MAXLEN", "3")
+	c.mustInt(3, "XLEN", "t")
+	c.mustErr("LIMIT cannot be used without the special ~ option", "XTRIM", "t", "MAXLEN", "1", "LIMIT", "5")
+	c.mustInt(0, "XTRIM", "t", "MAXLEN", "~", "1") // less than a whole node
+	c.do("XADD", "t", "MAXLEN", "2", "*", "i", "y")
+	c.mustInt(2, "XLEN", "t")
+	c.mustInt(0, "XTRIM", "s", "MINID", "1-1")
+	c.mustInt(1, "XTRIM", "s", "MINID", "2")
+	c.mustReply(`[["5-0" ["c" "3"]]]`, "XRANGE", "s", "-", "+")
+
+	c.mustOK("SET", "str", "v")
+	c.mustErr("WRONGTYPE", "XADD", "str", "*", "f", "v")
+	c.mustErr("WRONGTYPE", "XLEN", "str")
+}
+
+func TestXREAD_BlockWokenByXAdd(t *testing.T) {
+	_, addr := startTestServer(t)
+	reader := dialTest(t, addr)
+	writer := dialTest(t, addr)
+
+	writer.mustBulk("1-0", "XADD", "s", "1-0", "f", "old")
+	reader.mustReply(`[["s" [["1-0" ["f" "old"]]]]]`, "XREAD", "STREAMS", "s", "nokey", "0", "0")
+	reader.mustReply(`(nil array)`, "XREAD", "STREAMS", "s", "1-0")
+	reader.mustReply(`(nil array)`, "XREAD", "BLOCK", "20", "STREAMS", "s", "$")
+	reader.mustErr("Unbalanced 'xread' list of streams", "XREAD", "STREAMS", "s", "t", "0")
+
+	if err := sendCmd(reader.conn, reader.w, "XREAD", "BLOCK", "0", "STREAMS", "s", "$"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	writer.mustBulk("2-0", "XADD", "s", "2-0", "f", "new")
+	if got := fmtValue(reader.read()); got != `[["s" [["2-0" ["f" "new"]]]]]` {
+		t.Fatalf("unexpected XREAD reply %s", got)
+	}
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustErr("requires the key to exist", "XGROUP", "CREATE", "s", "g", "$")
+	c.mustOK("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM")
+	c.mustErr("BUSYGROUP", "XGROUP", "CREATE", "s", "g", "0")
+	c.mustErr("NOGROUP No such key 's' or consumer group 'nope' in XREADGROUP",
+		"XREADGROUP", "GROUP", "nope", "alice", "STREAMS", "s", ">")
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		c.mustBulk(id, "XADD", "s", id, "f", id)
+	}
+
+	c.mustReply(`[["s" [["1-0" ["f" "1-0"]] ["2-0" ["f" "2-0"]]]]]`,
+		"XREADGROUP", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "s", ">")
+	c.mustReply(`[["s" [["3-0" ["f" "3-0"]]]]]`, "XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">")
+	c.mustReply(`(nil array)`, "XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">")
+
+	c.mustReply(`[:3 "1-0" "3-0" [["alice" "2"] ["bob" "1"]]]`, "XPENDING", "s", "g")
+	v := c.do("XPENDING", "s", "g", "-", "+", "10", "alice")
+	if len(v.Array) != 2 || string(v.Array[0].Array[0].Bulk) != "1-0" || v.Array[0].Array[3].Int != 1 {
+		t.Fatalf("unexpected XPENDING reply %s", fmtValue(v))
+	}
+
+	// Alice's history: her pending entries, deleted ones with nil fields.
+	c.mustInt(1, "XDEL", "s", "2-0")
+	c.mustReply(`[["s" [["1-0" ["f" "1-0"]] ["2-0" (nil array)]]]]`,
+		"XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "0")
+	c.mustInt(1, "XACK", "s", "g", "1-0", "9-0")
+	c.mustInt(0, "XACK", "s", "g", "1-0")
+
+	// Deleted entries are dropped from the PEL when claimed.
+	c.mustReply(`["0-0" ["3-0"] ["2-0"]]`, "XAUTOCLAIM", "s", "g", "carol", "0", "0", "JUSTID")
+	c.mustReply(`[["3-0" ["f" "3-0"]]]`, "XCLAIM", "s", "g", "alice", "0", "3-0")
+	c.mustReply(`[]`, "XCLAIM", "s", "g", "bob", "3600000", "3-0")
+	v = c.do("XPENDING", "s", "g", "IDLE", "0", "-", "+", "10")
+	if len(v.Array) != 1 || string(v.Array[0].Array[1].Bulk) != "alice" || v.Array[0].Array[3].Int != 2 {
+		t.Fatalf("unexpected XPENDING reply %s", fmtValue(v))
+	}
+
+	c.mustReply(`[["name" "g" "consumers" :3 "pending" :1 "last-delivered-id" "3-0" "entries-read" :3 "lag" :0]]`,
+		"XINFO", "GROUPS", "s")
+	c.mustBulk("4-0", "XADD", "s", "4-0", "f", "4-0")
+	c.mustReply(`[["name" "g" "consumers" :3 "pending" :1 "last-delivered-id" "3-0" "entries-read" :3 "lag" :1]]`,
+		"XINFO", "GROUPS", "s")
+	v = c.do("XINFO", "CONSUMERS", "s", "g")
+	if len(v.Array) != 3 || string(v.Array[0].Array[1].Bulk) != "alice" || v.Array[0].Array[3].Int != 1 {
+		t.Fatalf("unexpected XINFO CONSUMERS reply %s", fmtValue(v))
+	}
+
+	c.mustOK("XGROUP", "SETID", "s", "g", "0")
+	c.mustReply(`[["name" "g" "consumers" :3 "pending" :1 "last-delivered-id" "0-0" "entries-read" (nil) "lag" (nil)]]`,
+		"XINFO", "GROUPS", "s")
+	c.mustInt(0, "XGROUP", "CREATECONSUMER", "s", "g", "alice")
+	c.mustInt(1, "XGROUP", "DELCONSUMER", "s", "g", "alice")
+	c.mustErr("NOGROUP No such consumer group 'x' for key name 's'", "XGROUP", "DELCONSUMER", "s", "x", "alice")
+	c.mustInt(1, "XGROUP", "DESTROY", "s", "g")
+	c.mustInt(0, "XGROUP", "DESTROY", "s", "g")
+}
+
+func TestXREADGROUP_BlockWokenByXAdd(t *testing.T) {
+	_, addr := startTestServer(t)
+	reader := dialTest(t, addr)
+	writer := dialTest(t, addr)
+
+	writer.mustOK("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM")
+	if err := sendCmd(reader.conn, reader.w, "XREADGROUP", "GROUP", "g", "c", "BLOCK", "0", "NOACK", "STREAMS", "s", ">"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	writer.mustBulk("1-0", "XADD", "s", "1-0", "f", "v")
+	if got := fmtValue(reader.read()); got != `[["s" [["1-0" ["f" "v"]]]]]` {
+		t.Fatalf("unexpected XREADGROUP reply %s", got)
+	}
+	writer.mustReply(`[:0 (nil) (nil) (nil array)]`, "XPENDING", "s", "g") // NOACK
+}
+
+func TestStream_GroupStateSurvivesAOFRewriteAndReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	st := s.dbs.DB(0)
+	c := dialTest(t, addr)
+
+	for i := 0; i < 250; i++ {
+		c.do("XADD", "s", "MAXLEN", "~", "200", "*", "n", strings.Repeat("x", i%7))
+	}
+	c.mustOK("XGROUP", "CREATE", "s", "g", "0")
+	c.mustOK("XGROUP", "CREATE", "s", "late", "$")
+	c.mustInt(1, "XGROUP", "CREATECONSUMER", "s", "late", "idle")
+	if v := c.do("XREADGROUP", "GROUP", "g", "a", "COUNT", "20", "STREAMS", "s", ">"); v.Type != resp.Array {
+		t.Fatalf("XREADGROUP: %s", fmtValue(v))
+	}
+	c.do("XREADGROUP", "GROUP", "g", "b", "COUNT", "5", "NOACK", "STREAMS", "s", ">")
+	c.do("XREADGROUP", "GROUP", "g", "a", "COUNT", "3", "STREAMS", "s", "0") // redelivery
+	first, _ := st.XRange("s", store.StreamID{}, store.MaxStreamID, 4, false)
+	c.mustInt(1, "XACK", "s", "g", first[0].ID.String())
+	c.mustInt(1, "XDEL", "s", first[1].ID.String()) // stays pending
+	c.do("XCLAIM", "s", "g", "b", "0", first[2].ID.String())
+	c.do("XAUTOCLAIM", "s", "g", "c", "0", first[3].ID.String(), "COUNT", "2")
+
+	c.mustOK("XGROUP", "CREATE", "empty", "g", "$", "MKSTREAM")
+	c.mustBulk("7-0", "XADD", "drained", "7-0", "f", "v")
+	c.mustInt(1, "XDEL", "drained", "7-0")
+	_ = s.Close()
+
+	snapshot := func(st *store.Store) map[string]*store.StreamSnapshot {
+		out := make(map[string]*store.StreamSnapshot)
+		for _, e := range st.Snapshot() {
+			out[e.Key] = e.Stream
+		}
+		return out
+	}
+	want := snapshot(st)
+	if len(want) != 3 || len(want["s"].Groups) != 2 || want["s"].Groups[0].Pending[0].ID != first[1].ID {
+		t.Fatalf("expected the deleted entry to be pending, got %+v", want["s"])
+	}
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		got := snapshot(dbs.DB(0))
+		for key := range want {
+			if !reflect.DeepEqual(got[key], want[key]) {
+				t.Fatalf("%s: %s differs:\n got %+v\nwant %+v", stage, key, got[key], want[key])
+			}
+		}
+	})
+}
+
+func TestXADD_ConcurrentAddsReplayInOrder(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+
+	// The second XADD must not take the next ID before the first is logged,
+	// or replay would reject the lower ID as out of order.
+	var n int
+	cmds := [][]string{{"XADD", "s", "*", "n", "0"}, {"XADD", "s", "*", "n", "1"}}
+	sendWhileLogStalled(t, s, addr, cmds, func() {
+		n, _ = s.dbs.DB(0).XLen("s")
+	})
+	if n != 1 {
+		t.Fatalf("second XADD applied while the first was not logged yet: XLEN %d", n)
+	}
+
+	const clients, perClient = 8, 200
+	pipelineFromClients(t, addr, clients, perClient, func(_, i int) []string {
+		return []string{"XADD", "s", "*", "n", strconv.Itoa(i)}
+	})
+	_ = s.Close()
+
+	want := 2 + clients*perClient
+	if n, _ := replayInto(t, path).XLen("s"); n != want {
+		t.Fatalf("expected %d entries after replay, got %d", want, n)
+	}
+}
+
+func TestXREADGROUP_ConcurrentReadersLogInOrder(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+	c.mustBulk("1-0", "XADD", "s", "1-0", "f", "v")
+	c.mustBulk("2-0", "XADD", "s", "2-0", "f", "v")
+	c.mustOK("XGROUP", "CREATE", "s", "g", "0")
+
+	// As for XADD: the second reader must not move the group on before the
+	// first reader's move is logged.
+	var sum store.PendingSummary
+	cmds := [][]string{
+		{"XREADGROUP", "GROUP", "g", "a", "COUNT", "1", "STREAMS", "s", ">"},
+		{"XREADGROUP", "GROUP", "g", "b", "COUNT", "1", "STREAMS", "s", ">"},
+	}
+	sendWhileLogStalled(t, s, addr, cmds, func() {
+		sum, _ = s.dbs.DB(0).XPendingSummary("s", "g")
+	})
+	if sum.Count != 1 {
+		t.Fatalf("second read applied while the first was not logged yet: %d pending", sum.Count)
+	}
+	_ = s.Close()
+
+	groups, _, _ := replayInto(t, path).XInfoGroups("s")
+	if len(groups) != 1 || groups[0].LastID != (store.StreamID{Ms: 2}) || groups[0].EntriesRead != 2 || groups[0].Pending != 2 {
+		t.Fatalf("unexpected group after replay: %+v", groups)
+	}
+}
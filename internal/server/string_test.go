@@ -0,0 +1,110 @@
+package server
+
+import "testing"
+
+func TestStringCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(5, "APPEND", "k", "Hello")
+	c.mustInt(11, "APPEND", "k", " World")
+	c.mustInt(11, "STRLEN", "k")
+	c.mustInt(0, "STRLEN", "missing")
+	c.mustBulk("World", "GETRANGE", "k", "-5", "-1")
+	c.mustBulk("", "GETRANGE", "missing", "0", "-1")
+	c.mustInt(11, "SETRANGE", "k", "6", "Redis")
+	c.mustBulk("Hello Redis", "GET", "k")
+	c.mustInt(3, "SETRANGE", "pad", "2", "x")
+	c.mustBulk("\x00\x00x", "GET", "pad")
+	c.mustErr("offset is out of range", "SETRANGE", "k", "-1", "x")
+	c.mustErr("string exceeds maximum allowed size", "SETRANGE", "k", "536870912", "x")
+
+	c.mustBulk("Hello Redis", "GETSET", "k", "new")
+	c.mustNil("GETSET", "fresh", "v")
+	c.mustBulk("new", "GETDEL", "k")
+	c.mustNil("GETDEL", "k")
+
+	c.mustInt(1, "SETNX", "nx", "1")
+	c.mustInt(0, "SETNX", "nx", "2")
+	c.mustBulk("1", "GET", "nx")
+
+	c.mustOK("SETEX", "ex", "100", "v")
+	c.mustInt(100, "TTL", "ex")
+	c.mustOK("PSETEX", "px", "100000", "v")
+	c.mustInt(100, "TTL", "px")
+	c.mustErr("invalid expire time in 'setex' command", "SETEX", "ex", "0", "v")
+	c.mustErr("value is not an integer", "PSETEX", "px", "abc", "v")
+
+	c.mustBulk("v", "GETEX", "ex", "PERSIST")
+	c.mustInt(-1, "TTL", "ex")
+	c.mustBulk("v", "GETEX", "ex", "EX", "50")
+	c.mustInt(50, "TTL", "ex")
+	c.mustErr("syntax error", "GETEX", "ex", "EX", "50", "PERSIST")
+	c.mustErr("invalid expire time in 'getex' command", "GETEX", "ex", "PX", "-1")
+	c.mustNil("GETEX", "missing", "EX", "10")
+
+	c.mustInt(1, "MSETNX", "m1", "a", "m2", "b")
+	c.mustInt(0, "MSETNX", "m2", "x", "m3", "c")
+	c.mustNil("GET", "m3")
+	c.mustErr("wrong number of arguments", "MSETNX", "m1", "a", "m2")
+
+	c.mustInt(1, "HSET", "h", "f", "v")
+	c.mustErr("WRONGTYPE", "APPEND", "h", "x")
+	c.mustErr("WRONGTYPE", "GETDEL", "h")
+}
+
+func TestLCSCommand(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustOK("SET", "key1", "ohmytext")
+	c.mustOK("SET", "key2", "mynewtext")
+	c.mustBulk("mytext", "LCS", "key1", "key2")
+	c.mustInt(6, "LCS", "key1", "key2", "LEN")
+	c.mustErr("please just use IDX", "LCS", "key1", "key2", "LEN", "IDX")
+
+	v := c.do("LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN")
+	if got, want := fmtValue(v), `["matches" [[[:4 :7] [:5 :8] :4]] "len" :6]`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	v = c.do("LCS", "key1", "key2", "IDX")
+	if got, want := fmtValue(v), `["matches" [[[:4 :7] [:5 :8]] [[:2 :3] [:0 :1]]] "len" :6]`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestStringCommands_AOFReplay(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "APPEND", "a", "abc")
+	c.mustInt(5, "SETRANGE", "a", "3", "de")
+	c.mustOK("SET", "gone", "x")
+	c.mustBulk("x", "GETDEL", "gone")
+	c.mustOK("SETEX", "ex", "100", "v")
+	c.mustBulk("v", "GETEX", "ex", "PERSIST")
+	c.mustOK("SET", "px", "v")
+	c.mustBulk("v", "GETEX", "px", "PX", "100000")
+	c.mustInt(1, "SETNX", "nx", "v")
+	c.mustInt(1, "MSETNX", "m1", "1", "m2", "2")
+	_ = s.Close()
+
+	st2 := replayInto(t, path)
+	if v, _ := st2.Get("a"); string(v) != "abcde" {
+		t.Fatalf("expected abcde, got %q", v)
+	}
+	if st2.Exists("gone") {
+		t.Fatalf("expected GETDEL to be replayed")
+	}
+	if ttl := st2.TTL("ex"); ttl != -1 {
+		t.Fatalf("expected GETEX PERSIST to be replayed, got ttl %d", ttl)
+	}
+	if ttl := st2.TTL("px"); ttl < 99 {
+		t.Fatalf("expected GETEX PX to be replayed, got ttl %d", ttl)
+	}
+	for _, key := range []string{"nx", "m1", "m2"} {
+		if !st2.Exists(key) {
+			t.Fatalf("expected %s to be replayed", key)
+		}
+	}
+}
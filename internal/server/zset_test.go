@@ -0,0 +1,178 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/protocol/resp"
+	"github.com/pranavbrkr/redigo/internal/store"
+)
+
+func TestSortedSetCommands(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(3, "ZADD", "z", "1", "a", "2", "b", "3", "c")
+	c.mustInt(0, "ZADD", "z", "5", "a")
+	c.mustInt(1, "ZADD", "z", "CH", "1", "a")
+	c.mustInt(0, "ZADD", "z", "XX", "9", "nope")
+	c.mustInt(0, "ZADD", "z", "GT", "CH", "0", "c")
+	c.mustBulk("4.5", "ZADD", "z", "INCR", "1.5", "c")
+	c.mustNil("ZADD", "z", "NX", "INCR", "1", "c")
+	c.mustErr("not compatible", "ZADD", "z", "NX", "XX", "1", "a")
+	c.mustErr("not compatible", "ZADD", "z", "GT", "LT", "1", "a")
+	c.mustErr("single increment-element pair", "ZADD", "z", "INCR", "1", "a", "2", "b")
+	c.mustErr("not a valid float", "ZADD", "z", "x", "a")
+	c.mustErr("syntax error", "ZADD", "z", "1", "a", "2")
+
+	c.mustBulk("3", "ZINCRBY", "z", "1", "b")
+	c.mustBulk("1", "ZSCORE", "z", "a")
+	c.mustNil("ZSCORE", "z", "nope")
+	c.mustInt(3, "ZCARD", "z")
+	c.mustInt(2, "ZCOUNT", "z", "(1", "+inf")
+
+	c.mustStrings([]string{"a", "b", "c"}, "ZRANGE", "z", "0", "-1")
+	c.mustStrings([]string{"c", "4.5", "b", "3"}, "ZRANGE", "z", "0", "1", "REV", "WITHSCORES")
+	c.mustStrings([]string{"b", "c"}, "ZRANGE", "z", "(1", "inf", "BYSCORE")
+	c.mustStrings([]string{"c"}, "ZRANGE", "z", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "0", "1")
+	c.mustErr("LIMIT is only supported", "ZRANGE", "z", "0", "1", "LIMIT", "0", "1")
+	c.mustErr("min or max is not a float", "ZRANGE", "z", "x", "1", "BYSCORE")
+
+	c.mustInt(3, "ZADD", "lex", "0", "a", "0", "b", "0", "c")
+	c.mustStrings([]string{"b", "c"}, "ZRANGE", "lex", "(a", "+", "BYLEX")
+	c.mustStrings([]string{"b", "a"}, "ZRANGE", "lex", "[b", "-", "BYLEX", "REV")
+	c.mustErr("not valid string range item", "ZRANGE", "lex", "a", "+", "BYLEX")
+	c.mustErr("WITHSCORES not supported", "ZRANGE", "lex", "-", "+", "BYLEX", "WITHSCORES")
+
+	c.mustInt(0, "ZRANK", "z", "a")
+	c.mustInt(0, "ZREVRANK", "z", "c")
+	c.mustNil("ZRANK", "z", "nope")
+	v := c.do("ZRANK", "z", "b", "WITHSCORE")
+	if v.Type != resp.Array || len(v.Array) != 2 || v.Array[0].Int != 1 || string(v.Array[1].Bulk) != "3" {
+		t.Fatalf("unexpected ZRANK WITHSCORE reply: %s", fmtValue(v))
+	}
+
+	c.mustInt(2, "ZRANGESTORE", "dst", "z", "0", "1")
+	c.mustStrings([]string{"a", "b"}, "ZRANGE", "dst", "0", "-1")
+
+	c.mustStrings([]string{"a", "1"}, "ZPOPMIN", "z")
+	c.mustStrings([]string{"c", "4.5", "b", "3"}, "ZPOPMAX", "z", "5")
+	c.mustInt(0, "EXISTS", "z")
+	c.mustStrings(nil, "ZPOPMIN", "z")
+
+	c.mustInt(1, "ZREM", "dst", "a", "nope")
+	c.mustInt(1, "ZCARD", "dst")
+}
+
+func TestZUNIONSTORE_ZINTERSTORE(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(2, "ZADD", "a", "1", "x", "2", "y")
+	c.mustInt(2, "ZADD", "b", "10", "y", "20", "z")
+
+	c.mustInt(3, "ZUNIONSTORE", "u", "2", "a", "b", "WEIGHTS", "2", "1")
+	c.mustStrings([]string{"x", "2", "y", "14", "z", "20"}, "ZRANGE", "u", "0", "-1", "WITHSCORES")
+	c.mustInt(1, "ZINTERSTORE", "i", "2", "a", "b", "AGGREGATE", "MIN")
+	c.mustStrings([]string{"y", "2"}, "ZRANGE", "i", "0", "-1", "WITHSCORES")
+
+	c.mustErr("at least 1 input key", "ZUNIONSTORE", "u", "0", "a")
+	c.mustErr("syntax error", "ZUNIONSTORE", "u", "3", "a", "b")
+	c.mustErr("weight value is not a float", "ZUNIONSTORE", "u", "1", "a", "WEIGHTS", "x")
+	c.mustErr("syntax error", "ZINTERSTORE", "u", "1", "a", "AGGREGATE", "AVG")
+
+	c.mustOK("SET", "str", "v")
+	c.mustErr("WRONGTYPE", "ZUNIONSTORE", "u", "2", "a", "str")
+	c.mustErr("WRONGTYPE", "ZADD", "str", "1", "m")
+	c.mustErr("WRONGTYPE", "GET", "a")
+}
+
+func TestBZPOPMIN_WokenByZAdd(t *testing.T) {
+	_, addr := startTestServer(t)
+	waiter := dialTest(t, addr)
+	writer := dialTest(t, addr)
+
+	if err := sendCmd(waiter.conn, waiter.w, "BZPOPMIN", "q1", "q2", "0"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	writer.mustInt(2, "ZADD", "q2", "7", "late", "3", "early")
+
+	v := waiter.read()
+	if got := bulkStrings(v); len(got) != 3 || got[0] != "q2" || got[1] != "early" || got[2] != "3" {
+		t.Fatalf("expected [q2 early 3], got %s", fmtValue(v))
+	}
+
+	v = waiter.do("BZPOPMIN", "q1", "0.05")
+	if v.Type != resp.Array || v.Array != nil {
+		t.Fatalf("expected null array on timeout, got %s", fmtValue(v))
+	}
+}
+
+func TestZSCAN_ReturnsScores(t *testing.T) {
+	_, addr := startTestServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(2, "ZADD", "z", "1.5", "a", "-inf", "b")
+	v := c.do("ZSCAN", "z", "0", "COUNT", "100")
+	got := bulkStrings(v.Array[1])
+	if len(got) != 4 {
+		t.Fatalf("unexpected ZSCAN reply: %s", fmtValue(v))
+	}
+	scores := map[string]string{got[0]: got[1], got[2]: got[3]}
+	if scores["a"] != "1.5" || scores["b"] != "-inf" {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+}
+
+func TestZINCRBY_LogsInTheOrderItApplies(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+
+	// The second increment must not apply before the first is logged, or
+	// the log could end up with the older score last.
+	var score float64
+	cmds := [][]string{{"ZINCRBY", "z", "1", "m"}, {"ZADD", "z", "INCR", "1", "m"}}
+	sendWhileLogStalled(t, s, addr, cmds, func() {
+		score, _, _ = s.dbs.DB(0).ZScore("z", "m")
+	})
+	if score != 1 {
+		t.Fatalf("second increment applied while the first was not logged yet: score %v", score)
+	}
+	_ = s.Close()
+
+	if score, _, _ := replayInto(t, path).ZScore("z", "m"); score != 2 {
+		t.Fatalf("expected score 2 after replay, got %v", score)
+	}
+}
+
+func TestSortedSet_ScoresRoundTripThroughAOF(t *testing.T) {
+	s, addr, path := startAOFServer(t)
+	c := dialTest(t, addr)
+
+	c.mustInt(4, "ZADD", "z", "0.1", "a", "1e-300", "tiny", "inf", "top", "123456789.123456789", "big")
+	c.mustBulk("0.30000000000000004", "ZINCRBY", "z", "0.2", "a")
+	c.mustInt(1, "ZADD", "z", "-0.5", "neg")
+	c.mustStrings([]string{"neg", "-0.5"}, "ZPOPMIN", "z")
+	c.mustInt(4, "ZINTERSTORE", "copy", "1", "z", "WEIGHTS", "3")
+	c.mustStrings([]string{"copy", "top", "inf"}, "BZPOPMAX", "copy", "0")
+	_ = s.Close()
+
+	all := store.ZRangeSpec{Start: 0, Stop: -1, Count: -1}
+	want := make(map[string][]store.ScoreMember)
+	for _, key := range []string{"z", "copy"} {
+		want[key], _ = s.dbs.DB(0).ZRange(key, all)
+	}
+	rewriteAndReplay(t, path, func(dbs *store.Databases, stage string) {
+		for key, members := range want {
+			got, _ := dbs.DB(0).ZRange(key, all)
+			if len(got) != len(members) {
+				t.Fatalf("%s: %s: expected %d members, got %d", stage, key, len(members), len(got))
+			}
+			for i := range members {
+				if got[i] != members[i] {
+					t.Fatalf("%s: %s member %d: got %+v, want %+v", stage, key, i, got[i], members[i])
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDatabases is the number of databases a server exposes unless
+// configured otherwise (Redis' "databases" default).
+const DefaultDatabases = 16
+
+// Databases is the fixed set of numbered databases clients switch between
+// with SELECT. Each database is an independent Store; operations spanning
+// two of them (SWAPDB, MOVE, COPY) are serialized by mu and lock the shards
+// they need in database index order, so they cannot deadlock with each
+// other or with commands on a single database.
+type Databases struct {
+	mu  sync.Mutex
+	dbs []*Store
+
+	cycle expireCycle // active expiry state, see reaper.go
+}
+
+// NewDatabases returns n empty databases (at least one).
+func NewDatabases(n int) *Databases {
+	stores := make([]*Store, max(n, 1))
+	for i := range stores {
+		stores[i] = New()
+	}
+	return &Databases{dbs: stores}
+}
+
+// NewDatabasesFrom uses the given stores as databases 0..len(stores)-1.
+func NewDatabasesFrom(stores ...*Store) *Databases {
+	return &Databases{dbs: append([]*Store(nil), stores...)}
+}
+
+// Len returns the number of databases.
+func (d *Databases) Len() int { return len(d.dbs) }
+
+// DB returns database i. The returned store stays valid for the lifetime of
+// d; SWAPDB exchanges contents, not stores.
+func (d *Databases) DB(i int) *Store { return d.dbs[i] }
+
+// lockPair write-locks the shard of key i in database dbI and the shard of
+// key j in database dbJ (dbI != dbJ), lower database first.
+func (d *Databases) lockPair(dbI int, i string, dbJ int, j string) func() {
+	if dbI > dbJ {
+		dbI, i, dbJ, j = dbJ, j, dbI, i
+	}
+	unlockI := d.dbs[dbI].lock(i)
+	unlockJ := d.dbs[dbJ].lock(j)
+	return func() {
+		unlockJ()
+		unlockI()
+	}
+}
+
+// Swap exchanges the contents of databases i and j, so clients connected
+// to one immediately see the data of the other.
+func (d *Databases) Swap(i, j int) {
+	if i == j {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a, b := d.dbs[min(i, j)], d.dbs[max(i, j)]
+	defer a.lockAll()()
+	defer b.lockAll()()
+
+	// Keys hash to the same shard in every store, so shards can be
+	// exchanged one by one.
+	for k := range a.shards {
+		sa, sb := &a.shards[k], &b.shards[k]
+		sa.data, sb.data = sb.data, sa.data
+		sa.volatile, sb.volatile = sb.volatile, sa.volatile
+		sa.order, sb.order = sb.order, sa.order
+	}
+	used := a.used.Load()
+	a.used.Store(b.used.Load())
+	b.used.Store(used)
+}
+
+// Move moves key (with its expiry) from database src to dst. It reports
+// false if the key does not exist in src or already exists in dst.
+func (d *Databases) Move(key string, src, dst int) bool {
+	if src == dst {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.lockPair(src, key, dst, key)()
+
+	now := time.Now()
+	from, to := d.dbs[src], d.dbs[dst]
+	e, ok := from.lookupLocked(key, now)
+	if !ok {
+		return false
+	}
+	if _, exists := to.lookupLocked(key, now); exists {
+		return false
+	}
+	from.removeLocked(key)
+	to.putLocked(key, e)
+	return true
+}
+
+// Copy copies the value at src in database srcDB to dst in database dstDB,
+// expiry included. It reports false if src does not exist, or dst exists and
+// replace is not set. src and dst must not be the same key of the same
+// database.
+func (d *Databases) Copy(src, dst string, srcDB, dstDB int, replace bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if srcDB == dstDB {
+		defer d.dbs[srcDB].lockKeys(src, dst)()
+	} else {
+		defer d.lockPair(srcDB, src, dstDB, dst)()
+	}
+
+	now := time.Now()
+	from, to := d.dbs[srcDB], d.dbs[dstDB]
+	e, ok := from.lookupLocked(src, now)
+	if !ok {
+		return false
+	}
+	if _, exists := to.peekLocked(dst, now); exists && !replace {
+		return false
+	}
+	to.putLocked(dst, e.clone())
+	return true
+}
+
+// FlushAll empties every database.
+func (d *Databases) FlushAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, db := range d.dbs {
+		db.Flush()
+	}
+}
+
+// Snapshot returns the snapshots of all databases in index order, with DB
+// set on every entry. Each database is copied under its own lock.
+func (d *Databases) Snapshot() []SnapshotEntry {
+	var out []SnapshotEntry
+	for i, db := range d.dbs {
+		snap := db.Snapshot()
+		for j := range snap {
+			snap[j].DB = i
+		}
+		out = append(out, snap...)
+	}
+	return out
+}
+
+// UsedMemory returns the estimated memory held by all databases.
+func (d *Databases) UsedMemory() int64 {
+	var n int64
+	for _, db := range d.dbs {
+		n += db.UsedMemory()
+	}
+	return n
+}
+
+// Evict deletes the best candidate for eviction under p among
+// EvictionSamples keys sampled from every database, and returns the
+// database and key it removed. key is empty if the chosen key went away
+// before it could be removed. It returns false when no key is eligible
+// (always under NoEviction).
+func (d *Databases) Evict(p EvictionPolicy) (db int, key string, ok bool) {
+	if p == NoEviction {
+		return 0, "", false
+	}
+
+	now := time.Now()
+	var best evictionCandidate
+	db = -1
+	for i, st := range d.dbs {
+		for _, c := range st.sample(p, EvictionSamples, now) {
+			if db < 0 || c.score > best.score {
+				db, best = i, c
+			}
+		}
+	}
+	if db < 0 {
+		return 0, "", false
+	}
+
+	st := d.dbs[db]
+	defer st.lock(best.key)()
+	if _, ok := st.peekLocked(best.key, now); !ok {
+		// Deleted or expired since it was sampled: memory went down anyway.
+		return db, "", true
+	}
+	st.removeLocked(best.key)
+	return db, best.key, true
+}
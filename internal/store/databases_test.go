@@ -0,0 +1,102 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabases_Swap(t *testing.T) {
+	d := NewDatabases(3)
+	db0, db1 := d.DB(0), d.DB(1)
+	db0.Set("a", []byte("0"))
+	db1.Set("b", []byte("1"))
+
+	d.Swap(0, 1)
+	if d.DB(0) != db0 || d.DB(1) != db1 {
+		t.Fatalf("SWAPDB must keep the store handles")
+	}
+	if db0.Exists("a") || !db0.Exists("b") || !db1.Exists("a") {
+		t.Fatalf("expected contents to be exchanged")
+	}
+}
+
+func TestDatabases_Move(t *testing.T) {
+	d := NewDatabases(2)
+	src, dst := d.DB(0), d.DB(1)
+	src.Set("k", []byte("v"))
+	src.Expire("k", 100)
+
+	if !d.Move("k", 0, 1) {
+		t.Fatalf("expected move to succeed")
+	}
+	if src.Exists("k") {
+		t.Fatalf("expected key to leave the source")
+	}
+	if v, _ := dst.Get("k"); string(v) != "v" || dst.TTL("k") < 99 {
+		t.Fatalf("expected value and ttl in the destination")
+	}
+
+	src.Set("k", []byte("other"))
+	if d.Move("k", 0, 1) {
+		t.Fatalf("expected move to fail when the destination has the key")
+	}
+	if d.Move("missing", 0, 1) {
+		t.Fatalf("expected move of a missing key to fail")
+	}
+
+	src.Set("old", []byte("v"))
+	src.PExpireAt("old", time.Now().Add(-time.Second).UnixMilli(), ExpireOptions{})
+	if d.Move("old", 0, 1) {
+		t.Fatalf("expected move of an expired key to fail")
+	}
+}
+
+func TestDatabases_FlushAllAndSnapshot(t *testing.T) {
+	d := NewDatabases(3)
+	d.DB(0).Set("a", []byte("1"))
+	d.DB(2).Set("b", []byte("2"))
+
+	snap := d.Snapshot()
+	if len(snap) != 2 || snap[0].DB != 0 || snap[1].DB != 2 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+
+	d.DB(1).Flush()
+	if d.DB(0).Len() != 1 {
+		t.Fatalf("FLUSHDB must only empty its own database")
+	}
+	d.FlushAll()
+	for i := 0; i < d.Len(); i++ {
+		if n := d.DB(i).Len(); n != 0 {
+			t.Fatalf("expected db %d to be empty, got %d keys", i, n)
+		}
+	}
+}
+
+func TestDatabases_Copy(t *testing.T) {
+	d := NewDatabases(2)
+	src := d.DB(0)
+	_, _ = src.HSet("h", []FieldValue{{"f", []byte("v")}})
+	src.Expire("h", 100)
+
+	if !d.Copy("h", "h2", 0, 0, false) {
+		t.Fatalf("expected copy to succeed")
+	}
+	_, _ = src.HSet("h", []FieldValue{{"f", []byte("changed")}})
+	if v, _, _ := src.HGet("h2", "f"); string(v) != "v" || src.TTL("h2") < 99 {
+		t.Fatalf("expected an independent copy with the ttl, got %q", v)
+	}
+
+	if !d.Copy("h", "h", 0, 1, false) || !d.DB(1).Exists("h") {
+		t.Fatalf("expected copy to another database")
+	}
+	if d.Copy("h", "h", 0, 1, false) {
+		t.Fatalf("expected copy without replace to fail when the destination exists")
+	}
+	if !d.Copy("h", "h", 0, 1, true) {
+		t.Fatalf("expected copy with replace to succeed")
+	}
+	if d.Copy("missing", "x", 0, 1, true) {
+		t.Fatalf("expected copy of a missing key to fail")
+	}
+}
@@ -0,0 +1,66 @@
+package store
+
+import "errors"
+
+// Errors returned by typed operations. The server maps them to Redis replies.
+var (
+	// ErrWrongType is returned when an operation hits a key holding a different data type.
+	ErrWrongType = errors.New("operation against a key holding the wrong kind of value")
+
+	// ErrHashNotInteger is returned by HINCRBY when the field does not hold an integer.
+	ErrHashNotInteger = errors.New("hash value is not an integer")
+
+	// ErrHashNotFloat is returned by HINCRBYFLOAT when the field does not hold a float.
+	ErrHashNotFloat = errors.New("hash value is not a float")
+
+	// ErrNotInteger is returned by INCR and friends when the value is not an integer.
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+
+	// ErrNotFloat is returned by INCRBYFLOAT when the value is not a float.
+	ErrNotFloat = errors.New("value is not a valid float")
+
+	// ErrOverflow is returned when an increment would overflow int64.
+	ErrOverflow = errors.New("increment or decrement would overflow")
+
+	// ErrNoSuchKey is returned by operations that require an existing key (LSET).
+	ErrNoSuchKey = errors.New("no such key")
+
+	// ErrIndexOutOfRange is returned by LSET for an index outside the list.
+	ErrIndexOutOfRange = errors.New("index out of range")
+
+	// ErrNaN is returned when a float increment would produce NaN or Infinity.
+	ErrNaN = errors.New("increment would produce NaN or Infinity")
+
+	// ErrStringTooLong is returned when APPEND or SETRANGE would exceed MaxStringLen.
+	ErrStringTooLong = errors.New("string exceeds maximum allowed size (proto-max-bulk-len)")
+
+	// ErrLCSTooLarge is returned when LCS would need too much temporary memory.
+	ErrLCSTooLarge = errors.New("Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+
+	// ErrStreamIDTooSmall is returned by XADD for an ID not greater than the stream's last one.
+	ErrStreamIDTooSmall = errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+
+	// ErrStreamIDZero is returned by XADD for the ID 0-0.
+	ErrStreamIDZero = errors.New("The ID specified in XADD must be greater than 0-0")
+
+	// ErrStreamExhausted is returned by XADD once the stream's last ID is the largest possible.
+	ErrStreamExhausted = errors.New("The stream has exhausted the last possible ID, unable to add more items")
+
+	// ErrXSetIDTooSmall is returned by XSETID for an ID smaller than the stream's last entry.
+	ErrXSetIDTooSmall = errors.New("The ID specified in XSETID is smaller than the target stream top item")
+
+	// ErrEntriesAddedTooSmall is returned by XSETID for an entries-added count below the stream's length.
+	ErrEntriesAddedTooSmall = errors.New("The entries_added specified in XSETID is smaller than the target stream length")
+
+	// ErrMaxDeletedTooLarge is returned by XSETID for a max deleted ID above the last ID.
+	ErrMaxDeletedTooLarge = errors.New("The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+
+	// ErrNoGroup is returned by consumer group operations when the key or the group does not exist.
+	ErrNoGroup = errors.New("NOGROUP No such key or consumer group")
+
+	// ErrBusyGroup is returned by XGROUP CREATE for a group that already exists.
+	ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+
+	// ErrScoreNaN is returned when a sorted set score increment would produce NaN.
+	ErrScoreNaN = errors.New("resulting score is not a number (NaN)")
+)
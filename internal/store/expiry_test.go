@@ -90,3 +90,78 @@ func TestExpireAtFutureExpiresEventually(t *testing.T) {
 		t.Fatalf("expected key to be expired after waiting")
 	}
 }
+
+func TestPExpireAt_MillisecondPrecision(t *testing.T) {
+	s := New()
+	s.Set("k", []byte("v"))
+	at := time.Now().Add(1500 * time.Millisecond).UnixMilli()
+	if !s.PExpireAt("k", at, ExpireOptions{}) {
+		t.Fatal("expected PExpireAt to succeed")
+	}
+	if got := s.ExpireTimeMs("k"); got != at {
+		t.Fatalf("expected expire time %d, got %d", at, got)
+	}
+	if pttl := s.PTTL("k"); pttl <= 1400 || pttl > 1500 {
+		t.Fatalf("expected pttl ~1500, got %d", pttl)
+	}
+	if ttl := s.TTL("k"); ttl != 2 {
+		t.Fatalf("expected ttl rounded up to 2, got %d", ttl)
+	}
+
+	snap := s.Snapshot()
+	if len(snap) != 1 || snap[0].ExpiresAt == nil || *snap[0].ExpiresAt != at {
+		t.Fatalf("expected snapshot to carry %d, got %+v", at, snap)
+	}
+}
+
+func TestPExpireAt_Conditions(t *testing.T) {
+	s := New()
+	s.Set("k", []byte("v"))
+	now := time.Now().UnixMilli()
+
+	if s.PExpireAt("k", now+10000, ExpireOptions{XX: true}) {
+		t.Fatal("XX must fail without an expiry")
+	}
+	if s.PExpireAt("k", now+10000, ExpireOptions{GT: true}) {
+		t.Fatal("GT must fail without an expiry (no expiry is infinite)")
+	}
+	if !s.PExpireAt("k", now+10000, ExpireOptions{LT: true}) {
+		t.Fatal("LT must succeed without an expiry")
+	}
+	if s.PExpireAt("k", now+20000, ExpireOptions{NX: true}) {
+		t.Fatal("NX must fail with an expiry")
+	}
+	if s.PExpireAt("k", now+5000, ExpireOptions{GT: true}) {
+		t.Fatal("GT must not shorten the expiry")
+	}
+	if !s.PExpireAt("k", now+20000, ExpireOptions{XX: true, GT: true}) {
+		t.Fatal("XX GT should extend the expiry")
+	}
+	if got := s.ExpireTimeMs("k"); got != now+20000 {
+		t.Fatalf("expected %d, got %d", now+20000, got)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	s := New()
+	if s.Persist("missing") {
+		t.Fatal("expected Persist on a missing key to fail")
+	}
+	s.Set("k", []byte("v"))
+	if s.Persist("k") {
+		t.Fatal("expected Persist without an expiry to fail")
+	}
+	s.Expire("k", 10)
+	if !s.Persist("k") {
+		t.Fatal("expected Persist to remove the expiry")
+	}
+	if ttl := s.TTL("k"); ttl != -1 {
+		t.Fatalf("expected ttl -1, got %d", ttl)
+	}
+	if got := s.ExpireTimeMs("k"); got != -1 {
+		t.Fatalf("expected -1, got %d", got)
+	}
+	if got := s.ExpireTimeMs("missing"); got != -2 {
+		t.Fatalf("expected -2, got %d", got)
+	}
+}
@@ -0,0 +1,286 @@
+package store
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// FieldValue is a single hash field and its value.
+type FieldValue struct {
+	Field string
+	Value []byte
+}
+
+// hashLocked returns the hash at key. If create is true, a missing key is
+// created as an empty hash (not yet stored). Caller must hold the lock of
+// key's shard.
+func (s *Store) hashLocked(key string, create bool) (*dict[[]byte], bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		if !create {
+			return nil, false, nil
+		}
+		h := newDict[[]byte]()
+		e := newEntry(KindHash)
+		e.hash = h
+		s.putLocked(key, e)
+		return h, true, nil
+	}
+	if e.kind != KindHash {
+		return nil, false, ErrWrongType
+	}
+	return e.hash, true, nil
+}
+
+// HSet sets the given fields and returns the number of fields that were added
+// (as opposed to updated).
+func (s *Store) HSet(key string, pairs []FieldValue) (int, error) {
+	defer s.lock(key)()
+
+	h, _, err := s.hashLocked(key, true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, p := range pairs {
+		if s.hsetLocked(key, h, p.Field, copyBytes(p.Value)) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// HSetNX sets field only if it does not exist yet. Returns true if it was set.
+func (s *Store) HSetNX(key, field string, val []byte) (bool, error) {
+	defer s.lock(key)()
+
+	h, _, err := s.hashLocked(key, true)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := h.m[field]; exists {
+		return false, nil
+	}
+	s.hsetLocked(key, h, field, copyBytes(val))
+	return true, nil
+}
+
+// HGet returns the value of field in the hash at key.
+func (s *Store) HGet(key, field string) ([]byte, bool, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	v, ok := h.m[field]
+	if !ok {
+		return nil, false, nil
+	}
+	return copyBytes(v), true, nil
+}
+
+// HMGet returns the values of fields; missing fields are nil.
+func (s *Store) HMGet(key string, fields []string) ([][]byte, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(fields))
+	if !ok {
+		return out, nil
+	}
+	for i, f := range fields {
+		if v, ok := h.m[f]; ok {
+			out[i] = copyBytes(v)
+		}
+	}
+	return out, nil
+}
+
+// HDel removes fields and returns how many were removed. The key is deleted
+// once the hash becomes empty.
+func (s *Store) HDel(key string, fields []string) (int, error) {
+	defer s.lock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range fields {
+		if v, exists := h.m[f]; exists {
+			h.del(f)
+			s.growLocked(key, -hashFieldSize(f, v))
+			removed++
+		}
+	}
+	if h.len() == 0 {
+		s.removeLocked(key)
+	}
+	return removed, nil
+}
+
+// HExists reports whether field exists in the hash at key.
+func (s *Store) HExists(key, field string) (bool, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, ok = h.m[field]
+	return ok, nil
+}
+
+// HLen returns the number of fields in the hash at key.
+func (s *Store) HLen(key string) (int, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return h.len(), nil
+}
+
+// HGetAll returns all fields and values of the hash at key.
+func (s *Store) HGetAll(key string) ([]FieldValue, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return []FieldValue{}, err
+	}
+	out := make([]FieldValue, 0, h.len())
+	for f, v := range h.m {
+		out = append(out, FieldValue{Field: f, Value: copyBytes(v)})
+	}
+	return out, nil
+}
+
+// HIncrBy adds delta to the integer stored in field and returns the new value.
+func (s *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	defer s.lock(key)()
+
+	h, _, err := s.hashLocked(key, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur int64
+	if v, ok := h.m[field]; ok {
+		cur, err = strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			s.dropIfEmptyHashLocked(key, h)
+			return 0, ErrHashNotInteger
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		s.dropIfEmptyHashLocked(key, h)
+		return 0, ErrOverflow
+	}
+
+	cur += delta
+	s.hsetLocked(key, h, field, []byte(strconv.FormatInt(cur, 10)))
+	return cur, nil
+}
+
+// HIncrByFloat adds delta to the float stored in field and returns the new
+// value formatted the way it is stored.
+func (s *Store) HIncrByFloat(key, field string, delta float64) (string, error) {
+	defer s.lock(key)()
+
+	h, _, err := s.hashLocked(key, true)
+	if err != nil {
+		return "", err
+	}
+
+	var cur float64
+	if v, ok := h.m[field]; ok {
+		cur, err = strconv.ParseFloat(string(v), 64)
+		if err != nil || math.IsNaN(cur) || math.IsInf(cur, 0) {
+			s.dropIfEmptyHashLocked(key, h)
+			return "", ErrHashNotFloat
+		}
+	}
+
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		s.dropIfEmptyHashLocked(key, h)
+		return "", ErrNaN
+	}
+
+	out := FormatFloat(cur)
+	s.hsetLocked(key, h, field, []byte(out))
+	return out, nil
+}
+
+// HScan iterates the hash at key, about count fields per call. See scanPos
+// for the cursor guarantees.
+func (s *Store) HScan(key string, cursor uint64, count int) ([]FieldValue, uint64, error) {
+	defer s.rlock(key)()
+
+	h, ok, err := s.hashLocked(key, false)
+	if err != nil || !ok {
+		return []FieldValue{}, 0, err
+	}
+	var out []FieldValue
+	next := h.scan(cursor, count, func(f string, v []byte) {
+		out = append(out, FieldValue{Field: f, Value: copyBytes(v)})
+	})
+	return out, next, nil
+}
+
+// hsetLocked sets field of the hash h stored at key, keeping the memory
+// estimate in sync, and reports whether the field is new.
+func (s *Store) hsetLocked(key string, h *dict[[]byte], field string, val []byte) bool {
+	old, exists := h.m[field]
+	if exists {
+		s.growLocked(key, int64(len(val)-len(old)))
+	} else {
+		s.growLocked(key, hashFieldSize(field, val))
+	}
+	return h.put(field, val)
+}
+
+// dropIfEmptyHashLocked removes a hash that was created for a failed write.
+func (s *Store) dropIfEmptyHashLocked(key string, h *dict[[]byte]) {
+	if h.len() == 0 {
+		s.removeLocked(key)
+	}
+}
+
+// FormatFloat formats f the way float results are replied and stored: the
+// shortest representation that parses back to exactly f, without an
+// exponent for ordinary magnitudes. Infinities are "inf" and "-inf".
+func FormatFloat(f float64) string {
+	if math.IsInf(f, 0) {
+		if f > 0 {
+			return "inf"
+		}
+		return "-inf"
+	}
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func copyBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+func copyHash(h map[string][]byte) map[string][]byte {
+	out := make(map[string][]byte, len(h))
+	for f, v := range h {
+		out[f] = copyBytes(v)
+	}
+	return out
+}
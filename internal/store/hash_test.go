@@ -0,0 +1,127 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestHSet_CountsOnlyNewFields(t *testing.T) {
+	s := New()
+	n, err := s.HSet("h", []FieldValue{{"a", []byte("1")}, {"b", []byte("2")}})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 added, got n=%d err=%v", n, err)
+	}
+	n, err = s.HSet("h", []FieldValue{{"a", []byte("x")}, {"c", []byte("3")}})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 added, got n=%d err=%v", n, err)
+	}
+	if v, ok, _ := s.HGet("h", "a"); !ok || string(v) != "x" {
+		t.Fatalf("expected a=x, got ok=%v v=%q", ok, v)
+	}
+}
+
+func TestHash_WrongTypeAgainstString(t *testing.T) {
+	s := New()
+	s.Set("k", []byte("v"))
+	if _, err := s.HSet("k", []FieldValue{{"f", []byte("v")}}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := s.HGet("k", "f"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+
+	_, _ = s.HSet("h", []FieldValue{{"f", []byte("v")}})
+	if _, _, err := s.GetString("h"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType from GetString on hash, got %v", err)
+	}
+}
+
+func TestHDel_RemovesKeyWhenEmpty(t *testing.T) {
+	s := New()
+	_, _ = s.HSet("h", []FieldValue{{"a", []byte("1")}})
+	n, err := s.HDel("h", []string{"a", "missing"})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 removed, got n=%d err=%v", n, err)
+	}
+	if s.Exists("h") {
+		t.Fatal("expected empty hash to be removed")
+	}
+}
+
+func TestHIncrBy_ErrorsAndOverflow(t *testing.T) {
+	s := New()
+	if n, err := s.HIncrBy("h", "n", 5); err != nil || n != 5 {
+		t.Fatalf("expected 5, got n=%d err=%v", n, err)
+	}
+	if _, err := s.HIncrBy("h", "n", 1<<63-1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow, got %v", err)
+	}
+
+	_, _ = s.HSet("h", []FieldValue{{"s", []byte("abc")}})
+	if _, err := s.HIncrBy("h", "s", 1); !errors.Is(err, ErrHashNotInteger) {
+		t.Fatalf("expected ErrHashNotInteger, got %v", err)
+	}
+
+	if _, err := s.HIncrBy("fresh", "s", 1<<63-1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.HIncrByFloat("bad", "f", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHIncrByFloat_FormatsShortest(t *testing.T) {
+	s := New()
+	_, _ = s.HSet("h", []FieldValue{{"f", []byte("10.5")}})
+	v, err := s.HIncrByFloat("h", "f", 0.1)
+	if err != nil || v != "10.6" {
+		t.Fatalf("expected 10.6, got %q err=%v", v, err)
+	}
+	v, _ = s.HIncrByFloat("h", "f", -0.6)
+	if v != "10" {
+		t.Fatalf("expected 10, got %q", v)
+	}
+}
+
+func TestHScan_ReturnsEveryFieldOnceAcrossGrowth(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		_, _ = s.HSet("h", []FieldValue{{Field: "f" + strconv.Itoa(i), Value: []byte("v")}})
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	round := 0
+	for {
+		batch, next, err := s.HScan("h", cursor, 7)
+		if err != nil {
+			t.Fatalf("hscan: %v", err)
+		}
+		for _, p := range batch {
+			seen[p.Field]++
+		}
+		// grow the hash while iterating
+		_, _ = s.HSet("h", []FieldValue{{Field: "new" + strconv.Itoa(round), Value: []byte("v")}})
+		round++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 100; i++ {
+		if seen["f"+strconv.Itoa(i)] != 1 {
+			t.Fatalf("field f%d seen %d times", i, seen["f"+strconv.Itoa(i)])
+		}
+	}
+}
+
+func TestSnapshot_IncludesHashes(t *testing.T) {
+	s := New()
+	_, _ = s.HSet("h", []FieldValue{{"a", []byte("1")}})
+	snap := s.Snapshot()
+	if len(snap) != 1 || snap[0].Kind != KindHash || string(snap[0].Hash["a"]) != "1" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+}
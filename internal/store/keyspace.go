@@ -0,0 +1,222 @@
+package store
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/pranavbrkr/redigo/internal/glob"
+)
+
+// Keys returns every live key matching the glob pattern, in no particular
+// order.
+func (s *Store) Keys(pattern string) []string {
+	defer s.rlockAll()()
+
+	now := time.Now()
+	out := make([]string, 0)
+	for i := range s.shards {
+		for k, e := range s.shards[i].data {
+			if isExpired(e, now) {
+				continue
+			}
+			if pattern == "*" || glob.Match(pattern, k) {
+				out = append(out, k)
+			}
+		}
+	}
+	return out
+}
+
+// A keyspace cursor names a shard in its top bits and a scan position within
+// the shard in the rest, so SCAN walks the shards one after the other.
+const (
+	scanShardShift = scanPosBits - 6 // shardCount is 64
+	scanInShard    = 1<<scanShardShift - 1
+)
+
+// Scan iterates the keyspace, visiting about count keys per call (10 if
+// count is not positive). typ, if not empty, keeps only keys of that type
+// (as reported by Kind.String); like expired keys, the others count as
+// visited. See scanPos for the cursor guarantees.
+//
+// Only the shards a call visits are read-locked, one at a time.
+func (s *Store) Scan(cursor uint64, count int, typ string) ([]string, uint64) {
+	if count <= 0 {
+		count = 10
+	}
+	now := time.Now()
+	var names []string
+	visited := 0
+	pos := (cursor & scanInShard) << (scanPosBits - scanShardShift)
+	for i := int(cursor >> scanShardShift); i < shardCount; i, pos = i+1, 0 {
+		if visited >= count {
+			return names, uint64(i) << scanShardShift
+		}
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		next := sh.order.scan(pos, count-visited, func(k string) {
+			visited++
+			if e := sh.data[k]; !isExpired(e, now) && (typ == "" || e.kind.String() == typ) {
+				names = append(names, k)
+			}
+		})
+		sh.mu.RUnlock()
+		if next != 0 {
+			return names, uint64(i)<<scanShardShift | next>>(scanPosBits-scanShardShift)
+		}
+	}
+	return names, 0
+}
+
+// Len returns the number of keys in the store (DBSIZE). Expired keys that
+// have not been reaped yet are included, like in Redis.
+func (s *Store) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		n += len(sh.data)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// Flush deletes every key. The old map is simply dropped: the garbage
+// collector reclaims it in the background, which is what FLUSHDB ASYNC asks
+// for, so SYNC and ASYNC behave the same.
+func (s *Store) Flush() {
+	defer s.lockAll()()
+
+	for i := range s.shards {
+		s.shards[i].data = make(map[string]entry)
+		s.shards[i].volatile = volatileSet{}
+		s.shards[i].order = scanOrder{}
+	}
+	s.used.Store(0)
+}
+
+// Rename moves the value at src (with its expiry) to dst, overwriting dst
+// unless nx is set. It returns ErrNoSuchKey if src does not exist and false
+// if nothing was renamed because of nx.
+func (s *Store) Rename(src, dst string, nx bool) (bool, error) {
+	defer s.lockKeys(src, dst)()
+
+	now := time.Now()
+	e, ok := s.lookupLocked(src, now)
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if _, exists := s.peekLocked(dst, now); exists && nx {
+		return false, nil
+	}
+	s.removeLocked(src)
+	s.putLocked(dst, e)
+	return true, nil
+}
+
+// Type returns the type name of the value at key ("none" if missing).
+func (s *Store) Type(key string) string {
+	defer s.rlock(key)()
+
+	e, ok := s.peekLocked(key, time.Now())
+	if !ok {
+		return "none"
+	}
+	return e.kind.String()
+}
+
+// Touch records an access to every existing key and returns how many exist.
+func (s *Store) Touch(keys []string) int {
+	defer s.rlockKeys(keys...)()
+
+	now := time.Now()
+	n := 0
+	for _, k := range keys {
+		if _, ok := s.lookupLocked(k, now); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// RandomKey returns a random live key: shards are visited from a random
+// one on, and Go's randomized map iteration order picks the key inside.
+func (s *Store) RandomKey() (string, bool) {
+	now := time.Now()
+	start := rand.IntN(shardCount)
+	for n := range shardCount {
+		if k, ok := s.shards[(start+n)%shardCount].randomKey(s, now); ok {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func (sh *shard) randomKey(s *Store, now time.Time) (string, bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	for k, e := range sh.data {
+		if isExpired(e, now) {
+			s.expireLocked(k)
+			continue
+		}
+		return k, true
+	}
+	return "", false
+}
+
+// Unlink removes keys under a single lock and returns the ones that existed.
+// Only the map entries are removed here; the values themselves are reclaimed
+// by the garbage collector concurrently, so even huge collections cost O(1)
+// on the request path.
+func (s *Store) Unlink(keys []string) []string {
+	defer s.lockKeys(keys...)()
+
+	now := time.Now()
+	var removed []string
+	for _, k := range keys {
+		if _, ok := s.peekLocked(k, now); ok {
+			s.removeLocked(k)
+			removed = append(removed, k)
+		}
+	}
+	return removed
+}
+
+// clone returns a deep copy of e with fresh access data.
+func (e entry) clone() entry {
+	out := newEntry(e.kind)
+	switch e.kind {
+	case KindString:
+		out.value = copyBytes(e.value)
+	case KindHash:
+		out.hash = dictOf(copyHash(e.hash.m))
+	case KindList:
+		out.list = newQuicklist()
+		for _, v := range e.list.all() {
+			out.list.pushBack(copyBytes(v))
+		}
+	case KindSet:
+		out.set = newDict[struct{}]()
+		for m := range e.set.m {
+			out.set.put(m, struct{}{})
+		}
+	case KindZSet:
+		out.zset = newZset()
+		for _, it := range e.zset.all() {
+			out.zset.set(it.Member, it.Score)
+		}
+	case KindStream:
+		out.stream = e.stream.clone()
+	}
+	if e.expiresAt != nil {
+		exp := *e.expiresAt
+		out.expiresAt = &exp
+	}
+	out.size = e.size
+	return out
+}
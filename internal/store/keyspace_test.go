@@ -0,0 +1,204 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	s := New()
+	for _, k := range []string{"hello", "hallo", "hxllo", "heeeello", "world"} {
+		s.Set(k, []byte("v"))
+	}
+	s.shard("hillo").data["hillo"] = entry{kind: KindString, value: []byte("v"), expiresAt: ptrTime(time.Now().Add(-time.Second))}
+
+	got := s.Keys("h?llo")
+	sort.Strings(got)
+	if want := []string{"hallo", "hello", "hxllo"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := s.Keys("*"); len(got) != 5 {
+		t.Fatalf("expected 5 live keys, got %v", got)
+	}
+}
+
+func TestScan_StableWhileGrowing(t *testing.T) {
+	s := New()
+	for i := 0; i < 200; i++ {
+		s.Set("orig:"+strconv.Itoa(i), []byte("v"))
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	added := 0
+	for {
+		batch, next := s.Scan(cursor, 10, "")
+		for _, k := range batch {
+			seen[k]++
+		}
+		// Grow the map between calls.
+		for i := 0; i < 20; i++ {
+			s.Set("new:"+strconv.Itoa(added), []byte("v"))
+			added++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 200; i++ {
+		k := "orig:" + strconv.Itoa(i)
+		if seen[k] != 1 {
+			t.Fatalf("key %q returned %d times", k, seen[k])
+		}
+	}
+}
+
+func TestScan_CallsStayNearCount(t *testing.T) {
+	s := New()
+	for i := 0; i < 20000; i++ {
+		s.Set("k:"+strconv.Itoa(i), []byte("v"))
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	calls := 0
+	for {
+		batch, next := s.Scan(cursor, 20, "")
+		if len(batch) > 40 {
+			t.Fatalf("call %d returned %d keys for COUNT 20", calls, len(batch))
+		}
+		for _, k := range batch {
+			seen[k]++
+		}
+		calls++
+		// Shrink the keyspace while iterating; the keys that stay must
+		// still come back exactly once.
+		if calls == 10 {
+			for i := 0; i < 20000; i += 2 {
+				s.Del("k:" + strconv.Itoa(i))
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 1; i < 20000; i += 2 {
+		if k := "k:" + strconv.Itoa(i); seen[k] != 1 {
+			t.Fatalf("key %q returned %d times", k, seen[k])
+		}
+	}
+}
+
+func TestScan_TypeFilter(t *testing.T) {
+	s := New()
+	s.Set("str", []byte("v"))
+	_, _ = s.HSet("hash", []FieldValue{{"f", []byte("v")}})
+	_, _ = s.SAdd("set", []string{"m"})
+
+	keys, next := s.Scan(0, 100, "hash")
+	if next != 0 || !slices.Equal(keys, []string{"hash"}) {
+		t.Fatalf("expected [hash], got %v (next %d)", keys, next)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
+
+func TestRename(t *testing.T) {
+	s := New()
+	s.Set("a", []byte("1"))
+	s.Expire("a", 100)
+
+	if ok, err := s.Rename("a", "b", false); !ok || err != nil {
+		t.Fatalf("expected rename to succeed, got %v %v", ok, err)
+	}
+	if s.Exists("a") || s.TTL("b") < 99 {
+		t.Fatalf("expected b to take a's value and ttl")
+	}
+	if _, err := s.Rename("a", "b", false); !errors.Is(err, ErrNoSuchKey) {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+
+	s.Set("c", []byte("3"))
+	if ok, _ := s.Rename("c", "b", true); ok {
+		t.Fatalf("RENAMENX must not overwrite")
+	}
+	if ok, _ := s.Rename("c", "c", true); ok {
+		t.Fatalf("RENAMENX onto itself reports 0")
+	}
+}
+
+func TestTypeTouchRandomKeyUnlink(t *testing.T) {
+	s := New()
+	if got := s.Type("missing"); got != "none" {
+		t.Fatalf("expected none, got %q", got)
+	}
+	if _, ok := s.RandomKey(); ok {
+		t.Fatalf("expected no random key in an empty store")
+	}
+
+	s.Set("str", []byte("v"))
+	_, _, _ = s.ZAdd("z", ZAddOptions{}, []ScoreMember{{Member: "m", Score: 1}})
+	if got := s.Type("z"); got != "zset" {
+		t.Fatalf("expected zset, got %q", got)
+	}
+	if n := s.Touch([]string{"str", "z", "missing"}); n != 2 {
+		t.Fatalf("expected 2 touched, got %d", n)
+	}
+	if k, ok := s.RandomKey(); !ok || (k != "str" && k != "z") {
+		t.Fatalf("unexpected random key %q", k)
+	}
+
+	removed := s.Unlink([]string{"str", "missing", "z"})
+	if !slices.Equal(removed, []string{"str", "z"}) || s.Len() != 0 {
+		t.Fatalf("unexpected unlink result %v", removed)
+	}
+}
+
+func TestObject(t *testing.T) {
+	s := New()
+	s.Set("int", []byte("12345"))
+	s.Set("short", []byte("hello"))
+	s.Set("long", []byte(strings.Repeat("x", 45)))
+	_, _ = s.RPush("l", [][]byte{[]byte("a")})
+
+	for key, want := range map[string]string{"int": "int", "short": "embstr", "long": "raw", "l": "quicklist"} {
+		if info, _ := s.Object(key); info.Encoding != want {
+			t.Fatalf("%s: expected %s, got %s", key, want, info.Encoding)
+		}
+	}
+
+	// Backdate the last access: OBJECT reports it without resetting it.
+	s.shard("short").data["short"].access.lru.Store(time.Now().Add(-3 * time.Minute).UnixMilli())
+	for i := 0; i < 2; i++ {
+		info, _ := s.Object("short")
+		if info.Idle < 3*time.Minute || info.Freq != lfuInitVal-3 {
+			t.Fatalf("unexpected idle %v / freq %d", info.Idle, info.Freq)
+		}
+	}
+	s.Get("short")
+	if info, _ := s.Object("short"); info.Idle > time.Second {
+		t.Fatalf("expected an access to reset the idle time, got %v", info.Idle)
+	}
+	if _, ok := s.Object("missing"); ok {
+		t.Fatalf("expected no info for a missing key")
+	}
+}
+
+func TestLFUCounterIsLogarithmic(t *testing.T) {
+	c := uint8(lfuInitVal)
+	for i := 0; i < 1000; i++ {
+		c = lfuLogIncr(c)
+	}
+	if c <= lfuInitVal || c > 40 {
+		t.Fatalf("expected a slowly growing counter after 1000 hits, got %d", c)
+	}
+}
@@ -0,0 +1,382 @@
+package store
+
+import (
+	"bytes"
+	"time"
+)
+
+// listLocked returns the list at key. If create is true, a missing key is
+// created as an empty list (the caller must make sure it doesn't stay empty).
+// Caller must hold the lock of key's shard.
+func (s *Store) listLocked(key string, create bool) (*quicklist, bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		if !create {
+			return nil, false, nil
+		}
+		l := newQuicklist()
+		e := newEntry(KindList)
+		e.list = l
+		s.putLocked(key, e)
+		return l, true, nil
+	}
+	if e.kind != KindList {
+		return nil, false, ErrWrongType
+	}
+	return e.list, true, nil
+}
+
+// dropIfEmptyListLocked deletes key once its list has no elements left.
+func (s *Store) dropIfEmptyListLocked(key string, l *quicklist) {
+	if l.len() == 0 {
+		s.removeLocked(key)
+	}
+}
+
+// LPush prepends vals (one at a time, so the last ends up first) and returns
+// the new length.
+func (s *Store) LPush(key string, vals [][]byte) (int, error) {
+	return s.push(key, vals, true, false)
+}
+
+// RPush appends vals and returns the new length.
+func (s *Store) RPush(key string, vals [][]byte) (int, error) {
+	return s.push(key, vals, false, false)
+}
+
+// LPushX is LPush that only acts on an existing list; returns 0 otherwise.
+func (s *Store) LPushX(key string, vals [][]byte) (int, error) {
+	return s.push(key, vals, true, true)
+}
+
+// RPushX is RPush that only acts on an existing list; returns 0 otherwise.
+func (s *Store) RPushX(key string, vals [][]byte) (int, error) {
+	return s.push(key, vals, false, true)
+}
+
+func (s *Store) push(key string, vals [][]byte, left, onlyExisting bool) (int, error) {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, !onlyExisting)
+	if err != nil || !ok {
+		return 0, err
+	}
+	before := l.bytes
+	for _, v := range vals {
+		if left {
+			l.pushFront(copyBytes(v))
+		} else {
+			l.pushBack(copyBytes(v))
+		}
+	}
+	s.growLocked(key, l.bytes-before)
+	return l.len(), nil
+}
+
+// LPop removes and returns up to count elements from the head.
+// It returns nil when the key does not exist.
+func (s *Store) LPop(key string, count int) ([][]byte, error) {
+	return s.pop(key, count, true)
+}
+
+// RPop removes and returns up to count elements from the tail.
+func (s *Store) RPop(key string, count int) ([][]byte, error) {
+	return s.pop(key, count, false)
+}
+
+func (s *Store) pop(key string, count int, left bool) ([][]byte, error) {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	before := l.bytes
+	out := make([][]byte, 0, min(count, l.len()))
+	for len(out) < count {
+		var v []byte
+		var ok bool
+		if left {
+			v, ok = l.popFront()
+		} else {
+			v, ok = l.popBack()
+		}
+		if !ok {
+			break
+		}
+		out = append(out, v)
+	}
+	s.growLocked(key, l.bytes-before)
+	s.dropIfEmptyListLocked(key, l)
+	return out, nil
+}
+
+// LLen returns the length of the list at key.
+func (s *Store) LLen(key string) (int, error) {
+	defer s.rlock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return l.len(), nil
+}
+
+// LRange returns elements start..stop (inclusive, negative counts from the end).
+func (s *Store) LRange(key string, start, stop int) ([][]byte, error) {
+	defer s.rlock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+	start, stop, ok = normalizeRange(start, stop, l.len())
+	if !ok {
+		return [][]byte{}, nil
+	}
+	return copyItems(l.rangeItems(start, stop)), nil
+}
+
+// LIndex returns the element at index (negative counts from the end).
+func (s *Store) LIndex(key string, index int) ([]byte, bool, error) {
+	defer s.rlock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if index < 0 {
+		index += l.len()
+	}
+	v, ok := l.index(index)
+	if !ok {
+		return nil, false, nil
+	}
+	return copyBytes(v), true, nil
+}
+
+// LSet replaces the element at index.
+func (s *Store) LSet(key string, index int, val []byte) error {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoSuchKey
+	}
+	if index < 0 {
+		index += l.len()
+	}
+	before := l.bytes
+	if !l.set(index, copyBytes(val)) {
+		return ErrIndexOutOfRange
+	}
+	s.growLocked(key, l.bytes-before)
+	return nil
+}
+
+// LRem removes occurrences of val: the first count from the head if count > 0,
+// the last -count from the tail if count < 0, all of them if count == 0.
+func (s *Store) LRem(key string, count int, val []byte) (int, error) {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	drop := make(map[int]struct{})
+	visit := func(i int, v []byte) bool {
+		if bytes.Equal(v, val) {
+			drop[i] = struct{}{}
+		}
+		return limit == 0 || len(drop) < limit
+	}
+	if count < 0 {
+		l.eachReverse(visit)
+	} else {
+		l.each(visit)
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+
+	kept := make([][]byte, 0, l.len()-len(drop))
+	l.each(func(i int, v []byte) bool {
+		if _, gone := drop[i]; !gone {
+			kept = append(kept, v)
+		}
+		return true
+	})
+	before := l.bytes
+	l.reset(kept)
+	s.growLocked(key, l.bytes-before)
+	s.dropIfEmptyListLocked(key, l)
+	return len(drop), nil
+}
+
+// LTrim keeps only elements start..stop (inclusive).
+func (s *Store) LTrim(key string, start, stop int) error {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return err
+	}
+
+	start, stop, ok = normalizeRange(start, stop, l.len())
+	if !ok {
+		s.removeLocked(key)
+		return nil
+	}
+	before := l.bytes
+	for i := l.len() - 1; i > stop; i-- {
+		l.popBack()
+	}
+	for i := 0; i < start; i++ {
+		l.popFront()
+	}
+	s.growLocked(key, l.bytes-before)
+	s.dropIfEmptyListLocked(key, l)
+	return nil
+}
+
+// LInsert inserts val before or after the first occurrence of pivot.
+// Returns the new length, -1 if pivot was not found, 0 if key does not exist.
+func (s *Store) LInsert(key string, before bool, pivot, val []byte) (int, error) {
+	defer s.lock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	pos := -1
+	l.each(func(i int, v []byte) bool {
+		if bytes.Equal(v, pivot) {
+			pos = i
+			return false
+		}
+		return true
+	})
+	if pos < 0 {
+		return -1, nil
+	}
+	if !before {
+		pos++
+	}
+	l.insertAt(pos, copyBytes(val))
+	s.growLocked(key, listItemSize(val))
+	return l.len(), nil
+}
+
+// LPos returns the indexes of elements equal to val.
+//
+// rank selects which match to start from (1 is the first from the head, -1
+// the first from the tail); count limits the number of matches (0 = all);
+// maxLen limits how many elements are compared (0 = all).
+func (s *Store) LPos(key string, val []byte, rank, count, maxLen int) ([]int, error) {
+	defer s.rlock(key)()
+
+	l, ok, err := s.listLocked(key, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	var out []int
+	compared := 0
+	visit := func(i int, v []byte) bool {
+		if maxLen > 0 && compared >= maxLen {
+			return false
+		}
+		compared++
+		if !bytes.Equal(v, val) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		out = append(out, i)
+		return count == 0 || len(out) < count
+	}
+	if rank < 0 {
+		l.eachReverse(visit)
+	} else {
+		l.each(visit)
+	}
+	return out, nil
+}
+
+// LMove atomically pops an element from src and pushes it to dst.
+// It returns false when src does not exist.
+func (s *Store) LMove(src, dst string, fromLeft, toLeft bool) ([]byte, bool, error) {
+	defer s.lockKeys(src, dst)()
+
+	sl, ok, err := s.listLocked(src, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	// Check the destination type before mutating anything.
+	if e, ok := s.lookupLocked(dst, time.Now()); ok && e.kind != KindList {
+		return nil, false, ErrWrongType
+	}
+
+	var v []byte
+	if fromLeft {
+		v, _ = sl.popFront()
+	} else {
+		v, _ = sl.popBack()
+	}
+	s.growLocked(src, -listItemSize(v))
+	s.dropIfEmptyListLocked(src, sl)
+
+	dl, _, _ := s.listLocked(dst, true)
+	if toLeft {
+		dl.pushFront(v)
+	} else {
+		dl.pushBack(v)
+	}
+	s.growLocked(dst, listItemSize(v))
+	return copyBytes(v), true, nil
+}
+
+// normalizeRange converts Redis-style inclusive indices (negative counts from
+// the end) into valid bounds for a sequence of length n.
+func normalizeRange(start, stop, n int) (int, int, bool) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	return start, stop, true
+}
+
+func copyItems(items [][]byte) [][]byte {
+	out := make([][]byte, len(items))
+	for i, v := range items {
+		out[i] = copyBytes(v)
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func listOf(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = []byte(strconv.Itoa(i))
+	}
+	return out
+}
+
+func joinItems(items [][]byte) string {
+	s := ""
+	for i, v := range items {
+		if i > 0 {
+			s += ","
+		}
+		s += string(v)
+	}
+	return s
+}
+
+func TestQuicklist_SpansNodes(t *testing.T) {
+	s := New()
+	n := 3*quicklistNodeSize + 5
+	if got, _ := s.RPush("l", listOf(n)); got != n {
+		t.Fatalf("expected len %d, got %d", n, got)
+	}
+	for _, i := range []int{0, quicklistNodeSize - 1, quicklistNodeSize, n - 1, -1} {
+		want := i
+		if i < 0 {
+			want = n + i
+		}
+		v, ok, err := s.LIndex("l", i)
+		if err != nil || !ok || string(v) != strconv.Itoa(want) {
+			t.Fatalf("LIndex %d: got ok=%v v=%q err=%v", i, ok, v, err)
+		}
+	}
+
+	// Insert into the middle of a full node to force a split.
+	if got, _ := s.LInsert("l", true, []byte("64"), []byte("x")); got != n+1 {
+		t.Fatalf("expected len %d after insert, got %d", n+1, got)
+	}
+	items, _ := s.LRange("l", 63, 65)
+	if joinItems(items) != "63,x,64" {
+		t.Fatalf("unexpected range after insert: %q", joinItems(items))
+	}
+	items, _ = s.LRange("l", 0, -1)
+	if len(items) != n+1 || string(items[n]) != strconv.Itoa(n-1) {
+		t.Fatalf("full range mismatch: len=%d", len(items))
+	}
+
+	popped, _ := s.LPop("l", n+10)
+	if len(popped) != n+1 {
+		t.Fatalf("expected %d popped, got %d", n+1, len(popped))
+	}
+	if s.Exists("l") {
+		t.Fatal("expected empty list to be removed")
+	}
+}
+
+func TestLRem_CountDirection(t *testing.T) {
+	s := New()
+	vals := [][]byte{[]byte("a"), []byte("b"), []byte("a"), []byte("c"), []byte("a")}
+
+	_, _ = s.RPush("l", vals)
+	if n, _ := s.LRem("l", 2, []byte("a")); n != 2 {
+		t.Fatalf("expected 2 removed, got %d", n)
+	}
+	items, _ := s.LRange("l", 0, -1)
+	if joinItems(items) != "b,c,a" {
+		t.Fatalf("unexpected list: %q", joinItems(items))
+	}
+
+	s.Del("l")
+	_, _ = s.RPush("l", vals)
+	if n, _ := s.LRem("l", -1, []byte("a")); n != 1 {
+		t.Fatalf("expected 1 removed, got %d", n)
+	}
+	items, _ = s.LRange("l", 0, -1)
+	if joinItems(items) != "a,b,a,c" {
+		t.Fatalf("unexpected list: %q", joinItems(items))
+	}
+
+	if n, _ := s.LRem("l", 0, []byte("a")); n != 2 {
+		t.Fatalf("expected 2 removed, got %d", n)
+	}
+}
+
+func TestLTrim_AndEmptyRangeDeletes(t *testing.T) {
+	s := New()
+	_, _ = s.RPush("l", listOf(10))
+	if err := s.LTrim("l", 2, -3); err != nil {
+		t.Fatalf("ltrim: %v", err)
+	}
+	items, _ := s.LRange("l", 0, -1)
+	if joinItems(items) != "2,3,4,5,6,7" {
+		t.Fatalf("unexpected list: %q", joinItems(items))
+	}
+	_ = s.LTrim("l", 5, 1)
+	if s.Exists("l") {
+		t.Fatal("expected empty trim to delete the key")
+	}
+}
+
+func TestLPos_RankCountMaxLen(t *testing.T) {
+	s := New()
+	_, _ = s.RPush("l", [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("1"), []byte("2"), []byte("3"), []byte("c"), []byte("c")})
+
+	check := func(rank, count, maxLen int, want string) {
+		t.Helper()
+		got, err := s.LPos("l", []byte("c"), rank, count, maxLen)
+		if err != nil {
+			t.Fatalf("lpos: %v", err)
+		}
+		parts := make([][]byte, len(got))
+		for i, p := range got {
+			parts[i] = []byte(strconv.Itoa(p))
+		}
+		if joinItems(parts) != want {
+			t.Fatalf("LPos rank=%d count=%d maxlen=%d: got %q, want %q", rank, count, maxLen, joinItems(parts), want)
+		}
+	}
+	check(1, 1, 0, "2")
+	check(2, 1, 0, "6")
+	check(-1, 2, 0, "7,6")
+	check(1, 0, 0, "2,6,7")
+	check(1, 0, 3, "2")
+	check(1, 0, 2, "")
+}
+
+func TestLMove_RotatesAndChecksType(t *testing.T) {
+	s := New()
+	_, _ = s.RPush("l", [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+	v, ok, err := s.LMove("l", "l", false, true)
+	if err != nil || !ok || string(v) != "c" {
+		t.Fatalf("expected c, got ok=%v v=%q err=%v", ok, v, err)
+	}
+	items, _ := s.LRange("l", 0, -1)
+	if joinItems(items) != "c,a,b" {
+		t.Fatalf("unexpected list after rotate: %q", joinItems(items))
+	}
+
+	s.Set("str", []byte("x"))
+	if _, _, err := s.LMove("l", "str", true, true); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if n, _ := s.LLen("l"); n != 3 {
+		t.Fatalf("expected source untouched on error, got len %d", n)
+	}
+
+	if _, ok, _ := s.LMove("missing", "l", true, true); ok {
+		t.Fatal("expected missing source to report false")
+	}
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Memory accounting is an estimate: every key costs its name plus a fixed
+// keyOverhead (map slot, entry header), and every collection element its
+// bytes plus a fixed per-element overhead. It does not need to match the Go
+// heap exactly, only to grow and shrink with the data so maxmemory means
+// something.
+const (
+	keyOverhead      = 64 // map slot, key header and entry struct
+	elemOverhead     = 16 // slot of a hash, set or list element
+	zsetElemOverhead = 64 // dict slot, score and skiplist node
+)
+
+func keyCost(key string) int64 { return int64(len(key)) + keyOverhead }
+
+func hashFieldSize(field string, val []byte) int64 {
+	return int64(len(field)+len(val)) + elemOverhead
+}
+
+func listItemSize(v []byte) int64 { return int64(len(v)) + elemOverhead }
+
+func setMemberSize(m string) int64 { return int64(len(m)) + elemOverhead }
+
+func zsetMemberSize(m string) int64 { return int64(len(m)) + zsetElemOverhead }
+
+// setValue replaces the value of a string entry, keeping its size in sync.
+func (e *entry) setValue(v []byte) {
+	e.value = v
+	e.size = int64(len(v))
+}
+
+// memSize computes the size of e's value from scratch. It is O(n) for hashes
+// and sets, so it is only used when an entry is built in one go (the STORE
+// variants of set operations) and by tests checking the running totals.
+func (e entry) memSize() int64 {
+	var n int64
+	switch e.kind {
+	case KindString:
+		n = int64(len(e.value))
+	case KindHash:
+		for f, v := range e.hash.m {
+			n += hashFieldSize(f, v)
+		}
+	case KindList:
+		n = e.list.bytes
+	case KindSet:
+		for m := range e.set.m {
+			n += setMemberSize(m)
+		}
+	case KindZSet:
+		n = e.zset.bytes
+	case KindStream:
+		n = e.stream.bytes
+	}
+	return n
+}
+
+// putLocked stores e at key, replacing any previous entry, and updates the
+// memory total and the volatile and scan key indexes. e.size must already
+// describe e's value.
+func (s *Store) putLocked(key string, e entry) {
+	sh := s.shard(key)
+	if old, ok := sh.data[key]; ok {
+		s.used.Add(e.size - old.size)
+		if old.expiresAt != nil && isExpired(old, time.Now()) {
+			s.noteExpired(key, old)
+		}
+	} else {
+		s.used.Add(keyCost(key) + e.size)
+		sh.order.add(key)
+	}
+	sh.data[key] = e
+	if e.expiresAt != nil {
+		sh.volatile.add(key)
+	} else {
+		sh.volatile.remove(key)
+	}
+}
+
+// removeLocked deletes key (if present) and releases its memory.
+func (s *Store) removeLocked(key string) {
+	sh := s.shard(key)
+	if old, ok := sh.data[key]; ok {
+		s.used.Add(-keyCost(key) - old.size)
+		delete(sh.data, key)
+		sh.order.remove(key)
+		if old.expiresAt != nil {
+			sh.volatile.remove(key)
+			if isExpired(old, time.Now()) {
+				s.noteExpired(key, old)
+			}
+		}
+	}
+}
+
+// growLocked records that the collection at key changed size in place by
+// delta bytes. It does nothing if key no longer exists.
+func (s *Store) growLocked(key string, delta int64) {
+	if delta == 0 {
+		return
+	}
+	sh := s.shard(key)
+	if e, ok := sh.data[key]; ok {
+		e.size += delta
+		sh.data[key] = e
+		s.used.Add(delta)
+	}
+}
+
+// UsedMemory returns the estimated number of bytes held by the store.
+func (s *Store) UsedMemory() int64 { return s.used.Load() }
+
+// EvictionPolicy selects which keys are evicted once maxmemory is reached.
+type EvictionPolicy uint8
+
+const (
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	AllKeysLFU
+	AllKeysRandom
+	VolatileLRU
+	VolatileLFU
+	VolatileRandom
+	VolatileTTL
+)
+
+var evictionPolicyNames = []string{
+	NoEviction:     "noeviction",
+	AllKeysLRU:     "allkeys-lru",
+	AllKeysLFU:     "allkeys-lfu",
+	AllKeysRandom:  "allkeys-random",
+	VolatileLRU:    "volatile-lru",
+	VolatileLFU:    "volatile-lfu",
+	VolatileRandom: "volatile-random",
+	VolatileTTL:    "volatile-ttl",
+}
+
+// String returns the maxmemory-policy name of p.
+func (p EvictionPolicy) String() string {
+	if int(p) < len(evictionPolicyNames) {
+		return evictionPolicyNames[p]
+	}
+	return "unknown"
+}
+
+// ParseEvictionPolicy parses a maxmemory-policy name.
+func ParseEvictionPolicy(name string) (EvictionPolicy, bool) {
+	for i, n := range evictionPolicyNames {
+		if n == name {
+			return EvictionPolicy(i), true
+		}
+	}
+	return NoEviction, false
+}
+
+// LFU reports whether p ranks keys by access frequency, which is then what
+// OBJECT FREQ reports and OBJECT IDLETIME does not.
+func (p EvictionPolicy) LFU() bool {
+	return p == AllKeysLFU || p == VolatileLFU
+}
+
+// volatile reports whether p only evicts keys that have an expiry.
+func (p EvictionPolicy) volatile() bool {
+	return p == VolatileLRU || p == VolatileLFU || p == VolatileRandom || p == VolatileTTL
+}
+
+// EvictionSamples is how many keys each database contributes per eviction,
+// like Redis' maxmemory-samples.
+const EvictionSamples = 5
+
+// evictionCandidate is a sampled key; the one with the highest score is
+// evicted first.
+type evictionCandidate struct {
+	key   string
+	score float64
+}
+
+// score ranks e for eviction under p: idle time for LRU, inverted access
+// frequency for LFU, closeness of the expiry for TTL and chance for the
+// random policies.
+func (p EvictionPolicy) score(e entry, now time.Time) float64 {
+	switch p {
+	case AllKeysLRU, VolatileLRU:
+		return float64(e.idle(now))
+	case AllKeysLFU, VolatileLFU:
+		return float64(255 - int(e.lfuAt(now)))
+	case VolatileTTL:
+		return -float64(e.expiresAt.UnixMilli())
+	default:
+		return rand.Float64()
+	}
+}
+
+// sample returns up to n eviction candidates under p, visiting shards from
+// a random one on. Go randomizes where map iteration starts, which makes
+// the first keys of a shard a random sample, as Redis' dictGetSomeKeys
+// does. The volatile policies sample the shard's volatile index instead,
+// so keys without an expiry cost nothing. Expired keys met on the way are
+// purged.
+func (s *Store) sample(p EvictionPolicy, n int, now time.Time) []evictionCandidate {
+	out := make([]evictionCandidate, 0, n)
+	start := rand.IntN(shardCount)
+	for i := 0; i < shardCount && len(out) < n; i++ {
+		sh := &s.shards[(start+i)%shardCount]
+		sh.mu.Lock()
+		if p.volatile() {
+			for _, k := range sh.volatile.sample(n - len(out)) {
+				out = s.candidateLocked(out, p, k, sh.data[k], now)
+			}
+		} else {
+			for k, e := range sh.data {
+				if len(out) == n {
+					break
+				}
+				out = s.candidateLocked(out, p, k, e, now)
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return out
+}
+
+// candidateLocked appends key to out unless it has expired, in which case
+// it is purged instead.
+func (s *Store) candidateLocked(out []evictionCandidate, p EvictionPolicy, key string, e entry, now time.Time) []evictionCandidate {
+	if isExpired(e, now) {
+		s.expireLocked(key)
+		return out
+	}
+	return append(out, evictionCandidate{key: key, score: p.score(e, now)})
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recount computes the memory total of s from scratch and checks every
+// entry's cached size on the way.
+func recount(t *testing.T, s *Store) int64 {
+	t.Helper()
+	defer s.rlockAll()()
+
+	var n int64
+	for i := range s.shards {
+		for k, e := range s.shards[i].data {
+			if got := e.memSize(); got != e.size {
+				t.Fatalf("key %q: cached size %d, actual %d", k, e.size, got)
+			}
+			n += keyCost(k) + e.size
+		}
+	}
+	return n
+}
+
+func TestUsedMemory_TracksEveryType(t *testing.T) {
+	s := New()
+	check := func(step string) {
+		t.Helper()
+		if want, got := recount(t, s), s.UsedMemory(); got != want {
+			t.Fatalf("%s: UsedMemory = %d, recount = %d", step, got, want)
+		}
+	}
+
+	s.Set("str", []byte("hello"))
+	_, _ = s.Append("str", []byte(" world"))
+	_, _ = s.SetRange("str", 20, []byte("x"))
+	_, _ = s.IncrBy("n", 41)
+	check("strings")
+
+	_, _ = s.HSet("h", []FieldValue{{"a", []byte("1")}, {"b", []byte("22")}})
+	_, _ = s.HSet("h", []FieldValue{{"a", []byte("longer value")}})
+	_, _ = s.HIncrBy("h", "c", 7)
+	_, _ = s.HIncrByFloat("h", "d", 1.5)
+	_, _ = s.HDel("h", []string{"b"})
+	check("hash")
+
+	_, _ = s.RPush("l", [][]byte{[]byte("a"), []byte("bb"), []byte("ccc"), []byte("bb")})
+	_, _ = s.LPop("l", 1)
+	_ = s.LSet("l", 0, []byte("replaced"))
+	_, _ = s.LInsert("l", true, []byte("ccc"), []byte("ins"))
+	_, _ = s.LRem("l", 0, []byte("bb"))
+	_, _, _ = s.LMove("l", "l2", true, false)
+	check("list")
+
+	_, _ = s.SAdd("s", []string{"x", "y", "z"})
+	_, _ = s.SRem("s", []string{"x"})
+	_, _ = s.SMove("s", "s2", "y")
+	_, _ = s.SUnionStore("s3", []string{"s", "s2"})
+	check("set")
+
+	_, _, _ = s.ZAdd("z", ZAddOptions{}, []ScoreMember{{"a", 1}, {"b", 2}, {"c", 3}})
+	_, _, _ = s.ZIncr("z", ZAddOptions{}, 5, "a")
+	_, _ = s.ZRem("z", []string{"b"})
+	_, _ = s.ZPop("z", 1, true)
+	_, _ = s.ZUnionStore("z2", []string{"z", "s3"}, nil, ZAggSum)
+	check("zset")
+
+	_, _ = s.Rename("z2", "z3", false)
+	_ = s.Del("str")
+	s.Unlink([]string{"h", "l2"})
+	check("delete")
+
+	s.Flush()
+	if got := s.UsedMemory(); got != 0 {
+		t.Fatalf("UsedMemory after Flush = %d", got)
+	}
+}
+
+func TestDatabases_MemoryFollowsMoveSwapAndCopy(t *testing.T) {
+	d := NewDatabases(2)
+	d.DB(0).Set("a", []byte("value"))
+	_, _ = d.DB(1).RPush("l", [][]byte{[]byte("x")})
+	total := d.UsedMemory()
+
+	d.Swap(0, 1)
+	d.Move("a", 1, 0)
+	for i := 0; i < 2; i++ {
+		if want, got := recount(t, d.DB(i)), d.DB(i).UsedMemory(); got != want {
+			t.Fatalf("db %d: UsedMemory = %d, recount = %d", i, got, want)
+		}
+	}
+	if got := d.UsedMemory(); got != total {
+		t.Fatalf("total changed from %d to %d", total, got)
+	}
+
+	d.Copy("l", "l", 0, 1, false)
+	if want, got := recount(t, d.DB(1)), d.DB(1).UsedMemory(); got != want {
+		t.Fatalf("after copy: UsedMemory = %d, recount = %d", got, want)
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for _, name := range evictionPolicyNames {
+		p, ok := ParseEvictionPolicy(name)
+		if !ok || p.String() != name {
+			t.Fatalf("round trip of %q gave %v, %v", name, p, ok)
+		}
+	}
+	if _, ok := ParseEvictionPolicy("allkeys-fifo"); ok {
+		t.Fatalf("expected unknown policy to fail")
+	}
+}
+
+func TestEvict_Policies(t *testing.T) {
+	now := time.Now()
+	// Each database holds fewer keys than EvictionSamples, so the sample
+	// sees all of them and the choice is deterministic.
+	setup := func() *Databases {
+		d := NewDatabases(2)
+		d.DB(0).Set("old", []byte("1"))
+		d.DB(0).Set("hot", []byte("1"))
+		d.DB(1).Set("soon", []byte("1"))
+		d.DB(1).Set("later", []byte("1"))
+		d.DB(1).PExpireAt("soon", now.Add(time.Minute).UnixMilli(), ExpireOptions{})
+		d.DB(1).PExpireAt("later", now.Add(time.Hour).UnixMilli(), ExpireOptions{})
+
+		st := d.DB(0)
+		st.shard("old").data["old"].access.lru.Store(now.Add(-time.Hour).UnixMilli())
+		st.shard("hot").data["hot"].access.lfu.Store(200)
+		return d
+	}
+
+	tests := []struct {
+		policy EvictionPolicy
+		db     int
+		key    string
+	}{
+		{AllKeysLRU, 0, "old"},
+		{VolatileTTL, 1, "soon"},
+	}
+	for _, tt := range tests {
+		d := setup()
+		db, key, ok := d.Evict(tt.policy)
+		if !ok || db != tt.db || key != tt.key {
+			t.Fatalf("%v: evicted db=%d key=%q ok=%v, want db=%d key=%q", tt.policy, db, key, ok, tt.db, tt.key)
+		}
+		if d.DB(db).Exists(key) {
+			t.Fatalf("%v: %q still exists", tt.policy, key)
+		}
+	}
+
+	// LFU never picks the frequently used key while others are left.
+	d := setup()
+	for i := 0; i < 3; i++ {
+		if _, key, _ := d.Evict(AllKeysLFU); key == "hot" {
+			t.Fatalf("allkeys-lfu evicted the hot key with %d others left", 3-i)
+		}
+	}
+
+	// Volatile policies leave keys without an expiry alone.
+	d = setup()
+	for _, p := range []EvictionPolicy{VolatileLRU, VolatileRandom} {
+		_, key, ok := d.Evict(p)
+		if !ok || (key != "soon" && key != "later") {
+			t.Fatalf("%v evicted %q, ok=%v", p, key, ok)
+		}
+	}
+	if _, _, ok := d.Evict(VolatileLFU); ok {
+		t.Fatalf("expected nothing left for volatile-lfu")
+	}
+	if !d.DB(0).Exists("old") || !d.DB(0).Exists("hot") {
+		t.Fatalf("volatile policies evicted a persistent key")
+	}
+
+	if _, _, ok := setup().Evict(NoEviction); ok {
+		t.Fatalf("noeviction must not evict")
+	}
+}
+
+func TestSample_VolatilePoliciesUseTheVolatileIndex(t *testing.T) {
+	now := time.Now()
+	s := New()
+	for i := 0; i < 5000; i++ {
+		s.Set("p:"+strconv.Itoa(i), []byte("v"))
+	}
+	for i := 0; i < 3; i++ {
+		k := "v:" + strconv.Itoa(i)
+		s.Set(k, []byte("v"))
+		s.PExpireAt(k, now.Add(time.Hour).UnixMilli(), ExpireOptions{})
+	}
+	gone := newEntry(KindString)
+	gone.setValue([]byte("v"))
+	gone.expiresAt = ptrTime(now.Add(-time.Second))
+	s.putLocked("gone", gone)
+
+	got := s.sample(VolatileTTL, EvictionSamples, now)
+	keys := make([]string, 0, len(got))
+	for _, c := range got {
+		keys = append(keys, c.key)
+	}
+	sort.Strings(keys)
+	if want := []string{"v:0", "v:1", "v:2"}; !slices.Equal(keys, want) {
+		t.Fatalf("volatile-ttl sampled %v, want %v", keys, want)
+	}
+	if _, ok := s.shard("gone").data["gone"]; ok {
+		t.Fatalf("expired key met while sampling was not purged")
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LFU parameters, as in Redis: counters start at lfuInitVal so new keys are
+// not evicted right away, grow logarithmically (lfu-log-factor) and lose one
+// point per lfuDecayTime without access (lfu-decay-time).
+const (
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// newEntry returns an empty entry of kind whose access data says "just
+// created".
+func newEntry(kind Kind) entry {
+	return entry{kind: kind, access: newAccess()}
+}
+
+// access is the LRU/LFU data of an entry. Entries are stored by value, so
+// it lives behind a pointer and uses atomics: reads record accesses under
+// the shard's read lock without writing the entry back.
+type access struct {
+	lru     atomic.Int64  // last access, unix milliseconds
+	lfu     atomic.Uint32 // logarithmic access counter, see touch
+	expired atomic.Bool   // the entry's expiry has been reported, see noteExpired
+}
+
+func newAccess() *access {
+	a := &access{}
+	a.lru.Store(time.Now().UnixMilli())
+	a.lfu.Store(lfuInitVal)
+	return a
+}
+
+// touch records an access at now. Concurrent readers may race on the
+// counter and lose an increment, which is as approximate as LFU is anyway.
+func (e entry) touch(now time.Time) {
+	e.access.lfu.Store(uint32(lfuLogIncr(e.lfuAt(now))))
+	e.access.lru.Store(now.UnixMilli())
+}
+
+// idle returns the time since the last access.
+func (e entry) idle(now time.Time) time.Duration {
+	return time.Duration(now.UnixMilli()-e.access.lru.Load()) * time.Millisecond
+}
+
+// lfuAt returns the access counter decayed to now.
+func (e entry) lfuAt(now time.Time) uint8 {
+	periods := (now.UnixMilli() - e.access.lru.Load()) / lfuDecayTime.Milliseconds()
+	lfu := uint8(e.access.lfu.Load())
+	if periods >= int64(lfu) {
+		return 0
+	}
+	return lfu - uint8(max(periods, 0))
+}
+
+// lfuLogIncr increments counter with a probability that shrinks as it grows,
+// so 255 stands for roughly a million accesses.
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	base := float64(max(int(counter)-lfuInitVal, 0))
+	if rand.Float64() < 1/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// ObjectInfo is what OBJECT reports about a key.
+type ObjectInfo struct {
+	Encoding string
+	Idle     time.Duration // time since the last access
+	Freq     int           // decayed LFU counter
+}
+
+// Object describes the value at key without counting as an access.
+func (s *Store) Object(key string) (ObjectInfo, bool) {
+	defer s.rlock(key)()
+
+	now := time.Now()
+	e, ok := s.peekLocked(key, now)
+	if !ok {
+		return ObjectInfo{}, false
+	}
+	return ObjectInfo{
+		Encoding: e.encoding(),
+		Idle:     e.idle(now),
+		Freq:     int(e.lfuAt(now)),
+	}, true
+}
+
+// encoding names the representation of e the way OBJECT ENCODING does.
+// Strings follow Redis' rules; collections report the structure this store
+// actually uses for them.
+func (e entry) encoding() string {
+	switch e.kind {
+	case KindString:
+		if len(e.value) <= 20 {
+			if n, err := strconv.ParseInt(string(e.value), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(e.value) {
+				return "int"
+			}
+		}
+		if len(e.value) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case KindList:
+		return "quicklist"
+	case KindZSet:
+		return "skiplist"
+	case KindStream:
+		return "stream"
+	default:
+		return "hashtable"
+	}
+}
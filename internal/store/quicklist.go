@@ -0,0 +1,239 @@
+package store
+
+// quicklistNodeSize is the maximum number of elements kept in one node.
+// Chunking keeps pushes and pops at both ends O(1) while avoiding a
+// per-element allocation like a plain linked list would need.
+const quicklistNodeSize = 128
+
+// quicklist is a doubly linked list of small element slices (Redis-style).
+type quicklist struct {
+	head  *qlNode
+	tail  *qlNode
+	count int
+	bytes int64 // memory estimate of the elements, see listItemSize
+}
+
+type qlNode struct {
+	prev  *qlNode
+	next  *qlNode
+	items [][]byte
+}
+
+func newQuicklist() *quicklist {
+	return &quicklist{}
+}
+
+func (q *quicklist) len() int { return q.count }
+
+func (q *quicklist) pushFront(v []byte) {
+	if q.head == nil || len(q.head.items) >= quicklistNodeSize {
+		n := &qlNode{next: q.head, items: make([][]byte, 0, 8)}
+		if q.head != nil {
+			q.head.prev = n
+		} else {
+			q.tail = n
+		}
+		q.head = n
+	}
+	h := q.head
+	h.items = append(h.items, nil)
+	copy(h.items[1:], h.items)
+	h.items[0] = v
+	q.count++
+	q.bytes += listItemSize(v)
+}
+
+func (q *quicklist) pushBack(v []byte) {
+	if q.tail == nil || len(q.tail.items) >= quicklistNodeSize {
+		n := &qlNode{prev: q.tail, items: make([][]byte, 0, 8)}
+		if q.tail != nil {
+			q.tail.next = n
+		} else {
+			q.head = n
+		}
+		q.tail = n
+	}
+	q.tail.items = append(q.tail.items, v)
+	q.count++
+	q.bytes += listItemSize(v)
+}
+
+func (q *quicklist) popFront() ([]byte, bool) {
+	if q.head == nil {
+		return nil, false
+	}
+	v := q.head.items[0]
+	q.removeAt(q.head, 0)
+	return v, true
+}
+
+func (q *quicklist) popBack() ([]byte, bool) {
+	if q.tail == nil {
+		return nil, false
+	}
+	v := q.tail.items[len(q.tail.items)-1]
+	q.removeAt(q.tail, len(q.tail.items)-1)
+	return v, true
+}
+
+// locate returns the node holding element i (0 <= i < count) and the
+// offset inside it, walking from whichever end is closer.
+func (q *quicklist) locate(i int) (*qlNode, int) {
+	if i < q.count/2 {
+		for n := q.head; n != nil; n = n.next {
+			if i < len(n.items) {
+				return n, i
+			}
+			i -= len(n.items)
+		}
+		return nil, 0
+	}
+
+	i = q.count - 1 - i // distance from the tail
+	for n := q.tail; n != nil; n = n.prev {
+		if i < len(n.items) {
+			return n, len(n.items) - 1 - i
+		}
+		i -= len(n.items)
+	}
+	return nil, 0
+}
+
+func (q *quicklist) index(i int) ([]byte, bool) {
+	if i < 0 || i >= q.count {
+		return nil, false
+	}
+	n, off := q.locate(i)
+	return n.items[off], true
+}
+
+func (q *quicklist) set(i int, v []byte) bool {
+	if i < 0 || i >= q.count {
+		return false
+	}
+	n, off := q.locate(i)
+	q.bytes += listItemSize(v) - listItemSize(n.items[off])
+	n.items[off] = v
+	return true
+}
+
+// insertAt inserts v so that it ends up at position i (0 <= i <= count).
+func (q *quicklist) insertAt(i int, v []byte) {
+	switch {
+	case i <= 0:
+		q.pushFront(v)
+		return
+	case i >= q.count:
+		q.pushBack(v)
+		return
+	}
+
+	n, off := q.locate(i)
+	n.items = append(n.items, nil)
+	copy(n.items[off+1:], n.items[off:])
+	n.items[off] = v
+	q.count++
+	q.bytes += listItemSize(v)
+
+	if len(n.items) > quicklistNodeSize {
+		q.split(n)
+	}
+}
+
+// split moves the upper half of n into a new node placed after it.
+func (q *quicklist) split(n *qlNode) {
+	half := len(n.items) / 2
+	right := &qlNode{prev: n, next: n.next}
+	right.items = append(make([][]byte, 0, len(n.items)-half), n.items[half:]...)
+
+	for j := half; j < len(n.items); j++ {
+		n.items[j] = nil
+	}
+	n.items = n.items[:half]
+
+	if n.next != nil {
+		n.next.prev = right
+	} else {
+		q.tail = right
+	}
+	n.next = right
+}
+
+// removeAt deletes the element at offset off of node n, unlinking the node
+// once it becomes empty.
+func (q *quicklist) removeAt(n *qlNode, off int) {
+	q.bytes -= listItemSize(n.items[off])
+	copy(n.items[off:], n.items[off+1:])
+	n.items[len(n.items)-1] = nil
+	n.items = n.items[:len(n.items)-1]
+	q.count--
+
+	if len(n.items) > 0 {
+		return
+	}
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		q.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		q.tail = n.prev
+	}
+}
+
+// rangeItems returns elements start..stop inclusive; indices must be valid.
+func (q *quicklist) rangeItems(start, stop int) [][]byte {
+	out := make([][]byte, 0, stop-start+1)
+	n, off := q.locate(start)
+	for n != nil && len(out) < stop-start+1 {
+		for ; off < len(n.items) && len(out) < stop-start+1; off++ {
+			out = append(out, n.items[off])
+		}
+		n = n.next
+		off = 0
+	}
+	return out
+}
+
+// each calls fn for every element from head to tail until fn returns false.
+func (q *quicklist) each(fn func(i int, v []byte) bool) {
+	i := 0
+	for n := q.head; n != nil; n = n.next {
+		for _, v := range n.items {
+			if !fn(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// eachReverse is each from tail to head; i is still the head-based index.
+func (q *quicklist) eachReverse(fn func(i int, v []byte) bool) {
+	i := q.count - 1
+	for n := q.tail; n != nil; n = n.prev {
+		for j := len(n.items) - 1; j >= 0; j-- {
+			if !fn(i, n.items[j]) {
+				return
+			}
+			i--
+		}
+	}
+}
+
+func (q *quicklist) all() [][]byte {
+	if q.count == 0 {
+		return [][]byte{}
+	}
+	return q.rangeItems(0, q.count-1)
+}
+
+// reset replaces the contents with items (used by O(n) rewrites like LREM).
+func (q *quicklist) reset(items [][]byte) {
+	q.head, q.tail, q.count, q.bytes = nil, nil, 0, 0
+	for _, v := range items {
+		q.pushBack(v)
+	}
+}
@@ -1,17 +1,226 @@
 package store
 
 import (
+	"math"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// StartReaper starts a background goroutine that periodically deletes expired keys.
+// Active expiry parameters, as in Redis' activeExpireCycle: every tick each
+// database is sampled expireKeysPerLoop volatile keys at a time, and sampling
+// continues while more than expireAcceptableStale percent of a sample had
+// expired. A cycle stops once it has used expireCycleBudget percent of the
+// tick, so a database full of expired keys is cleaned up over several ticks
+// instead of stalling clients.
+const (
+	expireKeysPerLoop     = 20
+	expireAcceptableStale = 10 // percent
+	expireCycleBudget     = 25 // percent of the tick interval
+)
+
+// volatileSet indexes the keys that have an expiry so the reaper can pick
+// random ones in O(1) without walking the whole keyspace.
+type volatileSet struct {
+	keys []string
+	pos  map[string]int
+}
+
+func (v *volatileSet) len() int { return len(v.keys) }
+
+func (v *volatileSet) add(key string) {
+	if _, ok := v.pos[key]; ok {
+		return
+	}
+	if v.pos == nil {
+		v.pos = make(map[string]int)
+	}
+	v.pos[key] = len(v.keys)
+	v.keys = append(v.keys, key)
+}
+
+func (v *volatileSet) remove(key string) {
+	i, ok := v.pos[key]
+	if !ok {
+		return
+	}
+	last := len(v.keys) - 1
+	v.keys[i] = v.keys[last]
+	v.pos[v.keys[i]] = i
+	v.keys = v.keys[:last]
+	delete(v.pos, key)
+}
+
+func (v *volatileSet) random() string { return v.keys[rand.IntN(len(v.keys))] }
+
+// sample returns up to n distinct random keys, all of them if there are no
+// more than n.
+func (v *volatileSet) sample(n int) []string {
+	if len(v.keys) <= n {
+		return slices.Clone(v.keys)
+	}
+	out := make([]string, 0, n)
+	for len(out) < n {
+		if k := v.random(); !slices.Contains(out, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// expireLocked removes key because its expiry passed. removeLocked reports
+// the expiry.
+func (s *Store) expireLocked(key string) {
+	s.removeLocked(key)
+}
+
+// noteExpired counts the expiry of key, whose entry is e, and reports it to
+// the OnExpire hook. An expired entry may be met several times (by reads,
+// which leave it in place, then by whatever removes it) but is reported
+// once.
+func (s *Store) noteExpired(key string, e entry) {
+	if !e.access.expired.CompareAndSwap(false, true) {
+		return
+	}
+	s.expired.Add(1)
+	if fn := s.onExpire.Load(); fn != nil {
+		(*fn)(key)
+	}
+}
+
+// OnExpire makes s call fn with every key whose expiry passes, once per
+// key, whether the expired key is met by a command or by the reaper. fn is
+// called with a shard lock held and must not use s.
+func (s *Store) OnExpire(fn func(key string)) {
+	s.onExpire.Store(&fn)
+}
+
+// OnExpire calls fn with the database and key of every key that expires in
+// d, see Store.OnExpire.
+func (d *Databases) OnExpire(fn func(db int, key string)) {
+	for i, st := range d.dbs {
+		st.OnExpire(func(key string) { fn(i, key) })
+	}
+}
+
+// expireBatch checks up to n random volatile keys of one shard and deletes
+// the expired ones, returning how many keys were checked and deleted. Keys
+// are drawn with replacement, like Redis does.
+func (s *Store) expireBatch(shard, n int, now time.Time) (sampled, expired int) {
+	sh := &s.shards[shard]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	n = min(n, sh.volatile.len())
+	for ; sampled < n; sampled++ {
+		if sh.volatile.len() == 0 {
+			break
+		}
+		k := sh.volatile.random()
+		if isExpired(sh.data[k], now) {
+			s.expireLocked(k)
+			expired++
+		}
+	}
+	return sampled, expired
+}
+
+// expireCycle holds the active expiry statistics of a Databases and where
+// the next cycle resumes.
+type expireCycle struct {
+	nextDB int // only touched by the reaper goroutine
+
+	cycles     atomic.Int64
+	timeCapped atomic.Int64
+	totalTime  atomic.Int64  // nanoseconds
+	lastTime   atomic.Int64  // nanoseconds
+	stalePerc  atomic.Uint64 // float64 bits
+}
+
+// ExpireStats describes expired-key cleanup, as reported by INFO.
+type ExpireStats struct {
+	ExpiredKeys    int64         // keys removed because their expiry passed, lazily or by the reaper
+	StalePerc      float64       // estimated percentage of volatile keys that are expired but not yet removed
+	TimeCapReached int64         // cycles that stopped because they ran out of time
+	Cycles         int64         // active expire cycles run
+	CycleTime      time.Duration // total time spent in active expire cycles
+	LastCycleTime  time.Duration // duration of the most recent cycle
+}
+
+// ExpireStats returns the expiry statistics of all databases.
+func (d *Databases) ExpireStats() ExpireStats {
+	st := ExpireStats{
+		StalePerc:      math.Float64frombits(d.cycle.stalePerc.Load()),
+		TimeCapReached: d.cycle.timeCapped.Load(),
+		Cycles:         d.cycle.cycles.Load(),
+		CycleTime:      time.Duration(d.cycle.totalTime.Load()),
+		LastCycleTime:  time.Duration(d.cycle.lastTime.Load()),
+	}
+	for _, db := range d.dbs {
+		st.ExpiredKeys += db.expired.Load()
+	}
+	return st
+}
+
+// activeExpireCycle runs one cycle over the databases within budget. Each
+// shard of each database is sampled until its expired ratio drops to the
+// acceptable level, holding the shard lock only for one batch at a time;
+// when the budget runs out the next cycle resumes in the database where
+// this one stopped.
+func (d *Databases) activeExpireCycle(budget time.Duration) {
+	start := time.Now()
+	deadline := start.Add(budget)
+	sampled, expired := 0, 0
+	timedOut := false
+
+	for n := 0; n < len(d.dbs) && !timedOut; n++ {
+		i := (d.cycle.nextDB + n) % len(d.dbs)
+		db := d.dbs[i]
+		for sh := 0; sh < shardCount && !timedOut; sh++ {
+			for {
+				s, e := db.expireBatch(sh, expireKeysPerLoop, time.Now())
+				sampled += s
+				expired += e
+				if !time.Now().Before(deadline) {
+					timedOut = true
+					d.cycle.nextDB = i
+					break
+				}
+				if s == 0 || e*100 <= s*expireAcceptableStale {
+					break
+				}
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	d.cycle.cycles.Add(1)
+	d.cycle.totalTime.Add(int64(elapsed))
+	d.cycle.lastTime.Store(int64(elapsed))
+	if timedOut {
+		d.cycle.timeCapped.Add(1)
+	}
+	// Same running average as Redis' expired_stale_perc.
+	current := 0.0
+	if sampled > 0 {
+		current = float64(expired) / float64(sampled) * 100
+	}
+	prev := math.Float64frombits(d.cycle.stalePerc.Load())
+	d.cycle.stalePerc.Store(math.Float64bits(current*0.05 + prev*0.95))
+}
+
+// StartReaper starts a background goroutine that runs an active expire
+// cycle over every database each interval, spending at most
+// expireCycleBudget percent of it.
 // It returns a stop() function you MUST call to stop the goroutine.
 // stop() is idempotent (safe to call multiple times).
-func (s *Store) StartReaper(interval time.Duration) (stop func()) {
+func (d *Databases) StartReaper(interval time.Duration) (stop func()) {
 	if interval <= 0 {
 		interval = 1 * time.Second
 	}
+	budget := interval * expireCycleBudget / 100
 
 	done := make(chan struct{})
 	var once sync.Once
@@ -23,7 +232,7 @@ func (s *Store) StartReaper(interval time.Duration) (stop func()) {
 		for {
 			select {
 			case <-t.C:
-				s.deleteExpired(time.Now())
+				d.activeExpireCycle(budget)
 			case <-done:
 				return
 			}
@@ -37,13 +246,7 @@ func (s *Store) StartReaper(interval time.Duration) (stop func()) {
 	}
 }
 
-func (s *Store) deleteExpired(now time.Time) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for k, e := range s.data {
-		if isExpired(e, now) {
-			delete(s.data, k)
-		}
-	}
+// StartReaper is Databases.StartReaper for a store used on its own.
+func (s *Store) StartReaper(interval time.Duration) (stop func()) {
+	return NewDatabasesFrom(s).StartReaper(interval)
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"strconv"
 	"testing"
 	"time"
 )
@@ -19,11 +20,149 @@ func TestReaperDeletesExpiredKeysWithoutAccess(t *testing.T) {
 	// Wait long enough for expiry + at least one reaper tick.
 	time.Sleep(1200 * time.Millisecond)
 
-	s.mu.RLock()
-	_, ok := s.data["a"]
-	s.mu.RUnlock()
+	unlock := s.rlock("a")
+	_, ok := s.shard("a").data["a"]
+	unlock()
 
 	if ok {
 		t.Fatalf("expected key to be deleted by reaper")
 	}
 }
+
+func TestVolatileIndexFollowsExpiry(t *testing.T) {
+	s := New()
+	inIndex := func(key string) bool {
+		defer s.rlock(key)()
+		_, ok := s.shard(key).volatile.pos[key]
+		return ok
+	}
+
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("1"))
+	if inIndex("a") {
+		t.Fatalf("persistent key indexed")
+	}
+	s.Expire("a", 100)
+	s.Expire("b", 100)
+	if !inIndex("a") || !inIndex("b") {
+		t.Fatalf("volatile keys not indexed")
+	}
+
+	s.Persist("a")
+	if inIndex("a") {
+		t.Fatalf("key still indexed after PERSIST")
+	}
+	if _, err := s.Rename("b", "c", false); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if inIndex("b") || !inIndex("c") {
+		t.Fatalf("index not moved by RENAME")
+	}
+	s.Set("c", []byte("2"))
+	if inIndex("c") {
+		t.Fatalf("key still indexed after SET cleared its expiry")
+	}
+
+	s.Expire("a", 100)
+	s.Del("a")
+	for i := range s.shards {
+		if n := s.shards[i].volatile.len(); n != 0 {
+			t.Fatalf("expected an empty index, got %d keys in shard %d", n, i)
+		}
+	}
+}
+
+func TestActiveExpireCycle_RemovesExpiredKeysBySampling(t *testing.T) {
+	d := NewDatabases(2)
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 500; i++ {
+		k := "k" + strconv.Itoa(i)
+		d.DB(i%2).Set(k, []byte("v"))
+		backdateExpiry(d.DB(i%2), k, past)
+	}
+	d.DB(0).Set("live", []byte("v"))
+	d.DB(0).Expire("live", 100)
+	d.DB(1).Set("persistent", []byte("v"))
+
+	for i := 0; i < 10 && d.DB(0).Len()+d.DB(1).Len() > 2; i++ {
+		d.activeExpireCycle(time.Second)
+	}
+	if n := d.DB(0).Len() + d.DB(1).Len(); n != 2 {
+		t.Fatalf("expected only the live keys to remain, got %d keys", n)
+	}
+
+	st := d.ExpireStats()
+	if st.ExpiredKeys != 500 {
+		t.Fatalf("ExpiredKeys = %d, want 500", st.ExpiredKeys)
+	}
+	if st.Cycles == 0 || st.CycleTime <= 0 || st.StalePerc <= 0 {
+		t.Fatalf("cycle statistics not recorded: %+v", st)
+	}
+}
+
+func TestActiveExpireCycle_StopsAtTimeBudget(t *testing.T) {
+	d := NewDatabases(3)
+	past := time.Now().Add(-time.Second)
+	// Keep every key in the first shard so the single batch a zero budget
+	// allows has them all to sample from.
+	for i, n := 0, 0; n < 100; i++ {
+		k := "k" + strconv.Itoa(i)
+		if shardIndex(k) != 0 {
+			continue
+		}
+		d.DB(1).Set(k, []byte("v"))
+		backdateExpiry(d.DB(1), k, past)
+		n++
+	}
+	d.cycle.nextDB = 1
+
+	// A zero budget allows a single batch.
+	d.activeExpireCycle(0)
+	if n := d.DB(1).Len(); n != 100-expireKeysPerLoop {
+		t.Fatalf("expected one batch of %d keys to expire, %d keys left", expireKeysPerLoop, n)
+	}
+	if st := d.ExpireStats(); st.TimeCapReached != 1 {
+		t.Fatalf("TimeCapReached = %d, want 1", st.TimeCapReached)
+	}
+	if d.cycle.nextDB != 1 {
+		t.Fatalf("next cycle should resume at db 1, got %d", d.cycle.nextDB)
+	}
+}
+
+// backdateExpiry gives key an expiry in the past without removing it.
+func backdateExpiry(s *Store, key string, at time.Time) {
+	defer s.lock(key)()
+	e := s.shard(key).data[key]
+	e.expiresAt = &at
+	s.putLocked(key, e)
+}
+
+func TestOnExpireReportsEachExpiryOnce(t *testing.T) {
+	d := NewDatabases(2)
+	var got []string
+	d.OnExpire(func(db int, key string) {
+		got = append(got, strconv.Itoa(db)+":"+key)
+	})
+
+	past := time.Now().Add(-time.Second)
+	for _, k := range []string{"read", "reaped", "overwritten"} {
+		d.DB(1).Set(k, []byte("v"))
+		backdateExpiry(d.DB(1), k, past)
+	}
+
+	// A read reports the expiry but leaves the key to the reaper, which
+	// must not report it again.
+	if _, ok := d.DB(1).Get("read"); ok {
+		t.Fatalf("expired key returned")
+	}
+	d.DB(1).Get("read")
+	d.DB(1).Set("overwritten", []byte("new"))
+	d.activeExpireCycle(time.Second)
+
+	if len(got) != 3 || got[0] != "1:read" || got[1] != "1:overwritten" || got[2] != "1:reaped" {
+		t.Fatalf("reported expiries %v", got)
+	}
+	if st := d.ExpireStats(); st.ExpiredKeys != 3 {
+		t.Fatalf("ExpiredKeys = %d, want 3", st.ExpiredKeys)
+	}
+}
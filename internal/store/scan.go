@@ -0,0 +1,168 @@
+package store
+
+// scanPosBits is the width of a scan position. Cursors stay within 62 bits
+// so every client can parse them as a signed 64-bit integer.
+const scanPosBits = 62
+
+// scanPos returns the fixed position of name in SCAN-family iteration order.
+// Because the position depends only on the name, an element present for the
+// whole iteration is returned exactly once no matter how the collection
+// grows or shrinks between calls.
+func scanPos(name string) uint64 {
+	// FNV-1a, then the murmur3 finalizer: buckets are picked by the top
+	// bits, which FNV alone mixes poorly for names differing at the end.
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		h ^= uint64(name[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h >> (64 - scanPosBits)
+}
+
+// scanOrder keeps names by scan position so a SCAN-family call can resume
+// from its cursor without looking at the rest. The position space is split
+// into len(buckets) equal ranges, a power of two that doubles and halves
+// with the number of names. Resizing keeps the ranges in order, so a cursor
+// (always the start of a range) means the same thing after one.
+type scanOrder struct {
+	buckets [][]scanItem
+	shift   uint // the bucket of a position is pos >> shift
+	n       int
+}
+
+type scanItem struct {
+	pos  uint64
+	name string
+}
+
+// add records name, which the caller knows is not present yet.
+func (o *scanOrder) add(name string) {
+	if o.buckets == nil {
+		o.resize(1)
+	}
+	pos := scanPos(name)
+	b := &o.buckets[pos>>o.shift]
+	*b = append(*b, scanItem{pos: pos, name: name})
+	o.n++
+	if o.n > 2*len(o.buckets) {
+		o.resize(2 * len(o.buckets))
+	}
+}
+
+// remove forgets name if it is present.
+func (o *scanOrder) remove(name string) {
+	if o.n == 0 {
+		return
+	}
+	b := &o.buckets[scanPos(name)>>o.shift]
+	for i, it := range *b {
+		if it.name != name {
+			continue
+		}
+		last := len(*b) - 1
+		(*b)[i] = (*b)[last]
+		(*b)[last] = scanItem{}
+		*b = (*b)[:last]
+		o.n--
+		break
+	}
+	switch {
+	case o.n == 0:
+		*o = scanOrder{}
+	case o.n < len(o.buckets)/4:
+		o.resize(len(o.buckets) / 2)
+	}
+}
+
+func (o *scanOrder) resize(size int) {
+	shift := uint(scanPosBits)
+	for s := size; s > 1; s >>= 1 {
+		shift--
+	}
+	buckets := make([][]scanItem, size)
+	for _, b := range o.buckets {
+		for _, it := range b {
+			buckets[it.pos>>shift] = append(buckets[it.pos>>shift], it)
+		}
+	}
+	o.buckets, o.shift = buckets, shift
+}
+
+// scan calls visit with the names at or after cursor, a whole bucket at a
+// time, until at least count were visited (10 if count is not positive).
+// It returns the cursor to resume from: the start of the next bucket, or 0
+// once the last one was visited.
+func (o *scanOrder) scan(cursor uint64, count int, visit func(name string)) uint64 {
+	if count <= 0 {
+		count = 10
+	}
+	if o.n == 0 {
+		return 0
+	}
+	visited := 0
+	for b := cursor >> o.shift; b < uint64(len(o.buckets)); b++ {
+		if visited >= count {
+			return b << o.shift
+		}
+		for _, it := range o.buckets[b] {
+			if it.pos >= cursor {
+				visit(it.name)
+				visited++
+			}
+		}
+	}
+	return 0
+}
+
+// dict is a map that also keeps its keys in scanOrder, for the collections
+// that support SCAN-family commands. Reads go to m directly; writes must go
+// through put and del so the order follows.
+type dict[V any] struct {
+	m     map[string]V
+	order scanOrder
+}
+
+func newDict[V any]() *dict[V] {
+	return &dict[V]{m: make(map[string]V)}
+}
+
+// dictOf builds a dict around m, which it takes over.
+func dictOf[V any](m map[string]V) *dict[V] {
+	d := &dict[V]{m: m}
+	for k := range m {
+		d.order.add(k)
+	}
+	return d
+}
+
+func (d *dict[V]) len() int { return len(d.m) }
+
+// put sets k to v and reports whether k is new.
+func (d *dict[V]) put(k string, v V) bool {
+	_, exists := d.m[k]
+	d.m[k] = v
+	if !exists {
+		d.order.add(k)
+	}
+	return !exists
+}
+
+// del removes k and reports whether it was present.
+func (d *dict[V]) del(k string) bool {
+	if _, ok := d.m[k]; !ok {
+		return false
+	}
+	delete(d.m, k)
+	d.order.remove(k)
+	return true
+}
+
+// scan is scanOrder.scan over the keys of d, passing their values along.
+func (d *dict[V]) scan(cursor uint64, count int, visit func(k string, v V)) uint64 {
+	return d.order.scan(cursor, count, func(k string) { visit(k, d.m[k]) })
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// setLocked returns the set at key. If create is true, a missing key is
+// created as an empty set (the caller must make sure it doesn't stay empty).
+// Caller must hold the lock of key's shard.
+func (s *Store) setLocked(key string, create bool) (*dict[struct{}], bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		if !create {
+			return nil, false, nil
+		}
+		m := newDict[struct{}]()
+		e := newEntry(KindSet)
+		e.set = m
+		s.putLocked(key, e)
+		return m, true, nil
+	}
+	if e.kind != KindSet {
+		return nil, false, ErrWrongType
+	}
+	return e.set, true, nil
+}
+
+// SAdd adds members and returns how many were not already present.
+func (s *Store) SAdd(key string, members []string) (int, error) {
+	defer s.lock(key)()
+
+	m, _, err := s.setLocked(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, v := range members {
+		if m.put(v, struct{}{}) {
+			s.growLocked(key, setMemberSize(v))
+			added++
+		}
+	}
+	return added, nil
+}
+
+// SRem removes members and returns how many were present. The key is
+// deleted once the set becomes empty.
+func (s *Store) SRem(key string, members []string) (int, error) {
+	defer s.lock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	removed := 0
+	for _, v := range members {
+		if m.del(v) {
+			s.growLocked(key, -setMemberSize(v))
+			removed++
+		}
+	}
+	if m.len() == 0 {
+		s.removeLocked(key)
+	}
+	return removed, nil
+}
+
+// SIsMember reports whether member is in the set at key.
+func (s *Store) SIsMember(key, member string) (bool, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, ok = m.m[member]
+	return ok, nil
+}
+
+// SMIsMember reports membership for each of members.
+func (s *Store) SMIsMember(key string, members []string) ([]bool, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bool, len(members))
+	if !ok {
+		return out, nil
+	}
+	for i, v := range members {
+		_, out[i] = m.m[v]
+	}
+	return out, nil
+}
+
+// SMembers returns all members of the set at key.
+func (s *Store) SMembers(key string) ([]string, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return []string{}, err
+	}
+	return setMembers(m.m), nil
+}
+
+// SCard returns the number of members in the set at key.
+func (s *Store) SCard(key string) (int, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return m.len(), nil
+}
+
+// SPop removes and returns up to count random members.
+func (s *Store) SPop(key string, count int) ([]string, error) {
+	defer s.lock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	out := make([]string, 0, min(count, m.len()))
+	// Map iteration order is already randomized.
+	for v := range m.m {
+		if len(out) == count {
+			break
+		}
+		out = append(out, v)
+	}
+	for _, v := range out {
+		m.del(v)
+		s.growLocked(key, -setMemberSize(v))
+	}
+	if m.len() == 0 {
+		s.removeLocked(key)
+	}
+	return out, nil
+}
+
+// SRandMember returns random members without removing them. A positive
+// count returns up to count distinct members; a negative count returns
+// exactly -count members that may repeat.
+func (s *Store) SRandMember(key string, count int) ([]string, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok || count == 0 {
+		return []string{}, err
+	}
+
+	if count > 0 {
+		out := make([]string, 0, min(count, m.len()))
+		for v := range m.m {
+			if len(out) == count {
+				break
+			}
+			out = append(out, v)
+		}
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+		return out, nil
+	}
+
+	all := setMembers(m.m)
+	out := make([]string, -count)
+	for i := range out {
+		out[i] = all[rand.IntN(len(all))]
+	}
+	return out, nil
+}
+
+// SMove moves member from src to dst. It returns false if member is not in src.
+func (s *Store) SMove(src, dst, member string) (bool, error) {
+	defer s.lockKeys(src, dst)()
+
+	sm, ok, err := s.setLocked(src, false)
+	if err != nil {
+		return false, err
+	}
+	// Check the destination type before mutating anything.
+	if _, _, err := s.setLocked(dst, false); err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	if _, ok := sm.m[member]; !ok {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+
+	sm.del(member)
+	s.growLocked(src, -setMemberSize(member))
+	if sm.len() == 0 {
+		s.removeLocked(src)
+	}
+	dm, _, _ := s.setLocked(dst, true)
+	if dm.put(member, struct{}{}) {
+		s.growLocked(dst, setMemberSize(member))
+	}
+	return true, nil
+}
+
+// SInter returns the members present in every set at keys. Missing keys
+// count as empty sets.
+func (s *Store) SInter(keys []string) ([]string, error) {
+	defer s.rlockKeys(keys...)()
+
+	res, err := s.setOpLocked(setInter, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(res), nil
+}
+
+// SUnion returns the members present in any of the sets at keys.
+func (s *Store) SUnion(keys []string) ([]string, error) {
+	defer s.rlockKeys(keys...)()
+
+	res, err := s.setOpLocked(setUnion, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(res), nil
+}
+
+// SDiff returns the members of the first set that are in none of the others.
+func (s *Store) SDiff(keys []string) ([]string, error) {
+	defer s.rlockKeys(keys...)()
+
+	res, err := s.setOpLocked(setDiff, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(res), nil
+}
+
+// SInterStore stores the intersection of keys at dst, replacing whatever
+// was there, and returns its cardinality.
+func (s *Store) SInterStore(dst string, keys []string) (int, error) {
+	return s.setOpStore(setInter, dst, keys)
+}
+
+// SUnionStore is SUnion that stores its result at dst.
+func (s *Store) SUnionStore(dst string, keys []string) (int, error) {
+	return s.setOpStore(setUnion, dst, keys)
+}
+
+// SDiffStore is SDiff that stores its result at dst.
+func (s *Store) SDiffStore(dst string, keys []string) (int, error) {
+	return s.setOpStore(setDiff, dst, keys)
+}
+
+// SInterCard returns the cardinality of the intersection of keys, stopping
+// early once limit is reached (0 means no limit).
+func (s *Store) SInterCard(keys []string, limit int) (int, error) {
+	defer s.rlockKeys(keys...)()
+
+	sets, err := s.setsLocked(keys)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for v := range sets[0] {
+		if inAll(v, sets[1:]) {
+			n++
+			if limit > 0 && n == limit {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// SScan iterates the set at key, about count members per call. See scanPos
+// for the cursor guarantees.
+func (s *Store) SScan(key string, cursor uint64, count int) ([]string, uint64, error) {
+	defer s.rlock(key)()
+
+	m, ok, err := s.setLocked(key, false)
+	if err != nil || !ok {
+		return []string{}, 0, err
+	}
+	var out []string
+	next := m.scan(cursor, count, func(v string, _ struct{}) {
+		out = append(out, v)
+	})
+	return out, next, nil
+}
+
+type setOp uint8
+
+const (
+	setInter setOp = iota
+	setUnion
+	setDiff
+)
+
+func (s *Store) setOpStore(op setOp, dst string, keys []string) (int, error) {
+	defer s.lockStore(dst, keys)()
+
+	res, err := s.setOpLocked(op, keys)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 {
+		s.removeLocked(dst)
+		return 0, nil
+	}
+	e := newEntry(KindSet)
+	e.set = dictOf(res)
+	e.size = e.memSize()
+	s.putLocked(dst, e)
+	return len(res), nil
+}
+
+// setOpLocked computes op over the sets at keys into a new map. Every key is
+// type-checked, even when the result is already known to be empty.
+func (s *Store) setOpLocked(op setOp, keys []string) (map[string]struct{}, error) {
+	sets, err := s.setsLocked(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]struct{})
+	switch op {
+	case setInter:
+		for v := range sets[0] {
+			if inAll(v, sets[1:]) {
+				res[v] = struct{}{}
+			}
+		}
+	case setUnion:
+		for _, m := range sets {
+			for v := range m {
+				res[v] = struct{}{}
+			}
+		}
+	case setDiff:
+		for v := range sets[0] {
+			res[v] = struct{}{}
+		}
+		for _, m := range sets[1:] {
+			for v := range m {
+				delete(res, v)
+			}
+		}
+	}
+	return res, nil
+}
+
+// setsLocked resolves keys to their sets; missing keys become nil (empty) sets.
+func (s *Store) setsLocked(keys []string) ([]map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, len(keys))
+	for i, k := range keys {
+		m, ok, err := s.setLocked(k, false)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sets[i] = m.m
+		}
+	}
+	return sets, nil
+}
+
+func inAll(v string, sets []map[string]struct{}) bool {
+	for _, m := range sets {
+		if _, ok := m[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func setMembers(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for v := range m {
+		out = append(out, v)
+	}
+	return out
+}
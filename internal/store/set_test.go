@@ -0,0 +1,199 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func sortedJoin(members []string) string {
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
+
+func TestSAdd_SRem_RemovesKeyWhenEmpty(t *testing.T) {
+	s := New()
+	if n, _ := s.SAdd("s", []string{"a", "b", "a"}); n != 2 {
+		t.Fatalf("expected 2 added, got %d", n)
+	}
+	if n, _ := s.SAdd("s", []string{"b", "c"}); n != 1 {
+		t.Fatalf("expected 1 added, got %d", n)
+	}
+	if n, _ := s.SRem("s", []string{"a", "b", "c", "x"}); n != 3 {
+		t.Fatalf("expected 3 removed, got %d", n)
+	}
+	if s.Exists("s") {
+		t.Fatal("expected empty set to be removed")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	s := New()
+	_, _ = s.SAdd("a", []string{"1", "2", "3", "4"})
+	_, _ = s.SAdd("b", []string{"3", "4", "5"})
+	_, _ = s.SAdd("c", []string{"4", "6"})
+
+	inter, _ := s.SInter([]string{"a", "b", "c"})
+	if got := sortedJoin(inter); got != "4" {
+		t.Fatalf("SInter: got %q", got)
+	}
+	union, _ := s.SUnion([]string{"a", "b", "c"})
+	if got := sortedJoin(union); got != "1,2,3,4,5,6" {
+		t.Fatalf("SUnion: got %q", got)
+	}
+	diff, _ := s.SDiff([]string{"a", "b", "c"})
+	if got := sortedJoin(diff); got != "1,2" {
+		t.Fatalf("SDiff: got %q", got)
+	}
+	if inter, _ := s.SInter([]string{"a", "missing"}); len(inter) != 0 {
+		t.Fatalf("expected empty intersection with a missing key, got %q", inter)
+	}
+
+	if n, _ := s.SInterCard([]string{"a", "b"}, 0); n != 2 {
+		t.Fatalf("SInterCard: expected 2, got %d", n)
+	}
+	if n, _ := s.SInterCard([]string{"a", "b"}, 1); n != 1 {
+		t.Fatalf("SInterCard with limit: expected 1, got %d", n)
+	}
+
+	if n, _ := s.SUnionStore("dst", []string{"b", "c"}); n != 4 {
+		t.Fatalf("SUnionStore: expected 4, got %d", n)
+	}
+	if n, _ := s.SDiffStore("dst", []string{"a", "a"}); n != 0 {
+		t.Fatalf("SDiffStore: expected 0, got %d", n)
+	}
+	if s.Exists("dst") {
+		t.Fatal("expected empty *STORE result to delete the destination")
+	}
+
+	s.Set("str", []byte("x"))
+	if _, err := s.SUnion([]string{"a", "str"}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.SInterStore("str", []string{"a", "b"}); err != nil {
+		t.Fatalf("SInterStore should overwrite any type, got %v", err)
+	}
+	if n, _ := s.SCard("str"); n != 2 {
+		t.Fatalf("expected str to hold the intersection, got card %d", n)
+	}
+}
+
+func TestSPop_SRandMember(t *testing.T) {
+	s := New()
+	_, _ = s.SAdd("s", []string{"a", "b", "c"})
+
+	if got, _ := s.SRandMember("s", 10); len(got) != 3 {
+		t.Fatalf("expected 3 distinct members, got %q", got)
+	}
+	if got, _ := s.SRandMember("s", -7); len(got) != 7 {
+		t.Fatalf("expected 7 members with repeats, got %q", got)
+	}
+
+	popped, _ := s.SPop("s", 2)
+	if len(popped) != 2 {
+		t.Fatalf("expected 2 popped, got %q", popped)
+	}
+	for _, m := range popped {
+		if ok, _ := s.SIsMember("s", m); ok {
+			t.Fatalf("popped member %q still present", m)
+		}
+	}
+	_, _ = s.SPop("s", 5)
+	if s.Exists("s") {
+		t.Fatal("expected set to be removed after popping everything")
+	}
+}
+
+func TestSMove_ChecksDestinationType(t *testing.T) {
+	s := New()
+	_, _ = s.SAdd("src", []string{"a", "b"})
+	s.Set("str", []byte("x"))
+
+	if _, err := s.SMove("src", "str", "a"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+	if ok, _ := s.SMove("src", "dst", "zz"); ok {
+		t.Fatal("expected missing member to report false")
+	}
+	if ok, _ := s.SMove("src", "dst", "a"); !ok {
+		t.Fatal("expected move to succeed")
+	}
+	if ok, _ := s.SIsMember("dst", "a"); !ok {
+		t.Fatal("expected a in dst")
+	}
+	_, _ = s.SMove("src", "dst", "b")
+	if s.Exists("src") {
+		t.Fatal("expected emptied source to be removed")
+	}
+}
+
+func TestSScan_ReturnsEveryMemberOnce(t *testing.T) {
+	s := New()
+	want := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		want = append(want, "m"+string(rune('a'+i%26))+strings.Repeat("x", i/26))
+	}
+	_, _ = s.SAdd("s", want)
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		batch, next, err := s.SScan("s", cursor, 7)
+		if err != nil {
+			t.Fatalf("sscan: %v", err)
+		}
+		for _, m := range batch {
+			seen[m]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d members, saw %d", len(want), len(seen))
+	}
+	for m, n := range seen {
+		if n != 1 {
+			t.Fatalf("member %q returned %d times", m, n)
+		}
+	}
+}
+
+func TestSScan_CallsStayNearCountWhileShrinking(t *testing.T) {
+	s := New()
+	members := make([]string, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		members = append(members, "m"+strconv.Itoa(i))
+	}
+	_, _ = s.SAdd("s", members)
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for calls := 1; ; calls++ {
+		batch, next, err := s.SScan("s", cursor, 10)
+		if err != nil {
+			t.Fatalf("sscan: %v", err)
+		}
+		if len(batch) > 20 {
+			t.Fatalf("call %d returned %d members for COUNT 10", calls, len(batch))
+		}
+		for _, m := range batch {
+			seen[m]++
+		}
+		if calls == 10 {
+			_, _ = s.SRem("s", members[:9000])
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for _, m := range members[9000:] {
+		if seen[m] != 1 {
+			t.Fatalf("member %q returned %d times", m, seen[m])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync"
+)
+
+// shardCount is the number of independently locked segments a Store is
+// split into. It is a power of two so a shard is picked by masking the key
+// hash, and at most 64 so a set of shards fits in a uint64.
+const shardCount = 64
+
+// shardSeed is shared by every Store so a key lands in the same shard
+// index everywhere; SWAPDB relies on it to exchange databases shard by
+// shard.
+var shardSeed = maphash.MakeSeed()
+
+// shard is one segment of a Store. mu guards data, volatile and order.
+type shard struct {
+	mu       sync.RWMutex
+	data     map[string]entry
+	volatile volatileSet // keys with an expiry, sampled by the reaper
+	order    scanOrder   // every key, in SCAN order
+}
+
+func shardIndex(key string) int {
+	return int(maphash.String(shardSeed, key) & (shardCount - 1))
+}
+
+// shard returns the segment holding key.
+func (s *Store) shard(key string) *shard { return &s.shards[shardIndex(key)] }
+
+// lock write-locks the shard of key and returns the matching unlock.
+func (s *Store) lock(key string) func() {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	return sh.mu.Unlock
+}
+
+// rlock read-locks the shard of key and returns the matching unlock.
+func (s *Store) rlock(key string) func() {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	return sh.mu.RUnlock
+}
+
+// lockKeys write-locks every shard holding one of keys, for commands that
+// must see and change several keys atomically.
+func (s *Store) lockKeys(keys ...string) func() { return s.lockShards(shardMask(keys), false) }
+
+// rlockKeys is lockKeys for commands that only read.
+func (s *Store) rlockKeys(keys ...string) func() { return s.lockShards(shardMask(keys), true) }
+
+// lockStore write-locks the shards of dst and keys, for the STORE variants
+// that compute dst from keys. keys is not modified.
+func (s *Store) lockStore(dst string, keys []string) func() {
+	return s.lockShards(shardMask(keys)|1<<shardIndex(dst), false)
+}
+
+// lockAll write-locks the whole store.
+func (s *Store) lockAll() func() { return s.lockShards(^uint64(0), false) }
+
+// rlockAll read-locks the whole store, for point-in-time views.
+func (s *Store) rlockAll() func() { return s.lockShards(^uint64(0), true) }
+
+func shardMask(keys []string) uint64 {
+	var mask uint64
+	for _, k := range keys {
+		mask |= 1 << shardIndex(k)
+	}
+	return mask
+}
+
+// lockShards locks the shards in mask in index order, so any two callers
+// locking overlapping sets cannot deadlock, and returns the unlock.
+func (s *Store) lockShards(mask uint64, read bool) func() {
+	for m := mask; m != 0; m &= m - 1 {
+		sh := &s.shards[bits.TrailingZeros64(m)]
+		if read {
+			sh.mu.RLock()
+		} else {
+			sh.mu.Lock()
+		}
+	}
+	return func() {
+		for m := mask; m != 0; m &= m - 1 {
+			sh := &s.shards[bits.TrailingZeros64(m)]
+			if read {
+				sh.mu.RUnlock()
+			} else {
+				sh.mu.Unlock()
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// spreadKeys returns n keys that all land in different shards.
+func spreadKeys(n int) []string {
+	var out []string
+	var used uint64
+	for i := 0; len(out) < n; i++ {
+		k := "k" + strconv.Itoa(i)
+		if bit := uint64(1) << shardIndex(k); used&bit == 0 {
+			used |= bit
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func TestMultiKeyCommandsAreAtomicAcrossShards(t *testing.T) {
+	s := New()
+	keys := spreadKeys(8)
+	pairs := func(v string) []KeyValue {
+		kv := make([]KeyValue, len(keys))
+		for i, k := range keys {
+			kv[i] = KeyValue{Key: k, Value: []byte(v)}
+		}
+		return kv
+	}
+	s.MSet(pairs("0"))
+
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; !stop.Load(); i++ {
+				s.MSet(pairs(strconv.Itoa(i)))
+			}
+		}()
+	}
+
+	check := func(vals []string) {
+		t.Helper()
+		for _, v := range vals[1:] {
+			if v != vals[0] {
+				t.Fatalf("torn multi-key write: %v", vals)
+			}
+		}
+	}
+	for i := 0; i < 500; i++ {
+		var vals []string
+		for _, v := range s.MGet(keys) {
+			vals = append(vals, string(v))
+		}
+		check(vals)
+
+		vals = vals[:0]
+		for _, e := range s.Snapshot() {
+			vals = append(vals, string(e.Value))
+		}
+		check(vals)
+	}
+	stop.Store(true)
+	wg.Wait()
+}
+
+func TestSwapExchangesEveryShard(t *testing.T) {
+	d := NewDatabases(2)
+	keys := spreadKeys(shardCount)
+	for _, k := range keys {
+		d.DB(0).Set(k, []byte("v"))
+		d.DB(0).Expire(k, 100)
+	}
+
+	d.Swap(0, 1)
+	if n := d.DB(0).Len(); n != 0 {
+		t.Fatalf("db 0 still has %d keys", n)
+	}
+	for _, k := range keys {
+		if !d.DB(1).Exists(k) || d.DB(1).TTL(k) <= 0 {
+			t.Fatalf("key %q or its expiry lost by SWAPDB", k)
+		}
+	}
+}
+
+// benchKeys is the size of the keyspace the benchmarks work on.
+const benchKeys = 10000
+
+// lockedStore serializes every call behind one lock, as the store did
+// before it was sharded, to give the sharded numbers a baseline.
+type lockedStore struct {
+	mu sync.RWMutex
+	s  *Store
+}
+
+func (l *lockedStore) Get(key string) ([]byte, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.s.Get(key)
+}
+
+func (l *lockedStore) Set(key string, val []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.s.Set(key, val)
+}
+
+type kvStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte)
+}
+
+// BenchmarkStore measures throughput of a GET-heavy workload (80% GET, 20%
+// SET over benchKeys keys) with 1, 8 and 64 concurrent clients, for the
+// sharded store and for the same store behind a single lock.
+func BenchmarkStore(b *testing.B) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+	val := []byte("value")
+
+	stores := []struct {
+		name string
+		new  func() kvStore
+	}{
+		{"sharded", func() kvStore { return New() }},
+		{"single-lock", func() kvStore { return &lockedStore{s: New()} }},
+	}
+	for _, st := range stores {
+		for _, clients := range []int{1, 8, 64} {
+			b.Run(fmt.Sprintf("%s/clients=%d", st.name, clients), func(b *testing.B) {
+				s := st.new()
+				for _, k := range keys {
+					s.Set(k, val)
+				}
+
+				var next atomic.Int64
+				var wg sync.WaitGroup
+				b.ResetTimer()
+				for c := 0; c < clients; c++ {
+					wg.Add(1)
+					go func(seed uint64) {
+						defer wg.Done()
+						r := rand.New(rand.NewPCG(seed, 0))
+						for next.Add(1) <= int64(b.N) {
+							k := keys[r.IntN(len(keys))]
+							if r.IntN(10) < 2 {
+								s.Set(k, val)
+							} else {
+								s.Get(k)
+							}
+						}
+					}(uint64(c))
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
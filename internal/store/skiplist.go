@@ -0,0 +1,243 @@
+package store
+
+import "math/rand/v2"
+
+// Skiplist parameters, as in Redis: a node gets one more level with
+// probability zslP, up to zslMaxLevel levels.
+const (
+	zslMaxLevel = 32
+	zslP        = 0.25
+)
+
+// zskiplist keeps sorted set members ordered by (score, member). Every
+// forward link records its span (how many nodes it skips) so ranks can be
+// computed in O(log n).
+type zskiplist struct {
+	header *zslNode
+	tail   *zslNode
+	length int
+	level  int
+}
+
+type zslNode struct {
+	member   string
+	score    float64
+	backward *zslNode
+	level    []zslLevel
+}
+
+type zslLevel struct {
+	forward *zslNode
+	span    int
+}
+
+func newZskiplist() *zskiplist {
+	return &zskiplist{
+		header: &zslNode{level: make([]zslLevel, zslMaxLevel)},
+		level:  1,
+	}
+}
+
+func zslRandomLevel() int {
+	lvl := 1
+	for lvl < zslMaxLevel && rand.Float64() < zslP {
+		lvl++
+	}
+	return lvl
+}
+
+// zslLess reports whether (s1, m1) sorts before (s2, m2).
+func zslLess(s1 float64, m1 string, s2 float64, m2 string) bool {
+	return s1 < s2 || (s1 == s2 && m1 < m2)
+}
+
+// insert adds a new node. The caller must make sure member is not already
+// present.
+func (zsl *zskiplist) insert(score float64, member string) *zslNode {
+	var update [zslMaxLevel]*zslNode
+	var rank [zslMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for f := x.level[i].forward; f != nil && zslLess(f.score, f.member, score, member); f = x.level[i].forward {
+			rank[i] += x.level[i].span
+			x = f
+		}
+		update[i] = x
+	}
+
+	lvl := zslRandomLevel()
+	if lvl > zsl.level {
+		for i := zsl.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = lvl
+	}
+
+	x = &zslNode{member: member, score: score, level: make([]zslLevel, lvl)}
+	for i := 0; i < lvl; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := lvl; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+// delete removes the node holding (score, member). Returns false if absent.
+func (zsl *zskiplist) delete(score float64, member string) bool {
+	var update [zslMaxLevel]*zslNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && zslLess(f.score, f.member, score, member); f = x.level[i].forward {
+			x = f
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+	return true
+}
+
+// rank returns the 1-based rank of (score, member), or 0 if it is absent.
+func (zsl *zskiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && (f.score < score || (f.score == score && f.member <= member)); f = x.level[i].forward {
+			rank += x.level[i].span
+			x = f
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the given 1-based rank, or nil.
+func (zsl *zskiplist) byRank(rank int) *zslNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstInScoreRange returns the lowest node within r, or nil.
+func (zsl *zskiplist) firstInScoreRange(r ScoreRange) *zslNode {
+	if r.empty() {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && !r.aboveMin(f.score); f = x.level[i].forward {
+			x = f
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.belowMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+// lastInScoreRange returns the highest node within r, or nil.
+func (zsl *zskiplist) lastInScoreRange(r ScoreRange) *zslNode {
+	if r.empty() {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && r.belowMax(f.score); f = x.level[i].forward {
+			x = f
+		}
+	}
+	if x == zsl.header || !r.aboveMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// firstInLexRange returns the lowest node within r, or nil. Like Redis, lex
+// ranges assume all members share the same score.
+func (zsl *zskiplist) firstInLexRange(r LexRange) *zslNode {
+	if r.empty() {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && !r.Min.below(f.member); f = x.level[i].forward {
+			x = f
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.Max.above(x.member) {
+		return nil
+	}
+	return x
+}
+
+// lastInLexRange returns the highest node within r, or nil.
+func (zsl *zskiplist) lastInLexRange(r LexRange) *zslNode {
+	if r.empty() {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for f := x.level[i].forward; f != nil && r.Max.above(f.member); f = x.level[i].forward {
+			x = f
+		}
+	}
+	if x == zsl.header || !r.Min.below(x.member) {
+		return nil
+	}
+	return x
+}
@@ -44,8 +44,8 @@ func TestSnapshot_ContainsNonExpiredKeysWithValuesAndExpiry(t *testing.T) {
 			if string(e.Value) != "2" {
 				t.Fatalf("key b: expected value 2, got %q", e.Value)
 			}
-			if e.ExpiresAt == nil || *e.ExpiresAt != future {
-				t.Fatalf("key b: expected ExpiresAt %d, got %v", future, e.ExpiresAt)
+			if e.ExpiresAt == nil || *e.ExpiresAt != future*1000 {
+				t.Fatalf("key b: expected ExpiresAt %d, got %v", future*1000, e.ExpiresAt)
 			}
 		}
 	}
@@ -69,3 +69,36 @@ func TestSnapshot_PurgesExpiredKeys(t *testing.T) {
 		t.Fatal("expected key a to be purged from store after snapshot")
 	}
 }
+
+func TestRestore_RebuildsSnapshotAndMemoryTotals(t *testing.T) {
+	src := New()
+	src.Set("s", []byte("v"))
+	src.RPush("l", [][]byte{[]byte("a"), []byte("b")})
+	src.ZAdd("z", ZAddOptions{}, []ScoreMember{{"m", 2}})
+	addIDs(t, src, "x", StreamID{1, 0}, StreamID{2, 0})
+	src.XGroupCreate("x", "g", GroupStart{Entries: NoEntriesRead}, false)
+	src.XReadGroup("x", "g", "c", nil, 1, false)
+	src.PExpireAt("l", time.Now().Add(time.Hour).UnixMilli(), ExpireOptions{})
+
+	dst := New()
+	for _, e := range src.Snapshot() {
+		if !dst.Restore(e) {
+			t.Fatalf("restore %q skipped", e.Key)
+		}
+	}
+	if dst.UsedMemory() != src.UsedMemory() {
+		t.Fatalf("used memory %d, want %d", dst.UsedMemory(), src.UsedMemory())
+	}
+	p, _ := dst.XPending("x", "g", StreamID{}, MaxStreamID, 10, "", 0)
+	if len(p) != 1 || p[0].Consumer != "c" || p[0].ID != (StreamID{1, 0}) {
+		t.Fatalf("restored PEL: %+v", p)
+	}
+	if dst.TTL("l") <= 0 {
+		t.Fatalf("restored TTL = %d", dst.TTL("l"))
+	}
+
+	past := time.Now().Add(-time.Second).UnixMilli()
+	if dst.Restore(SnapshotEntry{Key: "old", Kind: KindString, Value: []byte("v"), ExpiresAt: &past}) {
+		t.Fatal("restored an already expired key")
+	}
+}
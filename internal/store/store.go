@@ -1,130 +1,231 @@
 package store
 
 import (
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Kind identifies the data type stored at a key.
+type Kind uint8
+
+const (
+	KindString Kind = iota
+	KindHash
+	KindList
+	KindSet
+	KindZSet
+	KindStream
+)
+
+// String returns the name reported by the TYPE command.
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindHash:
+		return "hash"
+	case KindList:
+		return "list"
+	case KindSet:
+		return "set"
+	case KindZSet:
+		return "zset"
+	case KindStream:
+		return "stream"
+	default:
+		return "none"
+	}
+}
+
 type entry struct {
-	value     []byte
-	expiresAt *time.Time // nil means no expiration
+	kind      Kind
+	value     []byte          // KindString
+	hash      *dict[[]byte]   // KindHash
+	list      *quicklist      // KindList
+	set       *dict[struct{}] // KindSet
+	zset      *zset           // KindZSet
+	stream    *stream         // KindStream
+	expiresAt *time.Time      // nil means no expiration
+	access    *access         // shared by every copy of the entry, see touch
+	size      int64           // estimated value size in bytes, see memory.go
 }
 
+// Store is a keyspace split into shardCount shards (see shard.go). A
+// command locks only the shards of the keys it touches; reads take the
+// shard lock for reading and never modify the map, so an expired key met by
+// a read is reported missing and left to the next write or the reaper.
 type Store struct {
-	mu   sync.RWMutex
-	data map[string]entry
+	shards [shardCount]shard
+	used   atomic.Int64 // estimated bytes held, see putLocked
+
+	expired  atomic.Int64 // keys whose expiry passed, see noteExpired
+	onExpire atomic.Pointer[func(key string)]
 }
 
 // SnapshotEntry represents the minimum data needed to rebuild DB state.
+// Exactly one of the value fields is set, according to Kind.
 type SnapshotEntry struct {
 	Key       string
-	Value     []byte
-	ExpiresAt *int64 // unix seconds; nil means no expiry
+	Kind      Kind
+	Value     []byte            // KindString
+	Hash      map[string][]byte // KindHash
+	List      [][]byte          // KindList, head to tail
+	Set       []string          // KindSet, in no particular order
+	ZSet      []ScoreMember     // KindZSet, ascending by score
+	Stream    *StreamSnapshot   // KindStream
+	ExpiresAt *int64            // unix milliseconds; nil means no expiry
+	DB        int               // database index (set by Databases.Snapshot)
 }
 
 func New() *Store {
-	return &Store{
-		data: make(map[string]entry),
+	s := &Store{}
+	for i := range s.shards {
+		s.shards[i].data = make(map[string]entry)
 	}
+	return s
 }
 
+// Get returns the string stored at key. Keys holding other types are
+// reported as missing; use GetString to tell the two apart.
 func (s *Store) Get(key string) ([]byte, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	e, ok := s.data[key]
-	if !ok {
+	val, ok, err := s.GetString(key)
+	if err != nil {
 		return nil, false
 	}
+	return val, ok
+}
 
-	if isExpired(e, time.Now()) {
-		delete(s.data, key)
-		return nil, false
+// GetString returns the string stored at key, or ErrWrongType if key holds
+// another data type.
+func (s *Store) GetString(key string) ([]byte, bool, error) {
+	defer s.rlock(key)()
+
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+	if e.kind != KindString {
+		return nil, false, ErrWrongType
 	}
 
 	// Return a copy so callers can't mutate internal state
 	out := make([]byte, len(e.value))
 	copy(out, e.value)
-	return out, true
+	return out, true, nil
+}
+
+// lookupLocked returns the live entry for key and records the access. An
+// expired entry is reported missing (and its expiry to OnExpire) but stays
+// in the map, so this is safe under the shard's read lock. Caller must hold
+// the lock of key's shard.
+func (s *Store) lookupLocked(key string, now time.Time) (entry, bool) {
+	e, ok := s.peekLocked(key, now)
+	if ok {
+		e.touch(now)
+	}
+	return e, ok
+}
+
+// peekLocked is lookupLocked without recording an access (TYPE, OBJECT).
+func (s *Store) peekLocked(key string, now time.Time) (entry, bool) {
+	e, ok := s.shard(key).data[key]
+	if !ok {
+		return entry{}, false
+	}
+	if isExpired(e, now) {
+		s.noteExpired(key, e)
+		return entry{}, false
+	}
+	return e, true
 }
 
 func (s *Store) Set(key string, val []byte) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.lock(key)()
 
 	// Store a copy for safety
 	cp := make([]byte, len(val))
 	copy(cp, val)
 
 	// SET clears any existing expiry
-	s.data[key] = entry{
-		value:     cp,
-		expiresAt: nil,
+	e := newEntry(KindString)
+	e.setValue(cp)
+	s.putLocked(key, e)
+}
+
+// SetOptions are the SET command modifiers.
+type SetOptions struct {
+	NX       bool       // only set if key does not exist
+	XX       bool       // only set if key exists
+	Get      bool       // key must be missing or hold a string (its old value is returned)
+	KeepTTL  bool       // retain the existing expiry
+	ExpireAt *time.Time // absolute expiry; nil means none (unless KeepTTL)
+}
+
+// SetWithOptions is Set with the NX / XX / GET / KEEPTTL / expiry modifiers,
+// evaluated atomically. It returns the previous string value (if any) and
+// whether the write happened. An expiry that is already in the past
+// deletes the key.
+func (s *Store) SetWithOptions(key string, val []byte, opts SetOptions) (old []byte, hadOld, written bool, err error) {
+	defer s.lock(key)()
+
+	now := time.Now()
+	e, exists := s.lookupLocked(key, now)
+	if exists && e.kind == KindString {
+		old, hadOld = copyBytes(e.value), true
+	}
+	if opts.Get && exists && e.kind != KindString {
+		return nil, false, false, ErrWrongType
+	}
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return old, hadOld, false, nil
 	}
+
+	ne := newEntry(KindString)
+	ne.setValue(copyBytes(val))
+	switch {
+	case opts.KeepTTL && exists:
+		ne.expiresAt = e.expiresAt
+	case opts.ExpireAt != nil:
+		if !now.Before(*opts.ExpireAt) {
+			s.removeLocked(key)
+			return old, hadOld, true, nil
+		}
+		exp := *opts.ExpireAt
+		ne.expiresAt = &exp
+	}
+	s.putLocked(key, ne)
+	return old, hadOld, true, nil
 }
 
 func (s *Store) Del(key string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.lock(key)()
 
-	e, ok := s.data[key]
+	e, ok := s.shard(key).data[key]
 	if !ok {
 		return false
 	}
 
 	// If it's expired. treat it as already gone
 	if isExpired(e, time.Now()) {
-		delete(s.data, key)
+		s.expireLocked(key)
 		return false
 	}
 
-	delete(s.data, key)
+	s.removeLocked(key)
 	return true
 }
 
 func (s *Store) Exists(key string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.rlock(key)()
 
-	e, ok := s.data[key]
-	if !ok {
-		return false
-	}
-
-	if isExpired(e, time.Now()) {
-		delete(s.data, key)
-		return false
-	}
-
-	return true
+	_, ok := s.peekLocked(key, time.Now())
+	return ok
 }
 
 // Expire sets an expiration on key for given number of seconds
 // Returns true if key exists and expiry was set, false otherwise
 func (s *Store) Expire(key string, seconds int64) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	e, ok := s.data[key]
-	if !ok {
-		return false
-	}
-
-	now := time.Now()
-	if isExpired(e, now) {
-		delete(s.data, key)
-		return false
-	}
-
-	if seconds <= 0 {
-		delete(s.data, key)
-		return true
-	}
-
-	exp := now.Add(time.Duration(seconds) * time.Second)
-	e.expiresAt = &exp
-	s.data[key] = e
-	return true
+	return s.PExpireAt(key, time.Now().UnixMilli()+seconds*1000, ExpireOptions{})
 }
 
 // TTL returns Redis-like TTL semantics
@@ -132,33 +233,57 @@ func (s *Store) Expire(key string, seconds int64) bool {
 // -1 if key exists but has no expiry
 // >=0 remaining seconds otherwise
 func (s *Store) TTL(key string) int64 {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	e, ok := s.data[key]
-	if !ok {
-		return -2
+	ms := s.PTTL(key)
+	if ms < 0 {
+		return ms
 	}
+	// ceil to seconds
+	return (ms + 999) / 1000
+}
+
+// PTTL is TTL in milliseconds.
+func (s *Store) PTTL(key string) int64 {
+	defer s.rlock(key)()
 
 	now := time.Now()
-	if isExpired(e, now) {
-		delete(s.data, key)
+	e, ok := s.lookupLocked(key, now)
+	if !ok {
 		return -2
 	}
-
 	if e.expiresAt == nil {
 		return -1
 	}
+	// ceil to milliseconds; the key is live so this is at least 1
+	return int64((e.expiresAt.Sub(now) + time.Millisecond - 1) / time.Millisecond)
+}
 
-	remaining := e.expiresAt.Sub(now)
-	if remaining <= 0 {
-		delete(s.data, key)
+// ExpireTimeMs returns the absolute unix time in milliseconds at which key
+// expires, -1 if it has no expiry and -2 if it does not exist.
+func (s *Store) ExpireTimeMs(key string) int64 {
+	defer s.rlock(key)()
+
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
 		return -2
 	}
+	if e.expiresAt == nil {
+		return -1
+	}
+	return e.expiresAt.UnixMilli()
+}
 
-	// ceil to seconds
-	sec := int64((remaining + time.Second - 1) / time.Second)
-	return sec
+// Persist removes the expiry from key. It returns false if the key does not
+// exist or has no expiry.
+func (s *Store) Persist(key string) bool {
+	defer s.lock(key)()
+
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok || e.expiresAt == nil {
+		return false
+	}
+	e.expiresAt = nil
+	s.putLocked(key, e)
+	return true
 }
 
 func isExpired(e entry, now time.Time) bool {
@@ -171,62 +296,108 @@ func isExpired(e entry, now time.Time) bool {
 // ExpireAt sets an absolute expiration time on key (unix timestamp in seconds).
 // Returns true if key exists and expiry was set (or key deleted due to past timestamp), false otherwise.
 func (s *Store) ExpireAt(key string, unixSeconds int64) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.PExpireAt(key, unixSeconds*1000, ExpireOptions{})
+}
 
-	e, ok := s.data[key]
-	if !ok {
+// ExpireOptions are the EXPIRE NX / XX / GT / LT conditions. For GT and LT
+// a key without an expiry counts as expiring infinitely far in the future.
+type ExpireOptions struct {
+	NX bool // only if the key has no expiry
+	XX bool // only if the key has an expiry
+	GT bool // only if the new expiry is later
+	LT bool // only if the new expiry is earlier
+}
+
+func (o ExpireOptions) allows(cur *time.Time, exp time.Time) bool {
+	switch {
+	case o.NX && cur != nil, o.XX && cur == nil:
 		return false
+	case o.GT:
+		return cur != nil && exp.After(*cur)
+	case o.LT:
+		return cur == nil || exp.Before(*cur)
 	}
+	return true
+}
+
+// PExpireAt sets key to expire at the given unix time in milliseconds when
+// opts allow it. It returns true if key exists and the expiry was applied;
+// a time that is already past deletes the key.
+func (s *Store) PExpireAt(key string, unixMs int64, opts ExpireOptions) bool {
+	defer s.lock(key)()
 
 	now := time.Now()
-	if isExpired(e, now) {
-		delete(s.data, key)
+	e, ok := s.lookupLocked(key, now)
+	if !ok {
 		return false
 	}
 
-	exp := time.Unix(unixSeconds, 0)
+	exp := time.UnixMilli(unixMs)
+	if !opts.allows(e.expiresAt, exp) {
+		return false
+	}
 
 	// Redis semantics: if timestamp is in the past (or now), the key is deleted and return 1 (since it existed)
 	if !now.Before(exp) {
-		delete(s.data, key)
+		s.removeLocked(key)
 		return true
 	}
 
 	e.expiresAt = &exp
-	s.data[key] = e
+	s.putLocked(key, e)
 	return true
 }
 
 // Snapshot returns a point-in-time copy of all non-expired keys.
-// Values are deep-copied. Expired keys are purged during snapshot.
+// Values are deep-copied. Every shard is read-locked for the whole copy, so
+// no write lands halfway through; expired keys are skipped.
 func (s *Store) Snapshot() []SnapshotEntry {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.rlockAll()()
 
 	now := time.Now()
 
-	out := make([]SnapshotEntry, 0, len(s.data))
-	for k, e := range s.data {
+	out := make([]SnapshotEntry, 0)
+	for i := range s.shards {
+		out = s.shards[i].snapshot(out, now)
+	}
+	return out
+}
+
+func (sh *shard) snapshot(out []SnapshotEntry, now time.Time) []SnapshotEntry {
+	for k, e := range sh.data {
 		if isExpired(e, now) {
-			delete(s.data, k)
 			continue
 		}
 
-		v := make([]byte, len(e.value))
-		copy(v, e.value)
-
 		var exp *int64
 		if e.expiresAt != nil {
-			ts := e.expiresAt.Unix()
+			ts := e.expiresAt.UnixMilli()
 			exp = &ts
 		}
 
-		out = append(out, SnapshotEntry{
+		se := SnapshotEntry{
 			Key:       k,
-			Value:     v,
+			Kind:      e.kind,
 			ExpiresAt: exp,
-		})
+		}
+		switch e.kind {
+		case KindString:
+			v := make([]byte, len(e.value))
+			copy(v, e.value)
+			se.Value = v
+		case KindHash:
+			se.Hash = copyHash(e.hash.m)
+		case KindList:
+			se.List = copyItems(e.list.all())
+		case KindSet:
+			se.Set = setMembers(e.set.m)
+		case KindZSet:
+			se.ZSet = e.zset.all()
+		case KindStream:
+			se.Stream = e.stream.snapshot()
+		}
+
+		out = append(out, se)
 	}
 	return out
 }
@@ -0,0 +1,581 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamID identifies a stream entry: a millisecond timestamp and a
+// sequence number for entries added within the same millisecond.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID is the largest possible ID ("+" in ranges).
+var MaxStreamID = StreamID{math.MaxUint64, math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less reports whether id sorts before o.
+func (id StreamID) Less(o StreamID) bool {
+	return id.Ms < o.Ms || (id.Ms == o.Ms && id.Seq < o.Seq)
+}
+
+// IsZero reports whether id is 0-0.
+func (id StreamID) IsZero() bool { return id == StreamID{} }
+
+// Next returns the smallest ID after id; false if id is MaxStreamID.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{id.Ms, id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{id.Ms + 1, 0}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the largest ID before id; false if id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{id.Ms, id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// ParseStreamID parses "<ms>-<seq>" or "<ms>", in which case the sequence
+// is missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	if !hasSeq {
+		return StreamID{ms, missingSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	return StreamID{ms, seq}, true
+}
+
+// StreamEntry is a stream entry: its ID and field/value pairs, flattened.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// streamNodeSize is how many entries a stream node holds, like Redis'
+// stream-node-max-entries.
+const streamNodeSize = 100
+
+// streamNode is a run of consecutive entries, the counterpart of a Redis
+// listpack: appends fill the last node, and approximate trimming drops
+// whole nodes.
+type streamNode struct {
+	entries []StreamEntry
+}
+
+func (n *streamNode) first() StreamID { return n.entries[0].ID }
+func (n *streamNode) last() StreamID  { return n.entries[len(n.entries)-1].ID }
+
+// stream is an append-only log of entries with increasing IDs. Nodes are
+// kept in ID order, so finding an entry is a binary search over the nodes
+// and then within one, as a walk down Redis' radix tree would be.
+type stream struct {
+	nodes        []*streamNode // none of them empty
+	length       int
+	lastID       StreamID // the largest ID ever added, even if deleted since
+	maxDeletedID StreamID // the largest ID removed by XDEL
+	entriesAdded uint64   // every entry ever added
+	groups       map[string]*streamGroup
+	bytes        int64 // memory estimate of the entries, see streamEntrySize
+}
+
+func newStream() *stream {
+	return &stream{groups: make(map[string]*streamGroup)}
+}
+
+func streamEntrySize(e StreamEntry) int64 {
+	n := int64(16 + elemOverhead) // ID and slot
+	for _, f := range e.Fields {
+		n += int64(len(f))
+	}
+	return n
+}
+
+// nextID returns the ID an auto-generated entry gets at unix millisecond
+// now: now, or one more than the last ID if the clock has not moved past
+// it.
+func (st *stream) nextID(now uint64) (StreamID, bool) {
+	if now > st.lastID.Ms {
+		return StreamID{now, 0}, true
+	}
+	return st.lastID.Next()
+}
+
+// append adds e, whose ID must be greater than lastID.
+func (st *stream) append(e StreamEntry) {
+	if len(st.nodes) == 0 || len(st.nodes[len(st.nodes)-1].entries) >= streamNodeSize {
+		st.nodes = append(st.nodes, &streamNode{entries: make([]StreamEntry, 0, streamNodeSize)})
+	}
+	n := st.nodes[len(st.nodes)-1]
+	n.entries = append(n.entries, e)
+	st.length++
+	st.lastID = e.ID
+	st.entriesAdded++
+	st.bytes += streamEntrySize(e)
+}
+
+// seek returns the position of the first entry with an ID >= id, which is
+// (len(nodes), 0) if there is none.
+func (st *stream) seek(id StreamID) (int, int) {
+	ni := sort.Search(len(st.nodes), func(i int) bool { return !st.nodes[i].last().Less(id) })
+	if ni == len(st.nodes) {
+		return ni, 0
+	}
+	entries := st.nodes[ni].entries
+	return ni, sort.Search(len(entries), func(i int) bool { return !entries[i].ID.Less(id) })
+}
+
+// get returns the entry with exactly id.
+func (st *stream) get(id StreamID) (StreamEntry, bool) {
+	ni, ei := st.seek(id)
+	if ni == len(st.nodes) || st.nodes[ni].entries[ei].ID != id {
+		return StreamEntry{}, false
+	}
+	return st.nodes[ni].entries[ei], true
+}
+
+// first returns the entry with the smallest ID.
+func (st *stream) first() (StreamEntry, bool) {
+	if st.length == 0 {
+		return StreamEntry{}, false
+	}
+	return st.nodes[0].entries[0], true
+}
+
+// lastEntry returns the entry with the largest ID.
+func (st *stream) lastEntry() (StreamEntry, bool) {
+	if st.length == 0 {
+		return StreamEntry{}, false
+	}
+	n := st.nodes[len(st.nodes)-1]
+	return n.entries[len(n.entries)-1], true
+}
+
+// rangeIDs returns up to count (negative: all) entries with IDs in
+// [start, end], from end down to start if rev is set.
+func (st *stream) rangeIDs(start, end StreamID, count int, rev bool) []StreamEntry {
+	out := []StreamEntry{}
+	if end.Less(start) || count == 0 {
+		return out
+	}
+	if !rev {
+		ni, ei := st.seek(start)
+		for ; ni < len(st.nodes); ni, ei = ni+1, 0 {
+			for _, e := range st.nodes[ni].entries[ei:] {
+				if end.Less(e.ID) || len(out) == count {
+					return out
+				}
+				out = append(out, e)
+			}
+		}
+		return out
+	}
+
+	// Start right after end and walk back.
+	ni, ei := len(st.nodes), 0
+	if next, ok := end.Next(); ok {
+		ni, ei = st.seek(next)
+	}
+	for {
+		if ei == 0 {
+			if ni == 0 {
+				return out
+			}
+			ni--
+			ei = len(st.nodes[ni].entries)
+		}
+		ei--
+		e := st.nodes[ni].entries[ei]
+		if e.ID.Less(start) || len(out) == count {
+			return out
+		}
+		out = append(out, e)
+	}
+}
+
+// remove deletes the entry with id and reports whether it existed.
+func (st *stream) remove(id StreamID) bool {
+	ni, ei := st.seek(id)
+	if ni == len(st.nodes) || st.nodes[ni].entries[ei].ID != id {
+		return false
+	}
+	st.removeAt(ni, ei)
+	if st.maxDeletedID.Less(id) {
+		st.maxDeletedID = id
+	}
+	return true
+}
+
+func (st *stream) removeAt(ni, ei int) {
+	n := st.nodes[ni]
+	st.bytes -= streamEntrySize(n.entries[ei])
+	st.length--
+	n.entries = append(n.entries[:ei], n.entries[ei+1:]...)
+	if len(n.entries) == 0 {
+		st.nodes = append(st.nodes[:ni], st.nodes[ni+1:]...)
+	}
+}
+
+// StreamTrimStrategy selects what XADD and XTRIM trim by.
+type StreamTrimStrategy uint8
+
+const (
+	TrimNone StreamTrimStrategy = iota
+	TrimMaxLen
+	TrimMinID
+)
+
+// StreamTrim is a MAXLEN / MINID trimming request. Approximate trimming
+// ("~") only removes whole nodes, and at most Limit entries (0: no limit).
+type StreamTrim struct {
+	Strategy StreamTrimStrategy
+	MaxLen   int64
+	MinID    StreamID
+	Approx   bool
+	Limit    int64
+}
+
+// DefaultTrimLimit is the LIMIT of an approximate trim that gives none,
+// as in Redis: 100 nodes' worth of entries.
+const DefaultTrimLimit = 100 * streamNodeSize
+
+// trim removes entries from the head of the stream as t asks and returns
+// how many.
+func (st *stream) trim(t StreamTrim) int64 {
+	var removed int64
+	done := func(next StreamID, n int) bool {
+		if t.Strategy == TrimMaxLen {
+			return int64(st.length-n) < t.MaxLen
+		}
+		return !next.Less(t.MinID)
+	}
+	for st.length > 0 {
+		n := st.nodes[0]
+		if t.Approx {
+			// Only drop n if everything in it has to go.
+			if done(n.last(), len(n.entries)) || (t.Limit > 0 && removed+int64(len(n.entries)) > t.Limit) {
+				break
+			}
+			for _, e := range n.entries {
+				st.bytes -= streamEntrySize(e)
+			}
+			st.length -= len(n.entries)
+			removed += int64(len(n.entries))
+			st.nodes = st.nodes[1:]
+			continue
+		}
+		if done(n.first(), 1) {
+			break
+		}
+		st.removeAt(0, 0)
+		removed++
+	}
+	return removed
+}
+
+// streamLocked returns the stream at key. If create is true, a missing key
+// is created as an empty stream. Caller must hold the lock of key's shard.
+func (s *Store) streamLocked(key string, create bool) (*stream, bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		if !create {
+			return nil, false, nil
+		}
+		st := newStream()
+		e := newEntry(KindStream)
+		e.stream = st
+		s.putLocked(key, e)
+		return st, true, nil
+	}
+	if e.kind != KindStream {
+		return nil, false, ErrWrongType
+	}
+	return e.stream, true, nil
+}
+
+// XAddID is the ID argument of XADD: "*", "<ms>-*" or an explicit ID.
+type XAddID struct {
+	ID      StreamID
+	AutoMs  bool // "*": use the current time
+	AutoSeq bool // "<ms>-*": pick the next sequence of ID.Ms
+}
+
+// StreamAdded is the result of XAdd.
+type StreamAdded struct {
+	ID      StreamID
+	Trimmed int64 // entries removed by the trim
+	Length  int   // of the stream afterwards
+}
+
+// XAdd appends an entry with fields to the stream at key, creating it
+// unless noMkStream is set, then applies trim. ok is false if the stream
+// does not exist and noMkStream is set.
+func (s *Store) XAdd(key string, id XAddID, fields []string, noMkStream bool, trim StreamTrim) (StreamAdded, bool, error) {
+	defer s.lock(key)()
+
+	st, exists, err := s.streamLocked(key, false)
+	if err != nil {
+		return StreamAdded{}, false, err
+	}
+	if !exists {
+		if noMkStream {
+			return StreamAdded{}, false, nil
+		}
+		st = newStream()
+	}
+
+	var newID StreamID
+	switch {
+	case id.AutoMs:
+		var ok bool
+		if newID, ok = st.nextID(uint64(time.Now().UnixMilli())); !ok {
+			return StreamAdded{}, false, ErrStreamExhausted
+		}
+	case id.AutoSeq:
+		switch {
+		case id.ID.Ms > st.lastID.Ms:
+			newID = StreamID{id.ID.Ms, 0}
+		case id.ID.Ms == st.lastID.Ms && st.lastID.Seq < math.MaxUint64:
+			newID = StreamID{id.ID.Ms, st.lastID.Seq + 1}
+		default:
+			return StreamAdded{}, false, ErrStreamIDTooSmall
+		}
+	default:
+		newID = id.ID
+	}
+	if newID.IsZero() {
+		return StreamAdded{}, false, ErrStreamIDZero
+	}
+	if !st.lastID.Less(newID) {
+		return StreamAdded{}, false, ErrStreamIDTooSmall
+	}
+
+	if !exists {
+		e := newEntry(KindStream)
+		e.stream = st
+		s.putLocked(key, e)
+	}
+	before := st.bytes
+	st.append(StreamEntry{ID: newID, Fields: append([]string(nil), fields...)})
+	added := StreamAdded{ID: newID}
+	if trim.Strategy != TrimNone {
+		added.Trimmed = st.trim(trim)
+	}
+	added.Length = st.length
+	s.growLocked(key, st.bytes-before)
+	return added, true, nil
+}
+
+// XTrim trims the stream at key and returns how many entries it removed
+// and how many are left.
+func (s *Store) XTrim(key string, trim StreamTrim) (int64, int, error) {
+	defer s.lock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return 0, 0, err
+	}
+	before := st.bytes
+	n := st.trim(trim)
+	s.growLocked(key, st.bytes-before)
+	return n, st.length, nil
+}
+
+// XDel removes the entries with ids and returns how many existed. The
+// stream stays, even if empty, with its last ID and groups.
+func (s *Store) XDel(key string, ids []StreamID) (int, error) {
+	defer s.lock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	before := st.bytes
+	n := 0
+	for _, id := range ids {
+		if st.remove(id) {
+			n++
+		}
+	}
+	s.growLocked(key, st.bytes-before)
+	return n, nil
+}
+
+// XLen returns the number of entries in the stream at key.
+func (s *Store) XLen(key string) (int, error) {
+	defer s.rlock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return st.length, nil
+}
+
+// XRange returns up to count (negative: all) entries with IDs in [start,
+// end], in descending order if rev is set.
+func (s *Store) XRange(key string, start, end StreamID, count int, rev bool) ([]StreamEntry, error) {
+	defer s.rlock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return []StreamEntry{}, err
+	}
+	return st.rangeIDs(start, end, count, rev), nil
+}
+
+// XLastID returns the last ID of the stream at key ("$" in XREAD and
+// XGROUP); ok is false if there is no stream.
+func (s *Store) XLastID(key string) (StreamID, bool, error) {
+	defer s.rlock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return StreamID{}, false, err
+	}
+	return st.lastID, true, nil
+}
+
+// XSetID sets the last ID of the stream at key, and optionally (non-nil)
+// its entries-added counter and max deleted ID, as written by AOF rewrites.
+func (s *Store) XSetID(key string, lastID StreamID, entriesAdded *uint64, maxDeletedID *StreamID) error {
+	defer s.lock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoSuchKey
+	}
+	if last, ok := st.lastEntry(); ok && lastID.Less(last.ID) {
+		return ErrXSetIDTooSmall
+	}
+	if entriesAdded != nil && *entriesAdded < uint64(st.length) {
+		return ErrEntriesAddedTooSmall
+	}
+	if maxDeletedID != nil && lastID.Less(*maxDeletedID) {
+		return ErrMaxDeletedTooLarge
+	}
+	st.lastID = lastID
+	if entriesAdded != nil {
+		st.entriesAdded = *entriesAdded
+	}
+	if maxDeletedID != nil {
+		st.maxDeletedID = *maxDeletedID
+	}
+	return nil
+}
+
+// StreamInfo is what XINFO STREAM reports.
+type StreamInfo struct {
+	Length       int
+	Nodes        int
+	LastID       StreamID
+	MaxDeletedID StreamID
+	EntriesAdded uint64
+	FirstID      StreamID // recorded-first-entry-id, 0-0 if empty
+	Groups       int
+	First, Last  *StreamEntry // nil if empty
+}
+
+// XInfoStream describes the stream at key.
+func (s *Store) XInfoStream(key string) (StreamInfo, bool, error) {
+	defer s.rlock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return StreamInfo{}, false, err
+	}
+	info := StreamInfo{
+		Length:       st.length,
+		Nodes:        len(st.nodes),
+		LastID:       st.lastID,
+		MaxDeletedID: st.maxDeletedID,
+		EntriesAdded: st.entriesAdded,
+		Groups:       len(st.groups),
+	}
+	if e, ok := st.first(); ok {
+		info.FirstID = e.ID
+		info.First = &e
+	}
+	if e, ok := st.lastEntry(); ok {
+		info.Last = &e
+	}
+	return info, true, nil
+}
+
+// StreamSnapshot is a copy of a stream and its consumer groups, enough to
+// rebuild it with XADD, XSETID, XGROUP and XCLAIM.
+type StreamSnapshot struct {
+	Entries      []StreamEntry
+	LastID       StreamID
+	MaxDeletedID StreamID
+	EntriesAdded uint64
+	Groups       []StreamGroupSnapshot // sorted by name
+}
+
+func (st *stream) snapshot() *StreamSnapshot {
+	snap := &StreamSnapshot{
+		Entries:      make([]StreamEntry, 0, st.length),
+		LastID:       st.lastID,
+		MaxDeletedID: st.maxDeletedID,
+		EntriesAdded: st.entriesAdded,
+	}
+	for _, n := range st.nodes {
+		for _, e := range n.entries {
+			snap.Entries = append(snap.Entries, StreamEntry{ID: e.ID, Fields: append([]string(nil), e.Fields...)})
+		}
+	}
+	names := make([]string, 0, len(st.groups))
+	for name := range st.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		snap.Groups = append(snap.Groups, st.groups[name].snapshot(name))
+	}
+	return snap
+}
+
+// clone returns a deep copy of st, groups included (COPY copies them too).
+func (st *stream) clone() *stream {
+	out := newStream()
+	for _, n := range st.nodes {
+		out.nodes = append(out.nodes, &streamNode{entries: append([]StreamEntry(nil), n.entries...)})
+	}
+	out.length = st.length
+	out.lastID = st.lastID
+	out.maxDeletedID = st.maxDeletedID
+	out.entriesAdded = st.entriesAdded
+	out.bytes = st.bytes
+	for name, g := range st.groups {
+		out.groups[name] = g.clone()
+	}
+	return out
+}
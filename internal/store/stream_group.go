@@ -0,0 +1,669 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// streamGroup is a consumer group: how far it has read the stream and the
+// entries delivered to its consumers but not acknowledged yet (the PEL).
+type streamGroup struct {
+	lastID      StreamID
+	entriesRead int64 // entries of the stream read so far; -1 when unknown
+	pel         map[StreamID]*pendingEntry
+	pelIDs      []StreamID // the keys of pel, ascending
+	consumers   map[string]*streamConsumer
+}
+
+type pendingEntry struct {
+	consumer      *streamConsumer
+	deliveryTime  int64 // unix milliseconds of the last delivery
+	deliveryCount int64
+}
+
+type streamConsumer struct {
+	name       string
+	seenTime   int64 // unix milliseconds of the last interaction
+	activeTime int64 // of the last successful read or claim; -1 if none
+	pending    int
+}
+
+func newStreamGroup(lastID StreamID, entriesRead int64) *streamGroup {
+	return &streamGroup{
+		lastID:      lastID,
+		entriesRead: entriesRead,
+		pel:         make(map[StreamID]*pendingEntry),
+		consumers:   make(map[string]*streamConsumer),
+	}
+}
+
+// consumer returns the consumer name, creating it if needed, and whether it
+// was created.
+func (g *streamGroup) consumer(name string, now int64) (*streamConsumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &streamConsumer{name: name, seenTime: now, activeTime: -1}
+	g.consumers[name] = c
+	return c, true
+}
+
+// pend records id as delivered to c, moving it from another consumer if it
+// was pending already.
+func (g *streamGroup) pend(id StreamID, c *streamConsumer, deliveryTime, deliveryCount int64) {
+	if p, ok := g.pel[id]; ok {
+		p.consumer.pending--
+		p.consumer = c
+		p.deliveryTime, p.deliveryCount = deliveryTime, deliveryCount
+		c.pending++
+		return
+	}
+	g.pel[id] = &pendingEntry{consumer: c, deliveryTime: deliveryTime, deliveryCount: deliveryCount}
+	c.pending++
+	i := sort.Search(len(g.pelIDs), func(i int) bool { return !g.pelIDs[i].Less(id) })
+	g.pelIDs = append(g.pelIDs, StreamID{})
+	copy(g.pelIDs[i+1:], g.pelIDs[i:])
+	g.pelIDs[i] = id
+}
+
+// ack removes id from the PEL and reports whether it was there.
+func (g *streamGroup) ack(id StreamID) bool {
+	p, ok := g.pel[id]
+	if !ok {
+		return false
+	}
+	p.consumer.pending--
+	delete(g.pel, id)
+	i := sort.Search(len(g.pelIDs), func(i int) bool { return !g.pelIDs[i].Less(id) })
+	g.pelIDs = append(g.pelIDs[:i], g.pelIDs[i+1:]...)
+	return true
+}
+
+// pelFrom returns the index in pelIDs of the first ID >= id.
+func (g *streamGroup) pelFrom(id StreamID) int {
+	return sort.Search(len(g.pelIDs), func(i int) bool { return !g.pelIDs[i].Less(id) })
+}
+
+// hasTombstonesFrom reports whether entries with IDs >= start may have been
+// deleted by XDEL, in which case counting the entries a group has read by
+// position is unreliable.
+func (st *stream) hasTombstonesFrom(start StreamID) bool {
+	if st.length == 0 || st.maxDeletedID.IsZero() {
+		return false
+	}
+	if first, _ := st.first(); st.maxDeletedID.Less(first.ID) {
+		return false // everything deleted is gone from the head anyway
+	}
+	return !st.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead returns how many entries a group positioned at id
+// has read, or -1 if that cannot be known, like Redis'
+// streamEstimateDistanceFromFirstEverEntry.
+func (st *stream) estimateEntriesRead(id StreamID) int64 {
+	switch {
+	case st.entriesAdded == 0:
+		return 0
+	case st.length == 0 && !st.lastID.Less(id), id == st.lastID:
+		return int64(st.entriesAdded)
+	case st.lastID.Less(id):
+		return -1
+	}
+	first, _ := st.first()
+	if st.maxDeletedID.IsZero() || st.maxDeletedID.Less(first.ID) {
+		// Nothing was deleted past the head, so all entries before the
+		// first one were trimmed.
+		switch {
+		case id.Less(first.ID):
+			return int64(st.entriesAdded) - int64(st.length)
+		case id == first.ID:
+			return int64(st.entriesAdded) - int64(st.length) + 1
+		}
+	}
+	return -1
+}
+
+// advance moves g past the delivered entry id.
+func (st *stream) advance(g *streamGroup, id StreamID) {
+	if !g.lastID.Less(id) {
+		return
+	}
+	if g.entriesRead >= 0 && !st.hasTombstonesFrom(id) {
+		g.entriesRead++
+	} else {
+		g.entriesRead = st.estimateEntriesRead(id)
+	}
+	g.lastID = id
+}
+
+// lag returns how many entries g has yet to read, or -1 if unknown.
+func (st *stream) lag(g *streamGroup) int64 {
+	if st.entriesAdded == 0 {
+		return 0
+	}
+	read := g.entriesRead
+	if read < 0 || st.hasTombstonesFrom(g.lastID) {
+		read = st.estimateEntriesRead(g.lastID)
+	}
+	if read < 0 {
+		return -1
+	}
+	return int64(st.entriesAdded) - read
+}
+
+// groupLocked returns the group of the stream at key, or ErrNoGroup.
+// Caller must hold the lock of key's shard.
+func (s *Store) groupLocked(key, group string) (*stream, *streamGroup, error) {
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrNoGroup
+	}
+	g, ok := st.groups[group]
+	if !ok {
+		return nil, nil, ErrNoGroup
+	}
+	return st, g, nil
+}
+
+// GroupStart is where XGROUP CREATE / SETID positions a group: after ID,
+// or after the stream's last ID ("$"). Entries is the ENTRIESREAD
+// argument, NoEntriesRead if not given.
+type GroupStart struct {
+	ID      StreamID
+	Last    bool
+	Entries int64
+}
+
+// NoEntriesRead marks GroupStart.Entries as not given, in which case it is
+// estimated from the stream.
+const NoEntriesRead = -2
+
+func (st *stream) resolve(start GroupStart) (StreamID, int64) {
+	id := start.ID
+	if start.Last {
+		id = st.lastID
+	}
+	read := start.Entries
+	if read == NoEntriesRead {
+		read = st.estimateEntriesRead(id)
+	}
+	return id, read
+}
+
+// XGroupCreate creates group on the stream at key (creating an empty
+// stream if mkStream is set) and returns the ID it starts after and its
+// entries-read counter.
+func (s *Store) XGroupCreate(key, group string, start GroupStart, mkStream bool) (StreamID, int64, error) {
+	defer s.lock(key)()
+
+	st, ok, err := s.streamLocked(key, mkStream)
+	if err != nil {
+		return StreamID{}, 0, err
+	}
+	if !ok {
+		return StreamID{}, 0, ErrNoSuchKey
+	}
+	if _, exists := st.groups[group]; exists {
+		return StreamID{}, 0, ErrBusyGroup
+	}
+	id, read := st.resolve(start)
+	st.groups[group] = newStreamGroup(id, read)
+	return id, read, nil
+}
+
+// XGroupSetID moves group to start and returns, as XGroupCreate, where it
+// now is.
+func (s *Store) XGroupSetID(key, group string, start GroupStart) (StreamID, int64, error) {
+	defer s.lock(key)()
+
+	st, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return StreamID{}, 0, err
+	}
+	g.lastID, g.entriesRead = st.resolve(start)
+	return g.lastID, g.entriesRead, nil
+}
+
+// XGroupExists reports whether the stream at key has group.
+func (s *Store) XGroupExists(key, group string) (bool, error) {
+	defer s.rlock(key)()
+
+	_, _, err := s.groupLocked(key, group)
+	if err == ErrNoGroup {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// XGroupDestroy deletes group and reports whether it existed.
+func (s *Store) XGroupDestroy(key, group string) (bool, error) {
+	defer s.lock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+	if _, exists := st.groups[group]; !exists {
+		return false, nil
+	}
+	delete(st.groups, group)
+	return true, nil
+}
+
+// XGroupCreateConsumer adds consumer to group and reports whether it is new.
+func (s *Store) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	defer s.lock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return false, err
+	}
+	_, created := g.consumer(consumer, time.Now().UnixMilli())
+	return created, nil
+}
+
+// XGroupDelConsumer removes consumer from group, dropping its pending
+// entries, and returns how many it had; ok is false if there was no such
+// consumer.
+func (s *Store) XGroupDelConsumer(key, group, consumer string) (n int, ok bool, err error) {
+	defer s.lock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return 0, false, err
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, false, nil
+	}
+	n = c.pending
+	for _, id := range append([]StreamID(nil), g.pelIDs...) {
+		if g.pel[id].consumer == c {
+			g.ack(id)
+		}
+	}
+	delete(g.consumers, consumer)
+	return n, true, nil
+}
+
+// PendingEntry is an entry of a group's PEL.
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  int64 // unix milliseconds
+	DeliveryCount int64
+}
+
+func (g *streamGroup) pendingEntry(id StreamID) PendingEntry {
+	p := g.pel[id]
+	return PendingEntry{ID: id, Consumer: p.consumer.name, DeliveryTime: p.deliveryTime, DeliveryCount: p.deliveryCount}
+}
+
+// StreamRead is the result of XREADGROUP on one stream.
+type StreamRead struct {
+	Entries []StreamEntry // Fields is nil for a pending entry deleted since
+	// Delivered are the PEL entries the read created or updated (none with
+	// NOACK), as they have to be logged.
+	Delivered       []PendingEntry
+	CreatedConsumer bool
+	LastID          StreamID // the group's position after the read
+	EntriesRead     int64
+	Advanced        bool // LastID moved
+}
+
+// XReadGroup reads for consumer of group: with after nil, up to count
+// (negative: all) entries never delivered to the group, which are added to
+// the PEL unless noAck is set; otherwise the consumer's own pending
+// entries with IDs greater than *after, whose delivery count goes up.
+func (s *Store) XReadGroup(key, group, consumer string, after *StreamID, count int, noAck bool) (StreamRead, error) {
+	defer s.lock(key)()
+
+	st, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return StreamRead{}, err
+	}
+	now := time.Now().UnixMilli()
+	c, created := g.consumer(consumer, now)
+	c.seenTime = now
+	res := StreamRead{CreatedConsumer: created}
+
+	if after == nil {
+		start, ok := g.lastID.Next()
+		if ok {
+			res.Entries = st.rangeIDs(start, MaxStreamID, count, false)
+		}
+		for _, e := range res.Entries {
+			st.advance(g, e.ID)
+			if !noAck {
+				g.pend(e.ID, c, now, 1)
+				res.Delivered = append(res.Delivered, g.pendingEntry(e.ID))
+			}
+		}
+		if len(res.Entries) > 0 {
+			c.activeTime = now
+			res.Advanced = true
+		}
+	} else {
+		res.Entries = []StreamEntry{}
+		from, ok := after.Next()
+		for i := g.pelFrom(from); ok && i < len(g.pelIDs) && (count < 0 || len(res.Entries) < count); i++ {
+			id := g.pelIDs[i]
+			p := g.pel[id]
+			if p.consumer != c {
+				continue
+			}
+			p.deliveryTime = now
+			p.deliveryCount++
+			e, found := st.get(id)
+			if !found {
+				e = StreamEntry{ID: id}
+			}
+			res.Entries = append(res.Entries, e)
+			res.Delivered = append(res.Delivered, g.pendingEntry(id))
+		}
+	}
+	res.LastID, res.EntriesRead = g.lastID, g.entriesRead
+	return res, nil
+}
+
+// XAck acknowledges ids in group and returns how many were pending.
+func (s *Store) XAck(key, group string, ids []StreamID) (int, error) {
+	defer s.lock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		if err == ErrNoGroup {
+			return 0, nil
+		}
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		if g.ack(id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PendingSummary is the short form of XPENDING.
+type PendingSummary struct {
+	Count     int
+	Min, Max  StreamID
+	Consumers []ConsumerPending // sorted by name, only those with entries
+}
+
+// ConsumerPending is a consumer's number of pending entries.
+type ConsumerPending struct {
+	Name    string
+	Pending int
+}
+
+// XPendingSummary summarizes the PEL of group.
+func (s *Store) XPendingSummary(key, group string) (PendingSummary, error) {
+	defer s.rlock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+	sum := PendingSummary{Count: len(g.pelIDs)}
+	if sum.Count == 0 {
+		return sum, nil
+	}
+	sum.Min, sum.Max = g.pelIDs[0], g.pelIDs[len(g.pelIDs)-1]
+	for name, c := range g.consumers {
+		if c.pending > 0 {
+			sum.Consumers = append(sum.Consumers, ConsumerPending{Name: name, Pending: c.pending})
+		}
+	}
+	sort.Slice(sum.Consumers, func(i, j int) bool { return sum.Consumers[i].Name < sum.Consumers[j].Name })
+	return sum, nil
+}
+
+// XPending returns up to count PEL entries of group with IDs in [start,
+// end], optionally only those of consumer (non-empty) and idle for at
+// least minIdle.
+func (s *Store) XPending(key, group string, start, end StreamID, count int, consumer string, minIdle time.Duration) ([]PendingEntry, error) {
+	defer s.rlock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	out := []PendingEntry{}
+	for i := g.pelFrom(start); i < len(g.pelIDs) && len(out) < count; i++ {
+		id := g.pelIDs[i]
+		if end.Less(id) {
+			break
+		}
+		p := g.pendingEntry(id)
+		if (consumer != "" && p.Consumer != consumer) || now-p.DeliveryTime < minIdle.Milliseconds() {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// ClaimOptions are the XCLAIM modifiers.
+type ClaimOptions struct {
+	Time       int64 // delivery time to record (unix ms); 0 means now (IDLE is turned into TIME)
+	RetryCount int64 // -1: increment (unless JustID)
+	Force      bool  // create missing PEL entries
+	JustID     bool  // do not count as a delivery
+	LastID     *StreamID
+}
+
+// XClaim gives the pending entries ids of group that are idle for at least
+// minIdle to consumer. It returns the claimed entries (Fields nil with
+// JustID) and their PEL entries, for logging. A pending entry whose
+// message was deleted is dropped from the PEL and returned in deleted.
+//
+// With Force, ids missing from the PEL are added to it, and pending
+// entries whose message was deleted are kept, as long as the group has
+// read past them: that is how the AOF restores a PEL exactly.
+func (s *Store) XClaim(key, group, consumer string, minIdle time.Duration, ids []StreamID, opts ClaimOptions) (claimed []StreamEntry, delivered []PendingEntry, deleted []StreamID, err error) {
+	defer s.lock(key)()
+
+	st, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now().UnixMilli()
+	if opts.LastID != nil && g.lastID.Less(*opts.LastID) {
+		g.lastID = *opts.LastID
+	}
+	c, _ := g.consumer(consumer, now)
+	c.seenTime = now
+
+	claimed = []StreamEntry{}
+	for _, id := range ids {
+		e, found := st.get(id)
+		force := opts.Force && (found || !g.lastID.Less(id))
+		p, pending := g.pel[id]
+		switch {
+		case !pending && !force:
+			continue
+		case !found && !force:
+			g.ack(id)
+			deleted = append(deleted, id)
+			continue
+		case pending && minIdle > 0 && now-p.deliveryTime < minIdle.Milliseconds():
+			continue
+		case !pending:
+			p = &pendingEntry{}
+		}
+		deliveryTime, deliveryCount := now, p.deliveryCount
+		if opts.Time > 0 {
+			deliveryTime = opts.Time
+		}
+		switch {
+		case opts.RetryCount >= 0:
+			deliveryCount = opts.RetryCount
+		case !opts.JustID:
+			deliveryCount++
+		}
+		g.pend(id, c, deliveryTime, deliveryCount)
+		c.activeTime = now
+		if opts.JustID || !found {
+			e = StreamEntry{ID: id}
+		}
+		claimed = append(claimed, e)
+		delivered = append(delivered, g.pendingEntry(id))
+	}
+	return claimed, delivered, deleted, nil
+}
+
+// XAutoClaim claims for consumer up to count pending entries of group idle
+// for at least minIdle, scanning the PEL from start (at most 10 * count
+// entries). It returns where the next call should start (0-0 when the scan
+// reached the end) and, as XClaim, the claimed and deleted entries.
+func (s *Store) XAutoClaim(key, group, consumer string, minIdle time.Duration, start StreamID, count int, justID bool) (next StreamID, claimed []StreamEntry, delivered []PendingEntry, deleted []StreamID, err error) {
+	defer s.lock(key)()
+
+	st, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, nil, err
+	}
+	now := time.Now().UnixMilli()
+	c, _ := g.consumer(consumer, now)
+	c.seenTime = now
+
+	claimed, deleted = []StreamEntry{}, []StreamID{}
+	i := g.pelFrom(start)
+	for attempts := count * 10; i < len(g.pelIDs) && len(claimed) < count && attempts > 0; attempts-- {
+		id := g.pelIDs[i]
+		p := g.pel[id]
+		e, found := st.get(id)
+		if !found {
+			g.ack(id) // shifts the next ID to i
+			deleted = append(deleted, id)
+			continue
+		}
+		i++
+		if now-p.deliveryTime < minIdle.Milliseconds() {
+			continue
+		}
+		g.pend(id, c, now, p.deliveryCount)
+		if !justID {
+			p.deliveryCount++
+		} else {
+			e = StreamEntry{ID: id}
+		}
+		c.activeTime = now
+		claimed = append(claimed, e)
+		delivered = append(delivered, g.pendingEntry(id))
+	}
+	if i < len(g.pelIDs) {
+		next = g.pelIDs[i]
+	}
+	return next, claimed, delivered, deleted, nil
+}
+
+// GroupInfo is what XINFO GROUPS reports about a group.
+type GroupInfo struct {
+	Name        string
+	Consumers   int
+	Pending     int
+	LastID      StreamID
+	EntriesRead int64 // -1 if unknown
+	Lag         int64 // -1 if unknown
+}
+
+// XInfoGroups describes the groups of the stream at key, sorted by name.
+func (s *Store) XInfoGroups(key string) ([]GroupInfo, bool, error) {
+	defer s.rlock(key)()
+
+	st, ok, err := s.streamLocked(key, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	out := make([]GroupInfo, 0, len(st.groups))
+	for name, g := range st.groups {
+		out = append(out, GroupInfo{
+			Name:        name,
+			Consumers:   len(g.consumers),
+			Pending:     len(g.pelIDs),
+			LastID:      g.lastID,
+			EntriesRead: g.entriesRead,
+			Lag:         st.lag(g),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, true, nil
+}
+
+// ConsumerInfo is what XINFO CONSUMERS reports about a consumer.
+type ConsumerInfo struct {
+	Name     string
+	Pending  int
+	Idle     time.Duration // since it was last seen
+	Inactive time.Duration // since its last successful read or claim; -1 if none
+}
+
+// XInfoConsumers describes the consumers of group, sorted by name.
+func (s *Store) XInfoConsumers(key, group string) ([]ConsumerInfo, error) {
+	defer s.rlock(key)()
+
+	_, g, err := s.groupLocked(key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	out := make([]ConsumerInfo, 0, len(g.consumers))
+	for name, c := range g.consumers {
+		ci := ConsumerInfo{Name: name, Pending: c.pending, Idle: time.Duration(now-c.seenTime) * time.Millisecond, Inactive: -1}
+		if c.activeTime >= 0 {
+			ci.Inactive = time.Duration(now-c.activeTime) * time.Millisecond
+		}
+		out = append(out, ci)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// StreamGroupSnapshot is a copy of a consumer group.
+type StreamGroupSnapshot struct {
+	Name        string
+	LastID      StreamID
+	EntriesRead int64
+	Consumers   []string       // sorted
+	Pending     []PendingEntry // ascending ID
+}
+
+func (g *streamGroup) snapshot(name string) StreamGroupSnapshot {
+	snap := StreamGroupSnapshot{Name: name, LastID: g.lastID, EntriesRead: g.entriesRead}
+	for c := range g.consumers {
+		snap.Consumers = append(snap.Consumers, c)
+	}
+	sort.Strings(snap.Consumers)
+	for _, id := range g.pelIDs {
+		snap.Pending = append(snap.Pending, g.pendingEntry(id))
+	}
+	return snap
+}
+
+func (g *streamGroup) clone() *streamGroup {
+	out := newStreamGroup(g.lastID, g.entriesRead)
+	byOld := make(map[*streamConsumer]*streamConsumer, len(g.consumers))
+	for name, c := range g.consumers {
+		cp := *c
+		out.consumers[name] = &cp
+		byOld[c] = &cp
+	}
+	for _, id := range g.pelIDs {
+		p := *g.pel[id]
+		p.consumer = byOld[p.consumer]
+		out.pel[id] = &p
+	}
+	out.pelIDs = append([]StreamID(nil), g.pelIDs...)
+	return out
+}
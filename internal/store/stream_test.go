@@ -0,0 +1,191 @@
+package store
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func addIDs(t *testing.T, s *Store, key string, ids ...StreamID) {
+	t.Helper()
+	for _, id := range ids {
+		if _, _, err := s.XAdd(key, XAddID{ID: id}, []string{"f", id.String()}, false, StreamTrim{}); err != nil {
+			t.Fatalf("XAdd %s: %v", id, err)
+		}
+	}
+}
+
+func ids(entries []StreamEntry) []StreamID {
+	out := make([]StreamID, len(entries))
+	for i, e := range entries {
+		out[i] = e.ID
+	}
+	return out
+}
+
+func TestStream_RangeMatchesSliceAcrossNodes(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	s := New()
+	var ref []StreamID
+	for i := 1; i <= 1000; i++ {
+		id := StreamID{uint64(i), uint64(r.IntN(3))}
+		addIDs(t, s, "s", id)
+		ref = append(ref, id)
+	}
+	for i := 0; i < 200; i++ {
+		victim := ref[r.IntN(len(ref))]
+		if n, _ := s.XDel("s", []StreamID{victim}); n == 1 {
+			for j, id := range ref {
+				if id == victim {
+					ref = append(ref[:j], ref[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		start := StreamID{uint64(r.IntN(1100)), 0}
+		end := StreamID{start.Ms + uint64(r.IntN(300)), 1}
+		var want []StreamID
+		for _, id := range ref {
+			if !id.Less(start) && !end.Less(id) {
+				want = append(want, id)
+			}
+		}
+		got, _ := s.XRange("s", start, end, -1, false)
+		if len(got) != len(want) {
+			t.Fatalf("[%s, %s]: got %d entries, want %d", start, end, len(got), len(want))
+		}
+		for j := range want {
+			if got[j].ID != want[j] {
+				t.Fatalf("[%s, %s] #%d: got %s, want %s", start, end, j, got[j].ID, want[j])
+			}
+		}
+		rev, _ := s.XRange("s", start, end, 5, true)
+		for j := range rev {
+			if rev[j].ID != want[len(want)-1-j] {
+				t.Fatalf("rev [%s, %s] #%d: got %s", start, end, j, rev[j].ID)
+			}
+		}
+	}
+}
+
+func TestStream_Trim(t *testing.T) {
+	s := New()
+	for i := 1; i <= 250; i++ {
+		addIDs(t, s, "s", StreamID{uint64(i), 0})
+	}
+
+	// Approximate trimming only drops whole nodes.
+	if n, _, _ := s.XTrim("s", StreamTrim{Strategy: TrimMaxLen, MaxLen: 120, Approx: true}); n != 100 {
+		t.Fatalf("approx MAXLEN trimmed %d, want 100", n)
+	}
+	if n, _, _ := s.XTrim("s", StreamTrim{Strategy: TrimMaxLen, MaxLen: 0, Approx: true, Limit: 50}); n != 0 {
+		t.Fatalf("approx trim over LIMIT trimmed %d, want 0", n)
+	}
+	if n, left, _ := s.XTrim("s", StreamTrim{Strategy: TrimMinID, MinID: StreamID{200, 0}}); n != 99 || left != 51 {
+		t.Fatalf("MINID trimmed %d leaving %d, want 99 and 51", n, left)
+	}
+	info, _, _ := s.XInfoStream("s")
+	if info.FirstID != (StreamID{200, 0}) || info.EntriesAdded != 250 || !info.MaxDeletedID.IsZero() {
+		t.Fatalf("unexpected info after trim: %+v", info)
+	}
+
+	added, _, _ := s.XAdd("s", XAddID{AutoMs: true}, []string{"f", "v"}, false, StreamTrim{Strategy: TrimMaxLen, MaxLen: 10})
+	if added.Trimmed != 42 || added.Length != 10 {
+		t.Fatalf("XADD MAXLEN: %+v", added)
+	}
+}
+
+func TestStream_AutoIDs(t *testing.T) {
+	s := New()
+	addIDs(t, s, "s", StreamID{5, 7})
+	a, _, _ := s.XAdd("s", XAddID{ID: StreamID{Ms: 5}, AutoSeq: true}, []string{"f", "v"}, false, StreamTrim{})
+	if a.ID != (StreamID{5, 8}) {
+		t.Fatalf("5-* gave %s", a.ID)
+	}
+	if _, _, err := s.XAdd("s", XAddID{ID: StreamID{Ms: 4}, AutoSeq: true}, []string{"f", "v"}, false, StreamTrim{}); err != ErrStreamIDTooSmall {
+		t.Fatalf("4-* err = %v", err)
+	}
+	if err := s.XSetID("s", MaxStreamID, nil, nil); err != nil {
+		t.Fatalf("XSetID: %v", err)
+	}
+	if _, _, err := s.XAdd("s", XAddID{AutoMs: true}, []string{"f", "v"}, false, StreamTrim{}); err != ErrStreamExhausted {
+		t.Fatalf("exhausted err = %v", err)
+	}
+	if err := s.XSetID("s", StreamID{5, 7}, nil, nil); err != ErrXSetIDTooSmall {
+		t.Fatalf("XSetID below top entry err = %v", err)
+	}
+}
+
+func TestStreamGroup_EntriesReadAndLag(t *testing.T) {
+	s := New()
+	addIDs(t, s, "s", StreamID{1, 0}, StreamID{2, 0}, StreamID{3, 0}, StreamID{4, 0})
+	if _, read, _ := s.XGroupCreate("s", "g", GroupStart{Entries: NoEntriesRead}, false); read != 0 {
+		t.Fatalf("group at 0-0 has read %d", read)
+	}
+	lag := func() int64 {
+		groups, _, _ := s.XInfoGroups("s")
+		return groups[0].Lag
+	}
+
+	res, _ := s.XReadGroup("s", "g", "c", nil, 1, false)
+	if res.EntriesRead != 1 || !res.CreatedConsumer || lag() != 3 {
+		t.Fatalf("after one read: %+v, lag %d", res, lag())
+	}
+	// A deletion ahead of the group makes the lag unknown until it is
+	// read past.
+	s.XDel("s", []StreamID{{3, 0}})
+	if lag() != -1 {
+		t.Fatalf("lag with tombstone ahead = %d", lag())
+	}
+	res, _ = s.XReadGroup("s", "g", "c", nil, -1, false)
+	if len(res.Entries) != 2 || res.EntriesRead != 4 || lag() != 0 {
+		t.Fatalf("after reading all: %+v, lag %d", res, lag())
+	}
+
+	// The consumer's history includes entries deleted since delivery.
+	s.XDel("s", []StreamID{{2, 0}})
+	res, _ = s.XReadGroup("s", "g", "c", &StreamID{}, -1, false)
+	if got := ids(res.Entries); len(got) != 3 || res.Entries[1].Fields != nil || res.Entries[2].Fields == nil {
+		t.Fatalf("history: %v", got)
+	}
+	sum, _ := s.XPendingSummary("s", "g")
+	if sum.Count != 3 || sum.Consumers[0] != (ConsumerPending{"c", 3}) {
+		t.Fatalf("summary: %+v", sum)
+	}
+}
+
+func TestStreamGroup_ClaimAndClone(t *testing.T) {
+	d := NewDatabases(1)
+	s := d.DB(0)
+	addIDs(t, s, "s", StreamID{1, 0}, StreamID{2, 0})
+	s.XGroupCreate("s", "g", GroupStart{Entries: NoEntriesRead}, false)
+	s.XReadGroup("s", "g", "a", nil, -1, false)
+	s.XDel("s", []StreamID{{2, 0}})
+
+	if !d.Copy("s", "copy", 0, 0, false) {
+		t.Fatal("copy failed")
+	}
+	claimed, _, deleted, err := s.XClaim("s", "g", "b", 0, []StreamID{{1, 0}, {2, 0}}, ClaimOptions{RetryCount: -1})
+	if err != nil || len(claimed) != 1 || len(deleted) != 1 || deleted[0] != (StreamID{2, 0}) {
+		t.Fatalf("claim: %v %v %v", claimed, deleted, err)
+	}
+	p, _ := s.XPending("s", "g", StreamID{}, MaxStreamID, 10, "", 0)
+	if len(p) != 1 || p[0].Consumer != "b" || p[0].DeliveryCount != 2 {
+		t.Fatalf("pending after claim: %+v", p)
+	}
+
+	// The copy's PEL is its own.
+	p, _ = s.XPending("copy", "g", StreamID{}, MaxStreamID, 10, "", 0)
+	if len(p) != 2 || p[0].Consumer != "a" || p[1].Consumer != "a" {
+		t.Fatalf("copy pending: %+v", p)
+	}
+
+	// FORCE restores a PEL entry for a deleted message the group has read.
+	_, delivered, _, _ := s.XClaim("s", "g", "a", 0, []StreamID{{2, 0}, {9, 0}},
+		ClaimOptions{Force: true, JustID: true, RetryCount: 3, Time: 1000})
+	if len(delivered) != 1 || delivered[0] != (PendingEntry{StreamID{2, 0}, "a", 1000, 3}) {
+		t.Fatalf("forced claim: %+v", delivered)
+	}
+}
@@ -0,0 +1,367 @@
+package store
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// stringLocked returns the live string entry at key, or a fresh one ready
+// to be stored if key is missing. Caller must hold the lock of key's
+// shard.
+func (s *Store) stringLocked(key string) (entry, bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		return newEntry(KindString), false, nil
+	}
+	if e.kind != KindString {
+		return entry{}, false, ErrWrongType
+	}
+	return e, true, nil
+}
+
+// IncrBy adds delta to the integer stored at key (a missing key counts as 0)
+// and returns the new value. The key keeps its expiry.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	defer s.lock(key)()
+
+	e, ok, err := s.stringLocked(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur int64
+	if ok {
+		cur, err = strconv.ParseInt(string(e.value), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	cur += delta
+	e.setValue([]byte(strconv.FormatInt(cur, 10)))
+	s.putLocked(key, e)
+	return cur, nil
+}
+
+// IncrByFloat adds delta to the float stored at key and returns the new
+// value formatted the way it is stored. The key keeps its expiry.
+func (s *Store) IncrByFloat(key string, delta float64) (string, error) {
+	defer s.lock(key)()
+
+	e, ok, err := s.stringLocked(key)
+	if err != nil {
+		return "", err
+	}
+
+	var cur float64
+	if ok {
+		cur, err = strconv.ParseFloat(string(e.value), 64)
+		if err != nil || math.IsNaN(cur) || math.IsInf(cur, 0) {
+			return "", ErrNotFloat
+		}
+	}
+
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return "", ErrNaN
+	}
+
+	out := FormatFloat(cur)
+	e.setValue([]byte(out))
+	s.putLocked(key, e)
+	return out, nil
+}
+
+// MaxStringLen is the largest string value APPEND and SETRANGE may build
+// (Redis' default proto-max-bulk-len).
+const MaxStringLen = 512 * 1024 * 1024
+
+// Append appends val to the string at key (creating it if missing) and
+// returns the new length. The key keeps its expiry.
+func (s *Store) Append(key string, val []byte) (int, error) {
+	defer s.lock(key)()
+
+	e, _, err := s.stringLocked(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(e.value)+len(val) > MaxStringLen {
+		return 0, ErrStringTooLong
+	}
+
+	out := make([]byte, 0, len(e.value)+len(val))
+	out = append(append(out, e.value...), val...)
+	e.setValue(out)
+	s.putLocked(key, e)
+	return len(out), nil
+}
+
+// StrLen returns the length of the string at key (0 if missing).
+func (s *Store) StrLen(key string) (int, error) {
+	defer s.rlock(key)()
+
+	e, _, err := s.stringLocked(key)
+	if err != nil {
+		return 0, err
+	}
+	return len(e.value), nil
+}
+
+// GetRange returns the substring between start and end (inclusive, negative
+// offsets count from the end), clamped to the string like Redis does.
+func (s *Store) GetRange(key string, start, end int) ([]byte, error) {
+	defer s.rlock(key)()
+
+	e, _, err := s.stringLocked(key)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(e.value)
+	if start < 0 && end < 0 && start > end {
+		return []byte{}, nil
+	}
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	start = max(start, 0)
+	end = max(end, 0)
+	if end >= n {
+		end = n - 1
+	}
+	if n == 0 || start > end {
+		return []byte{}, nil
+	}
+	return copyBytes(e.value[start : end+1]), nil
+}
+
+// SetRange overwrites the string at key starting at offset, zero-padding it
+// if needed, and returns the new length. An empty val never creates the key.
+func (s *Store) SetRange(key string, offset int, val []byte) (int, error) {
+	defer s.lock(key)()
+
+	e, _, err := s.stringLocked(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(val) == 0 {
+		return len(e.value), nil
+	}
+	if offset+len(val) > MaxStringLen {
+		return 0, ErrStringTooLong
+	}
+
+	out := e.value
+	if need := offset + len(val); need > len(out) {
+		grown := make([]byte, need)
+		copy(grown, out)
+		out = grown
+	} else {
+		out = copyBytes(out)
+	}
+	copy(out[offset:], val)
+
+	e.setValue(out)
+	s.putLocked(key, e)
+	return len(out), nil
+}
+
+// GetDel returns the string at key and deletes the key.
+func (s *Store) GetDel(key string) ([]byte, bool, error) {
+	defer s.lock(key)()
+
+	e, ok, err := s.stringLocked(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	s.removeLocked(key)
+	return copyBytes(e.value), true, nil
+}
+
+// GetExOptions are the GETEX modifiers; at most one of them is set.
+type GetExOptions struct {
+	Persist  bool       // remove the expiry
+	ExpireAt *time.Time // set a new absolute expiry (a past time deletes the key)
+}
+
+// GetEx returns the string at key and optionally changes its expiry.
+func (s *Store) GetEx(key string, opts GetExOptions) ([]byte, bool, error) {
+	defer s.lock(key)()
+
+	now := time.Now()
+	e, ok, err := s.stringLocked(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	val := copyBytes(e.value)
+
+	switch {
+	case opts.Persist:
+		e.expiresAt = nil
+		s.putLocked(key, e)
+	case opts.ExpireAt != nil:
+		if !now.Before(*opts.ExpireAt) {
+			s.removeLocked(key)
+			break
+		}
+		exp := *opts.ExpireAt
+		e.expiresAt = &exp
+		s.putLocked(key, e)
+	}
+	return val, true, nil
+}
+
+// KeyValue is a key and the string value to store at it.
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// MGet returns the string value of every key, with nil for missing keys and
+// keys holding another type, all read under one lock.
+func (s *Store) MGet(keys []string) [][]byte {
+	defer s.rlockKeys(keys...)()
+
+	now := time.Now()
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		if e, ok := s.lookupLocked(key, now); ok && e.kind == KindString {
+			out[i] = copyBytes(e.value)
+		}
+	}
+	return out
+}
+
+// MSet sets all pairs atomically, overwriting existing keys of any type and
+// clearing their expiry. Later pairs win when a key repeats.
+func (s *Store) MSet(pairs []KeyValue) {
+	defer s.lockKeys(pairKeys(pairs)...)()
+
+	s.msetLocked(pairs)
+}
+
+// MSetNX sets all pairs only if none of the keys exist (of any type). It
+// reports whether the keys were set.
+func (s *Store) MSetNX(pairs []KeyValue) bool {
+	defer s.lockKeys(pairKeys(pairs)...)()
+
+	now := time.Now()
+	for _, p := range pairs {
+		if _, ok := s.lookupLocked(p.Key, now); ok {
+			return false
+		}
+	}
+	s.msetLocked(pairs)
+	return true
+}
+
+func (s *Store) msetLocked(pairs []KeyValue) {
+	for _, p := range pairs {
+		e := newEntry(KindString)
+		e.setValue(copyBytes(p.Value))
+		s.putLocked(p.Key, e)
+	}
+}
+
+func pairKeys(pairs []KeyValue) []string {
+	keys := make([]string, len(pairs))
+	for i, p := range pairs {
+		keys[i] = p.Key
+	}
+	return keys
+}
+
+// LCSMatch is one contiguous run of the longest common subsequence: the
+// inclusive byte ranges it covers in the first and second string.
+type LCSMatch struct {
+	A, B [2]int
+	Len  int
+}
+
+// LCS computes the longest common subsequence of the strings at key1 and key2
+// (missing keys count as empty). Matches are listed from the end of the
+// strings backwards, like Redis' LCS IDX.
+func (s *Store) LCS(key1, key2 string) ([]byte, []LCSMatch, error) {
+	unlock := s.rlockKeys(key1, key2)
+	e1, _, err1 := s.stringLocked(key1)
+	e2, _, err2 := s.stringLocked(key2)
+	unlock()
+
+	if err1 != nil {
+		return nil, nil, err1
+	}
+	if err2 != nil {
+		return nil, nil, err2
+	}
+	return lcs(e1.value, e2.value)
+}
+
+// lcs runs the classic dynamic programming algorithm. Stored values are
+// never modified in place, so it can run without holding the lock.
+func lcs(a, b []byte) ([]byte, []LCSMatch, error) {
+	cols := len(b) + 1
+	if uint64(len(a)+1)*uint64(cols)*4 > MaxStringLen {
+		return nil, nil, ErrLCSTooLarge
+	}
+	tbl := make([]uint32, (len(a)+1)*cols)
+	at := func(i, j int) uint32 { return tbl[i*cols+j] }
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				tbl[i*cols+j] = at(i-1, j-1) + 1
+			case at(i-1, j) > at(i, j-1):
+				tbl[i*cols+j] = at(i-1, j)
+			default:
+				tbl[i*cols+j] = at(i, j-1)
+			}
+		}
+	}
+
+	idx := int(at(len(a), len(b)))
+	seq := make([]byte, idx)
+	var matches []LCSMatch
+	var cur LCSMatch
+	open := false
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			seq[idx-1] = a[i-1]
+			if !open {
+				cur = LCSMatch{A: [2]int{i - 1, i - 1}, B: [2]int{j - 1, j - 1}}
+				open = true
+			} else {
+				// Backtracking over a match always moves diagonally, so a
+				// match right after another one extends the current run.
+				cur.A[0]--
+				cur.B[0]--
+			}
+			if cur.A[0] == 0 || cur.B[0] == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			emit = open
+		}
+		if emit {
+			cur.Len = cur.A[1] - cur.A[0] + 1
+			matches = append(matches, cur)
+			open = false
+		}
+	}
+	return seq, matches, nil
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetWithOptions_Conditions(t *testing.T) {
+	s := New()
+
+	if _, _, written, _ := s.SetWithOptions("k", []byte("v1"), SetOptions{XX: true}); written || s.Exists("k") {
+		t.Fatal("XX must not create the key")
+	}
+	if _, _, written, _ := s.SetWithOptions("k", []byte("v1"), SetOptions{NX: true}); !written {
+		t.Fatal("NX should create a missing key")
+	}
+	old, hadOld, written, _ := s.SetWithOptions("k", []byte("v2"), SetOptions{NX: true, Get: true})
+	if written || !hadOld || string(old) != "v1" {
+		t.Fatalf("NX GET on existing key: written=%v old=%q", written, old)
+	}
+
+	_, _ = s.HSet("h", []FieldValue{{"f", []byte("v")}})
+	if _, _, _, err := s.SetWithOptions("h", []byte("x"), SetOptions{Get: true}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType for GET on a hash, got %v", err)
+	}
+	if _, _, written, err := s.SetWithOptions("h", []byte("x"), SetOptions{}); !written || err != nil {
+		t.Fatalf("plain SET should overwrite any type, got written=%v err=%v", written, err)
+	}
+}
+
+func TestSetWithOptions_Expiry(t *testing.T) {
+	s := New()
+	at := time.Now().Add(100 * time.Second)
+	_, _, _, _ = s.SetWithOptions("k", []byte("v"), SetOptions{ExpireAt: &at})
+	if ttl := s.TTL("k"); ttl < 99 || ttl > 100 {
+		t.Fatalf("expected ttl ~100, got %d", ttl)
+	}
+
+	_, _, _, _ = s.SetWithOptions("k", []byte("v2"), SetOptions{KeepTTL: true})
+	if ttl := s.TTL("k"); ttl < 99 {
+		t.Fatalf("expected KEEPTTL to keep the expiry, got %d", ttl)
+	}
+
+	_, _, _, _ = s.SetWithOptions("k", []byte("v3"), SetOptions{})
+	if ttl := s.TTL("k"); ttl != -1 {
+		t.Fatalf("expected plain SET to clear the expiry, got %d", ttl)
+	}
+
+	past := time.Now().Add(-time.Second)
+	if _, _, written, _ := s.SetWithOptions("k", []byte("v"), SetOptions{ExpireAt: &past}); !written || s.Exists("k") {
+		t.Fatal("expected a past expiry to delete the key")
+	}
+}
+
+func TestIncrBy_ErrorsOverflowAndTTL(t *testing.T) {
+	s := New()
+	if n, _ := s.IncrBy("c", 5); n != 5 {
+		t.Fatalf("expected 5, got %d", n)
+	}
+	if n, _ := s.IncrBy("c", -7); n != -2 {
+		t.Fatalf("expected -2, got %d", n)
+	}
+
+	s.Expire("c", 100)
+	_, _ = s.IncrBy("c", 1)
+	if ttl := s.TTL("c"); ttl < 99 {
+		t.Fatalf("expected increment to keep the ttl, got %d", ttl)
+	}
+
+	s.Set("max", []byte("9223372036854775807"))
+	if _, err := s.IncrBy("max", 1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow, got %v", err)
+	}
+	s.Set("text", []byte("abc"))
+	if _, err := s.IncrBy("text", 1); !errors.Is(err, ErrNotInteger) {
+		t.Fatalf("expected ErrNotInteger, got %v", err)
+	}
+	_, _ = s.HSet("h", []FieldValue{{"f", []byte("1")}})
+	if _, err := s.IncrBy("h", 1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	s := New()
+	s.Set("f", []byte("10.5"))
+	if v, _ := s.IncrByFloat("f", 0.1); v != "10.6" {
+		t.Fatalf("expected 10.6, got %q", v)
+	}
+	if v, _ := s.IncrByFloat("new", 3); v != "3" {
+		t.Fatalf("expected 3, got %q", v)
+	}
+	s.Set("bad", []byte("x"))
+	if _, err := s.IncrByFloat("bad", 1); !errors.Is(err, ErrNotFloat) {
+		t.Fatalf("expected ErrNotFloat, got %v", err)
+	}
+	if _, err := s.IncrByFloat("f", math.Inf(1)); !errors.Is(err, ErrNaN) {
+		t.Fatalf("expected ErrNaN, got %v", err)
+	}
+}
+
+func TestAppendAndSetRange(t *testing.T) {
+	s := New()
+	if n, _ := s.Append("k", []byte("Hello")); n != 5 {
+		t.Fatalf("expected 5, got %d", n)
+	}
+	if n, _ := s.Append("k", []byte(" World")); n != 11 {
+		t.Fatalf("expected 11, got %d", n)
+	}
+	if n, _ := s.SetRange("k", 6, []byte("Redis")); n != 11 {
+		t.Fatalf("expected 11, got %d", n)
+	}
+	if v, _ := s.Get("k"); string(v) != "Hello Redis" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	if n, _ := s.SetRange("pad", 3, []byte("x")); n != 4 {
+		t.Fatalf("expected 4, got %d", n)
+	}
+	if v, _ := s.Get("pad"); string(v) != "\x00\x00\x00x" {
+		t.Fatalf("expected zero padding, got %q", v)
+	}
+	if n, _ := s.SetRange("missing", 10, nil); n != 0 || s.Exists("missing") {
+		t.Fatalf("empty SETRANGE must not create the key")
+	}
+	if _, err := s.SetRange("k", MaxStringLen, []byte("x")); !errors.Is(err, ErrStringTooLong) {
+		t.Fatalf("expected ErrStringTooLong, got %v", err)
+	}
+	if n, _ := s.StrLen("k"); n != 11 {
+		t.Fatalf("expected 11, got %d", n)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	s := New()
+	s.Set("k", []byte("This is a string"))
+	cases := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{5, 3, ""},
+		{-1, -5, ""},
+		{0, -100, "T"},
+	}
+	for _, tc := range cases {
+		if v, _ := s.GetRange("k", tc.start, tc.end); string(v) != tc.want {
+			t.Fatalf("GETRANGE %d %d: expected %q, got %q", tc.start, tc.end, tc.want, v)
+		}
+	}
+}
+
+func TestGetDelAndGetEx(t *testing.T) {
+	s := New()
+	s.Set("k", []byte("v"))
+	if v, ok, _ := s.GetDel("k"); !ok || string(v) != "v" || s.Exists("k") {
+		t.Fatalf("GETDEL should return and delete the value")
+	}
+
+	s.Set("k", []byte("v"))
+	at := time.Now().Add(time.Hour)
+	if _, ok, _ := s.GetEx("k", GetExOptions{ExpireAt: &at}); !ok || s.TTL("k") < 3599 {
+		t.Fatalf("GETEX should set the expiry, ttl=%d", s.TTL("k"))
+	}
+	if _, _, _ = s.GetEx("k", GetExOptions{Persist: true}); s.TTL("k") != -1 {
+		t.Fatalf("GETEX PERSIST should clear the expiry")
+	}
+	past := time.Now().Add(-time.Second)
+	if v, ok, _ := s.GetEx("k", GetExOptions{ExpireAt: &past}); !ok || string(v) != "v" || s.Exists("k") {
+		t.Fatalf("GETEX with a past time should return the value and delete the key")
+	}
+}
+
+func TestMSetNX(t *testing.T) {
+	s := New()
+	if !s.MSetNX([]KeyValue{{"a", []byte("1")}, {"b", []byte("2")}}) {
+		t.Fatalf("expected MSETNX to set new keys")
+	}
+	if s.MSetNX([]KeyValue{{"b", []byte("x")}, {"c", []byte("3")}}) {
+		t.Fatalf("expected MSETNX to fail when a key exists")
+	}
+	if s.Exists("c") {
+		t.Fatalf("MSETNX must not set any key when one exists")
+	}
+}
+
+func TestLCS(t *testing.T) {
+	s := New()
+	s.Set("a", []byte("ohmytext"))
+	s.Set("b", []byte("mynewtext"))
+	seq, matches, err := s.LCS("a", "b")
+	if err != nil || string(seq) != "mytext" {
+		t.Fatalf("expected mytext, got %q (%v)", seq, err)
+	}
+	want := []LCSMatch{
+		{A: [2]int{4, 7}, B: [2]int{5, 8}, Len: 4},
+		{A: [2]int{2, 3}, B: [2]int{0, 1}, Len: 2},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Fatalf("unexpected matches %+v", matches)
+	}
+	if seq, _, _ := s.LCS("a", "missing"); len(seq) != 0 {
+		t.Fatalf("expected empty LCS with a missing key, got %q", seq)
+	}
+}
+
+func TestMGetMSet(t *testing.T) {
+	s := New()
+	_, _ = s.HSet("h", []FieldValue{{"f", []byte("1")}})
+	s.Set("a", []byte("old"))
+	s.Expire("a", 100)
+
+	s.MSet([]KeyValue{{"a", []byte("1")}, {"b", []byte("")}, {"a", []byte("2")}})
+	got := s.MGet([]string{"a", "b", "missing", "h"})
+	if string(got[0]) != "2" || got[1] == nil || len(got[1]) != 0 || got[2] != nil || got[3] != nil {
+		t.Fatalf("unexpected MGET result %q", got)
+	}
+	if ttl := s.TTL("a"); ttl != -1 {
+		t.Fatalf("expected MSET to clear the ttl, got %d", ttl)
+	}
+
+	s.MSet([]KeyValue{{"h", []byte("str")}})
+	if v, _ := s.Get("h"); string(v) != "str" {
+		t.Fatalf("expected MSET to overwrite other types, got %q", v)
+	}
+}
@@ -0,0 +1,592 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// ScoreMember is a sorted set member and its score.
+type ScoreMember struct {
+	Member string
+	Score  float64
+}
+
+// zset is a sorted set: the dict answers score lookups in O(1) and the
+// skiplist keeps members ordered for ranges and ranks.
+type zset struct {
+	dict  *dict[float64]
+	zsl   *zskiplist
+	bytes int64 // memory estimate of the members, see zsetMemberSize
+}
+
+func newZset() *zset {
+	return &zset{dict: newDict[float64](), zsl: newZskiplist()}
+}
+
+func (z *zset) len() int { return z.dict.len() }
+
+// set inserts member or moves it to its new score.
+func (z *zset) set(member string, score float64) {
+	if old, ok := z.dict.m[member]; ok {
+		if old == score {
+			return
+		}
+		z.zsl.delete(old, member)
+	} else {
+		z.bytes += zsetMemberSize(member)
+	}
+	z.zsl.insert(score, member)
+	z.dict.put(member, score)
+}
+
+func (z *zset) remove(member string) bool {
+	score, ok := z.dict.m[member]
+	if !ok {
+		return false
+	}
+	z.zsl.delete(score, member)
+	z.dict.del(member)
+	z.bytes -= zsetMemberSize(member)
+	return true
+}
+
+// all returns every member in ascending order.
+func (z *zset) all() []ScoreMember {
+	out := make([]ScoreMember, 0, z.len())
+	for x := z.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		out = append(out, ScoreMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
+
+// ScoreRange is a score interval; either end may be exclusive.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(v float64) bool {
+	if r.MinExclusive {
+		return v > r.Min
+	}
+	return v >= r.Min
+}
+
+func (r ScoreRange) belowMax(v float64) bool {
+	if r.MaxExclusive {
+		return v < r.Max
+	}
+	return v <= r.Max
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+// LexBound is one end of a lexicographical range: "[v", "(v", "-" or "+".
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int // -1 for "-", +1 for "+", 0 for a finite bound
+}
+
+// below reports whether m satisfies b as a lower bound.
+func (b LexBound) below(m string) bool {
+	switch {
+	case b.Inf < 0:
+		return true
+	case b.Inf > 0:
+		return false
+	case b.Exclusive:
+		return m > b.Value
+	default:
+		return m >= b.Value
+	}
+}
+
+// above reports whether m satisfies b as an upper bound.
+func (b LexBound) above(m string) bool {
+	switch {
+	case b.Inf > 0:
+		return true
+	case b.Inf < 0:
+		return false
+	case b.Exclusive:
+		return m < b.Value
+	default:
+		return m <= b.Value
+	}
+}
+
+// LexRange is a lexicographical member interval.
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.Inf > 0 || r.Max.Inf < 0 {
+		return true
+	}
+	if r.Min.Inf < 0 || r.Max.Inf > 0 {
+		return false
+	}
+	return r.Min.Value > r.Max.Value ||
+		(r.Min.Value == r.Max.Value && (r.Min.Exclusive || r.Max.Exclusive))
+}
+
+// ZRangeBy selects how a ZRangeSpec is interpreted.
+type ZRangeBy uint8
+
+const (
+	ZByRank ZRangeBy = iota
+	ZByScore
+	ZByLex
+)
+
+// ZRangeSpec describes a ZRANGE query.
+type ZRangeSpec struct {
+	By          ZRangeBy
+	Start, Stop int        // ZByRank: inclusive, negative counts from the end
+	Score       ScoreRange // ZByScore
+	Lex         LexRange   // ZByLex
+	Rev         bool       // iterate from the highest member down
+	Offset      int        // LIMIT offset (ZByScore / ZByLex)
+	Count       int        // LIMIT count; negative means no limit
+}
+
+func (z *zset) rangeSpec(spec ZRangeSpec) []ScoreMember {
+	out := []ScoreMember{}
+
+	if spec.By == ZByRank {
+		start, stop, ok := normalizeRange(spec.Start, spec.Stop, z.len())
+		if !ok {
+			return out
+		}
+		var x *zslNode
+		if spec.Rev {
+			x = z.zsl.byRank(z.len() - start)
+		} else {
+			x = z.zsl.byRank(start + 1)
+		}
+		for n := stop - start + 1; x != nil && n > 0; n-- {
+			out = append(out, ScoreMember{Member: x.member, Score: x.score})
+			x = step(x, spec.Rev)
+		}
+		return out
+	}
+
+	if spec.Offset < 0 {
+		return out
+	}
+
+	var x *zslNode
+	var inRange func(*zslNode) bool
+	switch {
+	case spec.By == ZByScore && spec.Rev:
+		x = z.zsl.lastInScoreRange(spec.Score)
+		inRange = func(n *zslNode) bool { return spec.Score.aboveMin(n.score) }
+	case spec.By == ZByScore:
+		x = z.zsl.firstInScoreRange(spec.Score)
+		inRange = func(n *zslNode) bool { return spec.Score.belowMax(n.score) }
+	case spec.Rev:
+		x = z.zsl.lastInLexRange(spec.Lex)
+		inRange = func(n *zslNode) bool { return spec.Lex.Min.below(n.member) }
+	default:
+		x = z.zsl.firstInLexRange(spec.Lex)
+		inRange = func(n *zslNode) bool { return spec.Lex.Max.above(n.member) }
+	}
+
+	for i := 0; x != nil && i < spec.Offset; i++ {
+		x = step(x, spec.Rev)
+	}
+	for ; x != nil && inRange(x); x = step(x, spec.Rev) {
+		if spec.Count >= 0 && len(out) >= spec.Count {
+			break
+		}
+		out = append(out, ScoreMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
+
+func step(x *zslNode, rev bool) *zslNode {
+	if rev {
+		return x.backward
+	}
+	return x.level[0].forward
+}
+
+// zsetLocked returns the sorted set at key. If create is true, a missing key
+// is created as an empty sorted set (the caller must make sure it doesn't
+// stay empty). Caller must hold the lock of key's shard.
+func (s *Store) zsetLocked(key string, create bool) (*zset, bool, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		if !create {
+			return nil, false, nil
+		}
+		z := newZset()
+		e := newEntry(KindZSet)
+		e.zset = z
+		s.putLocked(key, e)
+		return z, true, nil
+	}
+	if e.kind != KindZSet {
+		return nil, false, ErrWrongType
+	}
+	return e.zset, true, nil
+}
+
+func (s *Store) dropIfEmptyZsetLocked(key string, z *zset) {
+	if z.len() == 0 {
+		s.removeLocked(key)
+	}
+}
+
+// ZAddOptions are the ZADD conditions. NX and XX restrict updates to new or
+// existing members; GT and LT only let an existing member's score grow or
+// shrink.
+type ZAddOptions struct {
+	NX, XX, GT, LT bool
+}
+
+// allows reports whether a member currently at cur (exists says whether it
+// is present) may be set to score.
+func (o ZAddOptions) allows(exists bool, cur, score float64) bool {
+	if exists {
+		return !o.NX && (!o.GT || score > cur) && (!o.LT || score < cur)
+	}
+	return !o.XX
+}
+
+// ZAdd sets the given members' scores and returns how many members were
+// added and how many existing members changed score.
+func (s *Store) ZAdd(key string, opts ZAddOptions, items []ScoreMember) (added, updated int, err error) {
+	defer s.lock(key)()
+
+	z, ok, err := s.zsetLocked(key, !opts.XX)
+	if err != nil || !ok {
+		return 0, 0, err
+	}
+	before := z.bytes
+	for _, it := range items {
+		cur, exists := z.dict.m[it.Member]
+		if !opts.allows(exists, cur, it.Score) {
+			continue
+		}
+		switch {
+		case !exists:
+			added++
+		case cur != it.Score:
+			updated++
+		}
+		z.set(it.Member, it.Score)
+	}
+	s.growLocked(key, z.bytes-before)
+	s.dropIfEmptyZsetLocked(key, z)
+	return added, updated, nil
+}
+
+// ZIncr adds delta to member's score (a missing member counts as 0) subject
+// to opts. It returns the new score, or false if opts prevented the update.
+func (s *Store) ZIncr(key string, opts ZAddOptions, delta float64, member string) (float64, bool, error) {
+	defer s.lock(key)()
+
+	z, ok, err := s.zsetLocked(key, !opts.XX)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	defer s.dropIfEmptyZsetLocked(key, z)
+
+	cur, exists := z.dict.m[member]
+	score := cur + delta
+	if math.IsNaN(score) {
+		return 0, false, ErrScoreNaN
+	}
+	if !opts.allows(exists, cur, score) {
+		return 0, false, nil
+	}
+	before := z.bytes
+	z.set(member, score)
+	s.growLocked(key, z.bytes-before)
+	return score, true, nil
+}
+
+// ZRem removes members and returns how many were present.
+func (s *Store) ZRem(key string, members []string) (int, error) {
+	defer s.lock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	before := z.bytes
+	removed := 0
+	for _, m := range members {
+		if z.remove(m) {
+			removed++
+		}
+	}
+	s.growLocked(key, z.bytes-before)
+	s.dropIfEmptyZsetLocked(key, z)
+	return removed, nil
+}
+
+// ZScore returns the score of member.
+func (s *Store) ZScore(key, member string) (float64, bool, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	score, ok := z.dict.m[member]
+	return score, ok, nil
+}
+
+// ZCard returns the number of members in the sorted set at key.
+func (s *Store) ZCard(key string) (int, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return z.len(), nil
+}
+
+// ZCount returns the number of members with a score within r.
+func (s *Store) ZCount(key string, r ScoreRange) (int, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return 0, err
+	}
+	first := z.zsl.firstInScoreRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.zsl.lastInScoreRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1, nil
+}
+
+// ZRange returns the members selected by spec, in iteration order.
+func (s *Store) ZRange(key string, spec ZRangeSpec) ([]ScoreMember, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return []ScoreMember{}, err
+	}
+	return z.rangeSpec(spec), nil
+}
+
+// ZRangeStore stores the members of src selected by spec at dst, replacing
+// whatever was there, and returns how many were stored.
+func (s *Store) ZRangeStore(dst, src string, spec ZRangeSpec) (int, error) {
+	defer s.lockKeys(src, dst)()
+
+	z, ok, err := s.zsetLocked(src, false)
+	if err != nil {
+		return 0, err
+	}
+	var items []ScoreMember
+	if ok {
+		items = z.rangeSpec(spec)
+	}
+	return s.storeZsetLocked(dst, items), nil
+}
+
+// ZRank returns the 0-based rank of member (counted from the highest score
+// when rev is set) together with its score.
+func (s *Store) ZRank(key, member string, rev bool) (int, float64, bool, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+	score, ok := z.dict.m[member]
+	if !ok {
+		return 0, 0, false, nil
+	}
+	rank := z.zsl.rank(score, member) - 1
+	if rev {
+		rank = z.len() - 1 - rank
+	}
+	return rank, score, true, nil
+}
+
+// ZPop removes and returns up to count members with the lowest scores, or
+// the highest if max is set. It returns nil when the key does not exist.
+func (s *Store) ZPop(key string, count int, max bool) ([]ScoreMember, error) {
+	defer s.lock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	before := z.bytes
+	out := make([]ScoreMember, 0, min(count, z.len()))
+	for len(out) < count && z.len() > 0 {
+		x := z.zsl.header.level[0].forward
+		if max {
+			x = z.zsl.tail
+		}
+		out = append(out, ScoreMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+	s.growLocked(key, z.bytes-before)
+	s.dropIfEmptyZsetLocked(key, z)
+	return out, nil
+}
+
+// ZAggregate selects how ZUNIONSTORE / ZINTERSTORE combine scores.
+type ZAggregate uint8
+
+const (
+	ZAggSum ZAggregate = iota
+	ZAggMin
+	ZAggMax
+)
+
+func (a ZAggregate) combine(x, y float64) float64 {
+	switch a {
+	case ZAggMin:
+		return math.Min(x, y)
+	case ZAggMax:
+		return math.Max(x, y)
+	default:
+		// inf + -inf is NaN; Redis turns it into 0.
+		if sum := x + y; !math.IsNaN(sum) {
+			return sum
+		}
+		return 0
+	}
+}
+
+// ZUnionStore stores the union of the sorted sets (or plain sets, whose
+// members score 1) at keys into dst and returns its cardinality. weights
+// multiplies each input's scores; nil means all 1.
+func (s *Store) ZUnionStore(dst string, keys []string, weights []float64, agg ZAggregate) (int, error) {
+	return s.zcombineStore(dst, keys, weights, agg, false)
+}
+
+// ZInterStore is ZUnionStore for the intersection.
+func (s *Store) ZInterStore(dst string, keys []string, weights []float64, agg ZAggregate) (int, error) {
+	return s.zcombineStore(dst, keys, weights, agg, true)
+}
+
+func (s *Store) zcombineStore(dst string, keys []string, weights []float64, agg ZAggregate, inter bool) (int, error) {
+	defer s.lockStore(dst, keys)()
+
+	inputs := make([]map[string]float64, len(keys))
+	for i, k := range keys {
+		m, err := s.zinputLocked(k)
+		if err != nil {
+			return 0, err
+		}
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		weighted := make(map[string]float64, len(m))
+		for member, score := range m {
+			v := score * w
+			if math.IsNaN(v) { // inf * 0
+				v = 0
+			}
+			weighted[member] = v
+		}
+		inputs[i] = weighted
+	}
+
+	res := make(map[string]float64)
+	if inter {
+		for member, score := range inputs[0] {
+			acc, ok := score, true
+			for _, in := range inputs[1:] {
+				v, found := in[member]
+				if !found {
+					ok = false
+					break
+				}
+				acc = agg.combine(acc, v)
+			}
+			if ok {
+				res[member] = acc
+			}
+		}
+	} else {
+		for _, in := range inputs {
+			for member, score := range in {
+				if acc, ok := res[member]; ok {
+					res[member] = agg.combine(acc, score)
+				} else {
+					res[member] = score
+				}
+			}
+		}
+	}
+
+	items := make([]ScoreMember, 0, len(res))
+	for member, score := range res {
+		items = append(items, ScoreMember{Member: member, Score: score})
+	}
+	return s.storeZsetLocked(dst, items), nil
+}
+
+// zinputLocked returns the member scores of a sorted set or plain set
+// (members score 1) at key; a missing key is an empty input.
+func (s *Store) zinputLocked(key string) (map[string]float64, error) {
+	e, ok := s.lookupLocked(key, time.Now())
+	if !ok {
+		return nil, nil
+	}
+	switch e.kind {
+	case KindZSet:
+		return e.zset.dict.m, nil
+	case KindSet:
+		m := make(map[string]float64, e.set.len())
+		for member := range e.set.m {
+			m[member] = 1
+		}
+		return m, nil
+	default:
+		return nil, ErrWrongType
+	}
+}
+
+// storeZsetLocked replaces dst with a sorted set holding items (deleting dst
+// if there are none) and returns the stored cardinality.
+func (s *Store) storeZsetLocked(dst string, items []ScoreMember) int {
+	if len(items) == 0 {
+		s.removeLocked(dst)
+		return 0
+	}
+	z := newZset()
+	for _, it := range items {
+		z.set(it.Member, it.Score)
+	}
+	e := newEntry(KindZSet)
+	e.zset = z
+	e.size = z.bytes
+	s.putLocked(dst, e)
+	return z.len()
+}
+
+// ZScan iterates the sorted set at key, about count members per call. See
+// scanPos for the cursor guarantees.
+func (s *Store) ZScan(key string, cursor uint64, count int) ([]ScoreMember, uint64, error) {
+	defer s.rlock(key)()
+
+	z, ok, err := s.zsetLocked(key, false)
+	if err != nil || !ok {
+		return nil, 0, err
+	}
+	var out []ScoreMember
+	next := z.dict.scan(cursor, count, func(m string, score float64) {
+		out = append(out, ScoreMember{Member: m, Score: score})
+	})
+	return out, next, nil
+}
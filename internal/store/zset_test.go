@@ -0,0 +1,191 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func members(items []ScoreMember) string {
+	parts := make([]string, len(items))
+	for i, it := range items {
+		parts[i] = it.Member
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestZskiplist_MatchesSortedSlice(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	zsl := newZskiplist()
+	ref := make(map[string]float64)
+
+	for i := 0; i < 2000; i++ {
+		m := "m" + strconv.Itoa(r.IntN(300))
+		if old, ok := ref[m]; ok && r.IntN(2) == 0 {
+			if !zsl.delete(old, m) {
+				t.Fatalf("delete %s failed", m)
+			}
+			delete(ref, m)
+			continue
+		}
+		if old, ok := ref[m]; ok {
+			zsl.delete(old, m)
+		}
+		score := float64(r.IntN(50)) // plenty of ties
+		zsl.insert(score, m)
+		ref[m] = score
+	}
+
+	sorted := make([]ScoreMember, 0, len(ref))
+	for m, sc := range ref {
+		sorted = append(sorted, ScoreMember{Member: m, Score: sc})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return zslLess(sorted[i].Score, sorted[i].Member, sorted[j].Score, sorted[j].Member)
+	})
+
+	if zsl.length != len(sorted) {
+		t.Fatalf("length %d, want %d", zsl.length, len(sorted))
+	}
+	for i, it := range sorted {
+		if got := zsl.rank(it.Score, it.Member); got != i+1 {
+			t.Fatalf("rank(%s) = %d, want %d", it.Member, got, i+1)
+		}
+		if n := zsl.byRank(i + 1); n == nil || n.member != it.Member {
+			t.Fatalf("byRank(%d) mismatch", i+1)
+		}
+	}
+	// Walk backwards from the tail.
+	i := len(sorted) - 1
+	for x := zsl.tail; x != nil; x = x.backward {
+		if x.member != sorted[i].Member {
+			t.Fatalf("backward walk mismatch at %d", i)
+		}
+		i--
+	}
+	if i != -1 {
+		t.Fatalf("backward walk stopped early at %d", i)
+	}
+}
+
+func TestZAdd_Options(t *testing.T) {
+	s := New()
+	add := func(opts ZAddOptions, score float64, m string) (int, int) {
+		t.Helper()
+		a, u, err := s.ZAdd("z", opts, []ScoreMember{{m, score}})
+		if err != nil {
+			t.Fatalf("zadd: %v", err)
+		}
+		return a, u
+	}
+
+	if a, _ := add(ZAddOptions{XX: true}, 1, "a"); a != 0 || s.Exists("z") {
+		t.Fatal("XX must not create the key")
+	}
+	if a, _ := add(ZAddOptions{}, 5, "a"); a != 1 {
+		t.Fatal("expected a to be added")
+	}
+	if _, u := add(ZAddOptions{NX: true}, 9, "a"); u != 0 {
+		t.Fatal("NX must not update")
+	}
+	if _, u := add(ZAddOptions{GT: true}, 3, "a"); u != 0 {
+		t.Fatal("GT must not lower the score")
+	}
+	if _, u := add(ZAddOptions{GT: true}, 7, "a"); u != 1 {
+		t.Fatal("GT should raise the score")
+	}
+	if _, u := add(ZAddOptions{LT: true}, 8, "a"); u != 0 {
+		t.Fatal("LT must not raise the score")
+	}
+	if a, _ := add(ZAddOptions{GT: true}, 1, "b"); a != 1 {
+		t.Fatal("GT still adds new members")
+	}
+	if sc, _, _ := s.ZScore("z", "a"); sc != 7 {
+		t.Fatalf("expected a=7, got %v", sc)
+	}
+
+	if sc, ok, _ := s.ZIncr("z", ZAddOptions{}, 0.5, "a"); !ok || sc != 7.5 {
+		t.Fatalf("expected 7.5, got %v ok=%v", sc, ok)
+	}
+	if _, ok, _ := s.ZIncr("z", ZAddOptions{LT: true}, 1, "a"); ok {
+		t.Fatal("LT must block a positive increment")
+	}
+	_, _, _ = s.ZAdd("z", ZAddOptions{}, []ScoreMember{{"inf", math.Inf(1)}})
+	if _, _, err := s.ZIncr("z", ZAddOptions{}, math.Inf(-1), "inf"); !errors.Is(err, ErrScoreNaN) {
+		t.Fatalf("expected ErrScoreNaN, got %v", err)
+	}
+}
+
+func TestZRange_RankScoreLex(t *testing.T) {
+	s := New()
+	_, _, _ = s.ZAdd("z", ZAddOptions{}, []ScoreMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}, {"e", 5}})
+
+	check := func(spec ZRangeSpec, want string) {
+		t.Helper()
+		got, err := s.ZRange("z", spec)
+		if err != nil {
+			t.Fatalf("zrange: %v", err)
+		}
+		if members(got) != want {
+			t.Fatalf("spec %+v: got %q, want %q", spec, members(got), want)
+		}
+	}
+
+	check(ZRangeSpec{Start: 1, Stop: -2, Count: -1}, "b,c,d")
+	check(ZRangeSpec{Start: 0, Stop: 1, Rev: true, Count: -1}, "e,d")
+	check(ZRangeSpec{By: ZByScore, Score: ScoreRange{Min: 2, Max: 4, MinExclusive: true}, Count: -1}, "c,d")
+	check(ZRangeSpec{By: ZByScore, Score: ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, Rev: true, Offset: 1, Count: 2}, "d,c")
+	check(ZRangeSpec{By: ZByScore, Score: ScoreRange{Min: 3, Max: 3, MaxExclusive: true}, Count: -1}, "")
+	check(ZRangeSpec{By: ZByLex, Lex: LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Value: "d", Exclusive: true}}, Count: -1}, "b,c")
+	check(ZRangeSpec{By: ZByLex, Lex: LexRange{Min: LexBound{Inf: -1}, Max: LexBound{Inf: 1}}, Rev: true, Count: 2}, "e,d")
+
+	if n, _ := s.ZCount("z", ScoreRange{Min: 2, Max: 5, MaxExclusive: true}); n != 3 {
+		t.Fatalf("expected ZCount 3, got %d", n)
+	}
+	if r, _, ok, _ := s.ZRank("z", "b", true); !ok || r != 3 {
+		t.Fatalf("expected rev rank 3, got %d ok=%v", r, ok)
+	}
+
+	n, _ := s.ZRangeStore("dst", "z", ZRangeSpec{By: ZByScore, Score: ScoreRange{Min: 4, Max: math.Inf(1)}, Count: -1})
+	if n != 2 {
+		t.Fatalf("expected 2 stored, got %d", n)
+	}
+	popped, _ := s.ZPop("dst", 5, true)
+	if members(popped) != "e,d" || s.Exists("dst") {
+		t.Fatalf("unexpected pop %q", members(popped))
+	}
+}
+
+func TestZUnionInterStore_WeightsAndAggregate(t *testing.T) {
+	s := New()
+	_, _, _ = s.ZAdd("a", ZAddOptions{}, []ScoreMember{{"x", 1}, {"y", 2}})
+	_, _, _ = s.ZAdd("b", ZAddOptions{}, []ScoreMember{{"y", 10}, {"z", 20}})
+	_, _ = s.SAdd("plain", []string{"y", "w"})
+
+	if n, _ := s.ZUnionStore("u", []string{"a", "b"}, []float64{2, 1}, ZAggSum); n != 3 {
+		t.Fatalf("expected 3, got %d", n)
+	}
+	if sc, _, _ := s.ZScore("u", "y"); sc != 14 {
+		t.Fatalf("expected y=14, got %v", sc)
+	}
+
+	if n, _ := s.ZInterStore("i", []string{"a", "b", "plain"}, nil, ZAggMax); n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+	if sc, _, _ := s.ZScore("i", "y"); sc != 10 {
+		t.Fatalf("expected y=10, got %v", sc)
+	}
+
+	if n, _ := s.ZInterStore("i", []string{"a", "missing"}, nil, ZAggSum); n != 0 || s.Exists("i") {
+		t.Fatal("expected empty intersection to delete the destination")
+	}
+
+	s.Set("str", []byte("v"))
+	if _, err := s.ZUnionStore("u", []string{"a", "str"}, nil, ZAggSum); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+}